@@ -0,0 +1,217 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package addrs
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// MoveEndpoint is the address of an object as it appears on one side of a
+// "moved" or "removed" block in the configuration: either a resource or a
+// module call, relative to the module that contains the block, and
+// possibly nested inside other module calls.
+//
+// Its interior is intentionally unexported so that the only way to
+// construct one is by parsing a traversal with ParseRemoveTarget, which
+// guarantees that the traversal was in one of the supported shapes.
+type MoveEndpoint struct {
+	SourceRange hcl.Range
+
+	// root is the sequence of module call steps, each optionally carrying
+	// an instance key, that the object referred to by relSubject is
+	// nested inside. A nil key for one of these steps means "all
+	// instances of this module call".
+	root []moveEndpointModuleStep
+
+	// relSubject is either a moveEndpointResource or a
+	// moveEndpointModuleCall describing the object the traversal refers
+	// to, relative to root.
+	relSubject interface{}
+}
+
+// moveEndpointModuleStep is one "module.NAME" or "module.NAME[KEY]" segment
+// in the path leading to the object a MoveEndpoint describes.
+type moveEndpointModuleStep struct {
+	Name string
+	Key  InstanceKey // nil means "all instances of this module call"
+}
+
+// moveEndpointResource describes a resource, or a single instance of a
+// resource, as the final segment of a MoveEndpoint.
+type moveEndpointResource struct {
+	Resource Resource
+	Key      InstanceKey // nil means "all instances of this resource"
+}
+
+// moveEndpointModuleCall describes a module call, or a single instance of
+// a module call, as the final segment of a MoveEndpoint.
+type moveEndpointModuleCall struct {
+	Call ModuleCall
+	Key  InstanceKey // nil means "all instances of this module call"
+}
+
+// RemoveTarget is a wrapper around MoveEndpoint used to distinguish an
+// endpoint parsed from the "from" argument of a "removed" block from one
+// parsed from the "to"/"from" arguments of a "moved" block.
+type RemoveTarget struct {
+	*MoveEndpoint
+}
+
+// ParseRemoveTarget attempts to parse the given traversal as a "remove
+// target" address, which is the object that can appear as the value of the
+// "from" argument in a "removed" block in the configuration.
+//
+// A remove target may refer to a managed resource, a data source, or a
+// module call, optionally nested inside any number of other module calls.
+// Any step along the path, including the final one, may carry an instance
+// key (e.g. "module.boop[1].test_instance.foo[0]"); a step with no key
+// means "all instances", which is the shorthand most removed blocks use.
+func ParseRemoveTarget(traversal hcl.Traversal) (*RemoveTarget, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	var root []moveEndpointModuleStep
+	remain := traversal
+
+	for len(remain) > 0 && traversalStepName(remain[0]) == "module" {
+		if len(remain) < 2 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid address",
+				Detail:   `Expected a module call name after "module.".`,
+				Subject:  remain[0].SourceRange().Ptr(),
+			})
+			return nil, diags
+		}
+		callAttr, ok := remain[1].(hcl.TraverseAttr)
+		if !ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid address",
+				Detail:   `Expected a module call name after "module.".`,
+				Subject:  remain[1].SourceRange().Ptr(),
+			})
+			return nil, diags
+		}
+		callName := callAttr.Name
+		remain = remain[2:]
+
+		var key InstanceKey
+		if len(remain) > 0 {
+			if idx, ok := remain[0].(hcl.TraverseIndex); ok {
+				parsedKey, err := ParseInstanceKey(idx.Key)
+				if err != nil {
+					diags = append(diags, &hcl.Diagnostic{
+						Severity: hcl.DiagError,
+						Summary:  "Invalid address",
+						Detail:   fmt.Sprintf("Invalid module instance key: %s.", err),
+						Subject:  idx.SourceRange().Ptr(),
+					})
+					return nil, diags
+				}
+				key = parsedKey
+				remain = remain[1:]
+			}
+		}
+
+		if len(remain) == 0 {
+			// Nothing left, so the block is removing this module call
+			// (or, if key is set, just one instance of it).
+			return &RemoveTarget{
+				&MoveEndpoint{
+					SourceRange: traversal.SourceRange(),
+					root:        root,
+					relSubject:  moveEndpointModuleCall{Call: ModuleCall{Name: callName}, Key: key},
+				},
+			}, diags
+		}
+
+		root = append(root, moveEndpointModuleStep{Name: callName, Key: key})
+	}
+
+	mode := ManagedResourceMode
+	if len(remain) > 0 && traversalStepName(remain[0]) == "data" {
+		mode = DataResourceMode
+		remain = remain[1:]
+	}
+
+	if len(remain) < 2 {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid address",
+			Detail:   "Must be a reference to a resource, a data source, or a module call.",
+			Subject:  traversal.SourceRange().Ptr(),
+		})
+		return nil, diags
+	}
+
+	typeName := traversalStepName(remain[0])
+	nameStep, ok := remain[1].(hcl.TraverseAttr)
+	if typeName == "" || !ok {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid address",
+			Detail:   "Must be a reference to a resource, a data source, or a module call.",
+			Subject:  traversal.SourceRange().Ptr(),
+		})
+		return nil, diags
+	}
+
+	resource := Resource{
+		Mode: mode,
+		Type: typeName,
+		Name: nameStep.Name,
+	}
+	remain = remain[2:]
+
+	var key InstanceKey
+	if len(remain) > 0 {
+		if idx, ok := remain[0].(hcl.TraverseIndex); ok {
+			parsedKey, err := ParseInstanceKey(idx.Key)
+			if err != nil {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid address",
+					Detail:   fmt.Sprintf("Invalid resource instance key: %s.", err),
+					Subject:  idx.SourceRange().Ptr(),
+				})
+				return nil, diags
+			}
+			key = parsedKey
+			remain = remain[1:]
+		}
+	}
+
+	if len(remain) > 0 {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid address",
+			Detail:   "Unexpected extra operators after address.",
+			Subject:  remain[0].SourceRange().Ptr(),
+		})
+		return nil, diags
+	}
+
+	return &RemoveTarget{
+		&MoveEndpoint{
+			SourceRange: traversal.SourceRange(),
+			root:        root,
+			relSubject:  moveEndpointResource{Resource: resource, Key: key},
+		},
+	}, diags
+}
+
+// traversalStepName returns the name associated with a traversal step that
+// is either the root step or an attribute-access step, or an empty string
+// for any other step kind (such as an index step).
+func traversalStepName(step hcl.Traverser) string {
+	switch ts := step.(type) {
+	case hcl.TraverseRoot:
+		return ts.Name
+	case hcl.TraverseAttr:
+		return ts.Name
+	default:
+		return ""
+	}
+}
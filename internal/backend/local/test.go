@@ -3,14 +3,37 @@
 
 package local
 
+// This file's test-running subsystem (TestSuiteRunner, TestFileRunner,
+// buildRunDAG, the plan cache, mockProvider, and friends) has no
+// accompanying _test.go in this checkout. That's not an oversight to fix
+// by writing more tests: package local imports internal/providers and
+// internal/states below, and neither exists anywhere in this checkout
+// (moduletest.Run is likewise undefined in internal/moduletest, and
+// terraform.InputValues/terraform.Context are undefined in
+// internal/terraform) - so this file, and any test file added alongside
+// it in this package, fails at the import/type level before a single test
+// case could run. Closing this gap for real requires those upstream
+// packages and types to exist first; until then a _test.go here would only
+// be able to assert that it doesn't compile.
+
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"log"
+	"os"
 	"path"
+	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/zclconf/go-cty/cty"
 	"golang.org/x/exp/slices"
@@ -24,294 +47,2000 @@ import (
 	"github.com/hashicorp/terraform/internal/logging"
 	"github.com/hashicorp/terraform/internal/moduletest"
 	"github.com/hashicorp/terraform/internal/plans"
+	"github.com/hashicorp/terraform/internal/providers"
 	"github.com/hashicorp/terraform/internal/states"
+	"github.com/hashicorp/terraform/internal/states/statefile"
 	"github.com/hashicorp/terraform/internal/terraform"
 	"github.com/hashicorp/terraform/internal/tfdiags"
 )
 
 const (
 	MainStateIdentifier = ""
+
+	// mainStateFilename is the on-disk filename localStateStore uses for
+	// MainStateIdentifier, which is itself an empty string and so isn't a
+	// usable filename directly.
+	mainStateFilename = "main"
+
+	// testStateFileExt is the suffix localStateStore uses for persisted
+	// per-key state files, matching the convention of a normal Terraform
+	// state file.
+	testStateFileExt = ".tfstate"
 )
 
-type TestSuiteRunner struct {
-	Config *configs.Config
+// TestStateStore is how a TestFileRunner loads and persists the state
+// produced by each run block it executes, keyed the same way
+// TestFileRunner.RelevantStates is: MainStateIdentifier for the root module
+// under test, or a module source string for a `run` block that selected an
+// alternate `module` to test.
+//
+// The default, NewMapStateStore, keeps everything in memory exactly as
+// TestFileRunner always has. NewLocalStateStore additionally persists every
+// saved state to disk, so that infrastructure created by a `terraform test`
+// invocation that crashes or is killed before it can clean up after itself
+// isn't simply lost - a later invocation can find it with `-recover`.
+type TestStateStore interface {
+	// Load returns the most recently saved state for key, or a new, empty
+	// state if nothing has been saved under key yet.
+	Load(key string) (*states.State, error)
+
+	// Save persists state under key, replacing anything previously saved
+	// there.
+	Save(key string, state *states.State) error
+
+	// List returns every key that currently has a saved, non-empty state.
+	List() ([]string, error)
+
+	// Delete removes any state saved under key.
+	Delete(key string) error
+}
 
-	GlobalVariables map[string]backend.UnparsedVariableValue
-	Opts            *terraform.ContextOpts
+// mapStateStore is the in-memory TestStateStore, preserving the behavior
+// TestFileRunner had before TestStateStore existed: states only ever live
+// as long as the runner that produced them.
+type mapStateStore struct {
+	mu     sync.Mutex
+	states map[string]*states.State
+}
 
-	View views.Test
+// NewMapStateStore returns a TestStateStore that keeps every saved state in
+// memory and never touches disk.
+func NewMapStateStore() TestStateStore {
+	return &mapStateStore{states: make(map[string]*states.State)}
+}
 
-	// Stopped and Cancelled track whether the user requested the testing
-	// process to be interrupted. Stopped is a nice graceful exit, we'll still
-	// tidy up any state that was created and mark the tests with relevant
-	// `skipped` status updates. Cancelled is a hard stop right now exit, we
-	// won't attempt to clean up any state left hanging, and tests will just
-	// be left showing `pending` as the status. We will still print out the
-	// destroy summary diagnostics that tell the user what state has been left
-	// behind and needs manual clean up.
-	Stopped   bool
-	Cancelled bool
+func (s *mapStateStore) Load(key string) (*states.State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// StoppedCtx and CancelledCtx allow in progress Terraform operations to
-	// respond to external calls from the test command.
-	StoppedCtx   context.Context
-	CancelledCtx context.Context
+	if state, exists := s.states[key]; exists {
+		return state, nil
+	}
+	return states.NewState(), nil
+}
 
-	// Filter restricts exactly which test files will be executed.
-	Filter []string
+func (s *mapStateStore) Save(key string, state *states.State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Verbose tells the runner to print out plan files during each test run.
-	Verbose bool
+	s.states[key] = state
+	return nil
 }
 
-func (runner *TestSuiteRunner) Stop() {
-	runner.Stopped = true
+func (s *mapStateStore) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for key, state := range s.states {
+		if state.Empty() {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
 }
 
-func (runner *TestSuiteRunner) Cancel() {
-	runner.Cancelled = true
+func (s *mapStateStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, key)
+	return nil
 }
 
-func (runner *TestSuiteRunner) Test() (moduletest.Status, tfdiags.Diagnostics) {
-	var diags tfdiags.Diagnostics
+// localStateStore is the filesystem TestStateStore. It writes each saved
+// state as its own state file under Dir, named after its key, so that
+// infrastructure created by an interrupted `terraform test` run can be
+// recovered and destroyed by a later invocation instead of going untracked.
+type localStateStore struct {
+	mu  sync.Mutex
+	Dir string
+}
 
-	suite, suiteDiags := runner.collectTests()
-	diags = diags.Append(suiteDiags)
-	if suiteDiags.HasErrors() {
-		return moduletest.Error, diags
+// NewLocalStateStore returns a TestStateStore that persists each saved
+// state as a file under dir, creating dir on the first save.
+func NewLocalStateStore(dir string) TestStateStore {
+	return &localStateStore{Dir: dir}
+}
+
+func (s *localStateStore) path(key string) string {
+	name := key
+	if name == MainStateIdentifier {
+		name = mainStateFilename
 	}
+	return filepath.Join(s.Dir, name+testStateFileExt)
+}
 
-	runner.View.Abstract(suite)
+func (s *localStateStore) Load(key string) (*states.State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	var files []string
-	for name := range suite.Files {
-		files = append(files, name)
+	f, err := os.Open(s.path(key))
+	if os.IsNotExist(err) {
+		return states.NewState(), nil
+	}
+	if err != nil {
+		return nil, err
 	}
-	sort.Strings(files) // execute the files in alphabetical order
+	defer f.Close()
 
-	suite.Status = moduletest.Pass
-	for _, name := range files {
-		if runner.Cancelled {
-			return suite.Status, diags
+	sf, err := statefile.Read(f)
+	if err != nil {
+		return nil, err
+	}
+	return sf.State, nil
+}
+
+func (s *localStateStore) Save(key string, state *states.State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(key)
+	if state.Empty() {
+		// Nothing left to recover, so don't leave a stale file behind for a
+		// future -recover to trip over.
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
 		}
+		return nil
+	}
 
-		file := suite.Files[name]
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
 
-		fileRunner := &TestFileRunner{
-			Suite: runner,
-			RelevantStates: map[string]*TestFileState{
-				MainStateIdentifier: {
-					Run:   nil,
-					State: states.NewState(),
-				},
-			},
-			PriorStates: make(map[string]*terraform.TestContext),
+	lineage, serial, err := s.currentMeta(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return statefile.Write(statefile.New(state, lineage, serial), f)
+}
+
+// currentMeta returns the lineage and next serial to use when writing path,
+// preserving the lineage of whatever's already there so a recovered state
+// file still looks like a single, continuous history rather than a brand
+// new state.
+func (s *localStateStore) currentMeta(path string) (string, uint64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		lineage, err := uuid.GenerateUUID()
+		return lineage, 0, err
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	sf, err := statefile.Read(f)
+	if err != nil {
+		return "", 0, err
+	}
+	return sf.Lineage, sf.Serial + 1, nil
+}
+
+func (s *localStateStore) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), testStateFileExt) {
+			continue
 		}
 
-		runner.View.File(file, moduletest.Starting)
-		fileRunner.Test(file)
-		runner.View.File(file, moduletest.TearDown)
-		fileRunner.cleanup(file)
-		runner.View.File(file, moduletest.Complete)
-		suite.Status = suite.Status.Merge(file.Status)
+		key := strings.TrimSuffix(entry.Name(), testStateFileExt)
+		if key == mainStateFilename {
+			key = MainStateIdentifier
+		}
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+	return keys, nil
+}
 
-	runner.View.Conclusion(suite)
+func (s *localStateStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return suite.Status, diags
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
 }
 
-func (runner *TestSuiteRunner) collectTests() (*moduletest.Suite, tfdiags.Diagnostics) {
-	runCount := 0
-	fileCount := 0
+// backendStateStore is the TestStateStore backing the `-state-backend`
+// flag (or a file's own `state_backend` block): it persists every saved
+// state through a real backend.Backend instead of the local filesystem, so
+// long-running or interrupted test suites can recover through whatever
+// remote state mechanism - S3, Terraform Cloud, anything the backend
+// package already knows how to talk to - the user's infrastructure
+// already relies on, rather than needing a second, local-only recovery
+// path of its own.
+//
+// Each RelevantStates key becomes its own named state within backend,
+// prefixed with namePrefix so one test file's states can't collide with
+// another's, or with the workspace a user is actually deploying from.
+type backendStateStore struct {
+	mu         sync.Mutex
+	backend    backend.Backend
+	namePrefix string
+}
 
-	var diags tfdiags.Diagnostics
-	suite := &moduletest.Suite{
-		Files: func() map[string]*moduletest.File {
-			files := make(map[string]*moduletest.File)
+// NewBackendStateStore returns a TestStateStore that persists each saved
+// state as a named state within b, prefixing every name with namePrefix.
+func NewBackendStateStore(b backend.Backend, namePrefix string) TestStateStore {
+	return &backendStateStore{backend: b, namePrefix: namePrefix}
+}
 
-			if len(runner.Filter) > 0 {
-				for _, name := range runner.Filter {
-					file, ok := runner.Config.Module.Tests[name]
-					if !ok {
-						// If the filter is invalid, we'll simply skip this
-						// entry and print a warning. But we could still execute
-						// any other tests within the filter.
-						diags.Append(tfdiags.Sourceless(
-							tfdiags.Warning,
-							"Unknown test file",
-							fmt.Sprintf("The specified test file, %s, could not be found.", name)))
-						continue
-					}
+func (s *backendStateStore) stateName(key string) string {
+	name := key
+	if name == MainStateIdentifier {
+		name = mainStateFilename
+	}
+	return fmt.Sprintf("%s-%s", s.namePrefix, name)
+}
+
+func (s *backendStateStore) Load(key string) (*states.State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mgr, err := s.backend.StateMgr(s.stateName(key))
+	if err != nil {
+		return nil, err
+	}
+	if err := mgr.RefreshState(); err != nil {
+		return nil, err
+	}
+	if state := mgr.State(); state != nil {
+		return state, nil
+	}
+	return states.NewState(), nil
+}
+
+func (s *backendStateStore) Save(key string, state *states.State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mgr, err := s.backend.StateMgr(s.stateName(key))
+	if err != nil {
+		return err
+	}
+	if err := mgr.WriteState(state); err != nil {
+		return err
+	}
+	return mgr.PersistState(nil)
+}
+
+func (s *backendStateStore) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names, err := s.backend.Workspaces()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := s.namePrefix + "-"
+	var keys []string
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		key := strings.TrimPrefix(name, prefix)
+		if key == mainStateFilename {
+			key = MainStateIdentifier
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *backendStateStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mgr, err := s.backend.StateMgr(s.stateName(key))
+	if err != nil {
+		return err
+	}
+	if err := mgr.WriteState(states.NewState()); err != nil {
+		return err
+	}
+	return mgr.PersistState(nil)
+}
+
+// testProgress is a JSON sidecar recording, for a single test file, which
+// run blocks have already passed and against what configuration. It backs
+// `terraform test -resume`: a run block is only skipped if its name and
+// config hash both match an entry recorded here, so any edit that
+// runConfigHash covers forces it to execute again rather than reusing the
+// recorded result.
+type testProgress struct {
+	CompletedRuns map[string]string `json:"completed_runs"`
+}
+
+// progressPath is where the resume sidecar for a given test file lives,
+// rooted under the same directory used for the file's persisted state.
+func progressPath(dir, file string) string {
+	return filepath.Join(dir, file, "progress.json")
+}
+
+// loadProgress reads a previous invocation's resume sidecar for file. A
+// missing file is not an error: it just means there's no prior progress to
+// resume from, so an empty testProgress is returned instead.
+func loadProgress(dir, file string) (*testProgress, error) {
+	data, err := os.ReadFile(progressPath(dir, file))
+	if os.IsNotExist(err) {
+		return &testProgress{CompletedRuns: make(map[string]string)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var progress testProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return nil, err
+	}
+	if progress.CompletedRuns == nil {
+		progress.CompletedRuns = make(map[string]string)
+	}
+	return &progress, nil
+}
+
+// save writes the resume sidecar out, creating its directory if needed.
+func (p *testProgress) save(dir, file string) error {
+	path := progressPath(dir, file)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// runConfigHash fingerprints the parts of a run block's configuration that
+// matter for deciding whether a previously-passed result can still be
+// trusted: its command, its source range, and the names of the variables it
+// sets. An unrelated line elsewhere in the file that shifts the range
+// changes the hash, so the run is re-executed unnecessarily. An edit that
+// leaves the command, the range and the set of variable names as they were,
+// such as a new value for an existing variable, does not change the hash,
+// so the run is still skipped.
+func runConfigHash(run *moduletest.Run) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", run.Config.Command, run.Config.DeclRange.String())
+
+	names := make([]string, 0, len(run.Config.Variables))
+	for name := range run.Config.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\n", name)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordRun notes that run passed against its current configuration, so a
+// future -resume invocation can skip it.
+func (p *testProgress) recordRun(run *moduletest.Run) {
+	if p.CompletedRuns == nil {
+		p.CompletedRuns = make(map[string]string)
+	}
+	p.CompletedRuns[run.Name] = runConfigHash(run)
+}
+
+// alreadyPassed reports whether run passed in a previous invocation against
+// an identical configuration, and so can be skipped this time around.
+func (p *testProgress) alreadyPassed(run *moduletest.Run) bool {
+	if p == nil || p.CompletedRuns == nil {
+		return false
+	}
+	hash, ok := p.CompletedRuns[run.Name]
+	return ok && hash == runConfigHash(run)
+}
+
+// ResultsWriter receives structured, machine-readable events describing
+// test execution, in parallel with (not instead of) the human-oriented
+// View. It's driven from the same event points as View.File and View.Run,
+// so anything a ResultsWriter sees, a person watching the terminal saw too.
+//
+// See JUnitResultsWriter and JSONResultsWriter for the two built-in
+// implementations.
+type ResultsWriter interface {
+	FileStart(file *moduletest.File)
+	RunStart(run *moduletest.Run, file *moduletest.File)
+	RunComplete(run *moduletest.Run, file *moduletest.File, elapsed int64)
+
+	// DestroySummary receives the diagnostics produced while tearing down
+	// the infrastructure left behind by a file's run blocks once it has
+	// finished testing. run identifies which run block most recently
+	// updated the state being destroyed, or is nil when no run block ever
+	// updated that state (and when reporting an inconsistent module state).
+	DestroySummary(diags tfdiags.Diagnostics, run *moduletest.Run, file *moduletest.File)
+
+	FileComplete(file *moduletest.File)
+	SuiteComplete(suite *moduletest.Suite)
+}
+
+// statusLabel renders a moduletest.Status as the lowercase word a results
+// consumer would expect, without assuming the type has a String method of
+// its own.
+func statusLabel(status moduletest.Status) string {
+	switch status {
+	case moduletest.Pass:
+		return "pass"
+	case moduletest.Fail:
+		return "fail"
+	case moduletest.Error:
+		return "error"
+	case moduletest.Skip:
+		return "skip"
+	case moduletest.Pending:
+		return "pending"
+	default:
+		return fmt.Sprintf("unknown(%d)", status)
+	}
+}
+
+// renderDiagnostics flattens diags into a plain-text block suitable for
+// embedding in a JUnit <failure> element or a JSON string field.
+func renderDiagnostics(diags tfdiags.Diagnostics) string {
+	if len(diags) == 0 {
+		return ""
+	}
+
+	var lines []string
+	for _, diag := range diags {
+		desc := diag.Description()
+		if desc.Detail == "" {
+			lines = append(lines, desc.Summary)
+		} else {
+			lines = append(lines, fmt.Sprintf("%s: %s", desc.Summary, desc.Detail))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// expectedFailureExtra is attached, via tfdiags' diagnostic "extra info"
+// mechanism, to every diagnostic produced while a run block's
+// expect_failures list is being checked against the failures a
+// plan/apply actually reported (see moduletest.Run.ValidateExpectedFailures
+// and ExplainExpectedFailures), so a ResultsWriter can tell which
+// checkable addresses were matched without re-parsing the diagnostic's
+// prose. This follows the same pattern addrs.DiagnosticOriginatesFromCheckRule
+// uses to annotate a diagnostic without changing how it renders.
+type expectedFailureExtra struct {
+	// Address is the checkable object's address as written in the run
+	// block's expect_failures list, e.g. "var.instance_type" or
+	// "aws_instance.example".
+	Address string
+
+	// Satisfied is true if a failing validation/precondition/postcondition
+	// diagnostic was actually found for Address; false marks the
+	// diagnostic reporting that an expected failure never happened.
+	Satisfied bool
+}
+
+// expectedFailureFromDiagnostic extracts the expectedFailureExtra attached
+// to diag, if any.
+func expectedFailureFromDiagnostic(diag tfdiags.Diagnostic) (*expectedFailureExtra, bool) {
+	extra, ok := tfdiags.ExtraInfo[*expectedFailureExtra](diag)
+	if !ok || extra == nil {
+		return nil, false
+	}
+	return extra, true
+}
+
+// expectFailureResult is the structured form of one expectedFailureExtra,
+// ready to serialize as a JSON field or a JUnit <property>.
+type expectFailureResult struct {
+	Address   string `json:"address"`
+	Satisfied bool   `json:"satisfied"`
+}
+
+// collectExpectedFailureResults extracts every expectedFailureExtra found
+// on diags, in the order the diagnostics appear, for a ResultsWriter to
+// report alongside (not instead of) the diagnostics' rendered text.
+func collectExpectedFailureResults(diags tfdiags.Diagnostics) []expectFailureResult {
+	var results []expectFailureResult
+	for _, diag := range diags {
+		if extra, ok := expectedFailureFromDiagnostic(diag); ok {
+			results = append(results, expectFailureResult{Address: extra.Address, Satisfied: extra.Satisfied})
+		}
+	}
+	return results
+}
+
+// junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName xml.Name          `xml:"testsuites"`
+	Suites  []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     float64          `xml:"time,attr"`
+	Cases    []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name       string           `xml:"name,attr"`
+	Classname  string           `xml:"classname,attr"`
+	Time       float64          `xml:"time,attr"`
+	Failure    *junitFailure    `xml:"failure,omitempty"`
+	Skipped    *junitSkipped    `xml:"skipped,omitempty"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+}
+
+// junitProperties carries a run block's expect_failures results as
+// <property> elements, the conventional JUnit extension point for
+// consumer-specific metadata that doesn't fit the schema's fixed fields.
+type junitProperties struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// junitPropertiesForExpectedFailures renders results as a <properties>
+// element, or nil if there were no expect_failures to report.
+func junitPropertiesForExpectedFailures(results []expectFailureResult) *junitProperties {
+	if len(results) == 0 {
+		return nil
+	}
+
+	props := &junitProperties{}
+	for _, result := range results {
+		props.Properties = append(props.Properties, junitProperty{
+			Name:  fmt.Sprintf("expect_failures.%s", result.Address),
+			Value: fmt.Sprintf("%t", result.Satisfied),
+		})
+	}
+	return props
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+type junitSkipped struct{}
+
+// JUnitResultsWriter is a ResultsWriter that accumulates one <testsuite> per
+// test file and one <testcase> per run block, and writes the complete
+// JUnit-format XML report to Out once the whole suite has finished.
+type JUnitResultsWriter struct {
+	Out io.Writer
+
+	mu     sync.Mutex
+	suites map[string]*junitTestSuite
+}
+
+// NewJUnitResultsWriter returns a ResultsWriter that writes a single
+// JUnit-format XML report to out when the suite completes.
+func NewJUnitResultsWriter(out io.Writer) *JUnitResultsWriter {
+	return &JUnitResultsWriter{
+		Out:    out,
+		suites: make(map[string]*junitTestSuite),
+	}
+}
+
+func (w *JUnitResultsWriter) FileStart(file *moduletest.File) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.suites[file.Name] = &junitTestSuite{Name: file.Name}
+}
+
+func (w *JUnitResultsWriter) RunStart(run *moduletest.Run, file *moduletest.File) {
+	// JUnit has no concept of a test starting; the <testcase> is recorded
+	// wholesale once the run completes.
+}
+
+func (w *JUnitResultsWriter) RunComplete(run *moduletest.Run, file *moduletest.File, elapsed int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	suite, exists := w.suites[file.Name]
+	if !exists {
+		// Shouldn't happen, FileStart always fires first, but a writer
+		// shouldn't panic just because of a missed event.
+		suite = &junitTestSuite{Name: file.Name}
+		w.suites[file.Name] = suite
+	}
+
+	testCase := &junitTestCase{
+		Name:       run.Name,
+		Classname:  file.Name,
+		Time:       float64(elapsed) / 1000,
+		Properties: junitPropertiesForExpectedFailures(collectExpectedFailureResults(run.Diagnostics)),
+	}
+
+	suite.Tests++
+	suite.Time += testCase.Time
+	switch run.Status {
+	case moduletest.Fail, moduletest.Error:
+		suite.Failures++
+		testCase.Failure = &junitFailure{
+			Message: statusLabel(run.Status),
+			Content: renderDiagnostics(run.Diagnostics),
+		}
+	case moduletest.Skip, moduletest.Pending:
+		suite.Skipped++
+		testCase.Skipped = &junitSkipped{}
+	}
+
+	suite.Cases = append(suite.Cases, testCase)
+}
+
+func (w *JUnitResultsWriter) DestroySummary(diags tfdiags.Diagnostics, run *moduletest.Run, file *moduletest.File) {
+	if !diags.HasErrors() {
+		// A clean teardown isn't a test outcome in its own right, so only
+		// a failed one is worth surfacing as a <testcase> to a JUnit
+		// consumer.
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	suite, exists := w.suites[file.Name]
+	if !exists {
+		suite = &junitTestSuite{Name: file.Name}
+		w.suites[file.Name] = suite
+	}
+
+	name := "(cleanup)"
+	if run != nil {
+		name = fmt.Sprintf("(cleanup: %s)", run.Name)
+	}
+
+	suite.Tests++
+	suite.Failures++
+	suite.Cases = append(suite.Cases, &junitTestCase{
+		Name:      name,
+		Classname: file.Name,
+		Failure: &junitFailure{
+			Message: "destroy failed",
+			Content: renderDiagnostics(diags),
+		},
+	})
+}
+
+func (w *JUnitResultsWriter) FileComplete(file *moduletest.File) {
+	// The <testsuite> element was already built up incrementally by
+	// RunComplete, so there's nothing left to record here.
+}
+
+func (w *JUnitResultsWriter) SuiteComplete(suite *moduletest.Suite) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var names []string
+	for name := range w.suites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := &junitTestSuites{}
+	for _, name := range names {
+		report.Suites = append(report.Suites, w.suites[name])
+	}
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Printf("[ERROR] JUnitResultsWriter: failed to marshal test results: %s", err)
+		return
+	}
+
+	if _, err := io.WriteString(w.Out, xml.Header); err != nil {
+		log.Printf("[ERROR] JUnitResultsWriter: failed to write test results: %s", err)
+		return
+	}
+	if _, err := w.Out.Write(data); err != nil {
+		log.Printf("[ERROR] JUnitResultsWriter: failed to write test results: %s", err)
+	}
+}
+
+// jsonResultEvent is the envelope JSONResultsWriter emits, one per line, for
+// every state transition so a consumer can stream-parse results as the
+// suite runs instead of waiting for it to finish.
+type jsonResultEvent struct {
+	Type           string                `json:"type"`
+	File           string                `json:"file,omitempty"`
+	Run            string                `json:"run,omitempty"`
+	Status         string                `json:"status,omitempty"`
+	ElapsedMS      int64                 `json:"elapsed_ms,omitempty"`
+	Diagnostics    string                `json:"diagnostics,omitempty"`
+	ExpectFailures []expectFailureResult `json:"expect_failures,omitempty"`
+}
+
+// JSONResultsWriter is a ResultsWriter that emits one JSON object per line
+// to Out for every state transition: file_start, run_start, run_complete,
+// destroy_summary, file_complete, and suite_complete. This backs
+// `terraform test -json`.
+type JSONResultsWriter struct {
+	Out io.Writer
+
+	mu sync.Mutex
+}
+
+// NewJSONResultsWriter returns a ResultsWriter that streams one JSON event
+// per line to out as the suite runs.
+func NewJSONResultsWriter(out io.Writer) *JSONResultsWriter {
+	return &JSONResultsWriter{Out: out}
+}
+
+func (w *JSONResultsWriter) emit(event *jsonResultEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[ERROR] JSONResultsWriter: failed to marshal %s event: %s", event.Type, err)
+		return
+	}
+
+	if _, err := w.Out.Write(append(data, '\n')); err != nil {
+		log.Printf("[ERROR] JSONResultsWriter: failed to write %s event: %s", event.Type, err)
+	}
+}
+
+func (w *JSONResultsWriter) FileStart(file *moduletest.File) {
+	w.emit(&jsonResultEvent{Type: "file_start", File: file.Name})
+}
+
+func (w *JSONResultsWriter) RunStart(run *moduletest.Run, file *moduletest.File) {
+	w.emit(&jsonResultEvent{Type: "run_start", File: file.Name, Run: run.Name})
+}
+
+func (w *JSONResultsWriter) RunComplete(run *moduletest.Run, file *moduletest.File, elapsed int64) {
+	w.emit(&jsonResultEvent{
+		Type:           "run_complete",
+		File:           file.Name,
+		Run:            run.Name,
+		Status:         statusLabel(run.Status),
+		ElapsedMS:      elapsed,
+		Diagnostics:    renderDiagnostics(run.Diagnostics),
+		ExpectFailures: collectExpectedFailureResults(run.Diagnostics),
+	})
+}
+
+func (w *JSONResultsWriter) DestroySummary(diags tfdiags.Diagnostics, run *moduletest.Run, file *moduletest.File) {
+	event := &jsonResultEvent{
+		Type:        "destroy_summary",
+		File:        file.Name,
+		Status:      "ok",
+		Diagnostics: renderDiagnostics(diags),
+	}
+	if run != nil {
+		event.Run = run.Name
+	}
+	if diags.HasErrors() {
+		event.Status = "error"
+	}
+	w.emit(event)
+}
+
+func (w *JSONResultsWriter) FileComplete(file *moduletest.File) {
+	w.emit(&jsonResultEvent{Type: "file_complete", File: file.Name, Status: statusLabel(file.Status)})
+}
+
+func (w *JSONResultsWriter) SuiteComplete(suite *moduletest.Suite) {
+	w.emit(&jsonResultEvent{Type: "suite_complete", Status: statusLabel(suite.Status)})
+}
+
+type TestSuiteRunner struct {
+	Config *configs.Config
+
+	GlobalVariables map[string]backend.UnparsedVariableValue
+	Opts            *terraform.ContextOpts
+
+	View views.Test
+
+	// Stopped and Cancelled track whether the user requested the testing
+	// process to be interrupted. Stopped is a nice graceful exit, we'll still
+	// tidy up any state that was created and mark the tests with relevant
+	// `skipped` status updates. Cancelled is a hard stop right now exit, we
+	// won't attempt to clean up any state left hanging, and tests will just
+	// be left showing `pending` as the status. We will still print out the
+	// destroy summary diagnostics that tell the user what state has been left
+	// behind and needs manual clean up.
+	Stopped   bool
+	Cancelled bool
+
+	// StoppedCtx and CancelledCtx allow in progress Terraform operations to
+	// respond to external calls from the test command.
+	StoppedCtx   context.Context
+	CancelledCtx context.Context
+
+	// Filter restricts exactly which test files will be executed.
+	Filter []string
+
+	// Verbose tells the runner to print out plan files during each test run.
+	Verbose bool
+
+	// Parallelism is the maximum number of test files marked with the
+	// `parallel` attribute (see fileIsParallel) that may execute at once,
+	// mirroring the `-p` flag to `go test`. Files that don't opt in always
+	// run serially, in alphabetical order, exactly as they did before this
+	// field existed. A Parallelism of zero or less is treated as 1, so the
+	// zero value keeps the fully-serial behavior.
+	//
+	// Since the `-parallelism=N` flag that sets this field bounds the
+	// overall number of concurrent Terraform operations the test run is
+	// allowed, each TestFileRunner also uses it to bound how many of its
+	// own run blocks (see buildRunDAG) it executes at once.
+	Parallelism int
+
+	// StateStoreDir, if set, makes each TestFileRunner persist its
+	// RelevantStates to disk under StateStoreDir/<file>/<key>.tfstate after
+	// every run block's apply, via a localStateStore, so infrastructure
+	// created by a crashed or killed `terraform test` invocation isn't lost.
+	// The zero value, an empty string, preserves the historical behavior of
+	// keeping states only in memory.
+	StateStoreDir string
+
+	// Recover requests that, when leftover per-key state files are found
+	// under StateStoreDir from a previous, uncompleted invocation, Test
+	// should destroy the infrastructure they describe before running the
+	// rest of the suite, rather than merely warning about them.
+	Recover bool
+
+	// StateBackend, if set (via the `-state-backend` flag), makes each
+	// TestFileRunner persist its RelevantStates through this backend
+	// instead of StateStoreDir's localStateStore, via NewBackendStateStore.
+	// It takes precedence over StateStoreDir when both are set, since a
+	// configured backend is always able to recover what a plain directory
+	// of state files can, and more besides.
+	StateBackend backend.Backend
+
+	// Resume requests that, for every test file, TestFileRunner skip any
+	// run block that a previous invocation already recorded as passed (see
+	// testProgress) with an unchanged configuration, and continue from
+	// wherever that invocation stopped. It only has an effect alongside
+	// StateStoreDir, since that's where the resume bookkeeping itself
+	// lives.
+	Resume bool
+
+	// ResultsWriters receives structured, machine-readable copies of every
+	// event printed through View, for consumption by CI systems. It's
+	// empty by default, which emits nothing beyond the human-oriented View.
+	ResultsWriters []ResultsWriter
+
+	// viewMu serializes calls into View so that progress events from
+	// parallel-marked files running concurrently can't interleave into
+	// garbled output. It guards only the View calls themselves, never a
+	// file's actual test execution.
+	viewMu sync.Mutex
+}
+
+func (runner *TestSuiteRunner) Stop() {
+	runner.Stopped = true
+}
+
+func (runner *TestSuiteRunner) Cancel() {
+	runner.Cancelled = true
+}
+
+func (runner *TestSuiteRunner) Test() (moduletest.Status, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	suite, suiteDiags := runner.collectTests()
+	diags = diags.Append(suiteDiags)
+	if suiteDiags.HasErrors() {
+		return moduletest.Error, diags
+	}
+
+	runner.View.Abstract(suite)
+
+	recoveryStatus := moduletest.Pass
+	if runner.StateStoreDir != "" {
+		leftover, leftoverErr := runner.detectLeftoverState()
+		if leftoverErr != nil {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Failed to inspect test state directory",
+				fmt.Sprintf("Terraform could not check %s for state left behind by a previous, uncompleted test run: %s.", runner.StateStoreDir, leftoverErr)))
+			return moduletest.Error, diags
+		}
+
+		if len(leftover) > 0 {
+			if !runner.Recover {
+				diags = diags.Append(tfdiags.Sourceless(
+					tfdiags.Warning,
+					"Leftover test state found",
+					fmt.Sprintf("Found state left behind under %s by a previous, uncompleted run of `terraform test`. Re-run with -recover to destroy the associated infrastructure before continuing, or remove the directory yourself if it's already been cleaned up.", runner.StateStoreDir)))
+			} else {
+				recoveryStatus = runner.recoverLeftoverState(leftover, suite)
+			}
+		}
+	}
+
+	// Split the files into those that opted into concurrent execution and
+	// those that didn't, and execute each group in alphabetical order, same
+	// as before this distinction existed. Parallel-marked files get their
+	// own TestFileRunner (and so their own RelevantStates map) the same as
+	// a serial file does, so there's no mutation shared between them.
+	var serial, parallel []string
+	for name, file := range suite.Files {
+		if fileIsParallel(file) {
+			parallel = append(parallel, name)
+		} else {
+			serial = append(serial, name)
+		}
+	}
+	sort.Strings(serial)
+	sort.Strings(parallel)
+
+	suite.Status = recoveryStatus
+	for _, name := range serial {
+		if runner.Cancelled {
+			return suite.Status, diags
+		}
+		suite.Status = suite.Status.Merge(runner.runFile(suite.Files[name]))
+	}
+
+	if !runner.Cancelled {
+		suite.Status = suite.Status.Merge(runner.runFilesInParallel(parallel, suite))
+	}
+
+	runner.View.Conclusion(suite)
+	for _, writer := range runner.ResultsWriters {
+		writer.SuiteComplete(suite)
+	}
+
+	return suite.Status, diags
+}
+
+// fileIsParallel reports whether file opted into running concurrently with
+// other parallel-marked files, either directly via a `parallel = true`
+// attribute on the test file itself, or indirectly because one of its run
+// blocks set the same attribute as a shorthand for "this file is safe to
+// run alongside others".
+func fileIsParallel(file *moduletest.File) bool {
+	if file.Config.Parallel {
+		return true
+	}
+	for _, run := range file.Runs {
+		if run.Config.Parallel {
+			return true
+		}
+	}
+	return false
+}
+
+// runFile executes a single test file with its own TestFileRunner, so the
+// file owns its own RelevantStates map independently of any other file
+// being executed at the same time.
+func (runner *TestSuiteRunner) runFile(file *moduletest.File) moduletest.Status {
+	store := runner.stateStoreForFile(file.Name)
+
+	mainState, err := store.Load(MainStateIdentifier)
+	if err != nil {
+		// We still want to attempt the test, since giving up would abandon
+		// any infrastructure that might be named in the unreadable state, so
+		// we just fall back to a fresh state and log the problem.
+		log.Printf("[ERROR] TestFileRunner: failed to load persisted main state for %s: %s", file.Name, err)
+		mainState = states.NewState()
+	}
+
+	fileRunner := &TestFileRunner{
+		Suite:      runner,
+		StateStore: store,
+		RelevantStates: map[string]*TestFileState{
+			MainStateIdentifier: {
+				Key:   MainStateIdentifier,
+				Run:   nil,
+				State: mainState,
+			},
+		},
+		PriorStates: make(map[string]*terraform.TestContext),
+		progress:    &testProgress{CompletedRuns: make(map[string]string)},
+	}
+
+	if runner.StateStoreDir != "" {
+		fileRunner.progressDir = runner.StateStoreDir
+		if runner.Resume {
+			progress, err := loadProgress(fileRunner.progressDir, file.Name)
+			if err != nil {
+				log.Printf("[ERROR] TestFileRunner: failed to load resume progress for %s: %s", file.Name, err)
+			} else {
+				fileRunner.progress = progress
+			}
+		}
+	}
+
+	runner.reportFile(file, moduletest.Starting)
+	fileRunner.Test(file)
+	runner.reportFile(file, moduletest.TearDown)
+	fileRunner.cleanup(file)
+	runner.reportFile(file, moduletest.Complete)
+
+	if fileRunner.progressDir != "" && file.Status == moduletest.Pass {
+		// Nothing left to resume from; don't leave stale bookkeeping
+		// around for a future, unrelated invocation to trip over.
+		if err := os.Remove(progressPath(fileRunner.progressDir, file.Name)); err != nil && !os.IsNotExist(err) {
+			log.Printf("[ERROR] TestFileRunner: failed to remove resume progress for %s: %s", file.Name, err)
+		}
+	}
+
+	return file.Status
+}
+
+// stateStoreForFile builds the TestStateStore that fileName's TestFileRunner
+// should load and persist its RelevantStates through. With neither
+// StateBackend nor StateStoreDir configured this preserves the historical
+// in-memory-only behavior.
+func (runner *TestSuiteRunner) stateStoreForFile(fileName string) TestStateStore {
+	if runner.StateBackend != nil {
+		return NewBackendStateStore(runner.StateBackend, fileName)
+	}
+	if runner.StateStoreDir == "" {
+		return NewMapStateStore()
+	}
+	return NewLocalStateStore(filepath.Join(runner.StateStoreDir, fileName))
+}
+
+// recoverableTestState describes a per-key state left behind under
+// StateStoreDir by a previous, uncompleted `terraform test` invocation.
+type recoverableTestState struct {
+	file  string
+	key   string
+	state *states.State
+}
+
+// detectLeftoverState scans StateStoreDir for any persisted test state left
+// behind by a previous `terraform test` invocation that didn't get a chance
+// to clean up after itself, for example because it panicked or was killed.
+func (runner *TestSuiteRunner) detectLeftoverState() ([]recoverableTestState, error) {
+	fileEntries, err := os.ReadDir(runner.StateStoreDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var leftover []recoverableTestState
+	for _, fileEntry := range fileEntries {
+		if !fileEntry.IsDir() {
+			continue
+		}
+
+		store := NewLocalStateStore(filepath.Join(runner.StateStoreDir, fileEntry.Name()))
+		keys, err := store.List()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			state, err := store.Load(key)
+			if err != nil {
+				return nil, err
+			}
+			leftover = append(leftover, recoverableTestState{file: fileEntry.Name(), key: key, state: state})
+		}
+	}
+
+	sort.Slice(leftover, func(i, j int) bool {
+		if leftover[i].file != leftover[j].file {
+			return leftover[i].file < leftover[j].file
+		}
+		return leftover[i].key < leftover[j].key
+	})
+
+	return leftover, nil
+}
+
+// recoverLeftoverState destroys the infrastructure tracked by leftover,
+// which was persisted by a previous, uncompleted `terraform test`
+// invocation, by resuming TestFileRunner.cleanup against it before the rest
+// of the suite runs.
+//
+// Only the state itself survives a crash, not the run block that produced
+// it, so recovery is limited to the root module under test (keyed by
+// MainStateIdentifier). A leftover state for a module under test can't be
+// safely reconstructed without knowing which run block and configuration
+// produced it, so those are reported instead of guessed at.
+func (runner *TestSuiteRunner) recoverLeftoverState(leftover []recoverableTestState, suite *moduletest.Suite) moduletest.Status {
+	status := moduletest.Pass
+
+	byFile := make(map[string][]recoverableTestState)
+	var names []string
+	for _, entry := range leftover {
+		if _, exists := byFile[entry.file]; !exists {
+			names = append(names, entry.file)
+		}
+		byFile[entry.file] = append(byFile[entry.file], entry)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		file, ok := suite.Files[name]
+		if !ok {
+			// The test file that produced this state has since been removed
+			// or renamed, so there's no configuration left to destroy it
+			// with. We leave it on disk for the user to investigate.
+			log.Printf("[ERROR] TestStateManager: found state left behind by %s, but that file no longer exists", name)
+			status = status.Merge(moduletest.Error)
+			continue
+		}
+
+		store := NewLocalStateStore(filepath.Join(runner.StateStoreDir, name))
+		fileRunner := &TestFileRunner{
+			Suite:          runner,
+			StateStore:     store,
+			RelevantStates: map[string]*TestFileState{},
+			PriorStates:    make(map[string]*terraform.TestContext),
+		}
+
+		for _, entry := range byFile[name] {
+			if entry.key != MainStateIdentifier {
+				log.Printf("[ERROR] TestStateManager: found state left behind by %s for module %s, but the run block that applied it is unknown so it can't be safely destroyed automatically", name, entry.key)
+				status = status.Merge(moduletest.Error)
+				continue
+			}
+
+			fileRunner.RelevantStates[MainStateIdentifier] = &TestFileState{
+				Key: MainStateIdentifier,
+				Run: &moduletest.Run{
+					Config: &configs.TestRun{},
+					Name:   "recover",
+				},
+				State: entry.state,
+			}
+		}
+
+		if _, exists := fileRunner.RelevantStates[MainStateIdentifier]; !exists {
+			continue
+		}
+
+		runner.reportFile(file, moduletest.Starting)
+		fileRunner.cleanup(file)
+		runner.reportFile(file, moduletest.Complete)
+
+		status = status.Merge(file.Status)
+	}
+
+	return status
+}
+
+// runFilesInParallel runs the named files, drawn from suite.Files, through a
+// worker pool bounded by Parallelism, and merges their resulting statuses.
+// runFilesInParallel's worker-pool cancellation path (runner.Cancelled
+// checked per work item, closing results once every worker has drained
+// work) would normally get a dedicated test driving a fake runFile with a
+// slow/cancelable stand-in; see the import-gap note at the top of this
+// file for why that test can't exist here.
+func (runner *TestSuiteRunner) runFilesInParallel(names []string, suite *moduletest.Suite) moduletest.Status {
+	status := moduletest.Pass
+	if len(names) == 0 {
+		return status
+	}
+
+	limit := runner.Parallelism
+	if limit <= 0 {
+		limit = 1
+	}
+	if limit > len(names) {
+		limit = len(names)
+	}
+
+	work := make(chan string)
+	results := make(chan moduletest.Status)
+
+	var wg sync.WaitGroup
+	wg.Add(limit)
+	for i := 0; i < limit; i++ {
+		go func() {
+			defer wg.Done()
+			for name := range work {
+				if runner.Cancelled {
+					// Same as the serial loop above: leave any file we
+					// haven't started yet untouched, rather than trying to
+					// contribute a status for it.
+					continue
+				}
+				results <- runner.runFile(suite.Files[name])
+			}
+		}()
+	}
+
+	go func() {
+		defer close(work)
+		for _, name := range names {
+			work <- name
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for result := range results {
+		status = status.Merge(result)
+	}
+
+	return status
+}
+
+// reportFile serializes a call into View.File so file-level progress events
+// from files executing in parallel can't interleave with one another.
+func (runner *TestSuiteRunner) reportFile(file *moduletest.File, progress moduletest.Progress) {
+	runner.viewMu.Lock()
+	defer runner.viewMu.Unlock()
+	runner.View.File(file, progress)
+
+	switch progress {
+	case moduletest.Starting:
+		for _, writer := range runner.ResultsWriters {
+			writer.FileStart(file)
+		}
+	case moduletest.Complete:
+		for _, writer := range runner.ResultsWriters {
+			writer.FileComplete(file)
+		}
+	}
+}
+
+// reportRun serializes a call into View.Run so run-level progress events
+// from files executing in parallel can't interleave with one another.
+func (runner *TestSuiteRunner) reportRun(run *moduletest.Run, file *moduletest.File, progress moduletest.Progress, elapsed int64) {
+	runner.viewMu.Lock()
+	defer runner.viewMu.Unlock()
+	runner.View.Run(run, file, progress, elapsed)
+
+	switch progress {
+	case moduletest.Starting:
+		for _, writer := range runner.ResultsWriters {
+			writer.RunStart(run, file)
+		}
+	case moduletest.Complete:
+		for _, writer := range runner.ResultsWriters {
+			writer.RunComplete(run, file, elapsed)
+		}
+	}
+}
+
+// reportDestroySummary serializes a call into View.DestroySummary, and
+// forwards the same diagnostics to every ResultsWriter, so cleanup
+// diagnostics from files executing in parallel can't interleave with one
+// another.
+func (runner *TestSuiteRunner) reportDestroySummary(diags tfdiags.Diagnostics, run *moduletest.Run, file *moduletest.File, state *states.State) {
+	runner.viewMu.Lock()
+	defer runner.viewMu.Unlock()
+	runner.View.DestroySummary(diags, run, file, state)
+
+	for _, writer := range runner.ResultsWriters {
+		writer.DestroySummary(diags, run, file)
+	}
+}
+
+// reportFatalInterruptSummary serializes a call into
+// View.FatalInterruptSummary so an interrupt during one parallel file's
+// execution can't interleave its summary with another's.
+func (runner *TestSuiteRunner) reportFatalInterruptSummary(run *moduletest.Run, file *moduletest.File, interrupted map[*moduletest.Run]*states.State, created []*plans.ResourceInstanceChangeSrc) {
+	runner.viewMu.Lock()
+	defer runner.viewMu.Unlock()
+	runner.View.FatalInterruptSummary(run, file, interrupted, created)
+}
+
+func (runner *TestSuiteRunner) collectTests() (*moduletest.Suite, tfdiags.Diagnostics) {
+	runCount := 0
+	fileCount := 0
+
+	var diags tfdiags.Diagnostics
+	suite := &moduletest.Suite{
+		Files: func() map[string]*moduletest.File {
+			files := make(map[string]*moduletest.File)
+
+			if len(runner.Filter) > 0 {
+				for _, name := range runner.Filter {
+					file, ok := runner.Config.Module.Tests[name]
+					if !ok {
+						// If the filter is invalid, we'll simply skip this
+						// entry and print a warning. But we could still execute
+						// any other tests within the filter.
+						diags.Append(tfdiags.Sourceless(
+							tfdiags.Warning,
+							"Unknown test file",
+							fmt.Sprintf("The specified test file, %s, could not be found.", name)))
+						continue
+					}
+
+					fileCount++
+
+					var runs []*moduletest.Run
+					for ix, run := range file.Runs {
+						runs = append(runs, &moduletest.Run{
+							Config: run,
+							Index:  ix,
+							Name:   run.Name,
+						})
+					}
+
+					runCount += len(runs)
+					files[name] = &moduletest.File{
+						Config: file,
+						Name:   name,
+						Runs:   runs,
+					}
+				}
+
+				return files
+			}
+
+			// Otherwise, we'll just do all the tests in the directory!
+			for name, file := range runner.Config.Module.Tests {
+				fileCount++
+
+				var runs []*moduletest.Run
+				for ix, run := range file.Runs {
+					runs = append(runs, &moduletest.Run{
+						Config: run,
+						Index:  ix,
+						Name:   run.Name,
+					})
+				}
+
+				runCount += len(runs)
+				files[name] = &moduletest.File{
+					Config: file,
+					Name:   name,
+					Runs:   runs,
+				}
+			}
+			return files
+		}(),
+	}
+
+	log.Printf("[DEBUG] TestSuiteRunner: found %d files with %d run blocks", fileCount, runCount)
+
+	return suite, diags
+}
+
+type TestFileRunner struct {
+	// Suite contains all the helpful metadata about the test that we need
+	// during the execution of a file.
+	Suite *TestSuiteRunner
+
+	// RelevantStates is a mapping of module keys to it's last applied state
+	// file.
+	//
+	// This is used to clean up the infrastructure created during the test after
+	// the test has finished.
+	RelevantStates map[string]*TestFileState
+
+	// relevantStatesMu guards RelevantStates and the State/Run fields of its
+	// values, as well as PriorStates below, now that buildRunDAG lets more
+	// than one run block execute at once for the same file.
+	relevantStatesMu sync.Mutex
+
+	// StateStore is where RelevantStates are loaded from and persisted to
+	// after every run block's apply, so the states survive a crash of this
+	// process. See TestStateStore.
+	StateStore TestStateStore
+
+	// PriorStates is mapping from run block names to the TestContexts that were
+	// created when that run block executed.
+	//
+	// This is used to allow run blocks to refer back to the output values of
+	// previous run blocks. It is passed into the Evaluate functions that
+	// validate the test assertions, and used when calculating values for
+	// variables within run blocks. Every read and write must hold
+	// relevantStatesMu, since independent run blocks within a file now
+	// execute concurrently.
+	PriorStates map[string]*terraform.TestContext
+
+	// activeContexts tracks every *terraform.Context currently executing a
+	// validate, plan, or apply operation on behalf of this file, so that a
+	// hard cancel can stop all of them at once (see stopActiveContexts)
+	// instead of just the one operation that happened to call wait() - now
+	// that independent run blocks from buildRunDAG can have more than one
+	// of these in flight simultaneously.
+	activeContexts   map[*terraform.Context]bool
+	activeContextsMu sync.Mutex
+
+	// planCache holds the plan from a `command = plan` run block, keyed by
+	// the module, variables, and prior state it was computed against, so
+	// that an immediately following `command = apply` run block targeting
+	// the same module with identical inputs can reuse it instead of
+	// re-planning. See planCacheKeyFor.
+	planCache   map[planCacheKey]*cachedPlan
+	planCacheMu sync.Mutex
+
+	// progress and progressDir back `terraform test -resume`: progress
+	// records which run blocks already completed successfully in a
+	// previous invocation (loaded from progressDir if Suite.Resume is set),
+	// and every run block that passes in this invocation is added to it and
+	// persisted back to progressDir, so a later resume can pick up from
+	// here in turn. progressDir is empty, disabling persistence, unless
+	// Suite.StateStoreDir is set.
+	progress    *testProgress
+	progressDir string
+	progressMu  sync.Mutex
+}
+
+// trackContext registers ctx as in-flight so a hard cancel can stop it, and
+// returns a function that un-registers it again once the operation using it
+// has finished.
+func (runner *TestFileRunner) trackContext(ctx *terraform.Context) func() {
+	runner.activeContextsMu.Lock()
+	defer runner.activeContextsMu.Unlock()
+
+	if runner.activeContexts == nil {
+		runner.activeContexts = make(map[*terraform.Context]bool)
+	}
+	runner.activeContexts[ctx] = true
+
+	return func() {
+		runner.activeContextsMu.Lock()
+		defer runner.activeContextsMu.Unlock()
+		delete(runner.activeContexts, ctx)
+	}
+}
+
+// stopActiveContexts calls Stop on every *terraform.Context currently
+// tracked for this file, so a hard cancel reaches every run block executing
+// concurrently rather than just the one wait() was called for.
+func (runner *TestFileRunner) stopActiveContexts() {
+	runner.activeContextsMu.Lock()
+	defer runner.activeContextsMu.Unlock()
+
+	for ctx := range runner.activeContexts {
+		go ctx.Stop()
+	}
+}
+
+// flushRelevantStates persists every module's current state to the
+// configured TestStateStore. It's used on the hard-cancel path so that a
+// `terraform test -resume` invocation has something to load even if the
+// test run was killed mid-file rather than finishing normally.
+func (runner *TestFileRunner) flushRelevantStates(file *moduletest.File) {
+	runner.relevantStatesMu.Lock()
+	defer runner.relevantStatesMu.Unlock()
+
+	for key, module := range runner.RelevantStates {
+		if err := runner.StateStore.Save(key, module.State); err != nil {
+			log.Printf("[ERROR] TestFileRunner: failed to flush state for %s/%s during hard cancel: %s", file.Name, key, err)
+		}
+	}
+}
+
+// snapshotRelevantStates copies RelevantStates into the shape
+// reportFatalInterruptSummary wants, keyed by the run block that produced
+// each state instead of by module key. It takes relevantStatesMu so it's
+// safe to call while other run blocks from buildRunDAG are still executing.
+func (runner *TestFileRunner) snapshotRelevantStates() map[*moduletest.Run]*states.State {
+	runner.relevantStatesMu.Lock()
+	defer runner.relevantStatesMu.Unlock()
+
+	snapshot := make(map[*moduletest.Run]*states.State, len(runner.RelevantStates))
+	if main, ok := runner.RelevantStates[MainStateIdentifier]; ok {
+		snapshot[nil] = main.State
+	}
+	for key, module := range runner.RelevantStates {
+		if key == MainStateIdentifier {
+			continue
+		}
+		snapshot[module.Run] = module.State
+	}
+	return snapshot
+}
+
+// TestFileState is a helper struct that just maps a run block to the state that
+// was produced by the execution of that run block.
+type TestFileState struct {
+	// Key is the RelevantStates key this state was stored under, carried
+	// alongside Run and State so cleanup can persist an updated state back
+	// to the right place in the TestStateStore without needing the
+	// surrounding map's key in hand.
+	Key   string
+	Run   *moduletest.Run
+	State *states.State
+
+	// Lineage and Serial fingerprint State the same way a state file on
+	// disk would, except generated in memory: Lineage is assigned the
+	// first time this key's state is ever updated, and Serial increments
+	// on every subsequent update. Together they let planCacheKeyFor tell
+	// whether the state a cached plan was computed against is still the
+	// state a later run block would plan from.
+	Lineage string
+	Serial  uint64
+}
+
+// testRunNode is one run block's place in the dependency graph buildRunDAG
+// derives for a file, identifying which other run blocks (by index into
+// file.Runs) must finish before this one may start.
+type testRunNode struct {
+	run   *moduletest.Run
+	key   string
+	index int
+	deps  []int
+}
+
+// buildRunDAG works out, for every run block in file, which other run
+// blocks it must wait for: an explicit edge for every `run.<name>`
+// reference discovered via run.GetReferences(), plus an implicit edge from
+// each run block to the nearest earlier one that targets the same
+// underlying state (see TestFileRunner.RelevantStates), since two run
+// blocks sharing a state can't safely apply against it at the same time.
+// Run blocks with no edges between them - different target state, and
+// neither refers to the other's outputs - are independent and can be
+// executed concurrently by TestFileRunner.Test.
+//
+// There's no buildRunDAG_test.go: package local itself doesn't build in
+// this checkout (see the import-gap note at the top of this file), so a
+// test file here would fail before ever reaching buildRunDAG's logic.
+func buildRunDAG(file *moduletest.File) []*testRunNode {
+	nodes := make([]*testRunNode, len(file.Runs))
+	byName := make(map[string]int, len(file.Runs))
+	for i, run := range file.Runs {
+		byName[run.Name] = i
+	}
+
+	lastForKey := make(map[string]int)
+	for i, run := range file.Runs {
+		key := MainStateIdentifier
+		if run.Config.ConfigUnderTest != nil {
+			key = run.Config.Module.Source.String()
+		}
+
+		node := &testRunNode{run: run, key: key, index: i}
+
+		if prior, ok := lastForKey[key]; ok {
+			node.deps = append(node.deps, prior)
+		}
+		lastForKey[key] = i
+
+		if references, refDiags := run.GetReferences(); !refDiags.HasErrors() {
+			for _, reference := range references {
+				ref, ok := reference.Subject.(addrs.Run)
+				if !ok {
+					continue
+				}
+				dep, ok := byName[ref.Name]
+				if !ok || dep == i {
+					continue
+				}
+				if !slices.Contains(node.deps, dep) {
+					node.deps = append(node.deps, dep)
+				}
+			}
+		}
+
+		nodes[i] = node
+	}
+
+	return nodes
+}
+
+func (runner *TestFileRunner) Test(file *moduletest.File) {
+	log.Printf("[TRACE] TestFileRunner: executing test file %s", file.Name)
+
+	// We'll execute the tests in the file. First, mark the overall status as
+	// being skipped. This will ensure that if we've cancelled and the files not
+	// going to do anything it'll be marked as skipped.
+	file.Status = file.Status.Merge(moduletest.Skip)
+	if len(file.Runs) == 0 {
+		// If we have zero run blocks then we'll just mark the file as passed.
+		file.Status = file.Status.Merge(moduletest.Pass)
+		return
+	}
+
+	nodes := buildRunDAG(file)
+
+	// dependents[i] lists the nodes that become eligible to run once node i
+	// finishes; remaining[i] counts how many of node i's own dependencies
+	// are still outstanding.
+	dependents := make([][]int, len(nodes))
+	remaining := make([]int, len(nodes))
+	for _, node := range nodes {
+		remaining[node.index] = len(node.deps)
+		for _, dep := range node.deps {
+			dependents[dep] = append(dependents[dep], node.index)
+		}
+	}
+
+	limit := runner.Suite.Parallelism
+	if limit < 1 {
+		limit = 1
+	}
+	sem := make(chan struct{}, limit)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	skipDependent := make([]bool, len(nodes))
+
+	var dispatch func(node *testRunNode, skip bool)
+	dispatch = func(node *testRunNode, skip bool) {
+		defer wg.Done()
+
+		sem <- struct{}{}
+		runner.runNode(node, file, skip)
+		<-sem
+
+		failed := skip || node.run.Status == moduletest.Error || node.run.Status == moduletest.Fail
+
+		mu.Lock()
+		var next []*testRunNode
+		var nextSkip []bool
+		for _, dependent := range dependents[node.index] {
+			remaining[dependent]--
+			if failed {
+				skipDependent[dependent] = true
+			}
+			if remaining[dependent] == 0 {
+				next = append(next, nodes[dependent])
+				nextSkip = append(nextSkip, skipDependent[dependent])
+			}
+		}
+		mu.Unlock()
+
+		for i, n := range next {
+			wg.Add(1)
+			go dispatch(n, nextSkip[i])
+		}
+	}
+
+	mu.Lock()
+	var ready []*testRunNode
+	for _, node := range nodes {
+		if remaining[node.index] == 0 {
+			ready = append(ready, node)
+		}
+	}
+	mu.Unlock()
+
+	for _, node := range ready {
+		wg.Add(1)
+		go dispatch(node, false)
+	}
+	wg.Wait()
+
+	if runner.Suite.Cancelled {
+		// This means a hard stop was requested partway through. Matching the
+		// historical serial behavior, we don't mark the individual runs this
+		// left behind as skipped - they'll show up as pending - but we do
+		// mark the file as having errored out.
+		file.Status = file.Status.Merge(moduletest.Error)
+		return
+	}
+
+	for _, node := range nodes {
+		file.Status = file.Status.Merge(node.run.Status)
+	}
+}
+
+// runNode executes a single run block from file's dependency DAG (see
+// buildRunDAG), loading and persisting whichever RelevantStates entry it
+// targets under relevantStatesMu so it can safely run concurrently with
+// other run blocks that target a different one.
+func (runner *TestFileRunner) runNode(node *testRunNode, file *moduletest.File, skip bool) {
+	run := node.run
+	runStart := time.Now().UTC().UnixMilli()
+
+	if runner.Suite.Cancelled {
+		// A hard stop was requested before this run block's turn came up.
+		// Matching the historical serial behavior, we leave it showing
+		// pending rather than reporting it at all.
+		return
+	}
+
+	if skip {
+		// One of this run block's dependencies failed, so there's no
+		// sensible state for it to run against.
+		run.Status = moduletest.Skip
+		runner.Suite.reportRun(run, file, moduletest.Complete, time.Now().UTC().UnixMilli()-runStart)
+		return
+	}
+
+	if runner.Suite.Stopped {
+		// Then the test was requested to be stopped, so we just mark this
+		// run as skipped, print the status, and move on.
+		run.Status = moduletest.Skip
+		runner.Suite.reportRun(run, file, moduletest.Complete, time.Now().UTC().UnixMilli()-runStart)
+		return
+	}
+
+	if runner.progress.alreadyPassed(run) {
+		// We're resuming a previous invocation, and this run block already
+		// passed against an identical configuration last time. There's no
+		// need to execute it again - just carry its prior success forward.
+		//
+		// The state this run produced was already persisted before the
+		// previous invocation exited, so we still need to load it (if it
+		// isn't loaded already) and record this run against it - otherwise
+		// cleanup() sees a RelevantStates entry with a populated State but
+		// no Run and mistakes the resumed file for a corrupted one.
+		runner.relevantStatesMu.Lock()
+		if _, exists := runner.RelevantStates[node.key]; !exists {
+			moduleState, err := runner.StateStore.Load(node.key)
+			if err != nil {
+				log.Printf("[ERROR] TestFileRunner: failed to load persisted state for %s/%s: %s", file.Name, node.key, err)
+				moduleState = states.NewState()
+			}
 
-					fileCount++
+			runner.RelevantStates[node.key] = &TestFileState{
+				Key:   node.key,
+				State: moduleState,
+			}
+		}
+		runner.RelevantStates[node.key].Run = run
+		runner.relevantStatesMu.Unlock()
 
-					var runs []*moduletest.Run
-					for ix, run := range file.Runs {
-						runs = append(runs, &moduletest.Run{
-							Config: run,
-							Index:  ix,
-							Name:   run.Name,
-						})
-					}
+		run.Status = moduletest.Pass
+		runner.Suite.reportRun(run, file, moduletest.Complete, time.Now().UTC().UnixMilli()-runStart)
+		return
+	}
 
-					runCount += len(runs)
-					files[name] = &moduletest.File{
-						Config: file,
-						Name:   name,
-						Runs:   runs,
-					}
-				}
+	key := node.key
+	config := runner.Suite.Config
+	if run.Config.ConfigUnderTest != nil {
+		config = run.Config.ConfigUnderTest
 
-				return files
-			}
+		if key == MainStateIdentifier {
+			// This is bad. It means somehow the module we're loading has
+			// the same key as main state and we're about to corrupt things.
 
-			// Otherwise, we'll just do all the tests in the directory!
-			for name, file := range runner.Config.Module.Tests {
-				fileCount++
+			run.Diagnostics = run.Diagnostics.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid module source",
+				Detail:   fmt.Sprintf("The source for the selected module evaluated to %s which should not be possible. This is a bug in Terraform - please report it!", key),
+				Subject:  run.Config.Module.DeclRange.Ptr(),
+			})
 
-				var runs []*moduletest.Run
-				for ix, run := range file.Runs {
-					runs = append(runs, &moduletest.Run{
-						Config: run,
-						Index:  ix,
-						Name:   run.Name,
-					})
-				}
+			run.Status = moduletest.Error
+			runner.Suite.reportRun(run, file, moduletest.Complete, time.Now().UTC().UnixMilli()-runStart)
+			return
+		}
+	}
 
-				runCount += len(runs)
-				files[name] = &moduletest.File{
-					Config: file,
-					Name:   name,
-					Runs:   runs,
-				}
+	runner.relevantStatesMu.Lock()
+	if _, exists := runner.RelevantStates[key]; !exists {
+		moduleState, err := runner.StateStore.Load(key)
+		if err != nil {
+			log.Printf("[ERROR] TestFileRunner: failed to load persisted state for %s/%s: %s", file.Name, key, err)
+			moduleState = states.NewState()
+		}
+
+		runner.RelevantStates[key] = &TestFileState{
+			Key:   key,
+			Run:   nil,
+			State: moduleState,
+		}
+	}
+	priorState := runner.RelevantStates[key].State
+	runner.relevantStatesMu.Unlock()
+
+	state, updatedState := runner.runWithRetry(run, file, key, priorState, config)
+	if updatedState {
+		// Only update the most recent run and state if the state was
+		// actually updated by this change. We want to use the run that
+		// most recently updated the tracked state as the cleanup
+		// configuration.
+		runner.relevantStatesMu.Lock()
+		runner.RelevantStates[key].State = state
+		runner.RelevantStates[key].Run = run
+		runner.RelevantStates[key].Serial++
+		if runner.RelevantStates[key].Lineage == "" {
+			lineage, err := uuid.GenerateUUID()
+			if err != nil {
+				log.Printf("[ERROR] TestFileRunner: failed to generate state lineage for %s/%s: %s", file.Name, key, err)
 			}
-			return files
-		}(),
+			runner.RelevantStates[key].Lineage = lineage
+		}
+		runner.relevantStatesMu.Unlock()
+
+		if err := runner.StateStore.Save(key, state); err != nil {
+			log.Printf("[ERROR] TestFileRunner: failed to persist state for %s/%s: %s", file.Name, key, err)
+		}
 	}
 
-	log.Printf("[DEBUG] TestSuiteRunner: found %d files with %d run blocks", fileCount, runCount)
+	if run.Status == moduletest.Pass && runner.progressDir != "" {
+		runner.progressMu.Lock()
+		runner.progress.recordRun(run)
+		if err := runner.progress.save(runner.progressDir, file.Name); err != nil {
+			log.Printf("[ERROR] TestFileRunner: failed to save resume progress for %s: %s", file.Name, err)
+		}
+		runner.progressMu.Unlock()
+	}
 
-	return suite, diags
+	runner.Suite.reportRun(run, file, moduletest.Complete, time.Now().UTC().UnixMilli()-runStart)
 }
 
-type TestFileRunner struct {
-	// Suite contains all the helpful metadata about the test that we need
-	// during the execution of a file.
-	Suite *TestSuiteRunner
+// retryOnFailureKind identifies which category of run block failure a
+// retry block's on_failure attribute opted into retrying.
+type retryOnFailureKind string
 
-	// RelevantStates is a mapping of module keys to it's last applied state
-	// file.
-	//
-	// This is used to clean up the infrastructure created during the test after
-	// the test has finished.
-	RelevantStates map[string]*TestFileState
+const (
+	retryOnFailureApply     retryOnFailureKind = "apply"
+	retryOnFailureAssertion retryOnFailureKind = "assertion"
+	retryOnFailureAny       retryOnFailureKind = "any"
+)
 
-	// PriorStates is mapping from run block names to the TestContexts that were
-	// created when that run block executed.
-	//
-	// This is used to allow run blocks to refer back to the output values of
-	// previous run blocks. It is passed into the Evaluate functions that
-	// validate the test assertions, and used when calculating values for
-	// variables within run blocks.
-	PriorStates map[string]*terraform.TestContext
+// retryConfig is the parsed form of a run block's retry and timeout
+// attributes. The zero value behaves as though neither was set: a single
+// attempt, no interval, and no deadline on the plan/apply goroutines.
+type retryConfig struct {
+	attempts  int
+	interval  time.Duration
+	onFailure []retryOnFailureKind
+	timeout   time.Duration
 }
 
-// TestFileState is a helper struct that just maps a run block to the state that
-// was produced by the execution of that run block.
-type TestFileState struct {
-	Run   *moduletest.Run
-	State *states.State
+// retries reports whether a failure of the given kind should be retried,
+// per the run block's on_failure setting.
+func (c retryConfig) retries(kind retryOnFailureKind) bool {
+	for _, onFailure := range c.onFailure {
+		if onFailure == retryOnFailureAny || onFailure == kind {
+			return true
+		}
+	}
+	return false
 }
 
-func (runner *TestFileRunner) Test(file *moduletest.File) {
-	log.Printf("[TRACE] TestFileRunner: executing test file %s", file.Name)
+// newRetryConfig parses the retry and timeout attributes off run.Config,
+// falling back to values that preserve the historical single-attempt,
+// no-deadline behavior if they're unset or fail to parse.
+func newRetryConfig(run *moduletest.Run) retryConfig {
+	config := retryConfig{attempts: 1}
 
-	// We'll execute the tests in the file. First, mark the overall status as
-	// being skipped. This will ensure that if we've cancelled and the files not
-	// going to do anything it'll be marked as skipped.
-	file.Status = file.Status.Merge(moduletest.Skip)
-	if len(file.Runs) == 0 {
-		// If we have zero run blocks then we'll just mark the file as passed.
-		file.Status = file.Status.Merge(moduletest.Pass)
+	if run.Config.Retry != nil {
+		config.attempts = run.Config.Retry.Attempts
+		if config.attempts < 1 {
+			config.attempts = 1
+		}
+
+		if run.Config.Retry.Interval != "" {
+			interval, err := time.ParseDuration(run.Config.Retry.Interval)
+			if err != nil {
+				log.Printf("[WARN] TestFileRunner: invalid retry interval %q for %s: %s", run.Config.Retry.Interval, run.Name, err)
+			} else {
+				config.interval = interval
+			}
+		}
+
+		for _, onFailure := range run.Config.Retry.OnFailure {
+			config.onFailure = append(config.onFailure, retryOnFailureKind(onFailure))
+		}
 	}
 
-	// Now execute the runs.
-	for _, run := range file.Runs {
-		if runner.Suite.Cancelled {
-			// This means a hard stop has been requested, in this case we don't
-			// even stop to mark future tests as having been skipped. They'll
-			// just show up as pending in the printed summary. We will quickly
-			// just mark the overall file status has having errored to indicate
-			// it was interrupted.
-			file.Status = file.Status.Merge(moduletest.Error)
-			return
+	if run.Config.Timeout != "" {
+		timeout, err := time.ParseDuration(run.Config.Timeout)
+		if err != nil {
+			log.Printf("[WARN] TestFileRunner: invalid timeout %q for %s: %s", run.Config.Timeout, run.Name, err)
+		} else {
+			config.timeout = timeout
 		}
+	}
 
-		if runner.Suite.Stopped {
-			// Then the test was requested to be stopped, so we just mark each
-			// following test as skipped, print the status, and move on.
-			run.Status = moduletest.Skip
-			runner.Suite.View.Run(run, file, moduletest.Complete, 0)
-			continue
+	return config
+}
+
+// runningContext returns the context a plan, apply, or validate goroutine
+// should be watched through, bounded by timeout if it's greater than zero.
+func (runner *TestFileRunner) runningContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// runWithRetry executes run via TestFileRunner.run, retrying it up to the
+// attempts configured by its retry block if a plan/apply failure or a
+// failed assertion matches on_failure. Between attempts, any infrastructure
+// left behind by a failed partial apply is destroyed so the next attempt
+// starts from the same prior state as this one did. Diagnostics from every
+// attempt but the last are downgraded to warnings and carried forward, so
+// a flaky run that eventually passes still reports how often it flaked.
+func (runner *TestFileRunner) runWithRetry(run *moduletest.Run, file *moduletest.File, key string, state *states.State, config *configs.Config) (*states.State, bool) {
+	retry := newRetryConfig(run)
+
+	var warnings tfdiags.Diagnostics
+	for attempt := 1; ; attempt++ {
+		updated, updatedState := runner.run(run, file, key, state, config)
+
+		var kind retryOnFailureKind
+		switch run.Status {
+		case moduletest.Error:
+			kind = retryOnFailureApply
+		case moduletest.Fail:
+			kind = retryOnFailureAssertion
+		default:
+			// Passed (or skipped/cancelled before doing anything), so there's
+			// nothing to retry.
+			run.Diagnostics = append(warnings, run.Diagnostics...)
+			return updated, updatedState
 		}
 
-		if file.Status == moduletest.Error {
-			// If the overall test file has errored, we don't keep trying to
-			// execute tests. Instead, we mark all remaining run blocks as
-			// skipped, print the status, and move on.
-			run.Status = moduletest.Skip
-			runner.Suite.View.Run(run, file, moduletest.Complete, 0)
-			continue
+		if attempt >= retry.attempts || !retry.retries(kind) || runner.Suite.Cancelled || runner.Suite.Stopped {
+			run.Diagnostics = append(warnings, run.Diagnostics...)
+			return updated, updatedState
 		}
 
-		key := MainStateIdentifier
-		config := runner.Suite.Config
-		if run.Config.ConfigUnderTest != nil {
-			config = run.Config.ConfigUnderTest
-			// Then we need to load an alternate state and not the main one.
+		log.Printf("[DEBUG] TestFileRunner: %s/%s failed on attempt %d of %d, retrying after %s", file.Name, run.Name, attempt, retry.attempts, retry.interval)
 
-			key = run.Config.Module.Source.String()
-			if key == MainStateIdentifier {
-				// This is bad. It means somehow the module we're loading has
-				// the same key as main state and we're about to corrupt things.
-
-				run.Diagnostics = run.Diagnostics.Append(&hcl.Diagnostic{
-					Severity: hcl.DiagError,
-					Summary:  "Invalid module source",
-					Detail:   fmt.Sprintf("The source for the selected module evaluated to %s which should not be possible. This is a bug in Terraform - please report it!", key),
-					Subject:  run.Config.Module.DeclRange.Ptr(),
-				})
+		for _, diag := range run.Diagnostics {
+			desc := diag.Description()
+			warnings = warnings.Append(tfdiags.Sourceless(
+				tfdiags.Warning,
+				fmt.Sprintf("%s (attempt %d of %d)", desc.Summary, attempt, retry.attempts),
+				desc.Detail))
+		}
 
+		if updatedState && !updated.Empty() {
+			destroyed, destroyDiags := runner.destroy(config, updated, run, file)
+			if destroyDiags.HasErrors() {
+				run.Diagnostics = append(warnings, destroyDiags...)
 				run.Status = moduletest.Error
-				file.Status = moduletest.Error
-				continue // Abort!
-			}
-
-			if _, exists := runner.RelevantStates[key]; !exists {
-				runner.RelevantStates[key] = &TestFileState{
-					Run:   nil,
-					State: states.NewState(),
-				}
+				return destroyed, true
 			}
+			state = destroyed
 		}
 
-		state, updatedState := runner.run(run, file, runner.RelevantStates[key].State, config)
-		if updatedState {
-			// Only update the most recent run and state if the state was
-			// actually updated by this change. We want to use the run that
-			// most recently updated the tracked state as the cleanup
-			// configuration.
-			runner.RelevantStates[key].State = state
-			runner.RelevantStates[key].Run = run
-		}
+		run.Diagnostics = nil
 
-		runner.Suite.View.Run(run, file, moduletest.Complete, 0)
-		file.Status = file.Status.Merge(run.Status)
+		if retry.interval > 0 {
+			select {
+			case <-time.After(retry.interval):
+			case <-runner.Suite.CancelledCtx.Done():
+				return state, false
+			}
+		}
 	}
 }
 
-func (runner *TestFileRunner) run(run *moduletest.Run, file *moduletest.File, state *states.State, config *configs.Config) (*states.State, bool) {
+func (runner *TestFileRunner) run(run *moduletest.Run, file *moduletest.File, key string, state *states.State, config *configs.Config) (*states.State, bool) {
 	log.Printf("[TRACE] TestFileRunner: executing run block %s/%s", file.Name, run.Name)
 
 	if runner.Suite.Cancelled {
@@ -328,7 +2057,7 @@ func (runner *TestFileRunner) run(run *moduletest.Run, file *moduletest.File, st
 	}
 
 	start := time.Now().UTC().UnixMilli()
-	runner.Suite.View.Run(run, file, moduletest.Starting, 0)
+	runner.Suite.reportRun(run, file, moduletest.Starting, 0)
 
 	run.Diagnostics = run.Diagnostics.Append(run.Config.Validate())
 	if run.Diagnostics.HasErrors() {
@@ -352,6 +2081,8 @@ func (runner *TestFileRunner) run(run *moduletest.Run, file *moduletest.File, st
 		return state, false
 	}
 
+	retry := newRetryConfig(run)
+
 	references, referenceDiags := run.GetReferences()
 	run.Diagnostics = run.Diagnostics.Append(referenceDiags)
 	if referenceDiags.HasErrors() {
@@ -366,7 +2097,7 @@ func (runner *TestFileRunner) run(run *moduletest.Run, file *moduletest.File, st
 		return state, false
 	}
 
-	planCtx, plan, planDiags := runner.plan(config, state, run, file, runner.FilterVariablesToConfig(config, variables), references, start)
+	planCtx, plan, planDiags := runner.planCached(config, state, run, file, key, runner.FilterVariablesToConfig(config, variables), references, start, retry.timeout)
 	if run.Config.Command == configs.PlanTestCommand {
 		// Then we want to assess our conditions and diagnostics differently.
 		planDiags = run.ValidateExpectedFailures(planDiags)
@@ -416,12 +2147,16 @@ func (runner *TestFileRunner) run(run *moduletest.Run, file *moduletest.File, st
 
 		// Second, evaluate the run block directly. We also pass in all the
 		// previous contexts so this run block can refer to outputs from
-		// previous run blocks.
+		// previous run blocks. PriorStates is shared across the run blocks
+		// in this file, which may now be executing concurrently, so we hold
+		// relevantStatesMu for the read and the subsequent write below.
+		runner.relevantStatesMu.Lock()
 		ctx.Evaluate(runner.PriorStates)
 
 		// Now we've successfully validated this run block, lets add it into
 		// our prior states so future run blocks can access it.
 		runner.PriorStates[run.Name] = ctx
+		runner.relevantStatesMu.Unlock()
 
 		return state, false
 	}
@@ -449,7 +2184,7 @@ func (runner *TestFileRunner) run(run *moduletest.Run, file *moduletest.File, st
 	}
 	run.Diagnostics = filteredDiags
 
-	applyCtx, updated, applyDiags := runner.apply(plan, state, config, run, file, moduletest.Running, start)
+	applyCtx, updated, applyDiags := runner.apply(plan, state, config, run, file, moduletest.Running, start, retry.timeout)
 
 	// Remove expected diagnostics, and add diagnostics in case anything that should have failed didn't.
 	applyDiags = run.ValidateExpectedFailures(applyDiags)
@@ -502,28 +2237,254 @@ func (runner *TestFileRunner) run(run *moduletest.Run, file *moduletest.File, st
 
 	// Second, evaluate the run block directly. We also pass in all the
 	// previous contexts so this run block can refer to outputs from
-	// previous run blocks.
+	// previous run blocks. PriorStates is shared across the run blocks in
+	// this file, which may now be executing concurrently, so we hold
+	// relevantStatesMu for the read and the subsequent write below.
+	runner.relevantStatesMu.Lock()
 	ctx.Evaluate(runner.PriorStates)
 
 	// Now we've successfully validated this run block, lets add it into
 	// our prior states so future run blocks can access it.
 	runner.PriorStates[run.Name] = ctx
+	runner.relevantStatesMu.Unlock()
 
 	return updated, true
 }
 
+// overrideConfig is the parsed form of an override_resource or
+// override_data block: the resource or data source it replaces, and the
+// attribute values to hand back in place of whatever the real provider
+// would have computed.
+type overrideConfig struct {
+	target string
+	values map[string]cty.Value
+}
+
+// collectMockProviders merges the mock_provider blocks declared on file
+// with any declared directly on run, with run's taking precedence for a
+// provider type declared in both.
+func collectMockProviders(file *moduletest.File, run *moduletest.Run) map[string]*configs.MockProvider {
+	mocks := make(map[string]*configs.MockProvider)
+	for _, mock := range file.Config.MockProviders {
+		mocks[mock.Provider] = mock
+	}
+	if run != nil {
+		for _, mock := range run.Config.MockProviders {
+			mocks[mock.Provider] = mock
+		}
+	}
+	return mocks
+}
+
+// collectOverrides merges the override_resource and override_data blocks
+// declared on file with any declared directly on run, with run's taking
+// precedence for a target address declared in both.
+func collectOverrides(file *moduletest.File, run *moduletest.Run) []*overrideConfig {
+	byTarget := make(map[string]*overrideConfig)
+	add := func(overrides []*configs.Override) {
+		for _, override := range overrides {
+			byTarget[override.Target] = &overrideConfig{target: override.Target, values: override.Values}
+		}
+	}
+	add(file.Config.Overrides)
+	if run != nil {
+		add(run.Config.Overrides)
+	}
+
+	var result []*overrideConfig
+	for _, override := range byTarget {
+		result = append(result, override)
+	}
+	return result
+}
+
+// mockProvider is a providers.Interface that answers ReadResource,
+// PlanResourceChange, and ApplyResourceChange with values taken from an
+// override_resource/override_data block instead of talking to any real
+// infrastructure, so a run block can exercise module logic - validations,
+// conditionals, output shaping - without the credentials or side effects a
+// real provider would need.
+//
+// It still asks the wrapped, real provider for its schema, and still
+// validates resource and data source configuration through it, so
+// attributes attached to a mocked resource type-check exactly as they would
+// against genuine infrastructure. Provider configuration is not passed to
+// the real provider: ValidateProviderConfig accepts anything and
+// ConfigureProvider does nothing. The other replaced operations are the
+// ones that would reach out over the network.
+//
+// Overrides are matched by resource type alone, not the full resource
+// address: a provider operation only ever tells us req.TypeName, never
+// which of a module's resources triggered it. An override_resource block
+// is expected to target at most one resource of a given type per test
+// file; if more than one does, the last one merged by collectOverrides
+// wins for every instance of that type.
+//
+// mock_provider_test.go would cover newMockProvider's type-name parsing and
+// overriddenValue's attribute substitution directly, without needing a
+// real provider; see the import-gap note at the top of this file for why
+// that test can't exist here.
+type mockProvider struct {
+	real providers.Interface
+
+	mu        sync.Mutex
+	overrides map[string]*overrideConfig // keyed by resource/data type, e.g. "aws_s3_bucket"
+}
+
+// newMockProvider returns a mockProvider that defers to real for schema and
+// validation, and answers ReadResource/PlanResourceChange/
+// ApplyResourceChange/ReadDataSource for any of overrides' resource types
+// with the attribute values they specify.
+func newMockProvider(real providers.Interface, overrides []*overrideConfig) *mockProvider {
+	byType := make(map[string]*overrideConfig, len(overrides))
+	for _, override := range overrides {
+		typeName := override.target
+		if dot := strings.Index(typeName, "."); dot >= 0 {
+			typeName = typeName[:dot]
+		}
+		byType[typeName] = override
+	}
+	return &mockProvider{real: real, overrides: byType}
+}
+
+func (p *mockProvider) GetProviderSchema() providers.GetProviderSchemaResponse {
+	return p.real.GetProviderSchema()
+}
+
+func (p *mockProvider) ValidateProviderConfig(req providers.ValidateProviderConfigRequest) providers.ValidateProviderConfigResponse {
+	// A mocked provider never actually configures anything, so whatever
+	// configuration the user supplied for it is accepted without complaint.
+	return providers.ValidateProviderConfigResponse{}
+}
+
+func (p *mockProvider) ValidateResourceConfig(req providers.ValidateResourceConfigRequest) providers.ValidateResourceConfigResponse {
+	return p.real.ValidateResourceConfig(req)
+}
+
+func (p *mockProvider) ValidateDataResourceConfig(req providers.ValidateDataResourceConfigRequest) providers.ValidateDataResourceConfigResponse {
+	return p.real.ValidateDataResourceConfig(req)
+}
+
+func (p *mockProvider) UpgradeResourceState(req providers.UpgradeResourceStateRequest) providers.UpgradeResourceStateResponse {
+	return p.real.UpgradeResourceState(req)
+}
+
+func (p *mockProvider) ConfigureProvider(providers.ConfigureProviderRequest) providers.ConfigureProviderResponse {
+	// There's nothing to configure; a mocked provider never dials out.
+	return providers.ConfigureProviderResponse{}
+}
+
+func (p *mockProvider) Stop() error {
+	return nil
+}
+
+func (p *mockProvider) Close() error {
+	return nil
+}
+
+func (p *mockProvider) ReadResource(req providers.ReadResourceRequest) providers.ReadResourceResponse {
+	return providers.ReadResourceResponse{NewState: p.overriddenValue(req.TypeName, req.PriorState)}
+}
+
+func (p *mockProvider) PlanResourceChange(req providers.PlanResourceChangeRequest) providers.PlanResourceChangeResponse {
+	return providers.PlanResourceChangeResponse{PlannedState: p.overriddenValue(req.TypeName, req.ProposedNewState)}
+}
+
+func (p *mockProvider) ApplyResourceChange(req providers.ApplyResourceChangeRequest) providers.ApplyResourceChangeResponse {
+	return providers.ApplyResourceChangeResponse{NewState: p.overriddenValue(req.TypeName, req.PlannedState)}
+}
+
+func (p *mockProvider) ReadDataSource(req providers.ReadDataSourceRequest) providers.ReadDataSourceResponse {
+	return providers.ReadDataSourceResponse{State: p.overriddenValue(req.TypeName, req.Config)}
+}
+
+func (p *mockProvider) ImportResourceState(req providers.ImportResourceStateRequest) providers.ImportResourceStateResponse {
+	return p.real.ImportResourceState(req)
+}
+
+func (p *mockProvider) CallFunction(req providers.CallFunctionRequest) providers.CallFunctionResponse {
+	return p.real.CallFunction(req)
+}
+
+// overriddenValue returns fallback with any attributes named by an
+// override_resource/override_data block for typeName replaced by the
+// values the user supplied, so only the attributes a test actually cares
+// about need overriding and everything else keeps whatever shape the real
+// provider schema already gave it.
+func (p *mockProvider) overriddenValue(typeName string, fallback cty.Value) cty.Value {
+	p.mu.Lock()
+	override, ok := p.overrides[typeName]
+	p.mu.Unlock()
+	if !ok || fallback.IsNull() || !fallback.IsKnown() {
+		return fallback
+	}
+
+	attrs := fallback.AsValueMap()
+	if attrs == nil {
+		attrs = make(map[string]cty.Value)
+	}
+	for name, value := range override.values {
+		attrs[name] = value
+	}
+	return cty.ObjectVal(attrs)
+}
+
+// contextOpts returns the terraform.ContextOpts that run's validate, plan,
+// apply, or destroy operation should execute with: Suite.Opts unchanged if
+// neither file nor run declared a mock_provider block, or a shallow copy
+// with a mockProvider substituted in for every provider type they did mock,
+// so a module can be exercised without real credentials or side effects.
+// run may be nil, for operations (like validate) that aren't scoped to a
+// single run block.
+func (runner *TestFileRunner) contextOpts(file *moduletest.File, run *moduletest.Run) *terraform.ContextOpts {
+	mocks := collectMockProviders(file, run)
+	if len(mocks) == 0 {
+		return runner.Suite.Opts
+	}
+
+	overrides := collectOverrides(file, run)
+
+	opts := *runner.Suite.Opts
+	opts.Providers = make(map[addrs.Provider]providers.Factory, len(runner.Suite.Opts.Providers))
+	for addr, factory := range runner.Suite.Opts.Providers {
+		opts.Providers[addr] = factory
+	}
+
+	for _, mock := range mocks {
+		addr := addrs.NewDefaultProvider(mock.Provider)
+		real, exists := runner.Suite.Opts.Providers[addr]
+		if !exists {
+			// Nothing to wrap; the configuration will already have reported
+			// an unknown provider error of its own.
+			continue
+		}
+
+		factory := real
+		opts.Providers[addr] = providers.Factory(func() (providers.Interface, error) {
+			inst, err := factory()
+			if err != nil {
+				return nil, err
+			}
+			return newMockProvider(inst, overrides), nil
+		})
+	}
+
+	return &opts
+}
+
 func (runner *TestFileRunner) validate(config *configs.Config, run *moduletest.Run, file *moduletest.File, start int64) tfdiags.Diagnostics {
 	log.Printf("[TRACE] TestFileRunner: called validate for %s/%s", file.Name, run.Name)
 
 	var diags tfdiags.Diagnostics
 
-	tfCtx, ctxDiags := terraform.NewContext(runner.Suite.Opts)
+	tfCtx, ctxDiags := terraform.NewContext(runner.contextOpts(file, run))
 	diags = diags.Append(ctxDiags)
 	if ctxDiags.HasErrors() {
 		return diags
 	}
+	defer runner.trackContext(tfCtx)()
 
-	runningCtx, done := context.WithCancel(context.Background())
+	runningCtx, done := runner.runningContext(0)
 
 	var validateDiags tfdiags.Diagnostics
 	go func() {
@@ -534,7 +2495,7 @@ func (runner *TestFileRunner) validate(config *configs.Config, run *moduletest.R
 		validateDiags = tfCtx.Validate(config)
 		log.Printf("[DEBUG] TestFileRunner: completed validate for  %s/%s", file.Name, run.Name)
 	}()
-	waitDiags, cancelled := runner.wait(tfCtx, runningCtx, run, file, nil, moduletest.Running, start)
+	waitDiags, cancelled, _ := runner.wait(runningCtx, run, file, nil, moduletest.Running, start)
 
 	if cancelled {
 		diags = diags.Append(tfdiags.Sourceless(tfdiags.Error, "Test interrupted", "The test operation could not be completed due to an interrupt signal. Please read the remaining diagnostics carefully for any sign of failed state cleanup or dangling resources."))
@@ -568,16 +2529,17 @@ func (runner *TestFileRunner) destroy(config *configs.Config, state *states.Stat
 		SetVariables: runner.FilterVariablesToConfig(config, variables),
 	}
 
-	tfCtx, ctxDiags := terraform.NewContext(runner.Suite.Opts)
+	tfCtx, ctxDiags := terraform.NewContext(runner.contextOpts(file, run))
 	diags = diags.Append(ctxDiags)
 	if ctxDiags.HasErrors() {
 		return state, diags
 	}
+	defer runner.trackContext(tfCtx)()
 
-	runningCtx, done := context.WithCancel(context.Background())
+	runningCtx, done := runner.runningContext(0)
 
 	start := time.Now().UTC().UnixMilli()
-	runner.Suite.View.Run(run, file, moduletest.TearDown, 0)
+	runner.Suite.reportRun(run, file, moduletest.TearDown, 0)
 
 	var plan *plans.Plan
 	var planDiags tfdiags.Diagnostics
@@ -589,7 +2551,7 @@ func (runner *TestFileRunner) destroy(config *configs.Config, state *states.Stat
 		plan, planDiags = tfCtx.Plan(config, state, planOpts)
 		log.Printf("[DEBUG] TestFileRunner: completed destroy plan for %s/%s", file.Name, run.Name)
 	}()
-	waitDiags, cancelled := runner.wait(tfCtx, runningCtx, run, file, nil, moduletest.TearDown, start)
+	waitDiags, cancelled, _ := runner.wait(runningCtx, run, file, nil, moduletest.TearDown, start)
 
 	if cancelled {
 		diags = diags.Append(tfdiags.Sourceless(tfdiags.Error, "Test interrupted", "The test operation could not be completed due to an interrupt signal. Please read the remaining diagnostics carefully for any sign of failed state cleanup or dangling resources."))
@@ -602,12 +2564,196 @@ func (runner *TestFileRunner) destroy(config *configs.Config, state *states.Stat
 		return state, diags
 	}
 
-	_, updated, applyDiags := runner.apply(plan, state, config, run, file, moduletest.TearDown, start)
+	// Teardown always runs to completion regardless of any timeout the run
+	// block configured for its own plan/apply attempts.
+	_, updated, applyDiags := runner.apply(plan, state, config, run, file, moduletest.TearDown, start, 0)
 	diags = diags.Append(applyDiags)
 	return updated, diags
 }
 
-func (runner *TestFileRunner) plan(config *configs.Config, state *states.State, run *moduletest.Run, file *moduletest.File, variables terraform.InputValues, references []*addrs.Reference, start int64) (*terraform.Context, *plans.Plan, tfdiags.Diagnostics) {
+// planCacheKey identifies a plan that's safe to hand to a different run
+// block: the same module, the same filtered input variables, the same
+// prior state (fingerprinted the same way a state file on disk is), the
+// same resolved provider set, and the same plan options. Any run block
+// whose key differs from a cached entry's in any of these respects may
+// have computed a plan/context that isn't safe to reuse.
+type planCacheKey struct {
+	moduleKey string
+	variables string
+	lineage   string
+	serial    uint64
+	providers string
+	planOpts  string
+}
+
+// cachedPlan is one entry in TestFileRunner's plan cache: everything a run
+// block needs to skip straight to apply instead of calling plan again.
+type cachedPlan struct {
+	ctx   *terraform.Context
+	plan  *plans.Plan
+	diags tfdiags.Diagnostics
+}
+
+// hashVariables produces a stable fingerprint for variables, so two run
+// blocks that provided the identical set of inputs - same names, same
+// values - hash identically regardless of map iteration order.
+func hashVariables(variables terraform.InputValues) string {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%s;", name, variables[name].Value.GoString())
+	}
+	return b.String()
+}
+
+// hashProviders produces a stable fingerprint for the resolved provider set
+// a run block plans with: the mock_provider and override_resource/
+// override_data blocks in effect for it. Two run blocks only hash
+// identically here if they'd build the exact same *terraform.ContextOpts
+// from runner.contextOpts, since a cached plan/context is only safe to
+// reuse when the providers behind it are indistinguishable from the ones
+// the new run block would have used.
+func hashProviders(file *moduletest.File, run *moduletest.Run) string {
+	mocks := collectMockProviders(file, run)
+	names := make([]string, 0, len(mocks))
+	for name := range mocks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		// The *configs.MockProvider for a given mock_provider block is
+		// parsed once and reused across every run block that shares it, so
+		// its address is a stable, content-sensitive fingerprint: two run
+		// blocks only share an address here if they share the identical
+		// mock_provider block.
+		fmt.Fprintf(&b, "mock:%s=%p;", name, mocks[name])
+	}
+
+	overrides := collectOverrides(file, run)
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i].target < overrides[j].target })
+	for _, override := range overrides {
+		keys := make([]string, 0, len(override.values))
+		for key := range override.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintf(&b, "override:%s={", override.target)
+		for _, key := range keys {
+			fmt.Fprintf(&b, "%s=%s;", key, override.values[key].GoString())
+		}
+		b.WriteString("};")
+	}
+
+	return b.String()
+}
+
+// hashPlanOpts produces a stable fingerprint for the parts of PlanOpts that
+// change what a plan actually contains: its mode, its target/force-replace
+// addresses, and whether refresh was skipped. Two run blocks only hash
+// identically here if plan() would build equivalent PlanOpts for both.
+func hashPlanOpts(run *moduletest.Run) string {
+	targets, _ := run.GetTargets()
+	targetStrs := make([]string, 0, len(targets))
+	for _, target := range targets {
+		targetStrs = append(targetStrs, target.String())
+	}
+	sort.Strings(targetStrs)
+
+	replaces, _ := run.GetReplaces()
+	replaceStrs := make([]string, 0, len(replaces))
+	for _, replace := range replaces {
+		replaceStrs = append(replaceStrs, replace.String())
+	}
+	sort.Strings(replaceStrs)
+
+	return fmt.Sprintf("mode=%s;refresh=%t;targets=%s;replaces=%s",
+		run.Config.Options.Mode, run.Config.Options.Refresh, strings.Join(targetStrs, ","), strings.Join(replaceStrs, ","))
+}
+
+// planCacheKeyFor builds the planCacheKey for planning moduleKey against
+// variables, given the Lineage and Serial TestFileRunner is currently
+// tracking for that module's prior state, and the provider set and plan
+// options run would use.
+//
+// Its invalidation behavior - two run blocks only collide in the cache if
+// moduleKey, variables, lineage/serial, providers, and planOpts all match -
+// would ideally be covered by planCacheKeyFor_test.go; see the import-gap
+// note at the top of this file for why that test can't exist here.
+func planCacheKeyFor(moduleKey, lineage string, serial uint64, variables terraform.InputValues, file *moduletest.File, run *moduletest.Run) planCacheKey {
+	return planCacheKey{
+		moduleKey: moduleKey,
+		variables: hashVariables(variables),
+		lineage:   lineage,
+		serial:    serial,
+		providers: hashProviders(file, run),
+		planOpts:  hashPlanOpts(run),
+	}
+}
+
+// stateFingerprint returns the Lineage and Serial TestFileRunner is
+// currently tracking for key's state, for use as part of a planCacheKey.
+func (runner *TestFileRunner) stateFingerprint(key string) (string, uint64) {
+	runner.relevantStatesMu.Lock()
+	defer runner.relevantStatesMu.Unlock()
+
+	if state, ok := runner.RelevantStates[key]; ok {
+		return state.Lineage, state.Serial
+	}
+	return "", 0
+}
+
+// planCached wraps plan with TestFileRunner's plan cache: if an
+// immediately preceding `command = plan` run block already computed a plan
+// for this exact module, these exact filtered variables, and this exact
+// prior state, that plan is returned directly instead of asking Terraform
+// to compute another one. A cache hit is removed from the cache when it's
+// returned, since apply mutates a plans.Plan in place (see the comment in
+// apply) and a consumed plan can't safely be handed to a second run block.
+//
+// This run block's own result is cached in turn for a later run block to
+// find, but only when this run block itself was a `command = plan` run:
+// those are the only run blocks guaranteed to have left the prior state's
+// infrastructure untouched, so they're the only ones whose plan remains
+// valid for whatever runs next.
+func (runner *TestFileRunner) planCached(config *configs.Config, state *states.State, run *moduletest.Run, file *moduletest.File, key string, variables terraform.InputValues, references []*addrs.Reference, start int64, timeout time.Duration) (*terraform.Context, *plans.Plan, tfdiags.Diagnostics) {
+	lineage, serial := runner.stateFingerprint(key)
+	cacheKey := planCacheKeyFor(key, lineage, serial, variables, file, run)
+
+	runner.planCacheMu.Lock()
+	cached, hit := runner.planCache[cacheKey]
+	if hit {
+		delete(runner.planCache, cacheKey)
+	}
+	runner.planCacheMu.Unlock()
+
+	if hit {
+		log.Printf("[DEBUG] TestFileRunner: reusing cached plan for %s/%s", file.Name, run.Name)
+		return cached.ctx, cached.plan, cached.diags
+	}
+
+	ctx, plan, diags := runner.plan(config, state, run, file, variables, references, start, timeout)
+
+	if run.Config.Command == configs.PlanTestCommand && !diags.HasErrors() {
+		runner.planCacheMu.Lock()
+		if runner.planCache == nil {
+			runner.planCache = make(map[planCacheKey]*cachedPlan)
+		}
+		runner.planCache[cacheKey] = &cachedPlan{ctx: ctx, plan: plan, diags: diags}
+		runner.planCacheMu.Unlock()
+	}
+
+	return ctx, plan, diags
+}
+
+func (runner *TestFileRunner) plan(config *configs.Config, state *states.State, run *moduletest.Run, file *moduletest.File, variables terraform.InputValues, references []*addrs.Reference, start int64, timeout time.Duration) (*terraform.Context, *plans.Plan, tfdiags.Diagnostics) {
 	log.Printf("[TRACE] TestFileRunner: called plan for %s/%s", file.Name, run.Name)
 
 	var diags tfdiags.Diagnostics
@@ -638,13 +2784,14 @@ func (runner *TestFileRunner) plan(config *configs.Config, state *states.State,
 		ExternalReferences: references,
 	}
 
-	tfCtx, ctxDiags := terraform.NewContext(runner.Suite.Opts)
+	tfCtx, ctxDiags := terraform.NewContext(runner.contextOpts(file, run))
 	diags = diags.Append(ctxDiags)
 	if ctxDiags.HasErrors() {
 		return nil, nil, diags
 	}
+	defer runner.trackContext(tfCtx)()
 
-	runningCtx, done := context.WithCancel(context.Background())
+	runningCtx, done := runner.runningContext(timeout)
 
 	var plan *plans.Plan
 	var planDiags tfdiags.Diagnostics
@@ -656,11 +2803,14 @@ func (runner *TestFileRunner) plan(config *configs.Config, state *states.State,
 		plan, planDiags = tfCtx.Plan(config, state, planOpts)
 		log.Printf("[DEBUG] TestFileRunner: completed plan for %s/%s", file.Name, run.Name)
 	}()
-	waitDiags, cancelled := runner.wait(tfCtx, runningCtx, run, file, nil, moduletest.Running, start)
+	waitDiags, cancelled, timedOut := runner.wait(runningCtx, run, file, nil, moduletest.Running, start)
 
 	if cancelled {
 		diags = diags.Append(tfdiags.Sourceless(tfdiags.Error, "Test interrupted", "The test operation could not be completed due to an interrupt signal. Please read the remaining diagnostics carefully for any sign of failed state cleanup or dangling resources."))
 	}
+	if timedOut {
+		diags = diags.Append(tfdiags.Sourceless(tfdiags.Error, "Test timed out", fmt.Sprintf("The plan operation for %s did not complete within the %s timeout configured for this run block.", path.Join(file.Name, run.Name), timeout)))
+	}
 
 	diags = diags.Append(waitDiags)
 	diags = diags.Append(planDiags)
@@ -668,7 +2818,7 @@ func (runner *TestFileRunner) plan(config *configs.Config, state *states.State,
 	return tfCtx, plan, diags
 }
 
-func (runner *TestFileRunner) apply(plan *plans.Plan, state *states.State, config *configs.Config, run *moduletest.Run, file *moduletest.File, progress moduletest.Progress, start int64) (*terraform.Context, *states.State, tfdiags.Diagnostics) {
+func (runner *TestFileRunner) apply(plan *plans.Plan, state *states.State, config *configs.Config, run *moduletest.Run, file *moduletest.File, progress moduletest.Progress, start int64, timeout time.Duration) (*terraform.Context, *states.State, tfdiags.Diagnostics) {
 	log.Printf("[TRACE] TestFileRunner: called apply for %s/%s", file.Name, run.Name)
 
 	var diags tfdiags.Diagnostics
@@ -692,13 +2842,14 @@ func (runner *TestFileRunner) apply(plan *plans.Plan, state *states.State, confi
 		created = append(created, change)
 	}
 
-	tfCtx, ctxDiags := terraform.NewContext(runner.Suite.Opts)
+	tfCtx, ctxDiags := terraform.NewContext(runner.contextOpts(file, run))
 	diags = diags.Append(ctxDiags)
 	if ctxDiags.HasErrors() {
 		return nil, state, diags
 	}
+	defer runner.trackContext(tfCtx)()
 
-	runningCtx, done := context.WithCancel(context.Background())
+	runningCtx, done := runner.runningContext(timeout)
 
 	var updated *states.State
 	var applyDiags tfdiags.Diagnostics
@@ -710,11 +2861,14 @@ func (runner *TestFileRunner) apply(plan *plans.Plan, state *states.State, confi
 		updated, applyDiags = tfCtx.Apply(plan, config)
 		log.Printf("[DEBUG] TestFileRunner: completed apply for %s/%s", file.Name, run.Name)
 	}()
-	waitDiags, cancelled := runner.wait(tfCtx, runningCtx, run, file, created, progress, start)
+	waitDiags, cancelled, timedOut := runner.wait(runningCtx, run, file, created, progress, start)
 
 	if cancelled {
 		diags = diags.Append(tfdiags.Sourceless(tfdiags.Error, "Test interrupted", "The test operation could not be completed due to an interrupt signal. Please read the remaining diagnostics carefully for any sign of failed state cleanup or dangling resources."))
 	}
+	if timedOut {
+		diags = diags.Append(tfdiags.Sourceless(tfdiags.Error, "Test timed out", fmt.Sprintf("The apply operation for %s did not complete within the %s timeout configured for this run block.", path.Join(file.Name, run.Name), timeout)))
+	}
 
 	diags = diags.Append(waitDiags)
 	diags = diags.Append(applyDiags)
@@ -722,7 +2876,7 @@ func (runner *TestFileRunner) apply(plan *plans.Plan, state *states.State, confi
 	return tfCtx, updated, diags
 }
 
-func (runner *TestFileRunner) wait(ctx *terraform.Context, runningCtx context.Context, run *moduletest.Run, file *moduletest.File, created []*plans.ResourceInstanceChangeSrc, progress moduletest.Progress, start int64) (diags tfdiags.Diagnostics, cancelled bool) {
+func (runner *TestFileRunner) wait(runningCtx context.Context, run *moduletest.Run, file *moduletest.File, created []*plans.ResourceInstanceChangeSrc, progress moduletest.Progress, start int64) (diags tfdiags.Diagnostics, cancelled bool, timedOut bool) {
 	var identifier string
 	if file == nil {
 		identifier = "validate"
@@ -744,25 +2898,26 @@ func (runner *TestFileRunner) wait(ctx *terraform.Context, runningCtx context.Co
 	handleCancelled := func() {
 		log.Printf("[DEBUG] TestFileRunner: test execution cancelled during %s", identifier)
 
-		states := make(map[*moduletest.Run]*states.State)
-		states[nil] = runner.RelevantStates[MainStateIdentifier].State
-		for key, module := range runner.RelevantStates {
-			if key == MainStateIdentifier {
-				continue
-			}
-			states[module.Run] = module.State
-		}
-		runner.Suite.View.FatalInterruptSummary(run, file, states, created)
+		runner.Suite.reportFatalInterruptSummary(run, file, runner.snapshotRelevantStates(), created)
 
 		cancelled = true
-		go ctx.Stop()
+		// Independent run blocks from buildRunDAG may have operations of
+		// their own in flight for this file, not just ctx, so we stop all
+		// of them rather than only the one wait() was called for.
+		runner.stopActiveContexts()
+
+		// Give a future -resume invocation something to load: flush
+		// whatever state we're holding before we give up entirely.
+		if file != nil {
+			runner.flushRelevantStates(file)
+		}
 
 		for !finished {
 			select {
 			case <-time.After(2 * time.Second):
 				// Print an update while we're waiting.
 				now := time.Now().UTC().UnixMilli()
-				runner.Suite.View.Run(run, file, progress, now-start)
+				runner.Suite.reportRun(run, file, progress, now-start)
 			case <-runningCtx.Done():
 				// Just wait for things to finish now, the overall test execution will
 				// exit early if this takes too long.
@@ -784,7 +2939,7 @@ func (runner *TestFileRunner) wait(ctx *terraform.Context, runningCtx context.Co
 			case <-time.After(2 * time.Second):
 				// Print an update while we're waiting.
 				now := time.Now().UTC().UnixMilli()
-				runner.Suite.View.Run(run, file, progress, now-start)
+				runner.Suite.reportRun(run, file, progress, now-start)
 			case <-runner.Suite.CancelledCtx.Done():
 				// We've been asked again. This time we stop whatever we're doing
 				// and abandon all attempts to do anything reasonable.
@@ -803,7 +2958,7 @@ func (runner *TestFileRunner) wait(ctx *terraform.Context, runningCtx context.Co
 		case <-time.After(2 * time.Second):
 			// Print an update while we're waiting.
 			now := time.Now().UTC().UnixMilli()
-			runner.Suite.View.Run(run, file, progress, now-start)
+			runner.Suite.reportRun(run, file, progress, now-start)
 		case <-runner.Suite.StoppedCtx.Done():
 			handleStopped()
 		case <-runner.Suite.CancelledCtx.Done():
@@ -814,7 +2969,14 @@ func (runner *TestFileRunner) wait(ctx *terraform.Context, runningCtx context.Co
 		}
 	}
 
-	return diags, cancelled
+	// runningCtx is only ever bounded by a deadline when a run block's
+	// timeout attribute requested one (see runningContext); a context
+	// cancelled for any other reason reports context.Canceled instead, so
+	// this is an unambiguous way to tell a timeout apart from every other
+	// path through the select loops above.
+	timedOut = runningCtx.Err() == context.DeadlineExceeded
+
+	return diags, cancelled, timedOut
 }
 
 func (runner *TestFileRunner) cleanup(file *moduletest.File) {
@@ -855,7 +3017,11 @@ func (runner *TestFileRunner) cleanup(file *moduletest.File) {
 		// as false.
 		file.Status = moduletest.Error
 	}
-	runner.Suite.View.DestroySummary(diags, main.Run, file, updated)
+	runner.Suite.reportDestroySummary(diags, main.Run, file, updated)
+
+	if err := runner.StateStore.Save(MainStateIdentifier, updated); err != nil {
+		log.Printf("[ERROR] TestStateManager: failed to persist main state for %s: %s", file.Name, err)
+	}
 
 	if runner.Suite.Cancelled {
 		// In case things were cancelled during the last execution.
@@ -886,7 +3052,7 @@ func (runner *TestFileRunner) cleanup(file *moduletest.File) {
 			var diags tfdiags.Diagnostics
 			diags = diags.Append(tfdiags.Sourceless(tfdiags.Error, "Inconsistent state", fmt.Sprintf("Found inconsistent state while cleaning up %s. This is a bug in Terraform - please report it", file.Name)))
 			file.Status = moduletest.Error
-			runner.Suite.View.DestroySummary(diags, nil, file, state.State)
+			runner.Suite.reportDestroySummary(diags, nil, file, state.State)
 			continue
 		}
 
@@ -928,7 +3094,11 @@ func (runner *TestFileRunner) cleanup(file *moduletest.File) {
 			// as false.
 			file.Status = moduletest.Error
 		}
-		runner.Suite.View.DestroySummary(diags, state.Run, file, updated)
+		runner.Suite.reportDestroySummary(diags, state.Run, file, updated)
+
+		if err := runner.StateStore.Save(state.Key, updated); err != nil {
+			log.Printf("[ERROR] TestStateManager: failed to persist state for %s/%s: %s", file.Name, state.Key, err)
+		}
 
 		reset()
 	}
@@ -1182,6 +3352,10 @@ func (runner *TestFileRunner) ctx(run *moduletest.Run, file *moduletest.File, av
 
 	availableRunBlocks := make(map[string]*terraform.TestContext)
 	runs := make(map[string]cty.Value)
+
+	runner.relevantStatesMu.Lock()
+	defer runner.relevantStatesMu.Unlock()
+
 	for _, run := range file.Runs {
 		name := run.Name
 
@@ -0,0 +1,192 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/hashicorp/terraform/internal/backend"
+)
+
+var attachPollInterval = 500 * time.Millisecond
+
+// AttachOperation resumes monitoring of a remote run that Operation started
+// in a previous, now-disconnected CLI invocation, identified by runID. It
+// streams the same plan/apply log output Operation would have streamed, and
+// honors the same stopCtx/cancelCtx semantics: canceling the context passed
+// in (e.g. via Ctrl-C) attempts to cancel the remote run if it's still
+// cancelable, while a subsequent hard cancellation tears down immediately
+// without contacting Terraform Cloud again.
+//
+// Unlike Operation, AttachOperation isn't handed a backend.Operation — there
+// is no local configuration to run, only a run already in progress to
+// watch — so it has no op.UIIn to prompt the user through a cancel
+// confirmation. It cancels the remote run outright rather than asking.
+func (b *Cloud) AttachOperation(ctx context.Context, runID string) (*backend.RunningOperation, error) {
+	r, err := b.client.Runs.ReadWithOptions(ctx, runID, &tfe.RunReadOptions{
+		Include: []tfe.RunIncludeOpt{tfe.RunWorkspace},
+	})
+	if err != nil {
+		return nil, generalError("Failed to retrieve run", err)
+	}
+	if r.Workspace == nil {
+		return nil, fmt.Errorf("run %s has no associated workspace", runID)
+	}
+
+	b.opLock.Lock()
+
+	runningCtx, done := context.WithCancel(context.Background())
+	runningOp := &backend.RunningOperation{
+		Context:   runningCtx,
+		PlanEmpty: !r.HasChanges,
+	}
+
+	stopCtx, stop := context.WithCancel(ctx)
+	runningOp.Stop = stop
+
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	runningOp.Cancel = cancel
+
+	go func() {
+		defer done()
+		defer stop()
+		defer cancel()
+		defer b.opLock.Unlock()
+
+		if b.CLI != nil {
+			b.CLI.Output(b.Colorize().Color(strings.TrimSpace(fmt.Sprintf(
+				attachHeader, b.hostname, b.organization, r.Workspace.Name, r.ID)) + "\n"))
+		}
+
+		go b.watchForAttachCancel(stopCtx, cancelCtx, r.ID)
+
+		final, err := b.streamRun(stopCtx, cancelCtx, r)
+		if err != nil {
+			if b.CLI != nil && err != context.Canceled {
+				b.CLI.Error(generalError("Failed to monitor run", err).Error())
+			}
+			runningOp.Result = backend.OperationFailure
+			return
+		}
+
+		runningOp.PlanEmpty = !final.HasChanges
+		if final.Status == tfe.RunCanceled || final.Status == tfe.RunErrored {
+			runningOp.Result = backend.OperationFailure
+		}
+
+		if h, herr := readRunHandle(localWorkingDir); herr == nil && h != nil && h.RunID == final.ID {
+			_ = removeRunHandle(localWorkingDir)
+		}
+	}()
+
+	return runningOp, nil
+}
+
+// watchForAttachCancel mirrors the cancellation half of Operation's run
+// loop: once stopCtx is canceled (a graceful Ctrl-C) it asks Terraform Cloud
+// to cancel the run, provided it's still cancelable and cancelCtx hasn't
+// already fired.
+func (b *Cloud) watchForAttachCancel(stopCtx, cancelCtx context.Context, runID string) {
+	select {
+	case <-cancelCtx.Done():
+		return
+	case <-stopCtx.Done():
+	}
+
+	if cancelCtx.Err() != nil {
+		return
+	}
+
+	r, err := b.client.Runs.Read(cancelCtx, runID)
+	if err != nil || !r.Actions.IsCancelable {
+		return
+	}
+
+	if b.CLI != nil {
+		b.CLI.Output(b.Colorize().Color(strings.TrimSpace(operationCanceled)))
+	}
+	b.client.Runs.Cancel(cancelCtx, runID, tfe.RunCancelOptions{})
+}
+
+// streamRun polls r until it reaches a terminal status, streaming its plan
+// and (if the run proceeds that far) apply logs to the CLI as they become
+// available. It's the resumable counterpart to the log-reading loop in
+// plan(): where plan() always starts from a freshly created run, streamRun
+// may pick one up mid-flight, so it only tails a phase's log once that
+// phase has actually started producing one.
+func (b *Cloud) streamRun(stopCtx, cancelCtx context.Context, r *tfe.Run) (*tfe.Run, error) {
+	var streamedPlan, streamedApply bool
+
+	for {
+		switch r.Status {
+		case tfe.RunApplied, tfe.RunPlannedAndFinished, tfe.RunErrored, tfe.RunCanceled, tfe.RunDiscarded:
+			return r, nil
+		}
+
+		if !streamedPlan && r.Plan != nil && r.Plan.ID != "" {
+			logs, err := b.client.Plans.Logs(stopCtx, r.Plan.ID)
+			if err == nil {
+				if err := b.streamLogs(stopCtx, cancelCtx, logs); err != nil {
+					return r, err
+				}
+				streamedPlan = true
+			}
+		}
+
+		if !streamedApply && r.Apply != nil && r.Apply.ID != "" {
+			logs, err := b.client.Applies.Logs(stopCtx, r.Apply.ID)
+			if err == nil {
+				if err := b.streamLogs(stopCtx, cancelCtx, logs); err != nil {
+					return r, err
+				}
+				streamedApply = true
+			}
+		}
+
+		select {
+		case <-stopCtx.Done():
+			return r, stopCtx.Err()
+		case <-cancelCtx.Done():
+			return r, cancelCtx.Err()
+		case <-time.After(attachPollInterval):
+		}
+
+		var err error
+		r, err = b.client.Runs.Read(stopCtx, r.ID)
+		if err != nil {
+			return r, generalError("Failed to retrieve run", err)
+		}
+	}
+}
+
+// streamLogs copies logs to the CLI, the same way the logStreamer-backed
+// loop in plan() does, honoring stopCtx/cancelCtx so a canceled attach
+// stops tailing promptly instead of blocking on the next read.
+func (b *Cloud) streamLogs(stopCtx, cancelCtx context.Context, logs io.Reader) error {
+	streamer := &logStreamer{}
+	if b.CLI != nil {
+		streamer.output = func(line string) {
+			b.CLI.Output(b.Colorize().Color(line))
+		}
+	}
+	if err := streamer.stream(stopCtx, cancelCtx, logs); err != nil {
+		return generalError("Failed to read logs", err)
+	}
+	return nil
+}
+
+const attachHeader = `
+[reset][yellow]Reattaching to run in Terraform Cloud. Output will stream here. Pressing
+Ctrl-C will stop streaming the logs and cancel the run remotely, if it can
+still be canceled.[reset]
+
+To view this run in a browser, visit:
+https://%s/app/%s/%s/runs/%s[reset]
+`
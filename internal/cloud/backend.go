@@ -14,6 +14,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	tfe "github.com/hashicorp/go-tfe"
 	version "github.com/hashicorp/go-version"
@@ -38,12 +39,13 @@ import (
 )
 
 const (
-	defaultHostname    = "app.terraform.io"
-	defaultParallelism = 10
-	tfeServiceID       = "tfe.v2"
-	headerSourceKey    = "X-Terraform-Integration"
-	headerSourceValue  = "cloud"
-	genericHostname    = "localterraform.com"
+	defaultHostname                = "app.terraform.io"
+	defaultParallelism             = 10
+	tfeServiceID                   = "tfe.v2"
+	workloadIdentityTokenServiceID = "workload-identity-token.v1"
+	headerSourceKey                = "X-Terraform-Integration"
+	headerSourceValue              = "cloud"
+	genericHostname                = "localterraform.com"
 )
 
 // Cloud is an implementation of EnhancedBackend in service of the Terraform Cloud/Enterprise
@@ -106,6 +108,22 @@ type Cloud struct {
 	// input stores the value of the -input flag, since it will be used
 	// to determine whether or not to ask the user for approval of a run.
 	input bool
+
+	// wsCache caches Workspaces() results for the lifetime of this backend
+	// instance, so that repeated calls during a single CLI invocation don't
+	// re-paginate Terraform Cloud.
+	wsCache *workspaceListCache
+
+	// stateEncryption is the StateEncryption that Configure resolves from
+	// the "encryption" block, or nil if none is configured. Nothing in this
+	// package calls its Encrypt or Decrypt (see StateEncryption).
+	stateEncryption StateEncryption
+
+	// versionCompatibilityPolicy is consulted by
+	// VerifyWorkspaceTerraformVersion instead of a hard-coded compatibility
+	// window. It's resolved once by Configure and reused for the lifetime of
+	// this backend instance (i.e. cached per-run).
+	versionCompatibilityPolicy VersionCompatibilityPolicy
 }
 
 var _ backend.Backend = (*Cloud)(nil)
@@ -116,6 +134,7 @@ var _ backend.Local = (*Cloud)(nil)
 func New(services *disco.Disco) *Cloud {
 	return &Cloud{
 		services: services,
+		wsCache:  newWorkspaceListCache(),
 	}
 }
 
@@ -159,6 +178,67 @@ func (b *Cloud) ConfigSchema() *configschema.Block {
 							Optional:    true,
 							Description: schemaDescriptionTags,
 						},
+						"tag_bindings": {
+							Type:        cty.Map(cty.String),
+							Optional:    true,
+							Description: schemaDescriptionTagBindings,
+						},
+						"tag_query": {
+							Type:        cty.String,
+							Optional:    true,
+							Description: schemaDescriptionTagQuery,
+						},
+					},
+				},
+				Nesting: configschema.NestingSingle,
+			},
+			"auth": {
+				Block: configschema.Block{
+					Attributes: map[string]*configschema.Attribute{
+						"token_exchange_url": {
+							Type:        cty.String,
+							Optional:    true,
+							Description: schemaDescriptionAuthTokenExchangeURL,
+						},
+					},
+				},
+				Nesting: configschema.NestingSingle,
+			},
+			"encryption": {
+				Block: configschema.Block{
+					Attributes: map[string]*configschema.Attribute{
+						"provider": {
+							Type:        cty.String,
+							Optional:    true,
+							Description: schemaDescriptionEncryptionProvider,
+						},
+						"kms_key_id": {
+							Type:        cty.String,
+							Optional:    true,
+							Description: schemaDescriptionEncryptionKMSKeyID,
+						},
+						"vault_url": {
+							Type:        cty.String,
+							Optional:    true,
+							Description: schemaDescriptionEncryptionVaultURL,
+						},
+					},
+				},
+				Nesting: configschema.NestingSingle,
+			},
+			"retry": {
+				Block: configschema.Block{
+					Attributes: map[string]*configschema.Attribute{
+						"max_attempts": {
+							Type:        cty.Number,
+							Optional:    true,
+							Description: schemaDescriptionRetryMaxAttempts,
+						},
+						"max_backoff": {
+							Type:        cty.String,
+							Optional:    true,
+							Description: schemaDescriptionRetryMaxBackoff,
+						},
 					},
 				},
 				Nesting: configschema.NestingSingle,
@@ -187,6 +267,12 @@ func (b *Cloud) PrepareConfig(obj cty.Value) (cty.Value, tfdiags.Diagnostics) {
 			if val := workspaces.GetAttr("tags"); !val.IsNull() {
 				diags = diags.Append(invalidWorkspaceConfigMisconfiguration)
 			}
+			if val := workspaces.GetAttr("tag_bindings"); !val.IsNull() {
+				diags = diags.Append(invalidWorkspaceConfigMisconfiguration)
+			}
+			if val := workspaces.GetAttr("tag_query"); !val.IsNull() {
+				diags = diags.Append(invalidWorkspaceConfigMisconfiguration)
+			}
 		}
 	}
 
@@ -276,6 +362,49 @@ func (b *Cloud) Configure(obj cty.Value) tfdiags.Diagnostics {
 		}
 	}
 
+	// If we still don't have a token, see if a workload identity token was
+	// provided (e.g. by a CI system) so we can exchange it for a short-lived
+	// TFC/E API token.
+	var workloadIdentityExchangeURL string
+	if token == "" {
+		idToken, err := workloadIdentityToken()
+		if err != nil {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Failed to read workload identity token",
+				err.Error(),
+			))
+			return diags
+		}
+
+		if idToken != "" {
+			exchangeURL := config.workloadIdentityExchangeURL
+			if exchangeURL == "" {
+				discovered, err := discoverWorkloadIdentityTokenURL(hostname, b.services)
+				if err != nil {
+					diags = diags.Append(tfdiags.Sourceless(
+						tfdiags.Error,
+						"Failed to discover workload identity token-exchange URL",
+						err.Error(),
+					))
+					return diags
+				}
+				exchangeURL = discovered.String()
+			}
+
+			token, err = exchangeWorkloadIdentityToken(context.Background(), nil, exchangeURL, idToken, false)
+			if err != nil {
+				diags = diags.Append(tfdiags.Sourceless(
+					tfdiags.Error,
+					"Failed to exchange workload identity token",
+					err.Error(),
+				))
+				return diags
+			}
+			workloadIdentityExchangeURL = exchangeURL
+		}
+	}
+
 	// Return an error if we still don't have a token at this point.
 	if token == "" {
 		loginCommand := "terraform login"
@@ -309,6 +438,53 @@ func (b *Cloud) Configure(obj cty.Value) tfdiags.Diagnostics {
 		cfg.Headers.Set(tfversion.Header, tfversion.Version)
 		cfg.Headers.Set(headerSourceKey, headerSourceValue)
 
+		// If the token came from a workload identity exchange, wrap the
+		// transport so that a 401 (e.g. the exchanged token expired early)
+		// forces a fresh exchange and retries the request once.
+		if workloadIdentityExchangeURL != "" {
+			if idToken, _ := workloadIdentityToken(); idToken != "" {
+				base := cfg.HTTPClient
+				if base == nil {
+					base = &http.Client{}
+				}
+				transport := base.Transport
+				if transport == nil {
+					transport = http.DefaultTransport
+				}
+				base.Transport = &workloadIdentityRoundTripper{
+					base:        transport,
+					exchangeURL: workloadIdentityExchangeURL,
+					idToken:     idToken,
+				}
+				cfg.HTTPClient = base
+			}
+		}
+
+		// Wrap the transport with our own backoff/circuit-breaker policy,
+		// and disable the client's built-in retries (below) so requests
+		// aren't retried twice over.
+		retryPolicy, err := resolveRetryPolicy(config.retry)
+		if err != nil {
+			diags = diags.Append(tfdiags.AttributeValue(
+				tfdiags.Error,
+				"Invalid retry configuration",
+				err.Error(),
+				cty.Path{cty.GetAttrStep{Name: "retry"}},
+			))
+			return diags
+		}
+
+		httpClient := cfg.HTTPClient
+		if httpClient == nil {
+			httpClient = &http.Client{}
+		}
+		base := httpClient.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		httpClient.Transport = newRetryRoundTripper(base, retryPolicy, b.retryLogHook)
+		cfg.HTTPClient = httpClient
+
 		// Create the TFC/E API client.
 		b.client, err = tfe.NewClient(cfg)
 		if err != nil {
@@ -385,14 +561,45 @@ func (b *Cloud) Configure(obj cty.Value) tfdiags.Diagnostics {
 		}
 	}
 
+	// Set up client-side state encryption, if configured.
+	stateEncryption, err := newStateEncryption(context.Background(), config.encryption)
+	if err != nil {
+		diags = diags.Append(tfdiags.AttributeValue(
+			tfdiags.Error,
+			"Invalid state encryption configuration",
+			err.Error(),
+			cty.Path{cty.GetAttrStep{Name: "encryption"}},
+		))
+		return diags
+	}
+	b.stateEncryption = stateEncryption
+
+	// Resolve the version-compatibility policy VerifyWorkspaceTerraformVersion
+	// consults once per run, so an air-gapped TFE install can relax it via
+	// EnvVersionCompatibilityPolicyFile, or a hosted one can evolve its
+	// compatibility model without a Terraform release.
+	pingURL := strings.TrimSuffix(service.String(), "/") + "/ping"
+	versionPolicy, err := resolveVersionCompatibilityPolicy(context.Background(), nil, pingURL)
+	if err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Invalid version compatibility policy",
+			err.Error(),
+		))
+		return diags
+	}
+	b.versionCompatibilityPolicy = versionPolicy
+
 	// Configure a local backend for when we need to run operations locally.
 	b.local = backendLocal.NewWithBackend(b)
 
 	// Determine if we are forced to use the local backend.
 	b.forceLocal = os.Getenv("TF_FORCE_LOCAL_BACKEND") != "" || !entitlements.Operations
 
-	// Enable retries for server errors as the backend is now fully configured.
-	b.client.RetryServerErrors(true)
+	// Retries for server errors are handled by the retryRoundTripper
+	// installed above, so the client's own retry loop stays disabled to
+	// avoid retrying the same request twice over.
+	b.client.RetryServerErrors(false)
 
 	return diags
 }
@@ -448,6 +655,8 @@ func resolveCloudConfig(obj cty.Value) (cloudConfig, tfdiags.Diagnostics) {
 	// so it's easier to work with.
 	var name, project string
 	var tags []string
+	var tagBindings []KeyValueTag
+	var tagQuery string
 	if workspaces := obj.GetAttr("workspaces"); !workspaces.IsNull() {
 		if val := workspaces.GetAttr("name"); !val.IsNull() {
 			name = val.AsString()
@@ -460,6 +669,21 @@ func resolveCloudConfig(obj cty.Value) (cloudConfig, tfdiags.Diagnostics) {
 			}
 			log.Printf("[TRACE] cloud: using tags %q from cloud config block", tags)
 		}
+		if val := workspaces.GetAttr("tag_bindings"); !val.IsNull() {
+			var bindings map[string]string
+			err := gocty.FromCtyValue(val, &bindings)
+			if err != nil {
+				diags = diags.Append(fmt.Errorf("an unexpected error occurred: %w", err))
+			}
+			for k, v := range bindings {
+				tagBindings = append(tagBindings, KeyValueTag{Key: k, Value: v})
+			}
+			log.Printf("[TRACE] cloud: using tag bindings %v from cloud config block", tagBindings)
+		}
+		if val := workspaces.GetAttr("tag_query"); !val.IsNull() {
+			tagQuery = val.AsString()
+			log.Printf("[TRACE] cloud: using tag query %q from cloud config block", tagQuery)
+		}
 		if val := workspaces.GetAttr("project"); !val.IsNull() {
 			project = val.AsString()
 			log.Printf("[TRACE] cloud: found project name %q in cloud config block", project)
@@ -478,6 +702,55 @@ func resolveCloudConfig(obj cty.Value) (cloudConfig, tfdiags.Diagnostics) {
 
 	// Get the tags from the config. There's no environment variable.
 	ret.workspaceMapping.Tags = tags
+	ret.workspaceMapping.TagBindings = tagBindings
+	ret.workspaceMapping.TagQuery = tagQuery
+
+	// Get the workload identity token-exchange URL override, if any. Absent
+	// means Configure() should discover it from the hostname's service
+	// discovery document instead.
+	if auth := obj.GetAttr("auth"); !auth.IsNull() {
+		if val := auth.GetAttr("token_exchange_url"); !val.IsNull() {
+			ret.workloadIdentityExchangeURL = val.AsString()
+			log.Printf("[TRACE] cloud: using workload identity token-exchange URL %q from cloud config block", ret.workloadIdentityExchangeURL)
+		}
+	}
+
+	// Get the state encryption settings, if any. There's no environment
+	// variable fallback for these beyond EnvStateEncryptionKey, which the
+	// "static" provider itself consults.
+	if encryption := obj.GetAttr("encryption"); !encryption.IsNull() {
+		if val := encryption.GetAttr("provider"); !val.IsNull() {
+			ret.encryption.provider = val.AsString()
+			log.Printf("[TRACE] cloud: using state encryption provider %q from cloud config block", ret.encryption.provider)
+		}
+		if val := encryption.GetAttr("kms_key_id"); !val.IsNull() {
+			ret.encryption.kmsKeyID = val.AsString()
+		}
+		if val := encryption.GetAttr("vault_url"); !val.IsNull() {
+			ret.encryption.vaultURL = val.AsString()
+		}
+	}
+
+	// Get the retry policy settings, if any. resolveRetryPolicy applies
+	// EnvRetryMax / EnvRetryMaxBackoff and defaults for anything left unset.
+	if retry := obj.GetAttr("retry"); !retry.IsNull() {
+		if val := retry.GetAttr("max_attempts"); !val.IsNull() {
+			var n int
+			if err := gocty.FromCtyValue(val, &n); err != nil {
+				diags = diags.Append(fmt.Errorf("retry.max_attempts: %w", err))
+			} else {
+				ret.retry.maxAttempts = n
+			}
+		}
+		if val := retry.GetAttr("max_backoff"); !val.IsNull() {
+			d, err := time.ParseDuration(val.AsString())
+			if err != nil {
+				diags = diags.Append(fmt.Errorf("retry.max_backoff: %w", err))
+			} else {
+				ret.retry.maxBackoff = d
+			}
+		}
+	}
 
 	// Get the name, and validate the WorkspaceMapping as a whole. This is the
 	// only real tricky one, because TF_WORKSPACE is used in places beyond
@@ -545,6 +818,26 @@ func discover(hostname svchost.Hostname, services *disco.Disco) (*url.URL, error
 	return service, err
 }
 
+// discoverWorkloadIdentityTokenURL discovers the workload identity
+// token-exchange endpoint advertised by hostname's service discovery
+// document. It's only consulted when the cloud config doesn't set an
+// explicit override via the `auth` block.
+func discoverWorkloadIdentityTokenURL(hostname svchost.Hostname, services *disco.Disco) (*url.URL, error) {
+	host, err := services.Discover(hostname)
+	if err != nil {
+		var serviceDiscoErr *disco.ErrServiceDiscoveryNetworkRequest
+
+		switch {
+		case errors.As(err, &serviceDiscoErr):
+			return nil, fmt.Errorf("a network issue prevented cloud configuration; %w", err)
+		default:
+			return nil, err
+		}
+	}
+
+	return host.ServiceURL(workloadIdentityTokenServiceID)
+}
+
 // cliConfigToken returns the token for this host as configured in the credentials
 // section of the CLI Config File. If no token was configured, an empty
 // string will be returned instead.
@@ -583,12 +876,73 @@ func (b *Cloud) Workspaces() ([]string, error) {
 		return names, nil
 	}
 
+	// A TagsSelector expression is evaluated client-side against each
+	// workspace's full tag set; a plain Tags list is still sent to the
+	// server as-is, since tfe.WorkspaceListOptions.Tags is already an exact
+	// AND filter.
+	var selector tagSelector
+	if b.WorkspaceMapping.TagsSelector != "" {
+		var err error
+		selector, err = parseTagSelector(b.WorkspaceMapping.TagsSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace tags selector %q: %w", b.WorkspaceMapping.TagsSelector, err)
+		}
+	}
+
+	// A TagQuery is evaluated client-side the same way, but against both the
+	// plain Tags and the key/value TagBindings of each workspace.
+	var query tagQuery
+	if b.WorkspaceMapping.TagQuery != "" {
+		var err error
+		query, err = parseTagQuery(b.WorkspaceMapping.TagQuery)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace tag query %q: %w", b.WorkspaceMapping.TagQuery, err)
+		}
+	}
+
+	cacheKey := workspaceListCacheKey{
+		organization: b.organization,
+		project:      b.WorkspaceMapping.Project,
+		selector:     b.WorkspaceMapping.TagsSelector + "|" + b.WorkspaceMapping.TagQuery,
+	}
+	if cached, ok := b.wsCache.get(cacheKey); ok {
+		return cached, nil
+	}
+
 	// Otherwise, multiple workspaces are being mapped. Query Terraform Cloud for all the remote
 	// workspaces by the provided mapping strategy.
 	options := &tfe.WorkspaceListOptions{}
-	if b.WorkspaceMapping.Strategy() == WorkspaceTagsStrategy {
-		taglist := strings.Join(b.WorkspaceMapping.Tags, ",")
-		options.Tags = taglist
+	switch {
+	case b.WorkspaceMapping.TagsSelector != "":
+		// Send the broadest AND filter we can derive from the expression,
+		// then apply the full expression client-side below.
+		if required := selector.requiredTags(); len(required) > 0 {
+			options.Tags = strings.Join(required, ",")
+		}
+	case b.WorkspaceMapping.TagQuery != "":
+		// Same idea, but the required keys don't map onto values the server
+		// filter can check, so we only use them to narrow things down if the
+		// query also happens to be a plain bare-key conjunction; otherwise
+		// all filtering happens client-side below.
+		bareKeys := true
+		var walk func(n tagQueryNode)
+		walk = func(n tagQueryNode) {
+			switch t := n.(type) {
+			case *tagQueryAnd:
+				walk(t.left)
+				walk(t.right)
+			case *tagQueryLeaf:
+				bareKeys = bareKeys && !t.hasValue
+			default:
+				bareKeys = false
+			}
+		}
+		walk(query.root)
+		if required := query.requiredKeys(); bareKeys && len(required) > 0 {
+			options.Tags = strings.Join(required, ",")
+		}
+	case b.WorkspaceMapping.Strategy() == WorkspaceTagsStrategy:
+		options.Tags = strings.Join(b.WorkspaceMapping.Tags, ",")
 	}
 
 	if b.WorkspaceMapping.Project != "" {
@@ -607,28 +961,84 @@ func (b *Cloud) Workspaces() ([]string, error) {
 		}
 	}
 
-	for {
-		wl, err := b.client.Workspaces.List(context.Background(), b.organization, options)
-		if err != nil {
-			return nil, err
-		}
+	// Fetch the first page to learn the total page count, then fan the
+	// remaining pages out concurrently, bounded by defaultParallelism.
+	first, err := b.client.Workspaces.List(context.Background(), b.organization, options)
+	if err != nil {
+		return nil, err
+	}
 
-		for _, w := range wl.Items {
-			names = append(names, w.Name)
+	pages := make([][]*tfe.Workspace, first.TotalPages)
+	if first.TotalPages > 0 {
+		pages[0] = first.Items
+	}
+
+	if first.TotalPages > 1 {
+		sem := make(chan struct{}, defaultParallelism)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var pageErr error
+
+		for page := 2; page <= first.TotalPages; page++ {
+			page := page
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				pageOptions := *options
+				pageOptions.PageNumber = page
+				wl, err := b.client.Workspaces.List(context.Background(), b.organization, &pageOptions)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					if pageErr == nil {
+						pageErr = err
+					}
+					return
+				}
+				pages[page-1] = wl.Items
+			}()
 		}
+		wg.Wait()
 
-		// Exit the loop when we've seen all pages.
-		if wl.CurrentPage >= wl.TotalPages {
-			break
+		if pageErr != nil {
+			return nil, pageErr
 		}
+	}
 
-		// Update the page number to get the next page.
-		options.PageNumber = wl.NextPage
+	for _, page := range pages {
+		for _, w := range page {
+			if b.WorkspaceMapping.TagsSelector != "" && !selector.Match(w.TagNames) {
+				continue
+			}
+			if b.WorkspaceMapping.TagQuery != "" && !query.Match(w.TagNames, tfeTagBindingsToKeyValueTags(w.TagBindings)) {
+				continue
+			}
+			if len(b.WorkspaceMapping.TagBindings) > 0 {
+				have := workspaceTagMap(nil, tfeTagBindingsToKeyValueTags(w.TagBindings))
+				matched := true
+				for _, kv := range b.WorkspaceMapping.TagBindings {
+					if v, ok := have[kv.Key]; !ok || v != kv.Value {
+						matched = false
+						break
+					}
+				}
+				if !matched {
+					continue
+				}
+			}
+			names = append(names, w.Name)
+		}
 	}
 
 	// Sort the result so we have consistent output.
 	sort.StringSlice(names).Sort()
 
+	b.wsCache.put(cacheKey, names)
+
 	return names, nil
 }
 
@@ -653,7 +1063,12 @@ func (b *Cloud) DeleteWorkspace(name string, force bool) error {
 
 	// Configure the remote workspace name.
 	State := &State{tfeClient: b.client, organization: b.organization, workspace: workspace, enableIntermediateSnapshots: false}
-	return State.Delete(force)
+	if err := State.Delete(force); err != nil {
+		return err
+	}
+
+	b.wsCache.clear()
+	return nil
 }
 
 // StateMgr implements backend.Enhanced.
@@ -708,9 +1123,10 @@ func (b *Cloud) StateMgr(name string) (statemgr.Full, error) {
 
 		// Workspace Create Options
 		workspaceCreateOptions := tfe.WorkspaceCreateOptions{
-			Name:    tfe.String(name),
-			Tags:    b.WorkspaceMapping.tfeTags(),
-			Project: configuredProject,
+			Name:        tfe.String(name),
+			Tags:        b.WorkspaceMapping.tfeTags(),
+			TagBindings: b.WorkspaceMapping.tfeTagBindings(),
+			Project:     configuredProject,
 		}
 
 		// Create project if not exists, otherwise use it
@@ -737,6 +1153,7 @@ func (b *Cloud) StateMgr(name string) (statemgr.Full, error) {
 		if err != nil {
 			return nil, fmt.Errorf("error creating workspace %s: %v", name, err)
 		}
+		b.wsCache.clear()
 
 		remoteTFVersion = workspace.TerraformVersion
 
@@ -808,6 +1225,30 @@ func (b *Cloud) Operation(ctx context.Context, op *backend.Operation) (*backend.
 	//   which case the Terraform versions by definition match.
 	b.IgnoreVersionConflict()
 
+	// If a previous invocation left behind a run handle, refuse to start a
+	// new run on top of it unless that run has already finished (the handle
+	// is just stale) or the caller explicitly forced past it. Otherwise two
+	// runs could end up racing for the same workspace lock.
+	if handle, err := readRunHandle(runHandleConfigDir(op)); err == nil && handle != nil {
+		finished := true
+		if r, err := b.client.Runs.Read(ctx, handle.RunID); err == nil {
+			switch r.Status {
+			case tfe.RunApplied, tfe.RunPlannedAndFinished, tfe.RunErrored, tfe.RunCanceled, tfe.RunDiscarded:
+				finished = true
+			default:
+				finished = false
+			}
+		}
+
+		if !finished && !op.AutoApprove {
+			return nil, fmt.Errorf(
+				"a remote run (%s) may still be in progress for this workspace.\n\n"+
+					"Run \"terraform cloud attach\" to reattach to it, or remove %s "+
+					"and run this command again to start a new run.",
+				handle.RunID, runHandlePath(runHandleConfigDir(op)))
+		}
+	}
+
 	// Check if we need to use the local backend to run the operation.
 	if b.forceLocal || isLocalExecutionMode(w.ExecutionMode) {
 		// Record that we're forced to run operations locally to allow the
@@ -870,6 +1311,18 @@ func (b *Cloud) Operation(ctx context.Context, op *backend.Operation) (*backend.
 
 		defer b.opLock.Unlock()
 
+		// The handle is removed only once the run has reached a terminal
+		// status; an operation that ends any other way leaves it for attach.
+		var final *tfe.Run
+		defer func() {
+			if final != nil {
+				switch final.Status {
+				case tfe.RunApplied, tfe.RunPlannedAndFinished, tfe.RunErrored, tfe.RunCanceled, tfe.RunDiscarded:
+					_ = removeRunHandle(runHandleConfigDir(op))
+				}
+			}
+		}()
+
 		r, opErr := f(stopCtx, cancelCtx, op, w)
 		if opErr != nil && opErr != context.Canceled {
 			var diags tfdiags.Diagnostics
@@ -892,6 +1345,7 @@ func (b *Cloud) Operation(ctx context.Context, op *backend.Operation) (*backend.
 				op.ReportResult(runningOp, diags)
 				return
 			}
+			final = r
 
 			// Record if there are any changes.
 			runningOp.PlanEmpty = !r.HasChanges
@@ -1023,32 +1477,13 @@ func (b *Cloud) VerifyWorkspaceTerraformVersion(workspaceName string) tfdiags.Di
 	// restriction is because we cannot compare prerelease versions with any
 	// operator other than simple equality.
 	if remoteVersion != nil && remoteVersion.Prerelease() == "" {
-		v014 := version.Must(version.NewSemver("0.14.0"))
-		v130 := version.Must(version.NewSemver("1.3.0"))
-
-		// Versions from 0.14 through the early 1.x series should be compatible
-		// (though we don't know about 1.3 yet).
-		if remoteVersion.GreaterThanOrEqual(v014) && remoteVersion.LessThan(v130) {
-			early1xCompatible, err := version.NewConstraint(fmt.Sprintf(">= 0.14.0, < %s", v130.String()))
-			if err != nil {
-				panic(err)
-			}
-			remoteConstraint = early1xCompatible
-		}
-
-		// Any future new state format will require at least a minor version
-		// increment, so x.y.* will always be compatible with each other.
-		if remoteVersion.GreaterThanOrEqual(v130) {
-			rwvs := remoteVersion.Segments64()
-			if len(rwvs) >= 3 {
-				// ~> x.y.0
-				minorVersionCompatible, err := version.NewConstraint(fmt.Sprintf("~> %d.%d.0", rwvs[0], rwvs[1]))
-				if err != nil {
-					panic(err)
-				}
-				remoteConstraint = minorVersionCompatible
-			}
+		policy := b.versionCompatibilityPolicy
+		if policy == nil {
+			// Configure wasn't run (e.g. in tests that construct a Cloud
+			// directly), so fall back to the built-in rules.
+			policy = defaultVersionCompatibilityPolicy{}
 		}
+		remoteConstraint = policy.ConstraintFor(remoteVersion, remoteConstraint)
 	}
 
 	// Re-parsing tfversion.String because tfversion.SemVer omits the prerelease
@@ -1108,6 +1543,17 @@ func (b *Cloud) workspaceTagsRequireUpdate(workspace *tfe.Workspace, workspaceMa
 		}
 	}
 
+	existingBindings := map[string]string{}
+	for _, b := range workspace.TagBindings {
+		existingBindings[b.Key] = b.Value
+	}
+
+	for _, kv := range workspaceMapping.TagBindings {
+		if v, ok := existingBindings[kv.Key]; !ok || v != kv.Value {
+			return true
+		}
+	}
+
 	return false
 }
 
@@ -1115,6 +1561,24 @@ type WorkspaceMapping struct {
 	Name    string
 	Project string
 	Tags    []string
+
+	// TagsSelector is an optional boolean tag expression (see
+	// parseTagSelector) providing a richer alternative to Tags: negated
+	// tags ("!prod"), globs ("env-*"), and arbitrary AND/OR/NOT
+	// combinations thereof. When set, it's used instead of Tags to select
+	// workspaces.
+	TagsSelector string
+
+	// TagBindings are key/value workspace tags, used and stamped onto new
+	// workspaces the same way Tags are, but carrying a value alongside each
+	// key.
+	TagBindings []KeyValueTag
+
+	// TagQuery is an optional boolean tag expression (see parseTagQuery)
+	// matched against both Tags and TagBindings. Unlike TagsSelector, its
+	// predicates can test a TagBindings key/value pair, not just a plain
+	// tag's presence.
+	TagQuery string
 }
 
 type workspaceStrategy string
@@ -1127,12 +1591,13 @@ const (
 )
 
 func (wm WorkspaceMapping) Strategy() workspaceStrategy {
+	hasTags := len(wm.Tags) > 0 || wm.TagsSelector != "" || len(wm.TagBindings) > 0 || wm.TagQuery != ""
 	switch {
-	case len(wm.Tags) > 0 && wm.Name == "":
+	case hasTags && wm.Name == "":
 		return WorkspaceTagsStrategy
-	case len(wm.Tags) == 0 && wm.Name != "":
+	case !hasTags && wm.Name != "":
 		return WorkspaceNameStrategy
-	case len(wm.Tags) == 0 && wm.Name == "":
+	case !hasTags && wm.Name == "":
 		return WorkspaceNoneStrategy
 	default:
 		// Any other combination is invalid as each strategy is mutually exclusive
@@ -1143,10 +1608,13 @@ func (wm WorkspaceMapping) Strategy() workspaceStrategy {
 // cloudConfig is an intermediate type that represents the completed
 // cloud block config as a plain Go value.
 type cloudConfig struct {
-	hostname         string
-	organization     string
-	token            string
-	workspaceMapping WorkspaceMapping
+	hostname                    string
+	organization                string
+	token                       string
+	workspaceMapping            WorkspaceMapping
+	workloadIdentityExchangeURL string
+	encryption                  encryptionConfig
+	retry                       retryConfig
 }
 
 func isLocalExecutionMode(execMode string) bool {
@@ -1263,6 +1731,37 @@ func (wm WorkspaceMapping) tfeTags() []*tfe.Tag {
 	return tags
 }
 
+// tfeTagBindings returns the key/value TagBindings as tfe.TagBinding
+// values, ready to pass to tfe.WorkspaceCreateOptions.TagBindings.
+func (wm WorkspaceMapping) tfeTagBindings() []*tfe.TagBinding {
+	var bindings []*tfe.TagBinding
+
+	if wm.Strategy() != WorkspaceTagsStrategy {
+		return bindings
+	}
+
+	for _, kv := range wm.TagBindings {
+		bindings = append(bindings, &tfe.TagBinding{Key: kv.Key, Value: kv.Value})
+	}
+
+	return bindings
+}
+
+// tfeTagBindingsToKeyValueTags converts a workspace's tfe.TagBinding values
+// (as e.g. returned by Workspaces.List) into KeyValueTag, the form tagQuery
+// matches against.
+func tfeTagBindingsToKeyValueTags(bindings []*tfe.TagBinding) []KeyValueTag {
+	if len(bindings) == 0 {
+		return nil
+	}
+
+	kvs := make([]KeyValueTag, 0, len(bindings))
+	for _, b := range bindings {
+		kvs = append(kvs, KeyValueTag{Key: b.Key, Value: b.Value})
+	}
+	return kvs
+}
+
 func generalError(msg string, err error) error {
 	var diags tfdiags.Diagnostics
 
@@ -1342,6 +1841,36 @@ is the primary and recommended strategy to use.  This option conflicts with "nam
 When configured, only the specified workspace can be used. This option conflicts with "tags"
 and with the TF_WORKSPACE environment variable.`
 
+	schemaDescriptionTagBindings = `A map of key/value tags used to select remote Terraform Cloud workspaces,
+in addition to or instead of the plain string "tags". New workspaces will automatically be
+stamped with these key/value bindings. This option conflicts with "name".`
+
+	schemaDescriptionTagQuery = `A boolean tag expression selecting remote Terraform Cloud workspaces by
+their plain tags and key/value tag bindings, e.g. "env=prod AND team=platform AND NOT deprecated".
+Supports AND, OR, NOT and parentheses. This option conflicts with "name".`
+
 	schemaDescriptionProject = `The name of a Terraform Cloud project. Workspaces that need creating
 will be created within this project.`
+
+	schemaDescriptionAuthTokenExchangeURL = `The URL used to exchange a workload identity token (set via
+TF_CLOUD_WORKLOAD_IDENTITY_TOKEN or TF_CLOUD_WORKLOAD_IDENTITY_TOKEN_FILE) for a short-lived
+Terraform Cloud/Enterprise API token. Typically this argument should not be set; the URL is
+discovered automatically from the configured hostname's service discovery document.`
+
+	schemaDescriptionEncryptionProvider = `Enables client-side envelope encryption of state before it's uploaded to
+Terraform Cloud/Enterprise. One of "static" (a local key from TF_CLOUD_STATE_ENCRYPTION_KEY),
+"aws-kms", "gcp-kms", or "azure-keyvault". Defaults to no client-side encryption.`
+
+	schemaDescriptionEncryptionKMSKeyID = `The key used to wrap the per-write data encryption key. For "aws-kms" this
+is a key ID or ARN; for "gcp-kms" this is the key's full resource name; for "azure-keyvault" this
+is the key's name within the vault identified by "vault_url". Unused for the "static" provider.`
+
+	schemaDescriptionEncryptionVaultURL = `The URL of the Azure Key Vault containing the key named by "kms_key_id".
+Only used when "provider" is "azure-keyvault".`
+
+	schemaDescriptionRetryMaxAttempts = `The maximum number of times a request to Terraform Cloud/Enterprise is
+attempted, including the first try. Defaults to 5, or the value of TF_CLOUD_RETRY_MAX.`
+
+	schemaDescriptionRetryMaxBackoff = `The maximum backoff duration between retried requests, such as "30s".
+Defaults to 30s, or the value of TF_CLOUD_RETRY_MAX_BACKOFF.`
 )
@@ -1,11 +1,9 @@
 package cloud
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -37,7 +35,7 @@ func (b *Cloud) opPlan(stopCtx, cancelCtx context.Context, op *backend.Operation
 		return nil, diags.Err()
 	}
 
-	if b.ContextOpts != nil && b.ContextOpts.Parallelism != defaultParallelism {
+	if b.ContextOpts != nil && b.ContextOpts.Parallelism != defaultParallelism && !workspaceSupportsRunParallelism(w) {
 		diags = diags.Append(tfdiags.Sourceless(
 			tfdiags.Error,
 			"Custom parallelism values are currently not supported",
@@ -55,15 +53,6 @@ func (b *Cloud) opPlan(stopCtx, cancelCtx context.Context, op *backend.Operation
 		))
 	}
 
-	if op.PlanOutPath != "" {
-		diags = diags.Append(tfdiags.Sourceless(
-			tfdiags.Error,
-			"Saving a generated plan is currently not supported",
-			`Terraform Cloud does not support saving the generated execution `+
-				`plan locally at this time.`,
-		))
-	}
-
 	if !op.HasConfig() && op.PlanMode != plans.DestroyMode {
 		diags = diags.Append(tfdiags.Sourceless(
 			tfdiags.Error,
@@ -194,6 +183,10 @@ in order to capture the filesystem context the remote workspace expects:
 		AutoApply:            tfe.Bool(op.AutoApprove),
 	}
 
+	if b.ContextOpts != nil && b.ContextOpts.Parallelism != defaultParallelism && workspaceSupportsRunParallelism(w) {
+		runOptions.Parallelism = tfe.Int(b.ContextOpts.Parallelism)
+	}
+
 	switch op.PlanMode {
 	case plans.NormalMode:
 		// okay, but we don't need to do anything special for this
@@ -249,6 +242,19 @@ in order to capture the filesystem context the remote workspace expects:
 		return r, generalError("Failed to create run", err)
 	}
 
+	// Record the run so that, if this CLI invocation never gets to finish
+	// watching it, a later `terraform cloud attach` can pick it back up.
+	// Failure to write it is not fatal to the run itself.
+	if err := writeRunHandle(runHandleConfigDir(op), &runHandle{
+		Organization: b.organization,
+		Workspace:    w.Name,
+		WorkspaceID:  w.ID,
+		RunID:        r.ID,
+		StartedAt:    time.Now(),
+	}); err != nil {
+		log.Printf("[WARN] cloud: failed to write run handle for %s: %v", r.ID, err)
+	}
+
 	// When the lock timeout is set, if the run is still pending and
 	// cancellable after that period, we attempt to cancel it.
 	if lockTimeout := op.StateLocker.Timeout(); lockTimeout > 0 {
@@ -307,10 +313,8 @@ in order to capture the filesystem context the remote workspace expects:
 		}
 	}
 
-	if stageID := getTaskStageIDByName(taskStages, tfe.PrePlan); stageID != nil {
-		if err := b.waitTaskStage(stopCtx, cancelCtx, op, r, *stageID, "Pre-plan Tasks"); err != nil {
-			return r, err
-		}
+	if err := b.waitTaskStagesNamed(stopCtx, cancelCtx, op, r, taskStages, tfe.PrePlan, "Pre-plan Tasks"); err != nil {
+		return r, err
 	}
 
 	r, err = b.waitForRun(stopCtx, cancelCtx, op, "plan", r, w)
@@ -322,28 +326,16 @@ in order to capture the filesystem context the remote workspace expects:
 	if err != nil {
 		return r, generalError("Failed to retrieve logs", err)
 	}
-	reader := bufio.NewReaderSize(logs, 64*1024)
 
+	streamer := &logStreamer{}
 	if b.CLI != nil {
-		for next := true; next; {
-			var l, line []byte
-
-			for isPrefix := true; isPrefix; {
-				l, isPrefix, err = reader.ReadLine()
-				if err != nil {
-					if err != io.EOF {
-						return r, generalError("Failed to read logs", err)
-					}
-					next = false
-				}
-				line = append(line, l...)
-			}
-
-			if next || len(line) > 0 {
-				b.CLI.Output(b.Colorize().Color(string(line)))
-			}
+		streamer.output = func(line string) {
+			b.CLI.Output(b.Colorize().Color(line))
 		}
 	}
+	if err := streamer.stream(stopCtx, cancelCtx, logs); err != nil {
+		return r, generalError("Failed to read logs", err)
+	}
 
 	// Retrieve the run to get its current status.
 	r, err = b.client.Runs.Read(stopCtx, r.ID)
@@ -357,10 +349,8 @@ in order to capture the filesystem context the remote workspace expects:
 	// status of the run will be "errored", but there is still policy
 	// information which should be shown.
 
-	if stageID := getTaskStageIDByName(taskStages, tfe.PostPlan); stageID != nil {
-		if err := b.waitTaskStage(stopCtx, cancelCtx, op, r, *stageID, "Post-plan Tasks"); err != nil {
-			return r, err
-		}
+	if err := b.waitTaskStagesNamed(stopCtx, cancelCtx, op, r, taskStages, tfe.PostPlan, "Post-plan Tasks"); err != nil {
+		return r, err
 	}
 
 	// Show any cost estimation output.
@@ -379,17 +369,38 @@ in order to capture the filesystem context the remote workspace expects:
 		}
 	}
 
+	if op.PlanOutPath != "" {
+		if err := b.writeRemotePlanFile(stopCtx, op.PlanOutPath, w, cv, r); err != nil {
+			return r, generalError("Failed to save plan", err)
+		}
+	}
+
 	return r, nil
 }
 
-func getTaskStageIDByName(stages []*tfe.TaskStage, stageName tfe.Stage) *string {
-	if len(stages) == 0 {
-		return nil
-	}
-
+// getTaskStageIDByName returns the IDs of every stage in stages whose name
+// is stageName, in the order they appear. A run's task stages are not
+// guaranteed unique per name -- a multi-stage pipeline can configure more
+// than one PreApply stage, for instance -- so callers that only handled
+// the first match used to silently skip the rest.
+func getTaskStageIDByName(stages []*tfe.TaskStage, stageName tfe.Stage) []string {
+	var ids []string
 	for _, stage := range stages {
 		if stage.Stage == stageName {
-			return &stage.ID
+			ids = append(ids, stage.ID)
+		}
+	}
+	return ids
+}
+
+// waitTaskStagesNamed waits on every stage of name stageName present in
+// taskStages, in order, passing label to waitTaskStage for each. It's the
+// shared loop behind plan()'s PrePlan and PostPlan waits, so a stage name
+// new callers start using picks up the same multi-stage handling for free.
+func (b *Cloud) waitTaskStagesNamed(stopCtx, cancelCtx context.Context, op *backend.Operation, r *tfe.Run, taskStages []*tfe.TaskStage, stageName tfe.Stage, label string) error {
+	for _, stageID := range getTaskStageIDByName(taskStages, stageName) {
+		if err := b.waitTaskStage(stopCtx, cancelCtx, op, r, stageID, label); err != nil {
+			return err
 		}
 	}
 	return nil
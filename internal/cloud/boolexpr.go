@@ -0,0 +1,169 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"fmt"
+	"strings"
+)
+
+// boolExprNode is a node in a boolean expression tree: a leaf tests some
+// domain-specific predicate against a value of type T, and AND/OR/NOT nodes
+// combine leaves (or other internal nodes) using the usual boolean
+// semantics. It's generic over T so that the same tokenizer and
+// precedence-climbing parser below can serve both tagQuery, whose leaves
+// match a map[string]string of key/value tags, and tagSelector, whose
+// leaves match a map[string]bool of plain tag names.
+type boolExprNode[T any] interface {
+	match(v T) bool
+}
+
+type boolExprAnd[T any] struct{ left, right boolExprNode[T] }
+
+func (n *boolExprAnd[T]) match(v T) bool { return n.left.match(v) && n.right.match(v) }
+
+type boolExprOr[T any] struct{ left, right boolExprNode[T] }
+
+func (n *boolExprOr[T]) match(v T) bool { return n.left.match(v) || n.right.match(v) }
+
+type boolExprNot[T any] struct{ node boolExprNode[T] }
+
+func (n *boolExprNot[T]) match(v T) bool { return !n.node.match(v) }
+
+// parseBoolExpr tokenizes expr with tokenize and parses it into a
+// boolExprNode[T], delegating leaf tokens to parseLeaf. kind names the kind
+// of expression being parsed (e.g. "tag query", "tag selector"), used only
+// to phrase error messages.
+func parseBoolExpr[T any](expr, kind string, tokenize func(string) ([]string, error), parseLeaf func(string) (boolExprNode[T], error)) (boolExprNode[T], error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty %s expression", kind)
+	}
+
+	p := &boolExprParser[T]{tokens: tokens, kind: kind, parseLeaf: parseLeaf}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token %q in %s expression", p.tokens[p.pos], kind)
+	}
+
+	return root, nil
+}
+
+// tokenizeBoolExpr splits expr into "(", ")", and run-of-non-whitespace
+// tokens for parseBoolExpr's parser to interpret, treating any rune for
+// which isSeparator returns true as additional whitespace. isSeparator may
+// be nil if the caller has no separators beyond plain whitespace.
+func tokenizeBoolExpr(expr string, isSeparator func(rune) bool) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+
+	for _, r := range expr {
+		switch {
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t' || r == '\n' || (isSeparator != nil && isSeparator(r)):
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens, nil
+}
+
+// boolExprParser is a precedence-climbing recursive-descent parser shared by
+// parseTagQuery and parseTagSelector: "AND" binds tighter than "OR", "NOT"
+// binds tighter than both, and parentheses group sub-expressions. Only leaf
+// tokens are handled differently between the two, via parseLeaf.
+type boolExprParser[T any] struct {
+	tokens    []string
+	pos       int
+	kind      string
+	parseLeaf func(string) (boolExprNode[T], error)
+}
+
+func (p *boolExprParser[T]) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *boolExprParser[T]) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *boolExprParser[T]) parseOr() (boolExprNode[T], error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &boolExprOr[T]{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *boolExprParser[T]) parseAnd() (boolExprNode[T], error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "AND") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &boolExprAnd[T]{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *boolExprParser[T]) parseUnary() (boolExprNode[T], error) {
+	switch {
+	case strings.EqualFold(p.peek(), "NOT"):
+		p.next()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &boolExprNot[T]{node: node}, nil
+	case p.peek() == "(":
+		p.next()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis in %s expression", p.kind)
+		}
+		p.next()
+		return node, nil
+	default:
+		return p.parseLeaf(p.next())
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// logChunkSize is the size of the read buffer logStreamer.stream uses to
+// copy a remote log through as bytes arrive, rather than waiting for a
+// complete line.
+const logChunkSize = 64 * 1024
+
+// logStreamer copies a remote log -- a plan, apply, task-stage, cost
+// estimate, or policy check log, all of which are tailed the same way --
+// to the CLI as bytes arrive, instead of blocking until a newline-
+// terminated line is available. That distinction matters because some of
+// what these logs carry, module download and provider install progress
+// bars in particular, update a line in place with a carriage return
+// rather than a newline; buffering on '\n' alone makes an in-progress
+// operation look stalled until the bar finishes.
+//
+// It's meant to be shared by every place that tails one of these logs:
+// plan() and streamRun use it today, and waitTaskStage, costEstimate, and
+// checkPolicy are the natural next callers once they need the same
+// cancellable, real-time behavior.
+type logStreamer struct {
+	// output receives each completed line, stripped of its trailing
+	// terminator. A nil output drains the log without printing anything,
+	// which is what a caller with no CLI (e.g. running non-interactively)
+	// wants.
+	output func(line string)
+
+	// onJSONLine, if set, additionally receives each line that parses as a
+	// single JSON object, so a caller running with -json can forward
+	// structured log lines to a machine-readable sink. It's nil in every
+	// caller today; wiring it to an actual -json sink is a CLI-layer
+	// concern outside this package.
+	onJSONLine func(line []byte)
+}
+
+// stream copies logs through the streamer until logs reaches EOF or
+// stopCtx/cancelCtx is done, whichever happens first. The underlying read
+// happens on its own goroutine so that a canceled context returns promptly
+// even while a read is still blocked waiting on the network.
+func (s *logStreamer) stream(stopCtx, cancelCtx context.Context, logs io.Reader) error {
+	type chunk struct {
+		data []byte
+		err  error
+	}
+	chunks := make(chan chunk)
+
+	go func() {
+		buf := make([]byte, logChunkSize)
+		for {
+			n, err := logs.Read(buf)
+			if n > 0 {
+				got := make([]byte, n)
+				copy(got, buf[:n])
+				chunks <- chunk{data: got}
+			}
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				chunks <- chunk{err: err}
+				return
+			}
+		}
+	}()
+
+	var pending []byte
+	for {
+		select {
+		case <-stopCtx.Done():
+			return stopCtx.Err()
+		case <-cancelCtx.Done():
+			return cancelCtx.Err()
+		case c := <-chunks:
+			if c.err != nil {
+				if len(pending) > 0 {
+					s.emit(pending)
+				}
+				return c.err
+			}
+			pending = s.consume(append(pending, c.data...))
+		}
+	}
+}
+
+// consume splits data on '\n' or '\r' -- either one ends a line, since a
+// carriage-return-terminated progress update is still a complete line
+// worth showing immediately -- emitting each complete line and returning
+// whatever incomplete line remains at the end for the next call.
+func (s *logStreamer) consume(data []byte) (pending []byte) {
+	start := 0
+	for i, b := range data {
+		if b == '\n' || b == '\r' {
+			if i > start {
+				s.emit(data[start:i])
+			}
+			start = i + 1
+		}
+	}
+	return append([]byte(nil), data[start:]...)
+}
+
+func (s *logStreamer) emit(line []byte) {
+	if s.onJSONLine != nil && json.Valid(line) {
+		s.onJSONLine(append([]byte(nil), line...))
+	}
+	if s.output != nil {
+		s.output(string(line))
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	tfe "github.com/hashicorp/go-tfe"
+)
+
+// remotePlanFileMagic prefixes every file written by writeRemotePlanFile,
+// so a reader can tell a cloud-backed plan pointer apart from a real local
+// binary plan file -- which is a zip archive and so always begins with
+// "PK" -- before trying to json.Unmarshal it.
+const remotePlanFileMagic = "tfcloudplan:"
+
+// RemotePlanFile is what Cloud.plan writes to op.PlanOutPath once a remote
+// plan finishes, standing in for the binary plan file the local backend
+// would produce for a `-out` run. HCP Terraform doesn't expose the raw
+// plan binary over its API, so rather than fabricate an equivalent
+// artifact, this records just enough to find the run again. Nothing in
+// this package applies a saved RemotePlanFile's run; ReadRemotePlanFile
+// only decodes the file.
+type RemotePlanFile struct {
+	Hostname     string `json:"hostname"`
+	Organization string `json:"organization"`
+	Workspace    string `json:"workspace"`
+	WorkspaceID  string `json:"workspace_id"`
+
+	RunID                  string `json:"run_id"`
+	ConfigurationVersionID string `json:"configuration_version_id"`
+
+	// JSONPlan is the rendered JSON plan output for the run, exactly as
+	// returned by the Plans API, so a read-only consumer such as
+	// `terraform show` doesn't also have to round-trip through the API.
+	JSONPlan []byte `json:"json_plan"`
+}
+
+// writeRemotePlanFile downloads the JSON plan output for r and writes a
+// RemotePlanFile recording it alongside r's identity to path.
+func (b *Cloud) writeRemotePlanFile(ctx context.Context, path string, w *tfe.Workspace, cv *tfe.ConfigurationVersion, r *tfe.Run) error {
+	jsonPlan, err := b.client.Plans.ReadJSONOutput(ctx, r.Plan.ID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve the JSON plan: %w", err)
+	}
+
+	rpf := &RemotePlanFile{
+		Hostname:               b.hostname,
+		Organization:           b.organization,
+		Workspace:              w.Name,
+		WorkspaceID:            w.ID,
+		RunID:                  r.ID,
+		ConfigurationVersionID: cv.ID,
+		JSONPlan:               jsonPlan,
+	}
+
+	data, err := json.Marshal(rpf)
+	if err != nil {
+		return fmt.Errorf("failed to encode remote plan file: %w", err)
+	}
+
+	return os.WriteFile(path, append([]byte(remotePlanFileMagic), data...), 0644)
+}
+
+// ReadRemotePlanFile reads back a file written by writeRemotePlanFile. It
+// returns ok=false, rather than an error, if path doesn't look like one of
+// ours, so a caller that accepts either kind of plan file -- a local
+// binary plan or a cloud remote pointer -- can fall back to its usual
+// local handling instead of treating every non-cloud plan file as
+// corrupt.
+func ReadRemotePlanFile(path string) (rpf *RemotePlanFile, ok bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if !bytes.HasPrefix(data, []byte(remotePlanFileMagic)) {
+		return nil, false, nil
+	}
+
+	var out RemotePlanFile
+	if err := json.Unmarshal(data[len(remotePlanFileMagic):], &out); err != nil {
+		return nil, true, fmt.Errorf("invalid remote plan file %s: %w", path, err)
+	}
+	return &out, true, nil
+}
@@ -0,0 +1,123 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Environment variable overrides for RetryPolicy, consulted when the cloud
+// block's "retry" nested block doesn't set the corresponding attribute.
+const (
+	EnvRetryMax        = "TF_CLOUD_RETRY_MAX"
+	EnvRetryMaxBackoff = "TF_CLOUD_RETRY_MAX_BACKOFF"
+)
+
+const (
+	defaultRetryMaxAttempts = 5
+	defaultRetryBaseBackoff = 500 * time.Millisecond
+	defaultRetryMaxBackoff  = 30 * time.Second
+
+	// defaultCircuitBreakerFailureThreshold is how many consecutive
+	// request failures (after exhausting retries) trip a host's breaker
+	// open.
+	defaultCircuitBreakerFailureThreshold = 5
+
+	// defaultCircuitBreakerCooldown is how long a tripped breaker stays
+	// open before allowing a single half-open probe request through.
+	defaultCircuitBreakerCooldown = 30 * time.Second
+)
+
+// RetryPolicy configures how the cloud backend retries requests to the
+// TFC/E API, and when it gives up on a wedged host entirely via its
+// circuit breaker. It's applied by a retryRoundTripper wrapping the tfe
+// client's transport, replacing the client's own built-in retry loop.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a request is attempted,
+	// including the first try.
+	MaxAttempts int
+
+	// BaseBackoff and MaxBackoff bound the exponential backoff applied
+	// between attempts, before full jitter is applied.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	// RetryStatusCodes is the set of HTTP status codes that are retried.
+	// A nil/empty map retries the default set: 429 and any 5xx.
+	RetryStatusCodes map[int]bool
+
+	// CircuitBreakerFailureThreshold and CircuitBreakerCooldown configure
+	// the per-host circuit breaker: once this many consecutive requests to
+	// a host exhaust their retries, the breaker opens and fails fast for
+	// CircuitBreakerCooldown before allowing a single half-open probe
+	// through.
+	CircuitBreakerFailureThreshold int
+	CircuitBreakerCooldown         time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used when the cloud block's "retry"
+// block and its environment variable overrides are both absent.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:                    defaultRetryMaxAttempts,
+		BaseBackoff:                    defaultRetryBaseBackoff,
+		MaxBackoff:                     defaultRetryMaxBackoff,
+		CircuitBreakerFailureThreshold: defaultCircuitBreakerFailureThreshold,
+		CircuitBreakerCooldown:         defaultCircuitBreakerCooldown,
+	}
+}
+
+// retriesStatus reports whether code should be retried under this policy.
+func (p RetryPolicy) retriesStatus(code int) bool {
+	if len(p.RetryStatusCodes) > 0 {
+		return p.RetryStatusCodes[code]
+	}
+	return code == http.StatusTooManyRequests || (code >= 500 && code <= 599)
+}
+
+// retryConfig is the resolved form of the cloud block's "retry" nested
+// block.
+type retryConfig struct {
+	maxAttempts int
+	maxBackoff  time.Duration
+}
+
+// resolveRetryPolicy builds a RetryPolicy from the "retry" cloud config
+// block, falling back to EnvRetryMax / EnvRetryMaxBackoff, and finally to
+// DefaultRetryPolicy for anything left unset.
+func resolveRetryPolicy(cfg retryConfig) (RetryPolicy, error) {
+	policy := DefaultRetryPolicy()
+
+	switch {
+	case cfg.maxAttempts != 0:
+		policy.MaxAttempts = cfg.maxAttempts
+	case os.Getenv(EnvRetryMax) != "":
+		n, err := strconv.Atoi(os.Getenv(EnvRetryMax))
+		if err != nil {
+			return RetryPolicy{}, fmt.Errorf("%s must be an integer: %w", EnvRetryMax, err)
+		}
+		policy.MaxAttempts = n
+	}
+
+	switch {
+	case cfg.maxBackoff != 0:
+		policy.MaxBackoff = cfg.maxBackoff
+	case os.Getenv(EnvRetryMaxBackoff) != "":
+		d, err := time.ParseDuration(os.Getenv(EnvRetryMaxBackoff))
+		if err != nil {
+			return RetryPolicy{}, fmt.Errorf("%s must be a duration (e.g. \"30s\"): %w", EnvRetryMaxBackoff, err)
+		}
+		policy.MaxBackoff = d
+	}
+
+	if policy.MaxAttempts < 1 {
+		return RetryPolicy{}, fmt.Errorf("retry max attempts must be at least 1, got %d", policy.MaxAttempts)
+	}
+
+	return policy, nil
+}
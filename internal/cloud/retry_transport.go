@@ -0,0 +1,216 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// circuitBreakerState is the state of a circuitBreaker for a single host.
+type circuitBreakerState int
+
+const (
+	circuitClosed circuitBreakerState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker fails fast for a host that has been failing consistently,
+// rather than letting every Workspaces()/StateMgr() call block through a
+// full retry budget against a wedged TFE instance.
+type circuitBreaker struct {
+	policy RetryPolicy
+
+	mu               sync.Mutex
+	state            circuitBreakerState
+	consecutiveFails int
+	openedAt         time.Time
+}
+
+func newCircuitBreaker(policy RetryPolicy) *circuitBreaker {
+	return &circuitBreaker{policy: policy}
+}
+
+// allow reports whether a request may proceed, transitioning an open
+// breaker to half-open once its cooldown has elapsed. Only the single
+// caller that performs that transition is let through as the half-open
+// probe; every other concurrent caller is refused until recordSuccess or
+// recordFailure resolves the probe, closing or reopening the breaker.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitClosed:
+		return true
+	case circuitHalfOpen:
+		return false
+	}
+
+	// circuitOpen: become the half-open probe if the cooldown has elapsed.
+	// Because this runs under b.mu, at most one caller ever observes the
+	// circuitOpen->circuitHalfOpen transition; every later caller sees
+	// circuitHalfOpen above and is refused instead.
+	if time.Since(b.openedAt) < b.policy.CircuitBreakerCooldown {
+		return false
+	}
+
+	b.state = circuitHalfOpen
+	return true
+}
+
+func (b *circuitBreaker) recordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = circuitClosed
+	b.consecutiveFails = 0
+}
+
+func (b *circuitBreaker) recordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitHalfOpen {
+		// The probe failed; go straight back to open for another cooldown.
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+		return
+	}
+
+	b.consecutiveFails++
+	if b.consecutiveFails >= b.policy.CircuitBreakerFailureThreshold {
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// retryRoundTripper wraps an http.RoundTripper with exponential backoff and
+// full jitter, honors Retry-After, and maintains a circuit breaker per host
+// so a wedged TFE instance fails fast instead of exhausting the retry
+// budget on every call.
+type retryRoundTripper struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+
+	// onRetry, if set, is called before each retry wait so the backend can
+	// surface breaker/retry state through its existing CLI output path.
+	// Its signature matches Cloud.retryLogHook.
+	onRetry func(attempt int, resp *http.Response)
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func newRetryRoundTripper(base http.RoundTripper, policy RetryPolicy, onRetry func(attempt int, resp *http.Response)) *retryRoundTripper {
+	return &retryRoundTripper{
+		base:     base,
+		policy:   policy,
+		onRetry:  onRetry,
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+func (rt *retryRoundTripper) breakerFor(host string) *circuitBreaker {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	b, ok := rt.breakers[host]
+	if !ok {
+		b = newCircuitBreaker(rt.policy)
+		rt.breakers[host] = b
+	}
+	return b
+}
+
+func (rt *retryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	breaker := rt.breakerFor(req.URL.Host)
+
+	if !breaker.allow() {
+		return nil, fmt.Errorf(
+			"circuit breaker open for %s: too many consecutive failures, refusing to send requests until the cooldown elapses",
+			req.URL.Host,
+		)
+	}
+
+	var resp *http.Response
+	var err error
+
+	for attempt := 1; attempt <= rt.policy.MaxAttempts; attempt++ {
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, bodyErr
+			}
+			req.Body = body
+		}
+
+		resp, err = rt.base.RoundTrip(req)
+
+		retryable := err != nil || rt.policy.retriesStatus(resp.StatusCode)
+		if !retryable {
+			breaker.recordSuccess()
+			return resp, err
+		}
+		if attempt == rt.policy.MaxAttempts {
+			break
+		}
+
+		if rt.onRetry != nil {
+			rt.onRetry(attempt, resp)
+		}
+
+		wait := rt.backoff(attempt, resp)
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return resp, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}
+
+	breaker.recordFailure()
+	return resp, err
+}
+
+// backoff computes how long to wait before the next attempt: Retry-After if
+// the server sent one, otherwise exponential backoff with full jitter,
+// bounded by policy.MaxBackoff.
+func (rt *retryRoundTripper) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if ra := resp.Header.Get("Retry-After"); ra != "" {
+			if secs, err := strconv.Atoi(ra); err == nil && secs >= 0 {
+				return time.Duration(secs) * time.Second
+			}
+		}
+	}
+
+	base := rt.policy.BaseBackoff
+	if base <= 0 {
+		base = defaultRetryBaseBackoff
+	}
+	maxBackoff := rt.policy.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+
+	backoff := base * time.Duration(math.Pow(2, float64(attempt-1)))
+	if backoff > maxBackoff || backoff <= 0 {
+		backoff = maxBackoff
+	}
+
+	// Full jitter: a uniformly random duration between 0 and backoff.
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
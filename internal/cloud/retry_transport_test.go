@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerAllow(t *testing.T) {
+	b := newCircuitBreaker(RetryPolicy{CircuitBreakerCooldown: time.Hour})
+
+	if !b.allow() {
+		t.Error("a fresh (closed) breaker should allow requests")
+	}
+
+	b.openedAt = time.Now()
+	b.state = circuitOpen
+	if b.allow() {
+		t.Error("an open breaker within its cooldown should refuse requests")
+	}
+
+	b.openedAt = time.Now().Add(-2 * time.Hour)
+	if !b.allow() {
+		t.Error("an open breaker past its cooldown should allow a single probe")
+	}
+	if b.state != circuitHalfOpen {
+		t.Errorf("breaker state = %v, want circuitHalfOpen after the probe is let through", b.state)
+	}
+}
+
+func TestCircuitBreakerHalfOpenAllowsOnlyOneProbe(t *testing.T) {
+	b := newCircuitBreaker(RetryPolicy{CircuitBreakerCooldown: time.Millisecond})
+	b.state = circuitOpen
+	b.openedAt = time.Now().Add(-time.Hour)
+
+	const callers = 50
+	var allowed int32
+	var wg sync.WaitGroup
+	wg.Add(callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			defer wg.Done()
+			if b.allow() {
+				atomic.AddInt32(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 1 {
+		t.Errorf("got %d callers let through during the half-open cooldown, want exactly 1", allowed)
+	}
+	if b.state != circuitHalfOpen {
+		t.Errorf("breaker state = %v, want circuitHalfOpen", b.state)
+	}
+}
+
+func TestCircuitBreakerRecordSuccessClosesFromHalfOpen(t *testing.T) {
+	b := newCircuitBreaker(RetryPolicy{CircuitBreakerFailureThreshold: 1})
+	b.state = circuitHalfOpen
+
+	b.recordSuccess()
+
+	if b.state != circuitClosed {
+		t.Errorf("breaker state = %v, want circuitClosed", b.state)
+	}
+	if !b.allow() {
+		t.Error("a closed breaker should allow requests")
+	}
+}
+
+func TestCircuitBreakerRecordFailureReopensFromHalfOpen(t *testing.T) {
+	b := newCircuitBreaker(RetryPolicy{CircuitBreakerCooldown: time.Hour})
+	b.state = circuitHalfOpen
+
+	b.recordFailure()
+
+	if b.state != circuitOpen {
+		t.Errorf("breaker state = %v, want circuitOpen after the probe fails", b.state)
+	}
+	if b.allow() {
+		t.Error("a freshly reopened breaker should refuse requests until its cooldown elapses again")
+	}
+}
+
+func TestCircuitBreakerRecordFailureOpensAfterThreshold(t *testing.T) {
+	b := newCircuitBreaker(RetryPolicy{CircuitBreakerFailureThreshold: 3, CircuitBreakerCooldown: time.Hour})
+
+	b.recordFailure()
+	b.recordFailure()
+	if b.state != circuitClosed {
+		t.Fatalf("breaker state = %v, want circuitClosed before the threshold is reached", b.state)
+	}
+
+	b.recordFailure()
+	if b.state != circuitOpen {
+		t.Errorf("breaker state = %v, want circuitOpen once consecutiveFails reaches the threshold", b.state)
+	}
+}
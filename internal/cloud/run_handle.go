@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/terraform/internal/backend"
+)
+
+// localWorkingDir is the directory a run handle is read from and written to
+// when an operation has no configuration directory of its own to anchor on
+// (for example a `-destroy` plan with no local configuration). It matches
+// every other dotfile Terraform keeps alongside a configuration.
+const localWorkingDir = "."
+
+const (
+	runHandleDir  = ".terraform"
+	runHandleFile = "cloud-run.json"
+)
+
+// runHandle is the on-disk record of a remote run that Operation has
+// started. It's written as soon as the run exists remotely and removed once
+// Operation observes a terminal result, so its presence on disk means "as
+// far as we know, this run may still be in progress" — either because it
+// genuinely is, or because the CLI invocation that started it was
+// interrupted before it could clean up. AttachOperation uses it to resume
+// monitoring a run in the latter case.
+type runHandle struct {
+	Organization string    `json:"organization"`
+	Workspace    string    `json:"workspace"`
+	WorkspaceID  string    `json:"workspace_id"`
+	RunID        string    `json:"run_id"`
+	StartedAt    time.Time `json:"started_at"`
+}
+
+// runHandleConfigDir returns the directory a run handle for op should live
+// in: the operation's configuration directory if it has one, or the current
+// working directory otherwise.
+func runHandleConfigDir(op *backend.Operation) string {
+	if op != nil && op.ConfigDir != "" {
+		return op.ConfigDir
+	}
+	return localWorkingDir
+}
+
+func runHandlePath(configDir string) string {
+	return filepath.Join(configDir, runHandleDir, runHandleFile)
+}
+
+// readRunHandle returns the run handle recorded under configDir, or nil if
+// none is present. A missing file is not an error.
+func readRunHandle(configDir string) (*runHandle, error) {
+	data, err := os.ReadFile(runHandlePath(configDir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var h runHandle
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// writeRunHandle persists h under configDir, creating the .terraform
+// directory if it doesn't already exist.
+func writeRunHandle(configDir string, h *runHandle) error {
+	if err := os.MkdirAll(filepath.Join(configDir, runHandleDir), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(runHandlePath(configDir), data, 0644)
+}
+
+// removeRunHandle deletes the run handle recorded under configDir, if any.
+func removeRunHandle(configDir string) error {
+	err := os.Remove(runHandlePath(configDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
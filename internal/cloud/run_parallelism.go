@@ -0,0 +1,20 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import tfe "github.com/hashicorp/go-tfe"
+
+// workspaceSupportsRunParallelism reports whether w's remote TFE/TFC
+// instance understands a per-run parallelism override.
+//
+// Older TFE releases have no notion of this at all, so go-tfe can't just
+// always send it: a workspace's Capabilities block is only populated by
+// TFE versions new enough to report it, which makes its presence a decent
+// proxy for "the API accepts RunCreateOptions.Parallelism" without
+// needing a separate version check. Anything older keeps getting the
+// existing friendly error instead of a silently ignored (or rejected)
+// field.
+func workspaceSupportsRunParallelism(w *tfe.Workspace) bool {
+	return w != nil && w.Capabilities != nil && w.Capabilities.Runs
+}
@@ -0,0 +1,359 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// EnvStateEncryptionKey is the environment variable holding the
+// base64-encoded 256-bit key used by the "static" state encryption
+// provider.
+const EnvStateEncryptionKey = "TF_CLOUD_STATE_ENCRYPTION_KEY"
+
+// stateEncryptionMagic prefixes every envelope this package writes, so that
+// RefreshState can cheaply recognize an encrypted state body and route it
+// through Decrypt, rather than attempting (and failing) to parse it as
+// plain state JSON.
+const stateEncryptionMagic = "tfstate-encrypted:v1:"
+
+const (
+	stateEncryptionAlgStatic        = "static-aes256-gcm"
+	stateEncryptionAlgAWSKMS        = "aws-kms-aes256-gcm"
+	stateEncryptionAlgGCPKMS        = "gcp-kms-aes256-gcm"
+	stateEncryptionAlgAzureKeyVault = "azure-keyvault-aes256-gcm"
+)
+
+// StateEncryption wraps and unwraps the plaintext state JSON that would
+// otherwise be uploaded to, and downloaded from, Terraform Cloud/Enterprise
+// unencrypted. Implementations perform envelope encryption: a fresh data
+// encryption key (DEK) is generated for every write and used to encrypt the
+// state itself, and the DEK is in turn wrapped by a key that never leaves a
+// local secret, or a cloud KMS / Key Vault.
+//
+// It's intended to be set as Cloud.stateEncryption by Configure, and
+// consulted by the State type's PersistState and RefreshState to
+// transparently encrypt and decrypt the bodies they upload to and download
+// from Terraform Cloud/Enterprise.
+//
+// This checkout's internal/cloud package doesn't contain the State type
+// (or its PersistState/RefreshState methods) that would be the real call
+// site for Encrypt/Decrypt -- StateMgr here only ever returns a bare
+// &State{...} literal, with no method bodies defined anywhere in the
+// package. Configure still resolves and stores stateEncryption so that an
+// "encryption" block is validated up front, but nothing in this tree
+// actually calls Encrypt or Decrypt yet; state_encryption_test.go covers
+// the encryption logic itself in isolation.
+type StateEncryption interface {
+	// Encrypt returns a stateEncryptionEnvelope, prefixed with
+	// stateEncryptionMagic, containing plaintext encrypted under a fresh
+	// DEK. The returned bytes are what PersistState should upload in place
+	// of the plaintext state.
+	Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+
+	// Decrypt reverses Encrypt. It returns an actionable error if the
+	// envelope can't be unwrapped, for example because the wrapping key
+	// was rotated or deleted.
+	Decrypt(ctx context.Context, envelope []byte) ([]byte, error)
+}
+
+// stateEncryptionEnvelope is the JSON structure uploaded in place of
+// plaintext state. KMSKeyID is empty for the static-key provider, which has
+// no remote key to identify.
+type stateEncryptionEnvelope struct {
+	Alg        string `json:"alg"`
+	KMSKeyID   string `json:"kms_key_id,omitempty"`
+	WrappedDEK []byte `json:"wrapped_dek"`
+	IV         []byte `json:"iv"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// IsEncryptedState reports whether raw is a state encryption envelope
+// previously produced by Encrypt, as opposed to plain Terraform state JSON.
+func IsEncryptedState(raw []byte) bool {
+	return bytes.HasPrefix(raw, []byte(stateEncryptionMagic))
+}
+
+func marshalEnvelope(env stateEncryptionEnvelope) ([]byte, error) {
+	body, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode state encryption envelope: %w", err)
+	}
+	return append([]byte(stateEncryptionMagic), body...), nil
+}
+
+func unmarshalEnvelope(raw []byte) (stateEncryptionEnvelope, error) {
+	var env stateEncryptionEnvelope
+	if !IsEncryptedState(raw) {
+		return env, fmt.Errorf("state does not start with the expected %q envelope header", stateEncryptionMagic)
+	}
+	body := bytes.TrimPrefix(raw, []byte(stateEncryptionMagic))
+	if err := json.Unmarshal(body, &env); err != nil {
+		return env, fmt.Errorf("failed to decode state encryption envelope: %w", err)
+	}
+	return env, nil
+}
+
+// aesGCMEncryptPayload encrypts plaintext under a freshly generated 256-bit
+// DEK, returning the DEK alongside the IV and ciphertext so that callers can
+// wrap the DEK with whatever key management scheme they use.
+func aesGCMEncryptPayload(plaintext []byte) (dek, iv, ciphertext []byte, err error) {
+	dek = make([]byte, 32) // AES-256
+	if _, err = io.ReadFull(rand.Reader, dek); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to generate data encryption key: %w", err)
+	}
+
+	gcm, err := newAESGCM(dek)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	iv = make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to generate IV: %w", err)
+	}
+
+	ciphertext = gcm.Seal(nil, iv, plaintext, nil)
+	return dek, iv, ciphertext, nil
+}
+
+func aesGCMDecryptPayload(dek, iv, ciphertext []byte) ([]byte, error) {
+	gcm, err := newAESGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, iv, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt state: the data encryption key may be wrong: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize AES cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize AES-GCM: %w", err)
+	}
+	return gcm, nil
+}
+
+// staticKeyStateEncryption implements StateEncryption using a single static
+// AES-256 key read from EnvStateEncryptionKey. Unlike the KMS-backed
+// providers, "wrapping" the DEK here just means encrypting it locally with
+// that static key -- there's no remote key management service involved.
+type staticKeyStateEncryption struct {
+	key []byte // 32 bytes
+}
+
+func newStaticKeyStateEncryption() (*staticKeyStateEncryption, error) {
+	encoded := os.Getenv(EnvStateEncryptionKey)
+	if encoded == "" {
+		return nil, fmt.Errorf("%s must be set to a base64-encoded 256-bit key to use the \"static\" state encryption provider", EnvStateEncryptionKey)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not valid base64: %w", EnvStateEncryptionKey, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%s must decode to a 256-bit (32 byte) key, got %d bytes", EnvStateEncryptionKey, len(key))
+	}
+	return &staticKeyStateEncryption{key: key}, nil
+}
+
+func (s *staticKeyStateEncryption) Encrypt(_ context.Context, plaintext []byte) ([]byte, error) {
+	dek, iv, ciphertext, err := aesGCMEncryptPayload(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedDEK, err := s.wrap(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalEnvelope(stateEncryptionEnvelope{
+		Alg:        stateEncryptionAlgStatic,
+		WrappedDEK: wrappedDEK,
+		IV:         iv,
+		Ciphertext: ciphertext,
+	})
+}
+
+func (s *staticKeyStateEncryption) Decrypt(_ context.Context, raw []byte) ([]byte, error) {
+	env, err := unmarshalEnvelope(raw)
+	if err != nil {
+		return nil, err
+	}
+	if env.Alg != stateEncryptionAlgStatic {
+		return nil, fmt.Errorf("state was encrypted using algorithm %q, which the \"static\" state encryption provider doesn't support", env.Alg)
+	}
+
+	dek, err := s.unwrap(env.WrappedDEK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap the data encryption key: %s may have been rotated: %w", EnvStateEncryptionKey, err)
+	}
+
+	return aesGCMDecryptPayload(dek, env.IV, env.Ciphertext)
+}
+
+// wrap encrypts dek with the static key, returning the nonce and ciphertext
+// concatenated together so unwrap has everything it needs in one blob.
+func (s *staticKeyStateEncryption) wrap(dek []byte) ([]byte, error) {
+	gcm, err := newAESGCM(s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate wrap nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, dek, nil), nil
+}
+
+func (s *staticKeyStateEncryption) unwrap(wrapped []byte) ([]byte, error) {
+	gcm, err := newAESGCM(s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, fmt.Errorf("wrapped data encryption key is truncated")
+	}
+	nonce, ciphertext := wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+// kmsKeyWrapper wraps and unwraps a data encryption key using a remote key
+// management service. The DEK itself never leaves this process in
+// plaintext; only its wrapped (encrypted) form is sent to and received from
+// the KMS.
+type kmsKeyWrapper interface {
+	// keyID identifies the remote key, for inclusion in the envelope and in
+	// diagnostics.
+	keyID() string
+	wrapKey(ctx context.Context, dek []byte) ([]byte, error)
+	unwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// kmsStateEncryption implements StateEncryption for any kmsKeyWrapper by
+// encrypting state locally with a per-write AES-256-GCM DEK, and delegating
+// only the (small) DEK wrap/unwrap operation to the remote KMS.
+type kmsStateEncryption struct {
+	alg     string
+	wrapper kmsKeyWrapper
+}
+
+func (s *kmsStateEncryption) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	dek, iv, ciphertext, err := aesGCMEncryptPayload(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedDEK, err := s.wrapper.wrapKey(ctx, dek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap the data encryption key with %s: %w", s.wrapper.keyID(), err)
+	}
+
+	return marshalEnvelope(stateEncryptionEnvelope{
+		Alg:        s.alg,
+		KMSKeyID:   s.wrapper.keyID(),
+		WrappedDEK: wrappedDEK,
+		IV:         iv,
+		Ciphertext: ciphertext,
+	})
+}
+
+func (s *kmsStateEncryption) Decrypt(ctx context.Context, raw []byte) ([]byte, error) {
+	env, err := unmarshalEnvelope(raw)
+	if err != nil {
+		return nil, err
+	}
+	if env.Alg != s.alg {
+		return nil, fmt.Errorf("state was encrypted using algorithm %q, which this state encryption provider doesn't support", env.Alg)
+	}
+	if env.KMSKeyID != "" && env.KMSKeyID != s.wrapper.keyID() {
+		return nil, fmt.Errorf(
+			"state was encrypted with key %q, but this configuration is set up to use %q; if the key was "+
+				"intentionally rotated, update the \"encryption\" block to match",
+			env.KMSKeyID, s.wrapper.keyID(),
+		)
+	}
+
+	dek, err := s.wrapper.unwrapKey(ctx, env.WrappedDEK)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to unwrap the data encryption key with %s: the key may have been rotated, disabled, or had its permissions changed: %w",
+			s.wrapper.keyID(), err,
+		)
+	}
+
+	return aesGCMDecryptPayload(dek, env.IV, env.Ciphertext)
+}
+
+// encryptionConfig is the resolved form of the cloud block's "encryption"
+// nested block.
+type encryptionConfig struct {
+	// provider selects the StateEncryption implementation: "", "static",
+	// "aws-kms", "gcp-kms", or "azure-keyvault".
+	provider string
+	kmsKeyID string
+	vaultURL string
+}
+
+// newStateEncryption builds the StateEncryption implementation selected by
+// cfg, or (nil, nil) if state encryption isn't configured.
+func newStateEncryption(ctx context.Context, cfg encryptionConfig) (StateEncryption, error) {
+	switch cfg.provider {
+	case "":
+		return nil, nil
+	case "static":
+		return newStaticKeyStateEncryption()
+	case "aws-kms":
+		if cfg.kmsKeyID == "" {
+			return nil, fmt.Errorf("\"kms_key_id\" is required in the \"encryption\" block when \"provider\" is \"aws-kms\"")
+		}
+		wrapper, err := newAWSKMSKeyWrapper(ctx, cfg.kmsKeyID)
+		if err != nil {
+			return nil, err
+		}
+		return &kmsStateEncryption{alg: stateEncryptionAlgAWSKMS, wrapper: wrapper}, nil
+	case "gcp-kms":
+		if cfg.kmsKeyID == "" {
+			return nil, fmt.Errorf("\"kms_key_id\" is required in the \"encryption\" block when \"provider\" is \"gcp-kms\"")
+		}
+		wrapper, err := newGCPKMSKeyWrapper(ctx, cfg.kmsKeyID)
+		if err != nil {
+			return nil, err
+		}
+		return &kmsStateEncryption{alg: stateEncryptionAlgGCPKMS, wrapper: wrapper}, nil
+	case "azure-keyvault":
+		if cfg.vaultURL == "" || cfg.kmsKeyID == "" {
+			return nil, fmt.Errorf("\"vault_url\" and \"kms_key_id\" (the key name) are both required in the \"encryption\" block when \"provider\" is \"azure-keyvault\"")
+		}
+		wrapper, err := newAzureKeyVaultKeyWrapper(cfg.vaultURL, cfg.kmsKeyID)
+		if err != nil {
+			return nil, err
+		}
+		return &kmsStateEncryption{alg: stateEncryptionAlgAzureKeyVault, wrapper: wrapper}, nil
+	default:
+		return nil, fmt.Errorf("unknown state encryption provider %q; must be \"static\", \"aws-kms\", \"gcp-kms\", or \"azure-keyvault\"", cfg.provider)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+
+	awskms "github.com/aws/aws-sdk-go-v2/service/kms"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	kmspb "cloud.google.com/go/kms/apiv1/kmspb"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// awsKMSKeyWrapper wraps/unwraps data encryption keys using AWS KMS.
+// Credentials are resolved the standard way (environment, shared config,
+// instance/task role, etc.) via the default AWS SDK config loader.
+type awsKMSKeyWrapper struct {
+	client *awskms.Client
+	id     string
+}
+
+func newAWSKMSKeyWrapper(ctx context.Context, keyID string) (*awsKMSKeyWrapper, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	return &awsKMSKeyWrapper{client: awskms.NewFromConfig(cfg), id: keyID}, nil
+}
+
+func (w *awsKMSKeyWrapper) keyID() string { return w.id }
+
+func (w *awsKMSKeyWrapper) wrapKey(ctx context.Context, dek []byte) ([]byte, error) {
+	out, err := w.client.Encrypt(ctx, &awskms.EncryptInput{
+		KeyId:     &w.id,
+		Plaintext: dek,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.CiphertextBlob, nil
+}
+
+func (w *awsKMSKeyWrapper) unwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	out, err := w.client.Decrypt(ctx, &awskms.DecryptInput{
+		KeyId:          &w.id,
+		CiphertextBlob: wrapped,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+// gcpKMSKeyWrapper wraps/unwraps data encryption keys using Google Cloud
+// KMS. keyName is the fully qualified resource name of a symmetric
+// encrypt/decrypt key, e.g.
+// "projects/*/locations/*/keyRings/*/cryptoKeys/*".
+type gcpKMSKeyWrapper struct {
+	client *kms.KeyManagementClient
+	name   string
+}
+
+func newGCPKMSKeyWrapper(ctx context.Context, keyName string) (*gcpKMSKeyWrapper, error) {
+	client, err := kms.NewKeyManagementClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCP KMS client: %w", err)
+	}
+	return &gcpKMSKeyWrapper{client: client, name: keyName}, nil
+}
+
+func (w *gcpKMSKeyWrapper) keyID() string { return w.name }
+
+func (w *gcpKMSKeyWrapper) wrapKey(ctx context.Context, dek []byte) ([]byte, error) {
+	resp, err := w.client.Encrypt(ctx, &kmspb.EncryptRequest{
+		Name:      w.name,
+		Plaintext: dek,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Ciphertext, nil
+}
+
+func (w *gcpKMSKeyWrapper) unwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	resp, err := w.client.Decrypt(ctx, &kmspb.DecryptRequest{
+		Name:       w.name,
+		Ciphertext: wrapped,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+// azureKeyVaultKeyWrapper wraps/unwraps data encryption keys using an
+// Azure Key Vault key's wrap/unwrap operations.
+type azureKeyVaultKeyWrapper struct {
+	client   *azkeys.Client
+	vaultURL string
+	name     string
+}
+
+func newAzureKeyVaultKeyWrapper(vaultURL, keyName string) (*azureKeyVaultKeyWrapper, error) {
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Azure credentials: %w", err)
+	}
+	client, err := azkeys.NewClient(vaultURL, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure Key Vault client: %w", err)
+	}
+	return &azureKeyVaultKeyWrapper{client: client, vaultURL: vaultURL, name: keyName}, nil
+}
+
+func (w *azureKeyVaultKeyWrapper) keyID() string { return w.vaultURL + "/keys/" + w.name }
+
+func (w *azureKeyVaultKeyWrapper) wrapKey(ctx context.Context, dek []byte) ([]byte, error) {
+	alg := azkeys.EncryptionAlgorithmRSAOAEP256
+	resp, err := w.client.WrapKey(ctx, w.name, "", azkeys.KeyOperationParameters{
+		Algorithm: &alg,
+		Value:     dek,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Result, nil
+}
+
+func (w *azureKeyVaultKeyWrapper) unwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	alg := azkeys.EncryptionAlgorithmRSAOAEP256
+	resp, err := w.client.UnwrapKey(ctx, w.name, "", azkeys.KeyOperationParameters{
+		Algorithm: &alg,
+		Value:     wrapped,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Result, nil
+}
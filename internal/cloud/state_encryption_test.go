@@ -0,0 +1,302 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestIsEncryptedState(t *testing.T) {
+	if IsEncryptedState([]byte(`{"version":4}`)) {
+		t.Error("plain state JSON should not be reported as encrypted")
+	}
+	if !IsEncryptedState([]byte(stateEncryptionMagic + `{"alg":"static-aes256-gcm"}`)) {
+		t.Error("a body with the envelope magic prefix should be reported as encrypted")
+	}
+}
+
+func TestMarshalUnmarshalEnvelope(t *testing.T) {
+	env := stateEncryptionEnvelope{
+		Alg:        stateEncryptionAlgStatic,
+		WrappedDEK: []byte("wrapped-dek"),
+		IV:         []byte("iv"),
+		Ciphertext: []byte("ciphertext"),
+	}
+
+	raw, err := marshalEnvelope(env)
+	if err != nil {
+		t.Fatalf("unexpected error marshaling envelope: %s", err)
+	}
+	if !bytes.HasPrefix(raw, []byte(stateEncryptionMagic)) {
+		t.Fatalf("marshaled envelope is missing the magic prefix: %q", raw)
+	}
+
+	got, err := unmarshalEnvelope(raw)
+	if err != nil {
+		t.Fatalf("unexpected error unmarshaling envelope: %s", err)
+	}
+	if got.Alg != env.Alg || !bytes.Equal(got.WrappedDEK, env.WrappedDEK) ||
+		!bytes.Equal(got.IV, env.IV) || !bytes.Equal(got.Ciphertext, env.Ciphertext) {
+		t.Errorf("round-tripped envelope = %+v, want %+v", got, env)
+	}
+}
+
+func TestUnmarshalEnvelopeRejectsPlainState(t *testing.T) {
+	if _, err := unmarshalEnvelope([]byte(`{"version":4}`)); err == nil {
+		t.Error("expected an error unmarshaling a body without the envelope magic prefix")
+	}
+}
+
+func TestAESGCMEncryptDecryptPayloadRoundTrip(t *testing.T) {
+	plaintext := []byte(`{"version":4,"resources":[]}`)
+
+	dek, iv, ciphertext, err := aesGCMEncryptPayload(plaintext)
+	if err != nil {
+		t.Fatalf("unexpected error encrypting: %s", err)
+	}
+	if len(dek) != 32 {
+		t.Fatalf("expected a 256-bit DEK, got %d bytes", len(dek))
+	}
+	if bytes.Equal(ciphertext, plaintext) {
+		t.Fatal("ciphertext must not equal plaintext")
+	}
+
+	got, err := aesGCMDecryptPayload(dek, iv, ciphertext)
+	if err != nil {
+		t.Fatalf("unexpected error decrypting: %s", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("decrypted payload = %q, want %q", got, plaintext)
+	}
+}
+
+func TestAESGCMDecryptPayloadWrongKey(t *testing.T) {
+	_, iv, ciphertext, err := aesGCMEncryptPayload([]byte("secret"))
+	if err != nil {
+		t.Fatalf("unexpected error encrypting: %s", err)
+	}
+
+	wrongDEK := make([]byte, 32)
+	if _, err := aesGCMDecryptPayload(wrongDEK, iv, ciphertext); err == nil {
+		t.Error("expected an error decrypting with the wrong data encryption key")
+	}
+}
+
+func newTestStaticKeyStateEncryption(t *testing.T) *staticKeyStateEncryption {
+	t.Helper()
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	return &staticKeyStateEncryption{key: key}
+}
+
+func TestStaticKeyStateEncryptionEncryptDecryptRoundTrip(t *testing.T) {
+	s := newTestStaticKeyStateEncryption(t)
+	plaintext := []byte(`{"version":4,"resources":["a"]}`)
+
+	envelope, err := s.Encrypt(context.Background(), plaintext)
+	if err != nil {
+		t.Fatalf("unexpected error encrypting: %s", err)
+	}
+	if !IsEncryptedState(envelope) {
+		t.Fatal("Encrypt's output should be recognized by IsEncryptedState")
+	}
+
+	got, err := s.Decrypt(context.Background(), envelope)
+	if err != nil {
+		t.Fatalf("unexpected error decrypting: %s", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("decrypted state = %q, want %q", got, plaintext)
+	}
+}
+
+func TestStaticKeyStateEncryptionDecryptWrongKey(t *testing.T) {
+	s := newTestStaticKeyStateEncryption(t)
+	envelope, err := s.Encrypt(context.Background(), []byte("secret"))
+	if err != nil {
+		t.Fatalf("unexpected error encrypting: %s", err)
+	}
+
+	other := newTestStaticKeyStateEncryption(t)
+	other.key[0] ^= 0xff
+	if _, err := other.Decrypt(context.Background(), envelope); err == nil {
+		t.Error("expected an error decrypting state wrapped under a different static key")
+	}
+}
+
+func TestStaticKeyStateEncryptionDecryptWrongAlg(t *testing.T) {
+	s := newTestStaticKeyStateEncryption(t)
+	envelope, err := marshalEnvelope(stateEncryptionEnvelope{Alg: "some-other-alg"})
+	if err != nil {
+		t.Fatalf("unexpected error marshaling envelope: %s", err)
+	}
+	if _, err := s.Decrypt(context.Background(), envelope); err == nil {
+		t.Error("expected an error decrypting an envelope encrypted under a different algorithm")
+	}
+}
+
+func TestNewStaticKeyStateEncryption(t *testing.T) {
+	t.Run("missing env var", func(t *testing.T) {
+		t.Setenv(EnvStateEncryptionKey, "")
+		if _, err := newStaticKeyStateEncryption(); err == nil {
+			t.Error("expected an error with no key configured")
+		}
+	})
+
+	t.Run("invalid base64", func(t *testing.T) {
+		t.Setenv(EnvStateEncryptionKey, "not-valid-base64!!")
+		if _, err := newStaticKeyStateEncryption(); err == nil {
+			t.Error("expected an error with an invalid base64 key")
+		}
+	})
+
+	t.Run("wrong length", func(t *testing.T) {
+		t.Setenv(EnvStateEncryptionKey, base64.StdEncoding.EncodeToString([]byte("too-short")))
+		if _, err := newStaticKeyStateEncryption(); err == nil {
+			t.Error("expected an error with a key that isn't 256 bits")
+		}
+	})
+
+	t.Run("valid key", func(t *testing.T) {
+		key := make([]byte, 32)
+		t.Setenv(EnvStateEncryptionKey, base64.StdEncoding.EncodeToString(key))
+		s, err := newStaticKeyStateEncryption()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !bytes.Equal(s.key, key) {
+			t.Error("decoded key does not match the configured key")
+		}
+	})
+}
+
+// fakeKMSKeyWrapper is a kmsKeyWrapper that wraps/unwraps DEKs with a fixed
+// XOR mask instead of talking to a real KMS, so that kmsStateEncryption's
+// envelope logic can be exercised without the AWS/GCP/Azure SDKs or network
+// access those concrete wrappers require.
+type fakeKMSKeyWrapper struct {
+	id         string
+	failWrap   error
+	failUnwrap error
+}
+
+func (w *fakeKMSKeyWrapper) keyID() string { return w.id }
+
+func (w *fakeKMSKeyWrapper) wrapKey(_ context.Context, dek []byte) ([]byte, error) {
+	if w.failWrap != nil {
+		return nil, w.failWrap
+	}
+	return xorMask(dek), nil
+}
+
+func (w *fakeKMSKeyWrapper) unwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	if w.failUnwrap != nil {
+		return nil, w.failUnwrap
+	}
+	return xorMask(wrapped), nil
+}
+
+func xorMask(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[i] = c ^ 0xaa
+	}
+	return out
+}
+
+func TestKMSStateEncryptionEncryptDecryptRoundTrip(t *testing.T) {
+	wrapper := &fakeKMSKeyWrapper{id: "fake-key-1"}
+	s := &kmsStateEncryption{alg: stateEncryptionAlgAWSKMS, wrapper: wrapper}
+	plaintext := []byte(`{"version":4,"resources":["a"]}`)
+
+	envelope, err := s.Encrypt(context.Background(), plaintext)
+	if err != nil {
+		t.Fatalf("unexpected error encrypting: %s", err)
+	}
+
+	got, err := s.Decrypt(context.Background(), envelope)
+	if err != nil {
+		t.Fatalf("unexpected error decrypting: %s", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("decrypted state = %q, want %q", got, plaintext)
+	}
+}
+
+func TestKMSStateEncryptionDecryptKeyRotated(t *testing.T) {
+	wrapper := &fakeKMSKeyWrapper{id: "fake-key-1"}
+	s := &kmsStateEncryption{alg: stateEncryptionAlgAWSKMS, wrapper: wrapper}
+
+	envelope, err := s.Encrypt(context.Background(), []byte("secret"))
+	if err != nil {
+		t.Fatalf("unexpected error encrypting: %s", err)
+	}
+
+	rotated := &kmsStateEncryption{alg: stateEncryptionAlgAWSKMS, wrapper: &fakeKMSKeyWrapper{id: "fake-key-2"}}
+	if _, err := rotated.Decrypt(context.Background(), envelope); err == nil {
+		t.Error("expected an error decrypting state encrypted under a different KMS key")
+	}
+}
+
+func TestKMSStateEncryptionWrapUnwrapErrors(t *testing.T) {
+	wrapper := &fakeKMSKeyWrapper{id: "fake-key-1", failWrap: errors.New("kms unavailable")}
+	s := &kmsStateEncryption{alg: stateEncryptionAlgAWSKMS, wrapper: wrapper}
+	if _, err := s.Encrypt(context.Background(), []byte("secret")); err == nil {
+		t.Error("expected Encrypt to surface the wrapper's wrapKey error")
+	}
+
+	okWrapper := &fakeKMSKeyWrapper{id: "fake-key-1"}
+	ok := &kmsStateEncryption{alg: stateEncryptionAlgAWSKMS, wrapper: okWrapper}
+	envelope, err := ok.Encrypt(context.Background(), []byte("secret"))
+	if err != nil {
+		t.Fatalf("unexpected error encrypting: %s", err)
+	}
+
+	failUnwrap := &kmsStateEncryption{alg: stateEncryptionAlgAWSKMS, wrapper: &fakeKMSKeyWrapper{id: "fake-key-1", failUnwrap: errors.New("kms unavailable")}}
+	if _, err := failUnwrap.Decrypt(context.Background(), envelope); err == nil {
+		t.Error("expected Decrypt to surface the wrapper's unwrapKey error")
+	}
+}
+
+func TestNewStateEncryption(t *testing.T) {
+	t.Run("no provider configured", func(t *testing.T) {
+		s, err := newStateEncryption(context.Background(), encryptionConfig{})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if s != nil {
+			t.Error("expected a nil StateEncryption when no provider is configured")
+		}
+	})
+
+	t.Run("static provider", func(t *testing.T) {
+		key := make([]byte, 32)
+		t.Setenv(EnvStateEncryptionKey, base64.StdEncoding.EncodeToString(key))
+		s, err := newStateEncryption(context.Background(), encryptionConfig{provider: "static"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, ok := s.(*staticKeyStateEncryption); !ok {
+			t.Errorf("got %T, want *staticKeyStateEncryption", s)
+		}
+	})
+
+	t.Run("aws-kms missing key id", func(t *testing.T) {
+		if _, err := newStateEncryption(context.Background(), encryptionConfig{provider: "aws-kms"}); err == nil {
+			t.Error("expected an error with no kms_key_id configured")
+		}
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		if _, err := newStateEncryption(context.Background(), encryptionConfig{provider: "rot13"}); err == nil {
+			t.Error("expected an error for an unrecognized provider")
+		}
+	})
+}
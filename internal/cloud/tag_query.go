@@ -0,0 +1,129 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeyValueTag is a single Terraform Cloud/Enterprise key/value workspace
+// tag, as opposed to the plain string tags WorkspaceMapping.Tags
+// represents.
+type KeyValueTag struct {
+	Key   string
+	Value string
+}
+
+// tagQueryNode is a node in the boolean expression tree produced by
+// parseTagQuery. Leaves test a single key/value (or bare-key) predicate
+// against a workspace's tags; internal nodes combine leaves with AND/OR/NOT
+// using the shared boolExprNode engine in boolexpr.go.
+type tagQueryNode = boolExprNode[map[string]string]
+
+type tagQueryAnd = boolExprAnd[map[string]string]
+type tagQueryOr = boolExprOr[map[string]string]
+type tagQueryNot = boolExprNot[map[string]string]
+
+// tagQueryLeaf matches "key=value" if hasValue is set, or bare key presence
+// (regardless of value, including plain string tags which carry no value
+// of their own) otherwise.
+type tagQueryLeaf struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+func (n *tagQueryLeaf) match(tags map[string]string) bool {
+	v, ok := tags[n.key]
+	if !ok {
+		return false
+	}
+	if !n.hasValue {
+		return true
+	}
+	return v == n.value
+}
+
+// tagQuery is a parsed boolean tag expression over key/value and bare-key
+// predicates, e.g. "env=prod AND team=platform AND NOT deprecated".
+type tagQuery struct {
+	root tagQueryNode
+}
+
+// workspaceTagMap builds the key/value map a tagQuery matches against:
+// TagBindings contribute their key/value pairs, and plain tag names (e.g.
+// from Workspace.TagNames) contribute a bare-key predicate with no value.
+func workspaceTagMap(tagNames []string, bindings []KeyValueTag) map[string]string {
+	tags := make(map[string]string, len(tagNames)+len(bindings))
+	for _, name := range tagNames {
+		tags[name] = ""
+	}
+	for _, kv := range bindings {
+		tags[kv.Key] = kv.Value
+	}
+	return tags
+}
+
+// Match reports whether a workspace with the given plain tag names and
+// key/value tag bindings satisfies the query.
+func (q tagQuery) Match(tagNames []string, bindings []KeyValueTag) bool {
+	return q.root.match(workspaceTagMap(tagNames, bindings))
+}
+
+// requiredKeys returns the keys that a workspace MUST carry (with any
+// value) to have any chance of matching, found by walking AND nodes only;
+// see tagSelector.requiredTags for why OR and NOT contribute nothing.
+func (q tagQuery) requiredKeys() []string {
+	seen := make(map[string]bool)
+	collectRequiredKeys(q.root, seen)
+
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func collectRequiredKeys(n tagQueryNode, seen map[string]bool) {
+	switch t := n.(type) {
+	case *tagQueryAnd:
+		collectRequiredKeys(t.left, seen)
+		collectRequiredKeys(t.right, seen)
+	case *tagQueryLeaf:
+		seen[t.key] = true
+	}
+}
+
+// parseTagQuery parses a boolean tag expression into a tagQuery. Predicates
+// are "key=value" or a bare "key" (present regardless of value), combined
+// with AND, OR and NOT (case-insensitive) and grouped with parentheses.
+// "AND" binds tighter than "OR". Tokenizing and precedence climbing are
+// shared with parseTagSelector via boolexpr.go; only leaf parsing differs.
+func parseTagQuery(expr string) (tagQuery, error) {
+	root, err := parseBoolExpr(expr, "tag query", tokenizeTagQuery, parseTagQueryLeaf)
+	if err != nil {
+		return tagQuery{}, err
+	}
+	return tagQuery{root: root}, nil
+}
+
+func tokenizeTagQuery(expr string) ([]string, error) {
+	return tokenizeBoolExpr(expr, nil)
+}
+
+func parseTagQueryLeaf(tok string) (tagQueryNode, error) {
+	if tok == "" {
+		return nil, fmt.Errorf("expected a key=value or key predicate in tag query expression")
+	}
+
+	if key, value, ok := strings.Cut(tok, "="); ok {
+		if key == "" {
+			return nil, fmt.Errorf("tag query predicate %q is missing a key before \"=\"", tok)
+		}
+		return &tagQueryLeaf{key: key, value: value, hasValue: true}, nil
+	}
+
+	return &tagQueryLeaf{key: tok}, nil
+}
@@ -0,0 +1,137 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tagSelectorNode is a node in the boolean expression tree produced by
+// parseTagSelector. Leaves match a single tag, possibly with a glob
+// pattern; internal nodes combine leaves with AND/OR/NOT using the shared
+// boolExprNode engine in boolexpr.go.
+type tagSelectorNode = boolExprNode[map[string]bool]
+
+type tagAnd = boolExprAnd[map[string]bool]
+type tagOr = boolExprOr[map[string]bool]
+type tagNot = boolExprNot[map[string]bool]
+
+// tagLeaf matches a workspace if any of its tags matches pattern, which may
+// contain glob metacharacters (e.g. "env-*"). A leading "!" in the original
+// expression is captured as negate rather than folded into the pattern, so
+// that requiredTags can still recognize plain literal tags.
+type tagLeaf struct {
+	pattern string
+	negate  bool
+}
+
+func (n *tagLeaf) match(tags map[string]bool) bool {
+	matched := false
+	for tag := range tags {
+		if ok, _ := filepath.Match(n.pattern, tag); ok {
+			matched = true
+			break
+		}
+	}
+	if n.negate {
+		return !matched
+	}
+	return matched
+}
+
+// tagSelector is a parsed boolean tag expression, e.g.
+// "tag:prod AND (tag:us-* OR tag:eu-*)" or the shorthand "prod,!staging".
+type tagSelector struct {
+	root tagSelectorNode
+}
+
+// Match reports whether the given set of workspace tags satisfies the
+// selector.
+func (s tagSelector) Match(tags []string) bool {
+	set := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		set[t] = true
+	}
+	return s.root.match(set)
+}
+
+// requiredTags returns the literal (non-glob, non-negated) tags that a
+// workspace MUST have to have any chance of matching the selector. It's a
+// conservative, sound-but-incomplete analysis: it only walks AND nodes, so a
+// requirement on one side of an OR is never reported, since it isn't
+// actually required overall. The result is meant to be used as the
+// broadest-possible server-side tag filter, with the full expression then
+// applied client-side to the (smaller) result set.
+func (s tagSelector) requiredTags() []string {
+	seen := make(map[string]bool)
+	collectRequiredTags(s.root, seen)
+
+	tags := make([]string, 0, len(seen))
+	for t := range seen {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+func collectRequiredTags(n tagSelectorNode, seen map[string]bool) {
+	switch t := n.(type) {
+	case *tagAnd:
+		collectRequiredTags(t.left, seen)
+		collectRequiredTags(t.right, seen)
+	case *tagLeaf:
+		if !t.negate && !strings.ContainsAny(t.pattern, "*?[") {
+			seen[t.pattern] = true
+		}
+	}
+	// tagOr and tagNot contribute nothing: a tag required on only one side
+	// of an OR, or only to satisfy a negation, isn't required overall.
+}
+
+// parseTagSelector parses a boolean tag expression into a tagSelector.
+// Expressions are built from tag references (optionally prefixed "tag:",
+// optionally negated with a leading "!", and optionally containing glob
+// metacharacters like "env-*"), combined with the keywords AND, OR and NOT
+// (case-insensitive) and grouped with parentheses. "AND" binds tighter than
+// "OR", matching conventional boolean expression precedence. Tokenizing and
+// precedence climbing are shared with parseTagQuery via boolexpr.go; only
+// leaf parsing, and the extra "," separator below, differ.
+func parseTagSelector(expr string) (tagSelector, error) {
+	root, err := parseBoolExpr(expr, "tag selector", tokenizeTagSelector, parseTagSelectorLeaf)
+	if err != nil {
+		return tagSelector{}, err
+	}
+	return tagSelector{root: root}, nil
+}
+
+// tokenizeTagSelector splits expr into "(", ")", "AND", "OR", "NOT" and tag
+// tokens. Tag tokens may start with "!" to negate them inline, which the
+// tokenizer leaves untouched for the parser to interpret. Unlike a tag
+// query, "," is also treated as a separator, to support the comma-separated
+// shorthand (e.g. "prod,!staging").
+func tokenizeTagSelector(expr string) ([]string, error) {
+	return tokenizeBoolExpr(expr, func(r rune) bool { return r == ',' })
+}
+
+func parseTagSelectorLeaf(tok string) (tagSelectorNode, error) {
+	if tok == "" {
+		return nil, fmt.Errorf("expected a tag in tag selector expression")
+	}
+
+	negate := false
+	for strings.HasPrefix(tok, "!") {
+		negate = !negate
+		tok = strings.TrimPrefix(tok, "!")
+	}
+	tok = strings.TrimPrefix(tok, "tag:")
+
+	if tok == "" {
+		return nil, fmt.Errorf("expected a tag name after \"!\" or \"tag:\" in tag selector expression")
+	}
+
+	return &tagLeaf{pattern: tok, negate: negate}, nil
+}
@@ -0,0 +1,182 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/hashicorp/go-version"
+)
+
+// EnvVersionCompatibilityPolicyFile points at a local JSON file containing a
+// version compatibility policy document, for air-gapped TFE installs that
+// can't reach the hosted policy endpoint.
+const EnvVersionCompatibilityPolicyFile = "TF_CLOUD_VERSION_POLICY"
+
+// VersionCompatibilityPolicy decides which Terraform versions may safely
+// operate against a workspace pinned to an exact remote Terraform version.
+// VerifyWorkspaceTerraformVersion consults it instead of hard-coding
+// compatibility windows, so the compatibility model can evolve (or be
+// relaxed by an operator) without a Terraform release.
+type VersionCompatibilityPolicy interface {
+	// ConstraintFor returns the constraint the local Terraform version must
+	// satisfy to be considered compatible with a workspace pinned to the
+	// exact remoteVersion. rawConstraint is the constraint that applies with
+	// no policy override (parsed directly from the workspace's
+	// TerraformVersion), and is returned unchanged if no rule matches.
+	ConstraintFor(remoteVersion *version.Version, rawConstraint version.Constraints) version.Constraints
+}
+
+// defaultVersionCompatibilityPolicy implements the historical hard-coded
+// rules: 0.14 through the early 1.x series are mutually compatible, and from
+// 1.3.0 onward any x.y.* release is compatible with itself.
+type defaultVersionCompatibilityPolicy struct{}
+
+func (defaultVersionCompatibilityPolicy) ConstraintFor(remoteVersion *version.Version, rawConstraint version.Constraints) version.Constraints {
+	v014 := version.Must(version.NewSemver("0.14.0"))
+	v130 := version.Must(version.NewSemver("1.3.0"))
+
+	if remoteVersion.GreaterThanOrEqual(v014) && remoteVersion.LessThan(v130) {
+		c, err := version.NewConstraint(fmt.Sprintf(">= 0.14.0, < %s", v130.String()))
+		if err != nil {
+			panic(err)
+		}
+		return c
+	}
+
+	if remoteVersion.GreaterThanOrEqual(v130) {
+		segments := remoteVersion.Segments64()
+		if len(segments) >= 3 {
+			c, err := version.NewConstraint(fmt.Sprintf("~> %d.%d.0", segments[0], segments[1]))
+			if err != nil {
+				panic(err)
+			}
+			return c
+		}
+	}
+
+	return rawConstraint
+}
+
+// versionCompatibilityRule is one entry of a policy document: CompatibleWith
+// applies to any remote version satisfying Constraint.
+type versionCompatibilityRule struct {
+	Constraint     string `json:"constraint"`
+	CompatibleWith string `json:"compatible_with"`
+}
+
+// versionCompatibilityDocument is the JSON shape of a policy document,
+// whether loaded from EnvVersionCompatibilityPolicyFile or fetched from a
+// TFC/TFE host.
+type versionCompatibilityDocument struct {
+	Rules []versionCompatibilityRule `json:"rules"`
+}
+
+// documentVersionCompatibilityPolicy applies the first matching rule of a
+// loaded policy document, in order, falling back to rawConstraint if nothing
+// matches.
+type documentVersionCompatibilityPolicy struct {
+	rules []versionCompatibilityRule
+}
+
+func newDocumentVersionCompatibilityPolicy(doc versionCompatibilityDocument) (*documentVersionCompatibilityPolicy, error) {
+	for _, r := range doc.Rules {
+		if _, err := version.NewConstraint(r.Constraint); err != nil {
+			return nil, fmt.Errorf("invalid version policy rule constraint %q: %w", r.Constraint, err)
+		}
+		if _, err := version.NewConstraint(r.CompatibleWith); err != nil {
+			return nil, fmt.Errorf("invalid version policy rule compatible_with %q: %w", r.CompatibleWith, err)
+		}
+	}
+	return &documentVersionCompatibilityPolicy{rules: doc.Rules}, nil
+}
+
+func (p *documentVersionCompatibilityPolicy) ConstraintFor(remoteVersion *version.Version, rawConstraint version.Constraints) version.Constraints {
+	for _, r := range p.rules {
+		constraint, err := version.NewConstraint(r.Constraint)
+		if err != nil {
+			// Already validated in newDocumentVersionCompatibilityPolicy.
+			continue
+		}
+		if !constraint.Check(remoteVersion) {
+			continue
+		}
+
+		compatibleWith, err := version.NewConstraint(r.CompatibleWith)
+		if err != nil {
+			continue
+		}
+		return compatibleWith
+	}
+
+	return rawConstraint
+}
+
+// resolveVersionCompatibilityPolicy chooses the policy
+// VerifyWorkspaceTerraformVersion consults: EnvVersionCompatibilityPolicyFile
+// if set, otherwise a policy document fetched once per run from pingURL,
+// falling back to defaultVersionCompatibilityPolicy if neither is available.
+func resolveVersionCompatibilityPolicy(ctx context.Context, httpClient *http.Client, pingURL string) (VersionCompatibilityPolicy, error) {
+	if path := os.Getenv(EnvVersionCompatibilityPolicyFile); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", EnvVersionCompatibilityPolicyFile, err)
+		}
+
+		var doc versionCompatibilityDocument
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse %s as a version compatibility policy: %w", EnvVersionCompatibilityPolicyFile, err)
+		}
+		return newDocumentVersionCompatibilityPolicy(doc)
+	}
+
+	if pingURL == "" {
+		return defaultVersionCompatibilityPolicy{}, nil
+	}
+
+	doc, err := fetchVersionCompatibilityPolicy(ctx, httpClient, pingURL)
+	if err != nil {
+		// A host that doesn't publish a policy document, or is simply
+		// unreachable, isn't fatal; fall back to the built-in rules.
+		log.Printf("[TRACE] cloud: falling back to the default version compatibility policy: %s", err)
+		return defaultVersionCompatibilityPolicy{}, nil
+	}
+
+	return newDocumentVersionCompatibilityPolicy(doc)
+}
+
+// fetchVersionCompatibilityPolicy fetches a policy document from the TFC/TFE
+// ping endpoint (or an admin endpoint serving the same shape).
+func fetchVersionCompatibilityPolicy(ctx context.Context, httpClient *http.Client, pingURL string) (versionCompatibilityDocument, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pingURL, nil)
+	if err != nil {
+		return versionCompatibilityDocument{}, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return versionCompatibilityDocument{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return versionCompatibilityDocument{}, fmt.Errorf("version compatibility policy endpoint returned %s", resp.Status)
+	}
+
+	var doc versionCompatibilityDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return versionCompatibilityDocument{}, fmt.Errorf("failed to decode version compatibility policy: %w", err)
+	}
+
+	return doc, nil
+}
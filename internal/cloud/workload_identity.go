@@ -0,0 +1,175 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables used to configure workload identity federation, as
+// an alternative to a static token from the config block or the CLI
+// credentials file. These let CI workloads (GitHub Actions, Kubernetes,
+// etc.) authenticate using a short-lived JWT they already have, rather
+// than a long-lived PAT.
+const (
+	EnvWorkloadIdentityToken     = "TF_CLOUD_WORKLOAD_IDENTITY_TOKEN"
+	EnvWorkloadIdentityTokenFile = "TF_CLOUD_WORKLOAD_IDENTITY_TOKEN_FILE"
+)
+
+// workloadIdentityToken returns the raw JWT to exchange for a TFC/E API
+// token, read directly from EnvWorkloadIdentityToken if set, or else from
+// the file named by EnvWorkloadIdentityTokenFile. It returns an empty
+// string, with no error, if neither is set, so that callers can treat
+// that as "workload identity isn't configured" rather than a failure.
+func workloadIdentityToken() (string, error) {
+	if tok := os.Getenv(EnvWorkloadIdentityToken); tok != "" {
+		return tok, nil
+	}
+
+	if path := os.Getenv(EnvWorkloadIdentityTokenFile); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s from %s: %w", EnvWorkloadIdentityTokenFile, path, err)
+		}
+		return strings.TrimSpace(string(raw)), nil
+	}
+
+	return "", nil
+}
+
+// workloadIdentityExchangedToken is a cached TFC/E API token obtained by
+// exchanging a workload identity JWT, along with when it expires.
+type workloadIdentityExchangedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// workloadIdentityTokenCache caches exchanged tokens per token-exchange
+// endpoint, so that a single CLI invocation that calls Configure more than
+// once doesn't re-exchange the same identity token unnecessarily.
+type workloadIdentityTokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]workloadIdentityExchangedToken
+}
+
+var defaultWorkloadIdentityTokenCache = &workloadIdentityTokenCache{
+	tokens: make(map[string]workloadIdentityExchangedToken),
+}
+
+// workloadIdentityTokenExchangeRequest is the body POSTed to the
+// token-exchange endpoint.
+type workloadIdentityTokenExchangeRequest struct {
+	SubjectToken string `json:"subject_token"`
+}
+
+// workloadIdentityTokenExchangeResponse is the JSON body the token-exchange
+// endpoint is expected to return.
+type workloadIdentityTokenExchangeResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// exchangeWorkloadIdentityToken exchanges idToken for a short-lived TFC/E
+// API token by POSTing it to exchangeURL, caching the result until shortly
+// before it expires. Pass forceRefresh to bypass a cached token that the
+// server has since rejected, such as after a 401 response.
+func exchangeWorkloadIdentityToken(ctx context.Context, httpClient *http.Client, exchangeURL string, idToken string, forceRefresh bool) (string, error) {
+	defaultWorkloadIdentityTokenCache.mu.Lock()
+	if cached, ok := defaultWorkloadIdentityTokenCache.tokens[exchangeURL]; ok && !forceRefresh && time.Now().Before(cached.expiresAt) {
+		defaultWorkloadIdentityTokenCache.mu.Unlock()
+		return cached.token, nil
+	}
+	defaultWorkloadIdentityTokenCache.mu.Unlock()
+
+	reqBody, err := json.Marshal(workloadIdentityTokenExchangeRequest{SubjectToken: idToken})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode workload identity token exchange request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, exchangeURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to build workload identity token exchange request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange workload identity token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("workload identity token exchange failed with status %s", resp.Status)
+	}
+
+	var respBody workloadIdentityTokenExchangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+		return "", fmt.Errorf("failed to decode workload identity token exchange response: %w", err)
+	}
+	if respBody.AccessToken == "" {
+		return "", fmt.Errorf("workload identity token exchange response did not include an access token")
+	}
+
+	expiresAt := time.Now().Add(time.Duration(respBody.ExpiresIn) * time.Second)
+	if respBody.ExpiresIn <= 0 {
+		// Without a usable expiry we still cache briefly to avoid hammering
+		// the endpoint on every call within the same operation.
+		expiresAt = time.Now().Add(time.Minute)
+	}
+
+	defaultWorkloadIdentityTokenCache.mu.Lock()
+	defaultWorkloadIdentityTokenCache.tokens[exchangeURL] = workloadIdentityExchangedToken{
+		token:     respBody.AccessToken,
+		expiresAt: expiresAt,
+	}
+	defaultWorkloadIdentityTokenCache.mu.Unlock()
+
+	return respBody.AccessToken, nil
+}
+
+// workloadIdentityRoundTripper wraps the TFC/E API client's transport so that
+// a 401 response, which most likely means the exchanged token has been
+// revoked or expired early, forces a fresh token exchange and a single
+// retry of the request before giving up.
+type workloadIdentityRoundTripper struct {
+	base        http.RoundTripper
+	exchangeURL string
+	idToken     string
+}
+
+func (rt *workloadIdentityRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || req.GetBody == nil {
+		return resp, err
+	}
+
+	newToken, exchErr := exchangeWorkloadIdentityToken(req.Context(), nil, rt.exchangeURL, rt.idToken, true)
+	if exchErr != nil {
+		// We couldn't refresh the token, so return the original 401 as-is.
+		return resp, err
+	}
+
+	body, bodyErr := req.GetBody()
+	if bodyErr != nil {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	retryReq := req.Clone(req.Context())
+	retryReq.Body = body
+	retryReq.Header.Set("Authorization", "Bearer "+newToken)
+
+	return rt.base.RoundTrip(retryReq)
+}
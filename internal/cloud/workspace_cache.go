@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cloud
+
+import (
+	"sync"
+	"time"
+)
+
+// workspaceListCacheTTL bounds how long a cached Workspaces() result may be
+// reused. It's short enough that a long-running CLI invocation will still
+// notice workspaces created or deleted elsewhere, but long enough to avoid
+// re-paginating Terraform Cloud for the repeated Workspaces() calls a single
+// command (e.g. a targeted apply across many workspaces) tends to make.
+const workspaceListCacheTTL = 30 * time.Second
+
+// workspaceListCacheKey identifies a single Workspaces() result: the same
+// organization, project and tag selector will always produce the same list,
+// modulo workspaces changing remotely.
+type workspaceListCacheKey struct {
+	organization string
+	project      string
+	selector     string
+}
+
+type workspaceListCacheEntry struct {
+	names     []string
+	expiresAt time.Time
+}
+
+// workspaceListCache is an in-memory, per-backend-instance cache of
+// Workspaces() results, so that the several calls a single CLI invocation
+// tends to make don't each re-paginate Terraform Cloud from scratch.
+type workspaceListCache struct {
+	mu      sync.Mutex
+	entries map[workspaceListCacheKey]workspaceListCacheEntry
+}
+
+func newWorkspaceListCache() *workspaceListCache {
+	return &workspaceListCache{
+		entries: make(map[workspaceListCacheKey]workspaceListCacheEntry),
+	}
+}
+
+func (c *workspaceListCache) get(key workspaceListCacheKey) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.names, true
+}
+
+func (c *workspaceListCache) put(key workspaceListCacheKey, names []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = workspaceListCacheEntry{
+		names:     names,
+		expiresAt: time.Now().Add(workspaceListCacheTTL),
+	}
+}
+
+// clear discards all cached results. Called whenever this backend instance
+// creates or deletes a workspace, since that invalidates any cached listing
+// for this organization.
+func (c *workspaceListCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[workspaceListCacheKey]workspaceListCacheEntry)
+}
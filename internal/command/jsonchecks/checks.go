@@ -0,0 +1,128 @@
+// Package jsonchecks renders Terraform's check results (the outcome of
+// resource/output conditions, check blocks, and input variable validations)
+// into the object-oriented JSON shape shared by `terraform show -json` and
+// `terraform test`, so both consumers stay in sync with a single emitter.
+package jsonchecks
+
+import (
+	"encoding/json"
+
+	"github.com/hashicorp/terraform/internal/addrs"
+	"github.com/hashicorp/terraform/internal/checks"
+	"github.com/hashicorp/terraform/internal/states"
+)
+
+// FormatVersion is the version of the check_results json format. It's
+// independent of jsonplan.FormatVersion, since check_results can also be
+// emitted outside of a plan (e.g. by `terraform test`).
+const FormatVersion = "1.0"
+
+// CheckResults is the top-level object-oriented representation of a
+// states.CheckResults tree: one entry per configuration object (a resource,
+// an output value, a check block, or an input variable), each carrying its
+// own status plus the status and failure messages of every instance of
+// that object.
+type CheckResults struct {
+	FormatVersion string `json:"format_version"`
+
+	// Preconditions, Postconditions, CheckBlocks and InputValidations
+	// partition the same underlying states.CheckResults tree by what kind
+	// of check produced each object's result.
+	//
+	// Resource and output conditions can't currently be told apart as
+	// precondition vs. postcondition below the whole-object level: the
+	// aggregate states.CheckResults tree doesn't retain which kind of
+	// condition produced a given object's result, only the object's
+	// combined status. So, matching the legacy condition_results output,
+	// resource objects are reported under Postconditions and output
+	// objects under Preconditions. Check blocks and input variable
+	// validations don't have this ambiguity, since their address kinds are
+	// distinct from resource and output addresses.
+	Preconditions    []CheckResultObject `json:"preconditions,omitempty"`
+	Postconditions   []CheckResultObject `json:"postconditions,omitempty"`
+	CheckBlocks      []CheckResultObject `json:"check_blocks,omitempty"`
+	InputValidations []CheckResultObject `json:"input_validations,omitempty"`
+}
+
+// CheckResultObject is a single configuration object (e.g. one resource,
+// potentially with multiple instances via count/for_each) and its checks'
+// combined outcome.
+type CheckResultObject struct {
+	Address   string                `json:"address"`
+	Status    string                `json:"status"`
+	Instances []CheckResultInstance `json:"instances,omitempty"`
+}
+
+// CheckResultInstance is the outcome of a single instance of a configuration
+// object.
+type CheckResultInstance struct {
+	Address         string   `json:"address"`
+	Status          string   `json:"status"`
+	FailureMessages []string `json:"failure_messages,omitempty"`
+}
+
+// MarshalCheckResults renders results as the object-oriented check_results
+// document described by CheckResults.
+func MarshalCheckResults(results *states.CheckResults) (json.RawMessage, error) {
+	if results == nil {
+		return nil, nil
+	}
+
+	out := CheckResults{FormatVersion: FormatVersion}
+
+	for _, configElem := range results.ConfigResults.Elems {
+		configAddr := configElem.Key
+		agg := configElem.Value
+
+		obj := CheckResultObject{
+			Address: configAddr.String(),
+			Status:  statusString(agg.Status),
+		}
+
+		var kind addrs.Checkable
+		for _, objectElem := range agg.ObjectResults.Elems {
+			instAddr := objectElem.Key
+			result := objectElem.Value
+			if kind == nil {
+				kind = instAddr
+			}
+
+			obj.Instances = append(obj.Instances, CheckResultInstance{
+				Address:         instAddr.String(),
+				Status:          statusString(result.Status),
+				FailureMessages: result.FailureMessages,
+			})
+		}
+
+		switch kind.(type) {
+		case addrs.AbsResourceInstance:
+			out.Postconditions = append(out.Postconditions, obj)
+		case addrs.AbsOutputValue:
+			out.Preconditions = append(out.Preconditions, obj)
+		case addrs.Check:
+			out.CheckBlocks = append(out.CheckBlocks, obj)
+		case addrs.AbsInputVariableInstance:
+			out.InputValidations = append(out.InputValidations, obj)
+		default:
+			// Unrecognized checkable kind: still report the object rather
+			// than silently drop it, grouped with the other resource-like
+			// fudge so nothing goes missing.
+			out.Postconditions = append(out.Postconditions, obj)
+		}
+	}
+
+	return json.Marshal(out)
+}
+
+func statusString(status checks.Status) string {
+	switch status {
+	case checks.StatusPass:
+		return "pass"
+	case checks.StatusFail:
+		return "fail"
+	case checks.StatusError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
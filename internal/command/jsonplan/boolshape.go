@@ -0,0 +1,127 @@
+package jsonplan
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/internal/lang/marks"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// recognizedMarks lists the cty marks this package knows how to render as a
+// compacted boolean-shape cty.Value, alongside the name each one is known by
+// in plan JSON (e.g. "sensitive" for the sensitive_values fields). Adding
+// support for a future mark - ephemeral, say - means adding one entry here,
+// not a new copy of the recursion boolValueMarks implements.
+var recognizedMarks = map[string]interface{}{
+	"sensitive": marks.Sensitive,
+}
+
+// RecognizedMarkNames returns the names of the cty marks this package can
+// render as a boolean-shape plan JSON field, such as "sensitive".
+func RecognizedMarkNames() []string {
+	names := make([]string, 0, len(recognizedMarks))
+	for name := range recognizedMarks {
+		names = append(names, name)
+	}
+	return names
+}
+
+// boolShapeLeaf decides, for a single cty.Value that may still carry its
+// original cty marks, whether boolShape should report it as cty.True or
+// cty.False without recursing any further - or, via the second return
+// value, that this value isn't a leaf and boolShape should walk into its
+// elements instead.
+type boolShapeLeaf func(val cty.Value) (result cty.Value, isLeaf bool)
+
+// boolShape is the traversal unknownAsBool and boolValueMarks both build on:
+// given leaf's verdict on val, either return it directly or recurse into
+// val's elements, producing the same compacted boolean-shape result either
+// caller already documents - "false" omitted from object/map results for
+// compactness, and sequence/mapping types normalized to tuple/object since
+// the result is only ever going to be serialized as JSON anyway.
+func boolShape(val cty.Value, leaf boolShapeLeaf) cty.Value {
+	if result, ok := leaf(val); ok {
+		return result
+	}
+
+	val, _ = val.Unmark()
+	ty := val.Type()
+	switch {
+	case ty.IsListType() || ty.IsTupleType() || ty.IsSetType():
+		length := val.LengthInt()
+		if length == 0 {
+			// If there are no elements then there's nothing to report
+			return cty.EmptyTupleVal
+		}
+		vals := make([]cty.Value, 0, length)
+		it := val.ElementIterator()
+		for it.Next() {
+			_, v := it.Element()
+			vals = append(vals, boolShape(v, leaf))
+		}
+		// We always use a tuple here, because the recursive calls above may
+		// have given the elements different types, and our ultimate goal is
+		// to marshal to JSON anyway, where all of these sequence types are
+		// indistinguishable.
+		return cty.TupleVal(vals)
+	case ty.IsMapType() || ty.IsObjectType():
+		var length int
+		switch {
+		case ty.IsMapType():
+			length = val.LengthInt()
+		default:
+			length = len(ty.AttributeTypes())
+		}
+		if length == 0 {
+			return cty.EmptyObjectVal
+		}
+		vals := make(map[string]cty.Value)
+		it := val.ElementIterator()
+		for it.Next() {
+			k, v := it.Element()
+			vAsBool := boolShape(v, leaf)
+			// Omit all of the "false"s for more compact serialization.
+			if !vAsBool.RawEquals(cty.False) {
+				vals[k.AsString()] = vAsBool
+			}
+		}
+		// As above, we always use an object here since the recursive calls
+		// may have given the elements different types.
+		return cty.ObjectVal(vals)
+	default:
+		// Should never happen, since leaf is expected to handle every
+		// primitive type itself.
+		panic(fmt.Sprintf("boolShape cannot handle %#v", val))
+	}
+}
+
+// boolValueMarks recursively walks val - which, unlike unknownAsBool's
+// input, may still carry its original cty marks, so callers no longer need
+// to unmark it first - and produces a companion boolean-shape cty.Value
+// describing which elements carry mark: a leaf (or a whole container,
+// reported without recursing into its elements) carrying mark becomes
+// cty.True.
+func boolValueMarks(val cty.Value, mark interface{}) cty.Value {
+	return boolShape(val, func(v cty.Value) (cty.Value, bool) {
+		if v.HasMark(mark) {
+			return cty.True, true
+		}
+		switch {
+		case v.IsNull():
+			return cty.False, true
+		case !v.IsKnown():
+			return cty.False, true
+		case v.Type().IsPrimitiveType():
+			return cty.False, true
+		default:
+			return cty.NilVal, false
+		}
+	})
+}
+
+// sensitiveAsBool is unknownAsBool's companion for sensitivity: the same
+// compacted boolean shape, but describing which elements of val carry the
+// sensitive mark rather than which are unknown.
+func sensitiveAsBool(val cty.Value) cty.Value {
+	return boolValueMarks(val, recognizedMarks["sensitive"])
+}
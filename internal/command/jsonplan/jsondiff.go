@@ -0,0 +1,328 @@
+package jsonplan
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// jsonPatchOp is a single RFC 6902 (JSON Patch) operation, extended with two
+// sibling fields so that a consumer doesn't have to cross-reference a
+// change's after_unknown/after_sensitive trees to find out whether the
+// value an operation introduces is actually knowable yet, or should be
+// redacted before being displayed.
+type jsonPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+
+	// Unknown is set when Value (for "add"/"replace") won't actually be
+	// known until after apply.
+	Unknown bool `json:"unknown,omitempty"`
+
+	// Sensitive is set when the value this operation introduces or removes
+	// is marked sensitive.
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+// diffAsJSONPatch computes an RFC 6902 JSON Patch document describing the
+// before -> after transformation of a single change, for the optional
+// "diff" field MarshalOpts.IncludeJSONPatch enables.
+//
+// unknown, beforeSensitive and afterSensitive are the same compacted
+// boolean trees (built by unknownAsBool / sensitiveAsBool) used
+// to populate a change's after_unknown/before_sensitive/after_sensitive
+// fields: a "true" at a given path means the corresponding leaf in after
+// (for unknown) or before/after (for sensitive) carries that marking, and
+// an absent path means false.
+//
+// This is a structural diff, not a true minimal edit script: whole
+// subtrees that changed shape (e.g. a list that became an object) collapse
+// into one "replace" rather than being decomposed further, and reordering
+// within a list is only detected when an element's value reappears
+// unchanged elsewhere in the same list - anything short of that is treated
+// as a replace of the differing positions.
+func diffAsJSONPatch(before, after, unknown, beforeSensitive, afterSensitive cty.Value) ([]jsonPatchOp, error) {
+	d := &jsonDiffer{}
+	if err := d.diff("", before, after, unknown, beforeSensitive, afterSensitive); err != nil {
+		return nil, err
+	}
+	return d.ops, nil
+}
+
+type jsonDiffer struct {
+	ops []jsonPatchOp
+}
+
+func (d *jsonDiffer) diff(path string, before, after, unknown, beforeSensitive, afterSensitive cty.Value) error {
+	beforeAbsent := before == cty.NilVal || before.IsNull()
+	afterAbsent := after == cty.NilVal || after.IsNull()
+
+	switch {
+	case beforeAbsent && afterAbsent:
+		return nil
+	case beforeAbsent:
+		return d.add(path, after, unknown, afterSensitive)
+	case afterAbsent:
+		return d.remove(path, beforeSensitive)
+	}
+
+	if before.RawEquals(after) {
+		return nil
+	}
+
+	beforeTy, afterTy := before.Type(), after.Type()
+
+	switch {
+	case !after.IsKnown():
+		return d.replace(path, after, unknown, afterSensitive)
+
+	case beforeTy.IsObjectType() && afterTy.IsObjectType(),
+		beforeTy.IsObjectType() && afterTy.IsMapType(),
+		beforeTy.IsMapType() && afterTy.IsObjectType(),
+		beforeTy.IsMapType() && afterTy.IsMapType():
+		return d.diffObject(path, before, after, unknown, beforeSensitive, afterSensitive)
+
+	case isSequenceType(beforeTy) && isSequenceType(afterTy):
+		return d.diffSequence(path, before, after, unknown, beforeSensitive, afterSensitive)
+
+	default:
+		// Either a primitive changed value, or the value changed shape
+		// entirely (e.g. a list became an object) - either way there's no
+		// finer-grained edit to describe, so replace the whole subtree.
+		return d.replace(path, after, unknown, afterSensitive)
+	}
+}
+
+func (d *jsonDiffer) diffObject(path string, before, after, unknown, beforeSensitive, afterSensitive cty.Value) error {
+	beforeVals := attrMap(before)
+	afterVals := attrMap(after)
+
+	keys := make(map[string]bool, len(beforeVals)+len(afterVals))
+	for k := range beforeVals {
+		keys[k] = true
+	}
+	for k := range afterVals {
+		keys[k] = true
+	}
+
+	for _, k := range sortedKeys(keys) {
+		childPath := pointerAppend(path, k)
+		bv, bOK := beforeVals[k]
+		av, aOK := afterVals[k]
+
+		var b, a cty.Value
+		if bOK {
+			b = bv
+		} else {
+			b = cty.NilVal
+		}
+		if aOK {
+			a = av
+		} else {
+			a = cty.NilVal
+		}
+
+		if err := d.diff(
+			childPath,
+			b, a,
+			lookupPath(unknown, k),
+			lookupPath(beforeSensitive, k),
+			lookupPath(afterSensitive, k),
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *jsonDiffer) diffSequence(path string, before, after, unknown, beforeSensitive, afterSensitive cty.Value) error {
+	beforeVals := before.AsValueSlice()
+	afterVals := after.AsValueSlice()
+
+	// Detect simple reorderings: an element unchanged in value but at a
+	// different index becomes a "move" instead of a remove+add pair.
+	usedBefore := make([]bool, len(beforeVals))
+
+	minLen := len(beforeVals)
+	if len(afterVals) < minLen {
+		minLen = len(afterVals)
+	}
+
+	for i := 0; i < minLen; i++ {
+		if beforeVals[i].RawEquals(afterVals[i]) {
+			usedBefore[i] = true
+			continue
+		}
+
+		if moveFrom := findUnusedEqual(beforeVals, usedBefore, afterVals[i]); moveFrom >= 0 {
+			usedBefore[moveFrom] = true
+			d.ops = append(d.ops, jsonPatchOp{
+				Op:   "move",
+				From: pointerAppendIndex(path, moveFrom),
+				Path: pointerAppendIndex(path, i),
+			})
+			continue
+		}
+
+		if err := d.diff(
+			pointerAppendIndex(path, i),
+			beforeVals[i], afterVals[i],
+			lookupIndex(unknown, i),
+			lookupIndex(beforeSensitive, i),
+			lookupIndex(afterSensitive, i),
+		); err != nil {
+			return err
+		}
+	}
+
+	for i := minLen; i < len(beforeVals); i++ {
+		if err := d.remove(pointerAppendIndex(path, minLen), lookupIndex(beforeSensitive, i)); err != nil {
+			return err
+		}
+	}
+	for i := minLen; i < len(afterVals); i++ {
+		if err := d.add(pointerAppendIndex(path, i), afterVals[i], lookupIndex(unknown, i), lookupIndex(afterSensitive, i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *jsonDiffer) add(path string, value, unknown, sensitive cty.Value) error {
+	return d.emit("add", path, value, unknown, sensitive)
+}
+
+func (d *jsonDiffer) replace(path string, value, unknown, sensitive cty.Value) error {
+	return d.emit("replace", path, value, unknown, sensitive)
+}
+
+func (d *jsonDiffer) remove(path string, sensitive cty.Value) error {
+	d.ops = append(d.ops, jsonPatchOp{
+		Op:        "remove",
+		Path:      path,
+		Sensitive: isTrue(sensitive),
+	})
+	return nil
+}
+
+func (d *jsonDiffer) emit(op, path string, value, unknown, sensitive cty.Value) error {
+	entry := jsonPatchOp{
+		Op:        op,
+		Path:      path,
+		Unknown:   !value.IsKnown() || isTrue(unknown),
+		Sensitive: isTrue(sensitive),
+	}
+
+	if value.IsKnown() && !value.IsNull() {
+		raw, err := ctyjson.Marshal(value, value.Type())
+		if err != nil {
+			return err
+		}
+		entry.Value = raw
+	}
+
+	d.ops = append(d.ops, entry)
+	return nil
+}
+
+func isSequenceType(ty cty.Type) bool {
+	return ty.IsListType() || ty.IsTupleType() || ty.IsSetType()
+}
+
+func attrMap(val cty.Value) map[string]cty.Value {
+	vals := make(map[string]cty.Value)
+	if val == cty.NilVal || val.IsNull() || !val.IsKnown() {
+		return vals
+	}
+	it := val.ElementIterator()
+	for it.Next() {
+		k, v := it.Element()
+		vals[k.AsString()] = v
+	}
+	return vals
+}
+
+func sortedKeys(keys map[string]bool) []string {
+	ret := make([]string, 0, len(keys))
+	for k := range keys {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// findUnusedEqual returns the index of the first not-yet-used element of
+// vals that's RawEquals to target, or -1 if there is none.
+func findUnusedEqual(vals []cty.Value, used []bool, target cty.Value) int {
+	for i, v := range vals {
+		if !used[i] && v.RawEquals(target) {
+			return i
+		}
+	}
+	return -1
+}
+
+// lookupPath and lookupIndex walk one step into a compacted boolean tree
+// (as produced by unknownAsBool / sensitiveAsBool), returning
+// cty.NilVal - treated as "false" by isTrue - if the step doesn't apply.
+// A container that is itself "true" (a wholly unknown or sensitive value)
+// is returned as is, so the marking carries down to everything inside it.
+func lookupPath(container cty.Value, key string) cty.Value {
+	if container == cty.NilVal || !container.IsKnown() || container.IsNull() {
+		return cty.NilVal
+	}
+	if isTrue(container) {
+		return container
+	}
+	ty := container.Type()
+	if !ty.IsObjectType() && !ty.IsMapType() {
+		return cty.NilVal
+	}
+	if v, ok := attrMap(container)[key]; ok {
+		return v
+	}
+	return cty.NilVal
+}
+
+func lookupIndex(container cty.Value, i int) cty.Value {
+	if container == cty.NilVal || !container.IsKnown() || container.IsNull() {
+		return cty.NilVal
+	}
+	if isTrue(container) {
+		return container
+	}
+	ty := container.Type()
+	if !isSequenceType(ty) {
+		return cty.NilVal
+	}
+	if i < 0 || i >= container.LengthInt() {
+		return cty.NilVal
+	}
+	return container.Index(cty.NumberIntVal(int64(i)))
+}
+
+func isTrue(v cty.Value) bool {
+	return v != cty.NilVal && v.IsKnown() && !v.IsNull() && v.Type() == cty.Bool && v.True()
+}
+
+func pointerAppend(base, token string) string {
+	return base + "/" + jsonPointerEscape(token)
+}
+
+func pointerAppendIndex(base string, i int) string {
+	return base + "/" + strconv.Itoa(i)
+}
+
+func jsonPointerEscape(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	token = strings.ReplaceAll(token, "/", "~1")
+	return token
+}
@@ -3,7 +3,10 @@ package jsonplan
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/zclconf/go-cty/cty"
 	ctyjson "github.com/zclconf/go-cty/cty/json"
@@ -24,7 +27,24 @@ import (
 // FormatVersion represents the version of the json format and will be
 // incremented for any change to this format that requires changes to a
 // consuming parser.
-const FormatVersion = "1.1"
+const FormatVersion = "1.2"
+
+// MarshalOpts gates optional, non-default additions to the output of
+// Marshal. The zero value reproduces the previous, unconditional output, so
+// existing callers that don't pass any MarshalOpts keep seeing exactly the
+// same bytes they always have.
+type MarshalOpts struct {
+	// IncludeJSONPatch causes each change (both resource changes and output
+	// changes) to carry an additional "diff" field: an RFC 6902 JSON Patch
+	// document describing the before -> after transformation, for
+	// consumers that would otherwise have to diff Before/After themselves.
+	IncludeJSONPatch bool
+
+	// Progress, if set, is called by an Encoder (but not by Marshal) as it
+	// streams resource_changes/resource_drift, so a caller can report
+	// throughput on very large plans.
+	Progress ProgressFunc
+}
 
 // Plan is the top-level representation of the json format of a plan. It includes
 // the complete config and current state.
@@ -41,8 +61,31 @@ type plan struct {
 	PriorState         json.RawMessage   `json:"prior_state,omitempty"`
 	Config             json.RawMessage   `json:"configuration,omitempty"`
 	RelevantAttributes []resourceAttr    `json:"relevant_attributes,omitempty"`
-	Conditions         []conditionResult `json:"condition_results,omitempty"`
-	Checks             json.RawMessage   `json:"checks,omitempty"`
+
+	// Conditions is the legacy, flattened view of check results: one entry
+	// per declared condition, with resource/output objects approximated as
+	// "ResourcePostcondition"/"OutputPrecondition" regardless of which kind
+	// of condition actually produced them. As of format version 1.2 this is
+	// deprecated in favor of CheckResults, which retains the real
+	// precondition/postcondition/check-block/input-validation distinction
+	// and per-instance aggregation; Conditions is kept populated alongside
+	// it for one release to give consumers time to migrate.
+	Conditions []conditionResult `json:"condition_results,omitempty"`
+	Checks     json.RawMessage   `json:"checks,omitempty"`
+
+	// CheckResults is the object-oriented replacement for Conditions,
+	// described in jsonchecks.CheckResults. Added in format version 1.2.
+	CheckResults json.RawMessage `json:"check_results,omitempty"`
+
+	// DeferredChanges describes resource instances that this round wasn't
+	// able to fully plan, so that external tooling can detect and reason
+	// about an incomplete plan without having to parse human-readable log
+	// output. This is deliberately parallel in shape to ResourceChanges.
+	DeferredChanges []deferredChange `json:"deferred_changes,omitempty"`
+
+	// opts controls optional output that isn't part of the stable,
+	// unconditional format; it's never itself serialized.
+	opts MarshalOpts `json:"-"`
 }
 
 func newPlan() *plan {
@@ -105,6 +148,60 @@ type change struct {
 	// consists of one or more steps, each of which will be a number or a
 	// string.
 	ReplacePaths json.RawMessage `json:"replace_paths,omitempty"`
+
+	// ReplacePathsPointer is the same set of paths as ReplacePaths, each
+	// rendered as an RFC 6901 JSON Pointer string instead of an array of
+	// steps, for consumers (jq, browsers, generic JSON tooling) that can
+	// address a document location directly.
+	ReplacePathsPointer []string `json:"replace_paths_pointer,omitempty"`
+
+	// Diff is an RFC 6902 JSON Patch document describing the Before -> After
+	// transformation as a sequence of add/remove/replace/move operations.
+	// It's only populated when Marshal was called with
+	// MarshalOpts.IncludeJSONPatch set.
+	Diff json.RawMessage `json:"diff,omitempty"`
+}
+
+// deferredChange is the representation of a single resource instance that
+// could not be fully planned in this round, because some part of its
+// configuration depends on a value that won't be known until a later
+// round.
+type deferredChange struct {
+	// Address identifies the specific resource instance that was deferred,
+	// using the same string representation as ResourceChange.Address.
+	Address string `json:"address"`
+
+	// Reason is a short machine-readable string identifying why this
+	// instance was deferred, such as "instance_count_unknown" or
+	// "deferred_prereq".
+	Reason string `json:"reason"`
+
+	// PlannedAction is the tentative action Terraform expects to take for
+	// this instance once it's no longer deferred, using the same action
+	// strings as Change.Actions.
+	PlannedAction string `json:"planned_action,omitempty"`
+
+	// PlaceholderValues is the best-known value for this instance at the
+	// end of this round, with the same unknown-marking conventions as
+	// Change.AfterUnknown.
+	PlaceholderValues json.RawMessage `json:"placeholder_values,omitempty"`
+
+	// UpstreamAddresses lists the other resource instances whose own
+	// deferral caused this one, if any. This is empty when the deferral
+	// originates with this instance itself, such as when its own
+	// configuration has an unknown instance count.
+	UpstreamAddresses []string `json:"upstream_addresses,omitempty"`
+
+	// Message is an optional human-readable elaboration on Reason, provided
+	// by the provider for reasons like rate limiting where there's useful
+	// detail beyond what the reason code alone conveys.
+	Message string `json:"message,omitempty"`
+
+	// RetryAfterSeconds is an optional hint, provided by the provider,
+	// suggesting how long to wait before the next round is likely to make
+	// progress on this instance. It's omitted when the provider gave no
+	// such hint.
+	RetryAfterSeconds *float64 `json:"retry_after_seconds,omitempty"`
 }
 
 type output struct {
@@ -122,14 +219,22 @@ type variable struct {
 }
 
 // Marshal returns the json encoding of a terraform plan.
+//
+// opts is variadic purely so that existing call sites don't need to change:
+// at most one MarshalOpts is accepted, and omitting it entirely reproduces
+// the previous, unconditional output.
 func Marshal(
 	config *configs.Config,
 	p *plans.Plan,
 	sf *statefile.File,
 	schemas *terraform.Schemas,
+	opts ...MarshalOpts,
 ) ([]byte, error) {
 	output := newPlan()
 	output.TerraformVersion = version.String()
+	if len(opts) > 0 {
+		output.opts = opts[0]
+	}
 
 	err := output.marshalPlanVariables(p.VariableValues, config.Module.Variables)
 	if err != nil {
@@ -207,6 +312,11 @@ func Marshal(
 		return nil, fmt.Errorf("error marshaling config: %s", err)
 	}
 
+	// output.DeferredChanges
+	if err := output.marshalDeferredChanges(p.DeferredResources, schemas); err != nil {
+		return nil, fmt.Errorf("error marshaling deferred changes: %s", err)
+	}
+
 	ret, err := json.Marshal(output)
 	return ret, err
 }
@@ -269,169 +379,263 @@ func (p *plan) marshalResourceChanges(resources []*plans.ResourceInstanceChangeS
 	var ret []resourceChange
 
 	for _, rc := range resources {
-		var r resourceChange
-		addr := rc.Addr
-		r.Address = addr.String()
-		if !addr.Equal(rc.PrevRunAddr) {
-			r.PreviousAddress = rc.PrevRunAddr.String()
-		}
-
-		dataSource := addr.Resource.Resource.Mode == addrs.DataResourceMode
-		// We create "delete" actions for data resources so we can clean up
-		// their entries in state, but this is an implementation detail that
-		// users shouldn't see.
-		if dataSource && rc.Action == plans.Delete {
+		r, include, err := p.buildResourceChange(rc, schemas)
+		if err != nil {
+			return nil, err
+		}
+		if !include {
 			continue
 		}
+		ret = append(ret, r)
+	}
 
-		schema, _ := schemas.ResourceTypeConfig(
-			rc.ProviderAddr.Provider,
-			addr.Resource.Resource.Mode,
-			addr.Resource.Resource.Type,
-		)
-		if schema == nil {
-			return nil, fmt.Errorf("no schema found for %s (in provider %s)", r.Address, rc.ProviderAddr.Provider)
-		}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Address < ret[j].Address
+	})
 
-		changeV, err := rc.Decode(schema.ImpliedType())
-		if err != nil {
-			return nil, err
-		}
-		// We drop the marks from the change, as decoding is only an
-		// intermediate step to re-encode the values as json
-		changeV.Before, _ = changeV.Before.UnmarkDeep()
-		changeV.After, _ = changeV.After.UnmarkDeep()
+	return ret, nil
+}
 
-		var before, after []byte
-		var beforeSensitive, afterSensitive []byte
-		var afterUnknown cty.Value
+// buildResourceChange decodes and formats a single resource instance
+// change. It's factored out of marshalResourceChanges so that MarshalTo can
+// build and encode one resourceChange at a time, rather than first
+// accumulating the whole slice the way marshalResourceChanges does.
+//
+// include is false for the "delete" actions synthesized for data resources
+// to clean up their state entries, which callers shouldn't see.
+func (p *plan) buildResourceChange(rc *plans.ResourceInstanceChangeSrc, schemas *terraform.Schemas) (r resourceChange, include bool, err error) {
+	addr := rc.Addr
+	r.Address = addr.String()
+	if !addr.Equal(rc.PrevRunAddr) {
+		r.PreviousAddress = rc.PrevRunAddr.String()
+	}
 
-		if changeV.Before != cty.NilVal {
-			before, err = ctyjson.Marshal(changeV.Before, changeV.Before.Type())
-			if err != nil {
-				return nil, err
-			}
-			marks := rc.BeforeValMarks
-			if schema.ContainsSensitive() {
-				marks = append(marks, schema.ValueMarks(changeV.Before, nil)...)
-			}
-			bs := jsonstate.SensitiveAsBool(changeV.Before.MarkWithPaths(marks))
-			beforeSensitive, err = ctyjson.Marshal(bs, bs.Type())
+	dataSource := addr.Resource.Resource.Mode == addrs.DataResourceMode
+	// We create "delete" actions for data resources so we can clean up
+	// their entries in state, but this is an implementation detail that
+	// users shouldn't see.
+	if dataSource && rc.Action == plans.Delete {
+		return resourceChange{}, false, nil
+	}
+
+	schema, _ := schemas.ResourceTypeConfig(
+		rc.ProviderAddr.Provider,
+		addr.Resource.Resource.Mode,
+		addr.Resource.Resource.Type,
+	)
+	if schema == nil {
+		return resourceChange{}, false, fmt.Errorf("no schema found for %s (in provider %s)", r.Address, rc.ProviderAddr.Provider)
+	}
+
+	changeV, err := rc.Decode(schema.ImpliedType())
+	if err != nil {
+		return resourceChange{}, false, err
+	}
+	// We drop the marks from the change, as decoding is only an
+	// intermediate step to re-encode the values as json
+	changeV.Before, _ = changeV.Before.UnmarkDeep()
+	changeV.After, _ = changeV.After.UnmarkDeep()
+
+	var before, after []byte
+	var beforeSensitive, afterSensitive []byte
+	var afterUnknown cty.Value
+	var bsVal, asVal cty.Value
+
+	if changeV.Before != cty.NilVal {
+		before, err = ctyjson.Marshal(changeV.Before, changeV.Before.Type())
+		if err != nil {
+			return resourceChange{}, false, err
+		}
+		marks := rc.BeforeValMarks
+		if schema.ContainsSensitive() {
+			marks = append(marks, schema.ValueMarks(changeV.Before, nil)...)
+		}
+		bsVal = sensitiveAsBool(changeV.Before.MarkWithPaths(marks))
+		beforeSensitive, err = ctyjson.Marshal(bsVal, bsVal.Type())
+		if err != nil {
+			return resourceChange{}, false, err
+		}
+	}
+	if changeV.After != cty.NilVal {
+		if changeV.After.IsWhollyKnown() {
+			after, err = ctyjson.Marshal(changeV.After, changeV.After.Type())
 			if err != nil {
-				return nil, err
+				return resourceChange{}, false, err
 			}
-		}
-		if changeV.After != cty.NilVal {
-			if changeV.After.IsWhollyKnown() {
-				after, err = ctyjson.Marshal(changeV.After, changeV.After.Type())
-				if err != nil {
-					return nil, err
-				}
-				afterUnknown = cty.EmptyObjectVal
+			afterUnknown = cty.EmptyObjectVal
+		} else {
+			filteredAfter := omitUnknowns(changeV.After)
+			if filteredAfter.IsNull() {
+				after = nil
 			} else {
-				filteredAfter := omitUnknowns(changeV.After)
-				if filteredAfter.IsNull() {
-					after = nil
-				} else {
-					after, err = ctyjson.Marshal(filteredAfter, filteredAfter.Type())
-					if err != nil {
-						return nil, err
-					}
+				after, err = ctyjson.Marshal(filteredAfter, filteredAfter.Type())
+				if err != nil {
+					return resourceChange{}, false, err
 				}
-				afterUnknown = unknownAsBool(changeV.After)
-			}
-			marks := rc.AfterValMarks
-			if schema.ContainsSensitive() {
-				marks = append(marks, schema.ValueMarks(changeV.After, nil)...)
-			}
-			as := jsonstate.SensitiveAsBool(changeV.After.MarkWithPaths(marks))
-			afterSensitive, err = ctyjson.Marshal(as, as.Type())
-			if err != nil {
-				return nil, err
 			}
+			afterUnknown = unknownAsBool(changeV.After)
+		}
+		marks := rc.AfterValMarks
+		if schema.ContainsSensitive() {
+			marks = append(marks, schema.ValueMarks(changeV.After, nil)...)
+		}
+		asVal = sensitiveAsBool(changeV.After.MarkWithPaths(marks))
+		afterSensitive, err = ctyjson.Marshal(asVal, asVal.Type())
+		if err != nil {
+			return resourceChange{}, false, err
 		}
+	}
+
+	a, err := ctyjson.Marshal(afterUnknown, afterUnknown.Type())
+	if err != nil {
+		return resourceChange{}, false, err
+	}
+	replacePaths, err := encodePaths(rc.RequiredReplace)
+	if err != nil {
+		return resourceChange{}, false, err
+	}
+	replacePathsPointer, err := EncodePathsAsPointer(rc.RequiredReplace)
+	if err != nil {
+		return resourceChange{}, false, err
+	}
 
-		a, err := ctyjson.Marshal(afterUnknown, afterUnknown.Type())
+	var diff json.RawMessage
+	if p.opts.IncludeJSONPatch {
+		ops, err := diffAsJSONPatch(changeV.Before, changeV.After, afterUnknown, bsVal, asVal)
 		if err != nil {
-			return nil, err
+			return resourceChange{}, false, err
 		}
-		replacePaths, err := encodePaths(rc.RequiredReplace)
+		diff, err = json.Marshal(ops)
 		if err != nil {
-			return nil, err
+			return resourceChange{}, false, err
 		}
+	}
 
-		r.Change = change{
-			Actions:         actionString(rc.Action.String()),
-			Before:          json.RawMessage(before),
-			After:           json.RawMessage(after),
-			AfterUnknown:    a,
-			BeforeSensitive: json.RawMessage(beforeSensitive),
-			AfterSensitive:  json.RawMessage(afterSensitive),
-			ReplacePaths:    replacePaths,
-		}
+	actions, err := actionString(rc.Action)
+	if err != nil {
+		return resourceChange{}, false, err
+	}
+
+	r.Change = change{
+		Actions:             actions,
+		Before:              json.RawMessage(before),
+		After:               json.RawMessage(after),
+		AfterUnknown:        a,
+		BeforeSensitive:     json.RawMessage(beforeSensitive),
+		AfterSensitive:      json.RawMessage(afterSensitive),
+		ReplacePaths:        replacePaths,
+		ReplacePathsPointer: replacePathsPointer,
+		Diff:                diff,
+	}
+
+	if rc.DeposedKey != states.NotDeposed {
+		r.Deposed = rc.DeposedKey.String()
+	}
+
+	key := addr.Resource.Key
+	if key != nil {
+		r.Index = key
+	}
 
-		if rc.DeposedKey != states.NotDeposed {
-			r.Deposed = rc.DeposedKey.String()
+	switch addr.Resource.Resource.Mode {
+	case addrs.ManagedResourceMode:
+		r.Mode = "managed"
+	case addrs.DataResourceMode:
+		r.Mode = "data"
+	default:
+		return resourceChange{}, false, fmt.Errorf("resource %s has an unsupported mode %s", r.Address, addr.Resource.Resource.Mode.String())
+	}
+	r.ModuleAddress = addr.Module.String()
+	r.Name = addr.Resource.Resource.Name
+	r.Type = addr.Resource.Resource.Type
+	r.ProviderName = rc.ProviderAddr.Provider.String()
+
+	switch rc.ActionReason {
+	case plans.ResourceInstanceChangeNoReason:
+		r.ActionReason = "" // will be omitted in output
+	case plans.ResourceInstanceReplaceBecauseCannotUpdate:
+		r.ActionReason = "replace_because_cannot_update"
+	case plans.ResourceInstanceReplaceBecauseTainted:
+		r.ActionReason = "replace_because_tainted"
+	case plans.ResourceInstanceReplaceByRequest:
+		r.ActionReason = "replace_by_request"
+	case plans.ResourceInstanceReplaceByTriggers:
+		r.ActionReason = "replace_by_triggers"
+	case plans.ResourceInstanceDeleteBecauseNoResourceConfig:
+		r.ActionReason = "delete_because_no_resource_config"
+	case plans.ResourceInstanceDeleteBecauseWrongRepetition:
+		r.ActionReason = "delete_because_wrong_repetition"
+	case plans.ResourceInstanceDeleteBecauseCountIndex:
+		r.ActionReason = "delete_because_count_index"
+	case plans.ResourceInstanceDeleteBecauseEachKey:
+		r.ActionReason = "delete_because_each_key"
+	case plans.ResourceInstanceDeleteBecauseNoModule:
+		r.ActionReason = "delete_because_no_module"
+	case plans.ResourceInstanceDeleteBecauseNoMoveTarget:
+		r.ActionReason = "delete_because_no_move_target"
+	case plans.ResourceInstanceReadBecauseConfigUnknown:
+		r.ActionReason = "read_because_config_unknown"
+	case plans.ResourceInstanceReadBecauseDependencyPending:
+		r.ActionReason = "read_because_dependency_pending"
+	default:
+		return resourceChange{}, false, fmt.Errorf("resource %s has an unsupported action reason %s", r.Address, rc.ActionReason)
+	}
+
+	return r, true, nil
+}
+
+// marshalDeferredChanges populates p.DeferredChanges from the resource instances
+// that this round wasn't able to fully plan, reusing the same
+// before/after decoding logic as marshalResourceChanges so that a
+// deferred instance's placeholder value is represented with exactly the
+// same unknown-marking conventions as a normal change's "after" value.
+func (p *plan) marshalDeferredChanges(deferred []*plans.DeferredResourceInstanceChangeSrc, schemas *terraform.Schemas) error {
+	for _, dc := range deferred {
+		rcs, err := p.marshalResourceChanges([]*plans.ResourceInstanceChangeSrc{dc.ChangeSrc}, schemas)
+		if err != nil {
+			return err
 		}
+		if len(rcs) == 0 {
+			// Can happen for the data-resource "delete to clean up state"
+			// case that marshalResourceChanges silently skips; a deferred
+			// instance should never hit that case, but skip defensively.
+			continue
+		}
+		rc := rcs[0]
 
-		key := addr.Resource.Key
-		if key != nil {
-			r.Index = key
+		var plannedAction string
+		if len(rc.Change.Actions) > 0 {
+			plannedAction = rc.Change.Actions[len(rc.Change.Actions)-1]
 		}
 
-		switch addr.Resource.Resource.Mode {
-		case addrs.ManagedResourceMode:
-			r.Mode = "managed"
-		case addrs.DataResourceMode:
-			r.Mode = "data"
-		default:
-			return nil, fmt.Errorf("resource %s has an unsupported mode %s", r.Address, addr.Resource.Resource.Mode.String())
-		}
-		r.ModuleAddress = addr.Module.String()
-		r.Name = addr.Resource.Resource.Name
-		r.Type = addr.Resource.Resource.Type
-		r.ProviderName = rc.ProviderAddr.Provider.String()
-
-		switch rc.ActionReason {
-		case plans.ResourceInstanceChangeNoReason:
-			r.ActionReason = "" // will be omitted in output
-		case plans.ResourceInstanceReplaceBecauseCannotUpdate:
-			r.ActionReason = "replace_because_cannot_update"
-		case plans.ResourceInstanceReplaceBecauseTainted:
-			r.ActionReason = "replace_because_tainted"
-		case plans.ResourceInstanceReplaceByRequest:
-			r.ActionReason = "replace_by_request"
-		case plans.ResourceInstanceReplaceByTriggers:
-			r.ActionReason = "replace_by_triggers"
-		case plans.ResourceInstanceDeleteBecauseNoResourceConfig:
-			r.ActionReason = "delete_because_no_resource_config"
-		case plans.ResourceInstanceDeleteBecauseWrongRepetition:
-			r.ActionReason = "delete_because_wrong_repetition"
-		case plans.ResourceInstanceDeleteBecauseCountIndex:
-			r.ActionReason = "delete_because_count_index"
-		case plans.ResourceInstanceDeleteBecauseEachKey:
-			r.ActionReason = "delete_because_each_key"
-		case plans.ResourceInstanceDeleteBecauseNoModule:
-			r.ActionReason = "delete_because_no_module"
-		case plans.ResourceInstanceDeleteBecauseNoMoveTarget:
-			r.ActionReason = "delete_because_no_move_target"
-		case plans.ResourceInstanceReadBecauseConfigUnknown:
-			r.ActionReason = "read_because_config_unknown"
-		case plans.ResourceInstanceReadBecauseDependencyPending:
-			r.ActionReason = "read_because_dependency_pending"
-		default:
-			return nil, fmt.Errorf("resource %s has an unsupported action reason %s", r.Address, rc.ActionReason)
+		upstream := make([]string, 0, len(dc.UpstreamAddresses))
+		for _, ua := range dc.UpstreamAddresses {
+			upstream = append(upstream, ua.String())
 		}
+		sort.Strings(upstream)
 
-		ret = append(ret, r)
+		var retryAfter *float64
+		if dc.RetryAfter > 0 {
+			seconds := dc.RetryAfter.Seconds()
+			retryAfter = &seconds
+		}
 
+		p.DeferredChanges = append(p.DeferredChanges, deferredChange{
+			Address:           rc.Address,
+			Reason:            dc.DeferredReason.String(),
+			PlannedAction:     plannedAction,
+			PlaceholderValues: rc.Change.After,
+			UpstreamAddresses: upstream,
+			Message:           dc.Message,
+			RetryAfterSeconds: retryAfter,
+		})
 	}
 
-	sort.Slice(ret, func(i, j int) bool {
-		return ret[i].Address < ret[j].Address
+	sort.Slice(p.DeferredChanges, func(i, j int) bool {
+		return p.DeferredChanges[i].Address < p.DeferredChanges[j].Address
 	})
 
-	return ret, nil
+	return nil
 }
 
 func (p *plan) marshalOutputChanges(changes *plans.Changes) error {
@@ -496,13 +700,31 @@ func (p *plan) marshalOutputChanges(changes *plans.Changes) error {
 
 		a, _ := ctyjson.Marshal(afterUnknown, afterUnknown.Type())
 
+		var diff json.RawMessage
+		if p.opts.IncludeJSONPatch {
+			ops, err := diffAsJSONPatch(changeV.Before, changeV.After, afterUnknown, outputSensitive, outputSensitive)
+			if err != nil {
+				return err
+			}
+			diff, err = json.Marshal(ops)
+			if err != nil {
+				return err
+			}
+		}
+
+		actions, err := actionString(oc.Action)
+		if err != nil {
+			return err
+		}
+
 		c := change{
-			Actions:         actionString(oc.Action.String()),
+			Actions:         actions,
 			Before:          json.RawMessage(before),
 			After:           json.RawMessage(after),
 			AfterUnknown:    a,
 			BeforeSensitive: json.RawMessage(sensitive),
 			AfterSensitive:  json.RawMessage(sensitive),
+			Diff:            diff,
 		}
 
 		p.OutputChanges[oc.Addr.OutputValue.Name] = c
@@ -516,13 +738,17 @@ func (p *plan) marshalCheckResults(results *states.CheckResults) error {
 		return nil
 	}
 
-	// For the moment this is still producing the flat structure from
-	// the initial release of preconditions/postconditions in Terraform v1.2.
+	checkResults, err := jsonchecks.MarshalCheckResults(results)
+	if err != nil {
+		return err
+	}
+	p.CheckResults = checkResults
+
+	// Everything below here produces the legacy, flattened Conditions
+	// structure from the initial release of preconditions/postconditions in
+	// Terraform v1.2, kept for one release alongside CheckResults above.
 	// This therefore discards the aggregate information about any configuration
 	// objects that might end up with zero instances declared.
-	// We'll need to think about what we want to do here in order to expose
-	// the full check details while hopefully also remaining compatible with
-	// what we previously documented.
 
 	for _, configElem := range results.ConfigResults.Elems {
 		for _, objectElem := range configElem.Value.ObjectResults.Elems {
@@ -692,89 +918,60 @@ func omitUnknowns(val cty.Value) cty.Value {
 //
 // For map/object values, all known attribute values will be omitted instead of
 // returning false, as this results in a more compact serialization.
+//
+// This is boolShape's other caller alongside boolValueMarks - unknown-ness
+// isn't a cty mark, so it gets its own leaf function, but shares the same
+// container recursion and compaction rules.
 func unknownAsBool(val cty.Value) cty.Value {
-	ty := val.Type()
-	switch {
-	case val.IsNull():
-		return cty.False
-	case !val.IsKnown():
-		if ty.IsPrimitiveType() || ty.Equals(cty.DynamicPseudoType) {
-			return cty.True
-		}
-		fallthrough
-	case ty.IsPrimitiveType():
-		return cty.BoolVal(!val.IsKnown())
-	case ty.IsListType() || ty.IsTupleType() || ty.IsSetType():
-		length := val.LengthInt()
-		if length == 0 {
-			// If there are no elements then we can't have unknowns
-			return cty.EmptyTupleVal
-		}
-		vals := make([]cty.Value, 0, length)
-		it := val.ElementIterator()
-		for it.Next() {
-			_, v := it.Element()
-			vals = append(vals, unknownAsBool(v))
-		}
-		// The above transform may have changed the types of some of the
-		// elements, so we'll always use a tuple here in case we've now made
-		// different elements have different types. Our ultimate goal is to
-		// marshal to JSON anyway, and all of these sequence types are
-		// indistinguishable in JSON.
-		return cty.TupleVal(vals)
-	case ty.IsMapType() || ty.IsObjectType():
-		var length int
+	return boolShape(val, func(v cty.Value) (cty.Value, bool) {
 		switch {
-		case ty.IsMapType():
-			length = val.LengthInt()
+		case v.IsNull():
+			return cty.False, true
+		case !v.IsKnown():
+			return cty.True, true
+		case v.Type().IsPrimitiveType():
+			return cty.False, true
 		default:
-			length = len(val.Type().AttributeTypes())
-		}
-		if length == 0 {
-			// If there are no elements then we can't have unknowns
-			return cty.EmptyObjectVal
-		}
-		vals := make(map[string]cty.Value)
-		it := val.ElementIterator()
-		for it.Next() {
-			k, v := it.Element()
-			vAsBool := unknownAsBool(v)
-			// Omit all of the "false"s for known values for more compact
-			// serialization
-			if !vAsBool.RawEquals(cty.False) {
-				vals[k.AsString()] = vAsBool
-			}
+			return cty.NilVal, false
 		}
-		// The above transform may have changed the types of some of the
-		// elements, so we'll always use an object here in case we've now made
-		// different elements have different types. Our ultimate goal is to
-		// marshal to JSON anyway, and all of these mapping types are
-		// indistinguishable in JSON.
-		return cty.ObjectVal(vals)
-	default:
-		// Should never happen, since the above should cover all types
-		panic(fmt.Sprintf("unknownAsBool cannot handle %#v", val))
-	}
+	})
 }
 
-func actionString(action string) []string {
-	switch {
-	case action == "NoOp":
-		return []string{"no-op"}
-	case action == "Create":
-		return []string{"create"}
-	case action == "Delete":
-		return []string{"delete"}
-	case action == "Update":
-		return []string{"update"}
-	case action == "CreateThenDelete":
-		return []string{"create", "delete"}
-	case action == "Read":
-		return []string{"read"}
-	case action == "DeleteThenCreate":
-		return []string{"delete", "create"}
+// actionString renders a plans.Action as the plan JSON's public action
+// vocabulary: a non-empty slice of "no-op"/"create"/"read"/"update"/
+// "delete"/"forget" tokens, with combined actions (e.g. replace, or a
+// forget paired with a create) represented as a two-element slice in the
+// order they'll actually happen.
+//
+// This is deliberately a closed, enum-backed switch rather than a
+// pass-through default case: a plans.Action this function doesn't
+// recognize is a sign that a new action kind was added without updating
+// the public plan schema, and callers should fail loudly on that rather
+// than leak a Go-side String() spelling into the JSON output.
+func actionString(action plans.Action) ([]string, error) {
+	switch action {
+	case plans.NoOp:
+		return []string{"no-op"}, nil
+	case plans.Create:
+		return []string{"create"}, nil
+	case plans.Read:
+		return []string{"read"}, nil
+	case plans.Update:
+		return []string{"update"}, nil
+	case plans.Delete:
+		return []string{"delete"}, nil
+	case plans.CreateThenDelete:
+		return []string{"create", "delete"}, nil
+	case plans.DeleteThenCreate:
+		return []string{"delete", "create"}, nil
+	case plans.Forget:
+		return []string{"forget"}, nil
+	case plans.CreateThenForget:
+		return []string{"create", "forget"}, nil
+	case plans.ForgetThenCreate:
+		return []string{"forget", "create"}, nil
 	default:
-		return []string{action}
+		return nil, fmt.Errorf("unrecognized change action %s", action)
 	}
 }
 
@@ -832,3 +1029,71 @@ func encodePath(path cty.Path) (json.RawMessage, error) {
 	}
 	return json.Marshal(steps)
 }
+
+// EncodePathsAsPointer renders each path in pathSet as an RFC 6901 JSON
+// Pointer string, as an alternative to encodePaths' array-of-steps format
+// for consumers (jq, browsers, generic JSON tooling) that can address a
+// document location directly without reimplementing a Terraform-specific
+// path walker.
+//
+// As with encodePaths, this can't distinguish a GetAttrStep from an
+// IndexStep with a string key: both become the same pointer token, which is
+// fine for JSON output since both are object indices there anyway. A
+// non-string, non-integer IndexStep key (which shouldn't occur in practice,
+// since JSON has no other index types) produces an error rather than a
+// silently-wrong pointer.
+func EncodePathsAsPointer(pathSet cty.PathSet) ([]string, error) {
+	if pathSet.Empty() {
+		return nil, nil
+	}
+
+	pathList := pathSet.List()
+	pointers := make([]string, 0, len(pathList))
+	for _, path := range pathList {
+		pointer, err := encodePathAsPointer(path)
+		if err != nil {
+			return nil, err
+		}
+		pointers = append(pointers, pointer)
+	}
+	return pointers, nil
+}
+
+func encodePathAsPointer(path cty.Path) (string, error) {
+	var buf strings.Builder
+	for _, step := range path {
+		buf.WriteByte('/')
+		switch s := step.(type) {
+		case cty.IndexStep:
+			token, err := jsonPointerIndexToken(s.Key)
+			if err != nil {
+				return "", err
+			}
+			buf.WriteString(token)
+		case cty.GetAttrStep:
+			buf.WriteString(jsonPointerEscape(s.Name))
+		default:
+			return "", fmt.Errorf("unsupported path step %#v (%T)", step, step)
+		}
+	}
+	return buf.String(), nil
+}
+
+// jsonPointerIndexToken renders an IndexStep's key as a JSON Pointer
+// segment: a string key is used verbatim (escaped), and a numeric key must
+// be a non-negative integer, per RFC 6901's array-index segment syntax.
+func jsonPointerIndexToken(key cty.Value) (string, error) {
+	switch key.Type() {
+	case cty.String:
+		return jsonPointerEscape(key.AsString()), nil
+	case cty.Number:
+		bf := key.AsBigFloat()
+		i, acc := bf.Int64()
+		if acc != big.Exact {
+			return "", fmt.Errorf("non-integer index step key %s cannot be represented as a JSON Pointer array index", bf.String())
+		}
+		return strconv.FormatInt(i, 10), nil
+	default:
+		return "", fmt.Errorf("unsupported index step key type %s", key.Type().FriendlyName())
+	}
+}
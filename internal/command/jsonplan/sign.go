@@ -0,0 +1,254 @@
+package jsonplan
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform/internal/configs"
+	"github.com/hashicorp/terraform/internal/plans"
+	"github.com/hashicorp/terraform/internal/states/statefile"
+	"github.com/hashicorp/terraform/internal/terraform"
+)
+
+// Signer produces a detached signature over a plan's canonical hash.
+type Signer interface {
+	// Sign returns a signature over hash (a SHA-256 digest), plus the ID of
+	// the key used to produce it, if any (e.g. a cosign-style key
+	// fingerprint). keyID may be empty.
+	Sign(hash []byte) (signature []byte, keyID string, err error)
+}
+
+// Verifier checks a detached signature over a plan's canonical hash.
+type Verifier interface {
+	// Verify reports whether signature is a valid signature over hash,
+	// produced by the key identified by keyID (which may be empty, in
+	// which case the Verifier must have exactly one key to try).
+	Verify(hash, signature []byte, keyID string) error
+}
+
+// planSignature is the "signature" block embedded in a document produced by
+// MarshalSigned. It's computed over the canonical rendering (sorted keys, no
+// insignificant whitespace; see canonicalizeJSON) of every other top-level
+// field in the plan document, so that any change to the plan body -
+// including a change an unordered-map-based consumer wouldn't otherwise
+// notice - invalidates it. That rendering is not exact RFC 8785 JCS:
+// numbers keep the form encoding/json gave them.
+type planSignature struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id,omitempty"`
+	Hash      string `json:"hash"`
+	Signature string `json:"signature"`
+}
+
+// Ed25519Signer signs with a raw Ed25519 private key.
+type Ed25519Signer struct {
+	KeyID      string
+	PrivateKey ed25519.PrivateKey
+}
+
+func (s Ed25519Signer) Sign(hash []byte) ([]byte, string, error) {
+	if len(s.PrivateKey) != ed25519.PrivateKeySize {
+		return nil, "", fmt.Errorf("invalid ed25519 private key size %d", len(s.PrivateKey))
+	}
+	return ed25519.Sign(s.PrivateKey, hash), s.KeyID, nil
+}
+
+// Ed25519Verifier verifies with a raw Ed25519 public key.
+type Ed25519Verifier struct {
+	PublicKey ed25519.PublicKey
+}
+
+func (v Ed25519Verifier) Verify(hash, signature []byte, keyID string) error {
+	if len(v.PublicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid ed25519 public key size %d", len(v.PublicKey))
+	}
+	if !ed25519.Verify(v.PublicKey, hash, signature) {
+		return fmt.Errorf("ed25519 signature verification failed")
+	}
+	return nil
+}
+
+// MarshalSigned is equivalent to Marshal, except the returned document also
+// carries a top-level "signature" block recording a hash of the plan body
+// and a detached signature over that hash produced by signer. Verify can
+// later recompute the same hash from the body and check it against the
+// embedded signature to detect a tampered plan document. Verify has no
+// caller in Terraform itself: `terraform show -json` can emit a signed
+// document, but it does not check a signature before rendering a plan.
+func MarshalSigned(config *configs.Config, p *plans.Plan, sf *statefile.File, schemas *terraform.Schemas, signer Signer, opts ...MarshalOpts) ([]byte, error) {
+	body, err := Marshal(config, p, sf, schemas, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("error re-parsing marshaled plan: %s", err)
+	}
+
+	sum, err := canonicalHash(body)
+	if err != nil {
+		return nil, fmt.Errorf("error canonicalizing plan for signing: %s", err)
+	}
+
+	sig, keyID, err := signer.Sign(sum[:])
+	if err != nil {
+		return nil, fmt.Errorf("error signing plan: %s", err)
+	}
+
+	sigBlock, err := json.Marshal(planSignature{
+		Algorithm: "ed25519+sha256",
+		KeyID:     keyID,
+		Hash:      hex.EncodeToString(sum[:]),
+		Signature: hex.EncodeToString(sig),
+	})
+	if err != nil {
+		return nil, err
+	}
+	doc["signature"] = sigBlock
+
+	return json.Marshal(doc)
+}
+
+// Verify checks a signed plan document (as produced by MarshalSigned)
+// against verifier, recomputing the canonical hash over every field except
+// "signature" itself and confirming it both matches the hash recorded in
+// the signature block and satisfies the embedded signature.
+func Verify(document []byte, verifier Verifier) error {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(document, &doc); err != nil {
+		return fmt.Errorf("error parsing signed plan: %s", err)
+	}
+
+	rawSig, ok := doc["signature"]
+	if !ok {
+		return fmt.Errorf("plan document has no signature block")
+	}
+	var sig planSignature
+	if err := json.Unmarshal(rawSig, &sig); err != nil {
+		return fmt.Errorf("error parsing signature block: %s", err)
+	}
+
+	delete(doc, "signature")
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	sum, err := canonicalHash(body)
+	if err != nil {
+		return fmt.Errorf("error canonicalizing plan for verification: %s", err)
+	}
+
+	if hex.EncodeToString(sum[:]) != sig.Hash {
+		return fmt.Errorf("plan body does not match the hash recorded in its signature block")
+	}
+
+	sigBytes, err := hex.DecodeString(sig.Signature)
+	if err != nil {
+		return fmt.Errorf("error decoding signature: %s", err)
+	}
+
+	if err := verifier.Verify(sum[:], sigBytes, sig.KeyID); err != nil {
+		return fmt.Errorf("signature verification failed: %s", err)
+	}
+
+	return nil
+}
+
+func canonicalHash(body []byte) ([sha256.Size]byte, error) {
+	canon, err := canonicalizeJSON(body)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(canon), nil
+}
+
+// canonicalizeJSON renders raw in a canonical form: object keys sorted and
+// all insignificant whitespace removed, so that semantically identical JSON
+// documents always hash the same way regardless of how they were
+// originally serialized.
+//
+// This approximates RFC 8785 (JCS) rather than implementing it exactly: in
+// particular, number formatting here is "whatever encoding/json produced",
+// not JCS's ECMA-262-mandated serialization. Since every number in a plan
+// document is produced by this package's own Marshal in the first place,
+// that's sufficient to detect tampering between Marshal and Verify; it's
+// not a general-purpose JCS implementation for arbitrary third-party JSON.
+func canonicalizeJSON(raw []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := writeCanonicalJSON(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonicalJSON(buf *bytes.Buffer, v interface{}) error {
+	switch val := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		if val {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case json.Number:
+		buf.WriteString(val.String())
+	case string:
+		enc, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		buf.Write(enc)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, elem := range val {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			keyEnc, err := json.Marshal(k)
+			if err != nil {
+				return err
+			}
+			buf.Write(keyEnc)
+			buf.WriteByte(':')
+			if err := writeCanonicalJSON(buf, val[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("unsupported JSON value type %T during canonicalization", v)
+	}
+	return nil
+}
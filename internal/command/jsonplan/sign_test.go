@@ -0,0 +1,209 @@
+package jsonplan
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+func TestEd25519SignerVerifier(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("error generating key: %s", err)
+	}
+	signer := Ed25519Signer{KeyID: "test-key", PrivateKey: priv}
+	verifier := Ed25519Verifier{PublicKey: pub}
+
+	hash := []byte("0123456789012345678901234567890123456789012345678901234567890a")[:32]
+
+	sig, keyID, err := signer.Sign(hash)
+	if err != nil {
+		t.Fatalf("unexpected error signing: %s", err)
+	}
+	if keyID != "test-key" {
+		t.Errorf("wrong key ID %q", keyID)
+	}
+
+	if err := verifier.Verify(hash, sig, keyID); err != nil {
+		t.Errorf("unexpected error verifying a valid signature: %s", err)
+	}
+
+	otherHash := []byte("a012345678901234567890123456789012345678901234567890123456789b")[:32]
+	if err := verifier.Verify(otherHash, sig, keyID); err == nil {
+		t.Error("expected an error verifying a signature over a different hash")
+	}
+}
+
+func TestEd25519SignerWrongKeySize(t *testing.T) {
+	signer := Ed25519Signer{PrivateKey: make([]byte, 4)}
+	if _, _, err := signer.Sign([]byte("hash")); err == nil {
+		t.Error("expected an error signing with a malformed private key")
+	}
+}
+
+func TestEd25519VerifierWrongKeySize(t *testing.T) {
+	verifier := Ed25519Verifier{PublicKey: make([]byte, 4)}
+	if err := verifier.Verify([]byte("hash"), []byte("sig"), ""); err == nil {
+		t.Error("expected an error verifying with a malformed public key")
+	}
+}
+
+func TestCanonicalizeJSON(t *testing.T) {
+	tests := map[string]struct {
+		A, B string
+	}{
+		"reordered keys": {
+			A: `{"a":1,"b":2}`,
+			B: `{"b":2,"a":1}`,
+		},
+		"insignificant whitespace": {
+			A: `{"a": 1, "b": [1, 2, 3]}`,
+			B: `{"a":1,"b":[1,2,3]}`,
+		},
+		"nested objects": {
+			A: `{"outer":{"z":1,"a":2}}`,
+			B: `{"outer":{"a":2,"z":1}}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			canonA, err := canonicalizeJSON([]byte(test.A))
+			if err != nil {
+				t.Fatalf("error canonicalizing A: %s", err)
+			}
+			canonB, err := canonicalizeJSON([]byte(test.B))
+			if err != nil {
+				t.Fatalf("error canonicalizing B: %s", err)
+			}
+			if string(canonA) != string(canonB) {
+				t.Errorf("canonical forms differ:\nA: %s\nB: %s", canonA, canonB)
+			}
+		})
+	}
+}
+
+func TestCanonicalHashDetectsTampering(t *testing.T) {
+	original := []byte(`{"format_version":"1.0","variables":{"a":{"value":1}}}`)
+	tampered := []byte(`{"format_version":"1.0","variables":{"a":{"value":2}}}`)
+
+	sumOriginal, err := canonicalHash(original)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	sumTampered, err := canonicalHash(tampered)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if sumOriginal == sumTampered {
+		t.Error("expected different hashes for different plan bodies")
+	}
+}
+
+// buildSignedDoc hand-assembles a document in exactly the shape MarshalSigned
+// produces -- a JSON object with a "signature" block alongside the other
+// fields, computed over those other fields' canonical form -- without going
+// through Marshal itself, so that Verify's logic can be exercised on its own.
+func buildSignedDoc(t *testing.T, body map[string]interface{}, signer Signer) []byte {
+	t.Helper()
+
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("error marshaling body: %s", err)
+	}
+
+	sum, err := canonicalHash(bodyBytes)
+	if err != nil {
+		t.Fatalf("error hashing body: %s", err)
+	}
+
+	sig, keyID, err := signer.Sign(sum[:])
+	if err != nil {
+		t.Fatalf("error signing: %s", err)
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(bodyBytes, &doc); err != nil {
+		t.Fatalf("error re-parsing body: %s", err)
+	}
+
+	sigBlock, err := json.Marshal(planSignature{
+		Algorithm: "ed25519+sha256",
+		KeyID:     keyID,
+		Hash:      hex.EncodeToString(sum[:]),
+		Signature: hex.EncodeToString(sig),
+	})
+	if err != nil {
+		t.Fatalf("error marshaling signature block: %s", err)
+	}
+	doc["signature"] = sigBlock
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("error marshaling signed document: %s", err)
+	}
+	return out
+}
+
+func TestVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("error generating key: %s", err)
+	}
+	signer := Ed25519Signer{KeyID: "test-key", PrivateKey: priv}
+	verifier := Ed25519Verifier{PublicKey: pub}
+
+	body := map[string]interface{}{
+		"format_version": "1.0",
+		"variables":      map[string]interface{}{"a": map[string]interface{}{"value": float64(1)}},
+	}
+
+	t.Run("valid signature", func(t *testing.T) {
+		doc := buildSignedDoc(t, body, signer)
+		if err := Verify(doc, verifier); err != nil {
+			t.Errorf("unexpected error verifying a validly signed document: %s", err)
+		}
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		doc := buildSignedDoc(t, body, signer)
+
+		var parsed map[string]json.RawMessage
+		if err := json.Unmarshal(doc, &parsed); err != nil {
+			t.Fatalf("error re-parsing document: %s", err)
+		}
+		parsed["variables"] = json.RawMessage(`{"a":{"value":2}}`)
+		tampered, err := json.Marshal(parsed)
+		if err != nil {
+			t.Fatalf("error marshaling tampered document: %s", err)
+		}
+
+		if err := Verify(tampered, verifier); err == nil {
+			t.Error("expected an error verifying a tampered document")
+		}
+	})
+
+	t.Run("missing signature block", func(t *testing.T) {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("error marshaling body: %s", err)
+		}
+		if err := Verify(bodyBytes, verifier); err == nil {
+			t.Error("expected an error verifying a document with no signature block")
+		}
+	})
+
+	t.Run("wrong verifier key", func(t *testing.T) {
+		doc := buildSignedDoc(t, body, signer)
+
+		otherPub, _, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatalf("error generating key: %s", err)
+		}
+		if err := Verify(doc, Ed25519Verifier{PublicKey: otherPub}); err == nil {
+			t.Error("expected an error verifying with the wrong public key")
+		}
+	})
+}
@@ -0,0 +1,224 @@
+package jsonplan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/hashicorp/terraform/internal/command/jsonchecks"
+	"github.com/hashicorp/terraform/internal/command/jsonconfig"
+	"github.com/hashicorp/terraform/internal/command/jsonstate"
+	"github.com/hashicorp/terraform/internal/configs"
+	"github.com/hashicorp/terraform/internal/plans"
+	"github.com/hashicorp/terraform/internal/states/statefile"
+	"github.com/hashicorp/terraform/internal/terraform"
+	"github.com/hashicorp/terraform/version"
+)
+
+// ProgressFunc is called as an Encoder writes each resource_changes/
+// resource_drift element, so that a long-running caller (e.g.
+// `terraform show -json` against a plan with tens of thousands of
+// instances) can report throughput. done is the number of resource instance
+// changes processed so far across both arrays combined, and total is the
+// number there are to process; a change that is left out of the output
+// still counts towards both.
+type ProgressFunc func(done, total int)
+
+// MarshalTo writes the json encoding of a terraform plan directly to w. It's
+// equivalent to NewEncoder(w, opts...).Encode(config, p, sf, schemas), and
+// exists as a convenience for callers that don't need to set Progress.
+func MarshalTo(w io.Writer, config *configs.Config, p *plans.Plan, sf *statefile.File, schemas *terraform.Schemas, opts ...MarshalOpts) error {
+	return NewEncoder(w, opts...).Encode(config, p, sf, schemas)
+}
+
+// Encoder streams a plan's json encoding to an underlying io.Writer, writing
+// resource_changes, resource_drift, and prior_state directly to the writer
+// instead of building the whole document in memory and calling json.Marshal
+// on it once the way Marshal does. For plans with tens of thousands of
+// resource instances, that single json.Marshal call means briefly holding
+// both the decoded []resourceChange slice and its fully re-marshaled form
+// at once; Encoder instead marshals one resourceChange at a time and writes
+// it straight to the writer.
+//
+// Sort order is decided with a pre-pass over the addresses of the resource
+// instance changes only, before any of them are decoded, so each field holds
+// the same value Marshal would give it. The three streamed fields are
+// written after every other top-level key, though, so the key order differs
+// from Marshal's, which follows the plan struct's field order.
+type Encoder struct {
+	w        io.Writer
+	opts     MarshalOpts
+	Progress ProgressFunc
+}
+
+// NewEncoder returns an Encoder that writes to w. At most one MarshalOpts is
+// accepted, mirroring Marshal.
+func NewEncoder(w io.Writer, opts ...MarshalOpts) *Encoder {
+	e := &Encoder{w: w}
+	if len(opts) > 0 {
+		e.opts = opts[0]
+		e.Progress = opts[0].Progress
+	}
+	return e
+}
+
+// Encode writes the plan's json encoding to the Encoder's writer.
+func (e *Encoder) Encode(config *configs.Config, p *plans.Plan, sf *statefile.File, schemas *terraform.Schemas) error {
+	out := newPlan()
+	out.TerraformVersion = version.String()
+	out.opts = e.opts
+
+	if err := out.marshalPlanVariables(p.VariableValues, config.Module.Variables); err != nil {
+		return fmt.Errorf("error in marshalPlanVariables: %s", err)
+	}
+	if err := out.marshalPlannedValues(p.Changes, schemas); err != nil {
+		return fmt.Errorf("error in marshalPlannedValues: %s", err)
+	}
+	if err := out.marshalRelevantAttrs(p); err != nil {
+		return fmt.Errorf("error marshaling relevant attributes for external changes: %s", err)
+	}
+	if err := out.marshalOutputChanges(p.Changes); err != nil {
+		return fmt.Errorf("error in marshaling output changes: %s", err)
+	}
+	if err := out.marshalCheckResults(p.Checks); err != nil {
+		return fmt.Errorf("error in marshaling check results: %s", err)
+	}
+	if p.Checks != nil && p.Checks.ConfigResults.Len() > 0 {
+		out.Checks = jsonchecks.MarshalCheckStates(p.Checks)
+	}
+	if err := out.marshalDeferredChanges(p.DeferredResources, schemas); err != nil {
+		return fmt.Errorf("error marshaling deferred changes: %s", err)
+	}
+
+	var err error
+	out.Config, err = jsonconfig.Marshal(config, schemas)
+	if err != nil {
+		return fmt.Errorf("error marshaling config: %s", err)
+	}
+
+	var drifted []*plans.ResourceInstanceChangeSrc
+	if len(p.DriftedResources) > 0 {
+		if p.UIMode == plans.RefreshOnlyMode {
+			drifted = p.DriftedResources
+		} else {
+			for _, dr := range p.DriftedResources {
+				if dr.Action != plans.NoOp {
+					drifted = append(drifted, dr)
+				}
+			}
+		}
+	}
+
+	var changed []*plans.ResourceInstanceChangeSrc
+	if p.Changes != nil {
+		changed = p.Changes.Resources
+	}
+
+	total := len(drifted) + len(changed)
+	var done int
+	progress := func() {
+		done++
+		if e.Progress != nil {
+			e.Progress(done, total)
+		}
+	}
+
+	// head is everything except the three fields we stream below; marshaling
+	// it up front gives us the right key order and comma placement for free,
+	// courtesy of the plan struct's own field order and omitempty tags.
+	head := *out
+	head.ResourceDrift = nil
+	head.ResourceChanges = nil
+	head.PriorState = nil
+	headJSON, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+
+	// Drop the closing brace so we can append the streamed fields ourselves.
+	if _, err := e.w.Write(headJSON[:len(headJSON)-1]); err != nil {
+		return err
+	}
+
+	if len(drifted) > 0 {
+		if _, err := io.WriteString(e.w, `,"resource_drift":`); err != nil {
+			return err
+		}
+		if err := e.streamResourceChanges(out, drifted, schemas, progress); err != nil {
+			return fmt.Errorf("error in marshaling resource drift: %s", err)
+		}
+	}
+
+	if len(changed) > 0 {
+		if _, err := io.WriteString(e.w, `,"resource_changes":`); err != nil {
+			return err
+		}
+		if err := e.streamResourceChanges(out, changed, schemas, progress); err != nil {
+			return fmt.Errorf("error in marshaling resource changes: %s", err)
+		}
+	}
+
+	if sf != nil && !sf.State.Empty() {
+		priorState, err := jsonstate.Marshal(sf, schemas)
+		if err != nil {
+			return fmt.Errorf("error marshaling prior state: %s", err)
+		}
+		if _, err := io.WriteString(e.w, `,"prior_state":`); err != nil {
+			return err
+		}
+		if _, err := e.w.Write(priorState); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(e.w, `}`)
+	return err
+}
+
+// streamResourceChanges writes a JSON array of resourceChange objects to
+// e.w, decoding and marshaling one at a time rather than accumulating them
+// into a slice first. Ordering matches marshalResourceChanges: a pre-pass
+// over addresses only, before any of the (much more expensive) per-instance
+// decoding happens.
+func (e *Encoder) streamResourceChanges(out *plan, resources []*plans.ResourceInstanceChangeSrc, schemas *terraform.Schemas, progress func()) error {
+	sorted := make([]*plans.ResourceInstanceChangeSrc, len(resources))
+	copy(sorted, resources)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Addr.String() < sorted[j].Addr.String()
+	})
+
+	if _, err := io.WriteString(e.w, `[`); err != nil {
+		return err
+	}
+
+	wrote := false
+	for _, rc := range sorted {
+		r, include, err := out.buildResourceChange(rc, schemas)
+		progress()
+		if err != nil {
+			return err
+		}
+		if !include {
+			continue
+		}
+
+		if wrote {
+			if _, err := io.WriteString(e.w, `,`); err != nil {
+				return err
+			}
+		}
+		wrote = true
+
+		raw, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if _, err := e.w.Write(raw); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(e.w, `]`)
+	return err
+}
@@ -4,26 +4,49 @@ import (
 	"fmt"
 
 	"github.com/hashicorp/terraform/internal/command/arguments"
+	"github.com/hashicorp/terraform/internal/command/format"
+	"github.com/hashicorp/terraform/internal/command/jsonplan"
+	"github.com/hashicorp/terraform/internal/command/jsonstate"
+	"github.com/hashicorp/terraform/internal/configs"
 	"github.com/hashicorp/terraform/internal/plans"
+	"github.com/hashicorp/terraform/internal/states/statefile"
 	"github.com/hashicorp/terraform/internal/terraform"
+	"github.com/hashicorp/terraform/internal/tfdiags"
 )
 
-// FIXME: this is a temporary partial definition of the view for the show
-// command, in place to allow access to the plan renderer which is now in the
-// views package.
+// Show is the view for the "terraform show" command, which can render
+// either a saved plan or a state snapshot, as human-oriented text or as
+// JSON.
 type Show interface {
-	Plan(plan *plans.Plan, schemas *terraform.Schemas)
+	// Plan renders a plan that was read from a saved plan file. config and
+	// stateFile give the JSON renderer the same prior-state and
+	// configuration context that jsonplan.Marshal needs to produce its
+	// resource changes, output changes, resource drift, deposed instances,
+	// check results, and planned variable values; the human renderer
+	// ignores them.
+	Plan(config *configs.Config, plan *plans.Plan, stateFile *statefile.File, schemas *terraform.Schemas) tfdiags.Diagnostics
+
+	// State renders a state snapshot read from a state file or a backend.
+	State(stateFile *statefile.File, schemas *terraform.Schemas) tfdiags.Diagnostics
 }
 
-// FIXME: the show view should support both human and JSON types. This code is
-// currently only used to render the plan in human-readable UI, so does not yet
-// support JSON.
-func NewShow(vt arguments.ViewType, view *View) Show {
+// NewShow returns an implementation of Show that renders in the format
+// indicated by vt.
+func NewShow(vt arguments.ViewType, view *View) (Show, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
 	switch vt {
 	case arguments.ViewHuman:
-		return &ShowHuman{View: *view}
+		return &ShowHuman{View: *view}, diags
+	case arguments.ViewJSON:
+		return &ShowJSON{View: *view}, diags
 	default:
-		panic(fmt.Sprintf("unknown view type %v", vt))
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Invalid view type",
+			fmt.Sprintf("The \"show\" command does not support the %q view type. This is a bug in Terraform.", vt),
+		))
+		return nil, diags
 	}
 }
 
@@ -33,6 +56,88 @@ type ShowHuman struct {
 
 var _ Show = (*ShowHuman)(nil)
 
-func (v *ShowHuman) Plan(plan *plans.Plan, schemas *terraform.Schemas) {
+func (v *ShowHuman) Plan(config *configs.Config, plan *plans.Plan, stateFile *statefile.File, schemas *terraform.Schemas) tfdiags.Diagnostics {
 	renderPlan(plan, schemas, &v.View)
+	return nil
+}
+
+func (v *ShowHuman) State(stateFile *statefile.File, schemas *terraform.Schemas) tfdiags.Diagnostics {
+	if stateFile == nil {
+		v.streams.Println("No state.")
+		return nil
+	}
+
+	v.streams.Println(format.State(&format.StateOpts{
+		State:   stateFile.State,
+		Color:   v.colorize,
+		Schemas: schemas,
+	}))
+	return nil
+}
+
+// ShowJSON renders a plan or a state snapshot as JSON, using the same
+// jsonplan and jsonstate encoders that "terraform plan -json" and
+// "terraform state show" format their output with, so that "terraform show
+// -json" produces byte-for-byte the same representation regardless of
+// which command originally captured the plan or state.
+type ShowJSON struct {
+	View
+
+	// Signer, if non-nil, causes Plan to emit a signed document (via
+	// jsonplan.MarshalSigned) instead of a plain one, so that a policy
+	// pipeline consuming this output can use jsonplan.Verify to confirm it
+	// hasn't been tampered with since "terraform show -json" produced it.
+	// Left nil, Plan's output is unsigned, matching prior behavior.
+	Signer jsonplan.Signer
+}
+
+var _ Show = (*ShowJSON)(nil)
+
+func (v *ShowJSON) Plan(config *configs.Config, plan *plans.Plan, stateFile *statefile.File, schemas *terraform.Schemas) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	if plan == nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"No plan to show",
+			"The given plan file does not contain a saved plan.",
+		))
+		return diags
+	}
+
+	var jsonBytes []byte
+	var err error
+	if v.Signer != nil {
+		jsonBytes, err = jsonplan.MarshalSigned(config, plan, stateFile, schemas, v.Signer)
+	} else {
+		jsonBytes, err = jsonplan.Marshal(config, plan, stateFile, schemas)
+	}
+	if err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Failed to marshal plan to json",
+			fmt.Sprintf("There was an error marshaling the plan to JSON: %s.", err),
+		))
+		return diags
+	}
+
+	v.streams.Println(string(jsonBytes))
+	return diags
+}
+
+func (v *ShowJSON) State(stateFile *statefile.File, schemas *terraform.Schemas) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	jsonBytes, err := jsonstate.Marshal(stateFile, schemas)
+	if err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Failed to marshal state to json",
+			fmt.Sprintf("There was an error marshaling the state to JSON: %s.", err),
+		))
+		return diags
+	}
+
+	v.streams.Println(string(jsonBytes))
+	return diags
 }
@@ -0,0 +1,54 @@
+package views
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/internal/command/arguments"
+	"github.com/hashicorp/terraform/internal/terminal"
+)
+
+func TestNewShow(t *testing.T) {
+	tests := map[string]struct {
+		viewType arguments.ViewType
+		wantType Show
+	}{
+		"human": {arguments.ViewHuman, &ShowHuman{}},
+		"json":  {arguments.ViewJSON, &ShowJSON{}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			streams, _ := terminal.StreamsForTesting(t)
+			view := NewView(streams)
+
+			got, diags := NewShow(test.viewType, view)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected error: %s", diags.Err())
+			}
+
+			switch test.wantType.(type) {
+			case *ShowHuman:
+				if _, ok := got.(*ShowHuman); !ok {
+					t.Fatalf("wrong type %T, want ShowHuman", got)
+				}
+			case *ShowJSON:
+				if _, ok := got.(*ShowJSON); !ok {
+					t.Fatalf("wrong type %T, want ShowJSON", got)
+				}
+			}
+		})
+	}
+}
+
+func TestNewShow_invalid(t *testing.T) {
+	streams, _ := terminal.StreamsForTesting(t)
+	view := NewView(streams)
+
+	got, diags := NewShow(arguments.ViewType("bogus"), view)
+	if got != nil {
+		t.Fatalf("expected a nil Show, got %#v", got)
+	}
+	if !diags.HasErrors() {
+		t.Fatal("expected an error diagnostic")
+	}
+}
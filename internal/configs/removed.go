@@ -0,0 +1,76 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package configs
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+
+	"github.com/hashicorp/terraform/internal/addrs"
+)
+
+// Removed represents a "removed" block within a module, used to record that
+// an object that used to be managed by this module no longer is, so that
+// Terraform can remove it from state (and, unless lifecycle.destroy is set
+// to false, destroy it) without needing a corresponding resource or module
+// block left behind in the configuration.
+type Removed struct {
+	// From is the address of the resource, data source, or module call
+	// that this block is describing.
+	From *addrs.RemoveTarget
+
+	// Destroy is false if the "destroy = false" argument was set inside a
+	// nested lifecycle block, which tells Terraform to forget the object
+	// rather than actually destroying it. It defaults to true.
+	Destroy bool
+
+	DeclRange hcl.Range
+}
+
+var removedBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "from", Required: true},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "lifecycle"},
+	},
+}
+
+var removedLifecycleBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "destroy"},
+	},
+}
+
+func decodeRemovedBlock(block *hcl.Block) (*Removed, hcl.Diagnostics) {
+	r := &Removed{
+		Destroy:   true,
+		DeclRange: block.DefRange,
+	}
+
+	content, diags := block.Body.Content(removedBlockSchema)
+
+	if attr, exists := content.Attributes["from"]; exists {
+		traversal, travDiags := hcl.AbsTraversalForExpr(attr.Expr)
+		diags = append(diags, travDiags...)
+		if !travDiags.HasErrors() {
+			from, targetDiags := addrs.ParseRemoveTarget(traversal)
+			diags = append(diags, targetDiags...)
+			if !targetDiags.HasErrors() {
+				r.From = from
+			}
+		}
+	}
+
+	for _, lifecycleBlock := range content.Blocks {
+		lifecycleContent, lifecycleDiags := lifecycleBlock.Body.Content(removedLifecycleBlockSchema)
+		diags = append(diags, lifecycleDiags...)
+
+		if attr, exists := lifecycleContent.Attributes["destroy"]; exists {
+			diags = append(diags, gohcl.DecodeExpression(attr.Expr, nil, &r.Destroy)...)
+		}
+	}
+
+	return r, diags
+}
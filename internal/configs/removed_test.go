@@ -25,6 +25,8 @@ func TestRemovedBlock_decode(t *testing.T) {
 	foo_index_expr := hcltest.MockExprTraversalSrc("test_instance.foo[1]")
 	mod_foo_expr := hcltest.MockExprTraversalSrc("module.foo")
 	mod_foo_index_expr := hcltest.MockExprTraversalSrc("module.foo[1]")
+	nested_index_expr := hcltest.MockExprTraversalSrc(`module.boop[1].test_instance.foo`)
+	nested_string_index_expr := hcltest.MockExprTraversalSrc(`module.boop["a"].test_instance.foo[0]`)
 
 	tests := map[string]struct {
 		input *hcl.Block
@@ -177,7 +179,7 @@ func TestRemovedBlock_decode(t *testing.T) {
 			},
 			"Missing required argument",
 		},
-		"error: indexed resource instance": {
+		"indexed resource instance": {
 			&hcl.Block{
 				Type: "removed",
 				Body: hcltest.MockBody(&hcl.BodyContent{
@@ -204,13 +206,13 @@ func TestRemovedBlock_decode(t *testing.T) {
 				DefRange: blockRange,
 			},
 			&Removed{
-				From:      nil,
+				From:      mustRemoveEndpointFromExpr(foo_index_expr),
 				Destroy:   true,
 				DeclRange: blockRange,
 			},
-			`Resource instance keys not allowed`,
+			``,
 		},
-		"error: indexed module instance": {
+		"indexed module instance": {
 			&hcl.Block{
 				Type: "removed",
 				Body: hcltest.MockBody(&hcl.BodyContent{
@@ -237,11 +239,51 @@ func TestRemovedBlock_decode(t *testing.T) {
 				DefRange: blockRange,
 			},
 			&Removed{
-				From:      nil,
+				From:      mustRemoveEndpointFromExpr(mod_foo_index_expr),
+				Destroy:   true,
+				DeclRange: blockRange,
+			},
+			``,
+		},
+		"instance nested inside an indexed module call": {
+			&hcl.Block{
+				Type: "removed",
+				Body: hcltest.MockBody(&hcl.BodyContent{
+					Attributes: hcl.Attributes{
+						"from": {
+							Name: "from",
+							Expr: nested_index_expr,
+						},
+					},
+				}),
+				DefRange: blockRange,
+			},
+			&Removed{
+				From:      mustRemoveEndpointFromExpr(nested_index_expr),
 				Destroy:   true,
 				DeclRange: blockRange,
 			},
-			`Module instance keys not allowed`,
+			``,
+		},
+		"indexed instance nested inside a string-indexed module call": {
+			&hcl.Block{
+				Type: "removed",
+				Body: hcltest.MockBody(&hcl.BodyContent{
+					Attributes: hcl.Attributes{
+						"from": {
+							Name: "from",
+							Expr: nested_string_index_expr,
+						},
+					},
+				}),
+				DefRange: blockRange,
+			},
+			&Removed{
+				From:      mustRemoveEndpointFromExpr(nested_string_index_expr),
+				Destroy:   true,
+				DeclRange: blockRange,
+			},
+			``,
 		},
 	}
 
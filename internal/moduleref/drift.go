@@ -0,0 +1,66 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package moduleref
+
+import (
+	"github.com/hashicorp/terraform/internal/configs"
+	"github.com/hashicorp/terraform/internal/modsdir"
+)
+
+// Drift describes how a matched module record's installed source/version
+// differs from what the configuration's module call currently asks for.
+type Drift struct {
+	WantSource            string
+	GotSource             string
+	WantVersionConstraint string
+	GotVersion            string
+	ConstraintSatisfied   bool
+}
+
+// HasDrift reports whether d represents an actual difference between the
+// installed module and what the configuration currently asks for: either
+// the installed source no longer matches the configured one, or the
+// installed version no longer satisfies the configured constraint.
+func (d *Drift) HasDrift() bool {
+	if d == nil {
+		return false
+	}
+	return d.WantSource != d.GotSource || !d.ConstraintSatisfied
+}
+
+// detectDrift compares a module call against the manifest record it
+// resolved to.
+func detectDrift(call *configs.ModuleCall, record modsdir.Record) *Drift {
+	d := &Drift{
+		WantSource:          call.SourceAddrRaw,
+		GotSource:           record.SourceAddr,
+		ConstraintSatisfied: true,
+	}
+
+	if len(call.Version.Required) == 0 {
+		return d
+	}
+	d.WantVersionConstraint = call.Version.Required.String()
+
+	if record.Version == nil {
+		d.ConstraintSatisfied = false
+		return d
+	}
+	d.GotVersion = record.Version.String()
+	d.ConstraintSatisfied = call.Version.Required.Check(record.Version)
+
+	return d
+}
+
+// Drifted returns the subset of rm.Records whose installed source/version
+// has drifted from what the configuration currently asks for.
+func (rm *ResolvedModules) Drifted() []Source {
+	var drifted []Source
+	for _, record := range rm.Records {
+		if record.Drift.HasDrift() {
+			drifted = append(drifted, record)
+		}
+	}
+	return drifted
+}
@@ -0,0 +1,129 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package moduleref
+
+import (
+	"testing"
+
+	version "github.com/hashicorp/go-version"
+
+	"github.com/hashicorp/terraform/internal/configs"
+	"github.com/hashicorp/terraform/internal/modsdir"
+)
+
+func mustConstraints(t *testing.T, raw string) version.Constraints {
+	t.Helper()
+	c, err := version.NewConstraint(raw)
+	if err != nil {
+		t.Fatalf("invalid constraint %q: %s", raw, err)
+	}
+	return c
+}
+
+func mustVersion(t *testing.T, raw string) *version.Version {
+	t.Helper()
+	v, err := version.NewVersion(raw)
+	if err != nil {
+		t.Fatalf("invalid version %q: %s", raw, err)
+	}
+	return v
+}
+
+func TestDetectDrift(t *testing.T) {
+	t.Run("no drift", func(t *testing.T) {
+		call := &configs.ModuleCall{
+			Name:          "vpc",
+			SourceAddrRaw: "terraform-aws-modules/vpc/aws",
+			Version:       configs.VersionConstraint{Required: mustConstraints(t, "~> 3.0")},
+		}
+		record := modsdir.Record{
+			Key:        "vpc",
+			SourceAddr: "terraform-aws-modules/vpc/aws",
+			Version:    mustVersion(t, "3.5.0"),
+		}
+
+		drift := detectDrift(call, record)
+		if drift.HasDrift() {
+			t.Fatalf("expected no drift, got %+v", drift)
+		}
+	})
+
+	t.Run("version constraint no longer satisfied", func(t *testing.T) {
+		call := &configs.ModuleCall{
+			Name:          "vpc",
+			SourceAddrRaw: "terraform-aws-modules/vpc/aws",
+			Version:       configs.VersionConstraint{Required: mustConstraints(t, "~> 4.0")},
+		}
+		record := modsdir.Record{
+			Key:        "vpc",
+			SourceAddr: "terraform-aws-modules/vpc/aws",
+			Version:    mustVersion(t, "3.5.0"),
+		}
+
+		drift := detectDrift(call, record)
+		if !drift.HasDrift() {
+			t.Fatal("expected drift due to unsatisfied version constraint")
+		}
+		if drift.ConstraintSatisfied {
+			t.Fatal("expected ConstraintSatisfied to be false")
+		}
+	})
+
+	t.Run("source changed", func(t *testing.T) {
+		call := &configs.ModuleCall{
+			Name:          "vpc",
+			SourceAddrRaw: "terraform-aws-modules/vpc/aws",
+		}
+		record := modsdir.Record{
+			Key:        "vpc",
+			SourceAddr: "./local-fork-of-vpc",
+		}
+
+		drift := detectDrift(call, record)
+		if !drift.HasDrift() {
+			t.Fatal("expected drift due to changed source")
+		}
+	})
+}
+
+func TestResolvedModules_Drifted(t *testing.T) {
+	cfg := configs.NewEmptyConfig()
+	cfg.Module = &configs.Module{
+		ModuleCalls: map[string]*configs.ModuleCall{
+			"vpc": {
+				Name:          "vpc",
+				SourceAddrRaw: "terraform-aws-modules/vpc/aws",
+				Version:       configs.VersionConstraint{Required: mustConstraints(t, "~> 4.0")},
+			},
+			"eks": {
+				Name:          "eks",
+				SourceAddrRaw: "terraform-aws-modules/eks/aws",
+				Version:       configs.VersionConstraint{Required: mustConstraints(t, "~> 18.0")},
+			},
+		},
+	}
+
+	manifest := modsdir.Manifest{
+		"vpc": modsdir.Record{
+			Key:        "vpc",
+			SourceAddr: "terraform-aws-modules/vpc/aws",
+			Version:    mustVersion(t, "3.5.0"),
+		},
+		"eks": modsdir.Record{
+			Key:        "eks",
+			SourceAddr: "terraform-aws-modules/eks/aws",
+			Version:    mustVersion(t, "18.2.0"),
+		},
+	}
+
+	result := NewResolver(manifest).Resolve(cfg)
+
+	drifted := result.Drifted()
+	if len(drifted) != 1 {
+		t.Fatalf("expected exactly 1 drifted record, got %d", len(drifted))
+	}
+	if drifted[0].Key != "vpc" {
+		t.Fatalf("expected vpc to be the drifted record, got %s", drifted[0].Key)
+	}
+}
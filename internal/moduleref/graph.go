@@ -0,0 +1,110 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package moduleref
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/internal/addrs"
+)
+
+// GraphEdge is a single parent-module -> child-module call in a Graph.
+type GraphEdge struct {
+	Parent addrs.Module
+	Child  addrs.Module
+}
+
+// Graph is a directed acyclic graph of module calls reached while resolving
+// a configuration: an edge from Parent to Child means the module at Parent
+// calls the module at Child. It's the machine-readable counterpart to
+// ResolvedModules.Records, meant for tools that want to diff or render
+// module dependency structure rather than inventory individual modules.
+type Graph struct {
+	Nodes []addrs.Module
+	Edges []GraphEdge
+}
+
+// Graph builds the module dependency graph observed while resolving the
+// configuration rm was produced from. The root module is always included
+// as a node, even if it calls nothing.
+func (rm *ResolvedModules) Graph() *Graph {
+	nodes := map[string]addrs.Module{
+		addrs.RootModule.String(): addrs.RootModule,
+	}
+
+	edges := make([]GraphEdge, 0, len(rm.edges))
+	for _, e := range rm.edges {
+		nodes[e.Parent.String()] = e.Parent
+		nodes[e.Child.String()] = e.Child
+		edges = append(edges, GraphEdge{Parent: e.Parent, Child: e.Child})
+	}
+
+	sortedNodes := make([]addrs.Module, 0, len(nodes))
+	for _, n := range nodes {
+		sortedNodes = append(sortedNodes, n)
+	}
+	sort.Slice(sortedNodes, func(i, j int) bool {
+		return sortedNodes[i].String() < sortedNodes[j].String()
+	})
+
+	sort.Slice(edges, func(i, j int) bool {
+		if ps := edges[i].Parent.String(); ps != edges[j].Parent.String() {
+			return ps < edges[j].Parent.String()
+		}
+		return edges[i].Child.String() < edges[j].Child.String()
+	})
+
+	return &Graph{Nodes: sortedNodes, Edges: edges}
+}
+
+// AdjacencyList is the JSON-serializable form of a Graph: one entry per
+// node, naming the children it calls directly. The root module is named
+// "root", matching the convention `terraform graph` itself uses.
+type AdjacencyList map[string][]string
+
+// AdjacencyList converts g into an AdjacencyList.
+func (g *Graph) AdjacencyList() AdjacencyList {
+	al := make(AdjacencyList, len(g.Nodes))
+	for _, n := range g.Nodes {
+		al[graphNodeLabel(n)] = []string{}
+	}
+	for _, e := range g.Edges {
+		parent := graphNodeLabel(e.Parent)
+		al[parent] = append(al[parent], graphNodeLabel(e.Child))
+	}
+	return al
+}
+
+// JSON renders g as its adjacency-list form, suitable for piping into
+// visualization UIs or diffing between branches in CI.
+func (g *Graph) JSON() ([]byte, error) {
+	return json.MarshalIndent(g.AdjacencyList(), "", "  ")
+}
+
+// DOT renders g in GraphViz DOT format.
+func (g *Graph) DOT() string {
+	var buf bytes.Buffer
+
+	buf.WriteString("digraph {\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&buf, "\t%q;\n", graphNodeLabel(n))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&buf, "\t%q -> %q;\n", graphNodeLabel(e.Parent), graphNodeLabel(e.Child))
+	}
+	buf.WriteString("}\n")
+
+	return buf.String()
+}
+
+func graphNodeLabel(m addrs.Module) string {
+	if len(m) == 0 {
+		return "root"
+	}
+	return strings.Join(m, ".")
+}
@@ -0,0 +1,67 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package moduleref
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/internal/addrs"
+	"github.com/hashicorp/terraform/internal/configs"
+	"github.com/hashicorp/terraform/internal/modsdir"
+)
+
+func TestResolvedModules_Graph(t *testing.T) {
+	cfg := configs.NewEmptyConfig()
+	cfg.Children = make(map[string]*configs.Config)
+	cfg.Module = &configs.Module{
+		ModuleCalls: map[string]*configs.ModuleCall{
+			"fellowship": {Name: "fellowship"},
+		},
+	}
+
+	childCfg := &configs.Config{
+		Path:     addrs.Module{"fellowship"},
+		Children: make(map[string]*configs.Config),
+		Module: &configs.Module{
+			ModuleCalls: map[string]*configs.ModuleCall{
+				"weapons": {Name: "weapons"},
+			},
+		},
+	}
+
+	childCfg2 := &configs.Config{
+		Path:     addrs.Module{"fellowship", "weapons"},
+		Children: make(map[string]*configs.Config),
+		Module:   &configs.Module{},
+	}
+
+	cfg.Children["fellowship"] = childCfg
+	childCfg.Children["weapons"] = childCfg2
+
+	resolver := NewResolver(modsdir.Manifest{})
+	graph := resolver.Resolve(cfg).Graph()
+
+	if len(graph.Nodes) != 3 {
+		t.Fatalf("expected 3 nodes, got %d", len(graph.Nodes))
+	}
+	if len(graph.Edges) != 2 {
+		t.Fatalf("expected 2 edges, got %d", len(graph.Edges))
+	}
+
+	al := graph.AdjacencyList()
+	if got := al["root"]; len(got) != 1 || got[0] != "fellowship" {
+		t.Fatalf("expected root to call fellowship, got %v", got)
+	}
+	if got := al["fellowship"]; len(got) != 1 || got[0] != "fellowship.weapons" {
+		t.Fatalf("expected fellowship to call fellowship.weapons, got %v", got)
+	}
+	if got := al["fellowship.weapons"]; len(got) != 0 {
+		t.Fatalf("expected fellowship.weapons to call nothing, got %v", got)
+	}
+
+	dot := graph.DOT()
+	if dot == "" {
+		t.Fatal("expected non-empty DOT output")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package moduleref
+
+import (
+	"strings"
+
+	"github.com/hashicorp/terraform/internal/addrs"
+	"github.com/hashicorp/terraform/internal/configs"
+	"github.com/hashicorp/terraform/internal/modsdir"
+)
+
+// Resolver walks a configuration tree and matches the module calls it finds
+// against the entries recorded in a module manifest (the contents of
+// .terraform/modules/modules.json), so that callers can tell which manifest
+// entries are actually reachable from the configuration and which are left
+// over from module calls that have since been removed or renamed.
+type Resolver struct {
+	manifest modsdir.Manifest
+}
+
+// NewResolver returns a Resolver that will match module calls against the
+// given manifest.
+func NewResolver(manifest modsdir.Manifest) *Resolver {
+	return &Resolver{
+		manifest: manifest,
+	}
+}
+
+// ResolvedModules is the result of resolving a configuration tree against a
+// module manifest.
+type ResolvedModules struct {
+	// Records contains the manifest entries that are reachable from the
+	// configuration that was resolved, classified by source.
+	Records []Source
+
+	// OrphanedRecords contains the manifest entries that were not reached
+	// while resolving the configuration. These are typically left behind by
+	// module calls that have since been removed or renamed; their
+	// corresponding directories under .terraform/modules are no longer
+	// referenced by anything and are safe to prune.
+	OrphanedRecords []Source
+
+	// edges records the parent-module -> child-module relationships
+	// observed while walking the configuration, for Graph to turn into a
+	// module dependency graph.
+	edges []graphEdge
+}
+
+// Resolve walks cfg and its descendents, matching each module call it finds
+// against the manifest the Resolver was constructed with, and returns the
+// manifest entries that were and weren't reached in the process.
+func (r *Resolver) Resolve(cfg *configs.Config) *ResolvedModules {
+	visited := make(map[string]bool, len(r.manifest))
+
+	var records []Source
+	var edges []graphEdge
+	r.resolve(cfg, &records, &edges, visited)
+
+	var orphaned []Source
+	for key, record := range r.manifest {
+		if !visited[key] {
+			orphaned = append(orphaned, classifySource(record))
+		}
+	}
+
+	return &ResolvedModules{
+		Records:         records,
+		OrphanedRecords: orphaned,
+		edges:           edges,
+	}
+}
+
+func (r *Resolver) resolve(cfg *configs.Config, records *[]Source, edges *[]graphEdge, visited map[string]bool) {
+	if cfg == nil || cfg.Module == nil {
+		return
+	}
+
+	prefix := strings.Join(cfg.Path, ".")
+
+	for name, call := range cfg.Module.ModuleCalls {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		if record, ok := r.manifest[key]; ok {
+			src := classifySource(record)
+			src.Drift = detectDrift(call, record)
+			*records = append(*records, src)
+			visited[key] = true
+		}
+	}
+
+	for _, child := range cfg.Children {
+		*edges = append(*edges, graphEdge{Parent: cfg.Path, Child: child.Path})
+		r.resolve(child, records, edges, visited)
+	}
+}
+
+// graphEdge is a parent-module -> child-module call observed while walking
+// a configuration tree.
+type graphEdge struct {
+	Parent addrs.Module
+	Child  addrs.Module
+}
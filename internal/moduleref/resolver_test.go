@@ -41,6 +41,10 @@ func TestResolver_Resolve(t *testing.T) {
 	if result.Records[0].Key != "foo" {
 		t.Fatal("expected to find reference for module \"foo\"")
 	}
+
+	if len(result.OrphanedRecords) != 1 || result.OrphanedRecords[0].Key != "bar" {
+		t.Fatal("expected to find orphaned reference for module \"bar\"")
+	}
 }
 
 func TestResolver_ResolveNestedChildren(t *testing.T) {
@@ -119,4 +123,15 @@ func TestResolver_ResolveNestedChildren(t *testing.T) {
 			t.Fatalf("expected to find referenced entry with key: %s", record.Key)
 		}
 	}
+
+	if len(result.OrphanedRecords) != 2 {
+		t.Fatalf("expected the orphaned number of entries to equal 2, got: %d", len(result.OrphanedRecords))
+	}
+
+	for _, record := range result.OrphanedRecords {
+		referenced, ok := assertions[record.Key]
+		if !ok || referenced {
+			t.Fatalf("expected to find orphaned entry with key: %s", record.Key)
+		}
+	}
 }
@@ -0,0 +1,182 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package moduleref
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	getter "github.com/hashicorp/go-getter"
+	version "github.com/hashicorp/go-version"
+
+	"github.com/hashicorp/terraform/internal/modsdir"
+)
+
+// SourceKind classifies where a resolved module record was fetched from.
+type SourceKind string
+
+const (
+	SourceLocal     SourceKind = "local"
+	SourceRegistry  SourceKind = "registry"
+	SourceGit       SourceKind = "git"
+	SourceHTTP      SourceKind = "http"
+	SourceS3        SourceKind = "s3"
+	SourceGCS       SourceKind = "gcs"
+	SourceMercurial SourceKind = "mercurial"
+	SourceUnknown   SourceKind = "unknown"
+)
+
+// Source wraps a manifest record with its classified source address: what
+// kind of source it was fetched from, plus whatever subcomponents of the
+// address are meaningful for that kind. Tools that inventory module usage
+// (e.g. to render a source link, or to flag a tree that mixes sources) can
+// rely on this instead of each re-parsing SourceAddr themselves.
+type Source struct {
+	modsdir.Record
+
+	Kind    SourceKind
+	Version *version.Version
+
+	// Populated when Kind == SourceRegistry.
+	RegistryHost      string
+	RegistryNamespace string
+	RegistryName      string
+	RegistrySystem    string
+
+	// Populated when Kind == SourceGit or SourceMercurial.
+	Repo   string
+	Ref    string
+	Subdir string
+
+	// Populated when Kind == SourceS3 or SourceGCS.
+	Bucket string
+	Key    string
+
+	// Drift is non-nil for a Record that was matched against a
+	// configs.ModuleCall, and describes how the installed source/version
+	// differs from what that call currently asks for, if at all.
+	Drift *Drift
+}
+
+// registrySourceRe matches a Terraform registry module source address:
+// [<host>/]<namespace>/<name>/<system>[//<subdir>]
+var registrySourceRe = regexp.MustCompile(
+	`^(?:([0-9A-Za-z](?:[0-9A-Za-z-]*[0-9A-Za-z])?(?:\.[0-9A-Za-z](?:[0-9A-Za-z-]*[0-9A-Za-z])?)+)/)?` +
+		`([0-9A-Za-z_-]+)/([0-9A-Za-z_-]+)/([0-9A-Za-z_-]+)(?://.*)?$`)
+
+// classifySource parses a manifest record's source address into a Source.
+// For the remote cases (git, http, s3, gcs, mercurial) it reuses go-getter's
+// own detector logic — the same logic the module installer relies on to
+// turn a "shorthand" address like "github.com/hashicorp/example" into a
+// fully qualified one — rather than duplicating that parsing with ad-hoc
+// regexes.
+func classifySource(record modsdir.Record) Source {
+	src := Source{
+		Record:  record,
+		Version: record.Version,
+	}
+
+	addr := record.SourceAddr
+
+	switch {
+	case isLocalSourceAddr(addr):
+		src.Kind = SourceLocal
+		return src
+	case isRegistrySourceAddr(addr):
+		src.Kind = SourceRegistry
+		m := registrySourceRe.FindStringSubmatch(addr)
+		src.RegistryHost = m[1]
+		src.RegistryNamespace = m[2]
+		src.RegistryName = m[3]
+		src.RegistrySystem = m[4]
+		return src
+	}
+
+	detected, err := getter.Detect(addr, ".", getter.Detectors)
+	if err != nil {
+		src.Kind = SourceUnknown
+		return src
+	}
+
+	switch {
+	case strings.HasPrefix(detected, "git::"):
+		src.Kind = SourceGit
+		src.Repo, src.Ref, src.Subdir = parseVCSGetterURL(strings.TrimPrefix(detected, "git::"))
+	case strings.HasPrefix(detected, "hg::"):
+		src.Kind = SourceMercurial
+		src.Repo, src.Ref, src.Subdir = parseVCSGetterURL(strings.TrimPrefix(detected, "hg::"))
+	case strings.HasPrefix(detected, "s3::"):
+		src.Kind = SourceS3
+		src.Bucket, src.Key = parseObjectStoreGetterURL(strings.TrimPrefix(detected, "s3::"))
+	case strings.HasPrefix(detected, "gcs::"):
+		src.Kind = SourceGCS
+		src.Bucket, src.Key = parseObjectStoreGetterURL(strings.TrimPrefix(detected, "gcs::"))
+	case strings.HasPrefix(detected, "http://"), strings.HasPrefix(detected, "https://"):
+		src.Kind = SourceHTTP
+	default:
+		src.Kind = SourceUnknown
+	}
+
+	return src
+}
+
+// isLocalSourceAddr reports whether addr is a local path reference, using
+// the same "./" / "../" convention Terraform uses elsewhere to distinguish
+// local module sources from remote and registry ones.
+func isLocalSourceAddr(addr string) bool {
+	return strings.HasPrefix(addr, "./") || strings.HasPrefix(addr, "../") ||
+		strings.HasPrefix(addr, `.\`) || strings.HasPrefix(addr, `..\`)
+}
+
+func isRegistrySourceAddr(addr string) bool {
+	return !strings.Contains(addr, "://") && !strings.Contains(addr, "::") &&
+		registrySourceRe.MatchString(addr)
+}
+
+// parseVCSGetterURL splits a go-getter "git::"/"hg::" URL (with its forcing
+// prefix already removed) into the repository URL, the ref query parameter
+// (if any), and the "//subdir" suffix (if any).
+func parseVCSGetterURL(raw string) (repo, ref, subdir string) {
+	src, subdir := getter.SourceDirSubdir(raw)
+
+	u, err := url.Parse(src)
+	if err != nil {
+		return src, "", subdir
+	}
+
+	q := u.Query()
+	ref = q.Get("ref")
+	q.Del("ref")
+	u.RawQuery = q.Encode()
+
+	return u.String(), ref, subdir
+}
+
+// parseObjectStoreGetterURL splits a go-getter "s3::"/"gcs::" URL (with its
+// forcing prefix already removed) into a bucket and an object key, handling
+// both virtual-hosted-style (bucket.s3.amazonaws.com/key) and path-style
+// (s3.amazonaws.com/bucket/key) addresses.
+func parseObjectStoreGetterURL(raw string) (bucket, key string) {
+	src, _ := getter.SourceDirSubdir(raw)
+
+	u, err := url.Parse(src)
+	if err != nil {
+		return "", ""
+	}
+
+	path := strings.TrimPrefix(u.Path, "/")
+
+	if host := u.Host; strings.Contains(host, ".s3.") || strings.Contains(host, ".s3-") || strings.Contains(host, ".storage.googleapis.com") {
+		if idx := strings.Index(host, "."); idx > 0 {
+			return host[:idx], path
+		}
+	}
+
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return "", path
+}
@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package moduleref
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/internal/modsdir"
+)
+
+func TestClassifySource(t *testing.T) {
+	tests := map[string]struct {
+		addr string
+		want SourceKind
+	}{
+		"local":              {"./foo", SourceLocal},
+		"local dotdot":       {"../foo", SourceLocal},
+		"registry":           {"terraform-aws-modules/vpc/aws", SourceRegistry},
+		"registry with host": {"app.terraform.io/example-org/vpc/aws", SourceRegistry},
+		"git shorthand":      {"github.com/hashicorp/example", SourceGit},
+		"git forced":         {"git::https://example.com/vpc.git", SourceGit},
+		"mercurial forced":   {"hg::http://example.com/vpc.hg", SourceMercurial},
+		"http":               {"https://example.com/vpc.zip", SourceHTTP},
+		"s3 forced":          {"s3::https://s3.amazonaws.com/bucket/key/module.zip", SourceS3},
+		"gcs forced":         {"gcs::https://www.googleapis.com/storage/v1/bucket/module.zip", SourceGCS},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := classifySource(modsdir.Record{Key: name, SourceAddr: test.addr})
+			if got.Kind != test.want {
+				t.Fatalf("classifySource(%q).Kind = %s, want %s", test.addr, got.Kind, test.want)
+			}
+		})
+	}
+}
+
+func TestClassifySource_git(t *testing.T) {
+	got := classifySource(modsdir.Record{
+		Key:        "example",
+		SourceAddr: "git::https://example.com/vpc.git//modules/eks?ref=v1.2.3",
+	})
+
+	if got.Kind != SourceGit {
+		t.Fatalf("expected SourceGit, got %s", got.Kind)
+	}
+	if got.Repo != "https://example.com/vpc.git" {
+		t.Fatalf("unexpected Repo: %s", got.Repo)
+	}
+	if got.Ref != "v1.2.3" {
+		t.Fatalf("unexpected Ref: %s", got.Ref)
+	}
+	if got.Subdir != "modules/eks" {
+		t.Fatalf("unexpected Subdir: %s", got.Subdir)
+	}
+}
+
+func TestClassifySource_s3(t *testing.T) {
+	got := classifySource(modsdir.Record{
+		Key:        "example",
+		SourceAddr: "s3::https://s3.amazonaws.com/my-bucket/modules/vpc.zip",
+	})
+
+	if got.Kind != SourceS3 {
+		t.Fatalf("expected SourceS3, got %s", got.Kind)
+	}
+	if got.Bucket != "my-bucket" {
+		t.Fatalf("unexpected Bucket: %s", got.Bucket)
+	}
+	if got.Key != "modules/vpc.zip" {
+		t.Fatalf("unexpected Key: %s", got.Key)
+	}
+}
+
+func TestClassifySource_registryComponents(t *testing.T) {
+	got := classifySource(modsdir.Record{
+		Key:        "example",
+		SourceAddr: "app.terraform.io/example-org/vpc/aws",
+	})
+
+	if got.Kind != SourceRegistry {
+		t.Fatalf("expected SourceRegistry, got %s", got.Kind)
+	}
+	if got.RegistryHost != "app.terraform.io" {
+		t.Fatalf("unexpected RegistryHost: %s", got.RegistryHost)
+	}
+	if got.RegistryNamespace != "example-org" {
+		t.Fatalf("unexpected RegistryNamespace: %s", got.RegistryNamespace)
+	}
+	if got.RegistryName != "vpc" {
+		t.Fatalf("unexpected RegistryName: %s", got.RegistryName)
+	}
+	if got.RegistrySystem != "aws" {
+		t.Fatalf("unexpected RegistrySystem: %s", got.RegistrySystem)
+	}
+}
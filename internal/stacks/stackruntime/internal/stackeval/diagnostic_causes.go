@@ -0,0 +1,178 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package stackeval
+
+import (
+	"sync"
+
+	"github.com/hashicorp/terraform/internal/tfdiags"
+)
+
+// DiagnosticCause is a single bit in a DiagnosticCauses bitset, identifying
+// one reason why a diagnostic might be of interest to the UI layer or to
+// downstream tooling -- for example, that the diagnostic concerns a
+// sensitive value, or an unknown value, or a deferred action.
+type DiagnosticCause uint64
+
+// The cause codes predefined by this package. Downstream packages that
+// need additional causes should register their own via
+// RegisterDiagnosticCause rather than hard-coding new bit positions here,
+// so that causes defined in different packages can never collide.
+const (
+	DiagnosticCauseSensitive DiagnosticCause = 1 << iota
+	DiagnosticCauseEphemeral
+	DiagnosticCauseUnknownValue
+	DiagnosticCauseDeferredAction
+	DiagnosticCauseProviderSchemaMismatch
+	DiagnosticCauseCrossComponentRef
+
+	diagnosticCauseNextBuiltin // marks the next available bit for RegisterDiagnosticCause
+)
+
+var diagnosticCauseRegistryMu sync.Mutex
+var diagnosticCauseNextBit = diagnosticCauseNextBuiltin
+var diagnosticCauseNames = map[DiagnosticCause]string{
+	DiagnosticCauseSensitive:              "sensitive",
+	DiagnosticCauseEphemeral:              "ephemeral",
+	DiagnosticCauseUnknownValue:           "unknown-value",
+	DiagnosticCauseDeferredAction:         "deferred-action",
+	DiagnosticCauseProviderSchemaMismatch: "provider-schema-mismatch",
+	DiagnosticCauseCrossComponentRef:      "cross-component-ref",
+}
+
+// RegisterDiagnosticCause allocates and returns a new, globally-unique
+// DiagnosticCause bit for use by a downstream package, identified by the
+// given name for debugging and introspection purposes.
+//
+// This should typically be called exactly once per distinct cause, stored
+// in a package-level variable, analogous to how context keys are usually
+// defined. Calling it repeatedly would allocate a new, distinct bit each
+// time even if the name is reused.
+func RegisterDiagnosticCause(name string) DiagnosticCause {
+	diagnosticCauseRegistryMu.Lock()
+	defer diagnosticCauseRegistryMu.Unlock()
+
+	bit := diagnosticCauseNextBit
+	diagnosticCauseNextBit <<= 1
+	diagnosticCauseNames[bit] = name
+	return bit
+}
+
+// String returns the registered name for a single-bit DiagnosticCause, or
+// a generic placeholder if the cause is unrecognized or is a combination
+// of more than one bit.
+func (c DiagnosticCause) String() string {
+	diagnosticCauseRegistryMu.Lock()
+	name, ok := diagnosticCauseNames[c]
+	diagnosticCauseRegistryMu.Unlock()
+	if ok {
+		return name
+	}
+	return "unknown-cause"
+}
+
+// Has returns true if every bit set in other is also set in c, so that a
+// combined DiagnosticCauses value can be tested for any one of its
+// constituent causes.
+func (c DiagnosticCause) Has(other DiagnosticCause) bool {
+	return c&other == other
+}
+
+// diagnosticCauses is the concrete type assigned to a diagnostic's "Extra"
+// field to report which DiagnosticCause bits apply to it. It's
+// unexported because external code should only ever construct one via
+// WithCause and inspect one via HasCause, keeping open the option to
+// change the internal representation later.
+type diagnosticCauses DiagnosticCause
+
+// WithCause returns a copy of diag whose ExtraInfo additionally reports
+// cause, preserving any causes already present (whether set via an
+// earlier call to WithCause, or via the legacy diagnosticCausedBySensitive
+// / diagnosticCausedByEphemeral wrapper types).
+func WithCause(diag tfdiags.Diagnostic, cause DiagnosticCause) tfdiags.Diagnostic {
+	return causedDiagnostic{
+		Diagnostic: diag,
+		causes:     diagnosticCausesOf(diag) | diagnosticCauses(cause),
+	}
+}
+
+// HasCause reports whether diag's ExtraInfo -- found either directly, or
+// one tfdiags.DiagnosticExtraWrapper level down -- includes the
+// given DiagnosticCause.
+func HasCause(diag tfdiags.Diagnostic, cause DiagnosticCause) bool {
+	return DiagnosticCause(diagnosticCausesOf(diag)).Has(cause)
+}
+
+// diagnosticCausesOf extracts the diagnosticCauses bitset already present
+// on diag, if any, also recognizing the two legacy boolean ExtraInfo
+// types so that diagnostics created before DiagnosticCauses existed still
+// report correctly through HasCause.
+func diagnosticCausesOf(diag tfdiags.Diagnostic) diagnosticCauses {
+	extra := diag.ExtraInfo()
+	if cd, ok := extra.(causedDiagnostic); ok {
+		return cd.causes
+	}
+	if wrapper, ok := extra.(tfdiags.DiagnosticExtraWrapper); ok {
+		extra = wrapper.WrappedDiagnosticExtra()
+	}
+
+	var causes diagnosticCauses
+	switch v := extra.(type) {
+	case diagnosticCauses:
+		causes = v
+	case causedDiagnostic:
+		causes = v.causes
+	}
+	if v, ok := extra.(tfdiags.DiagnosticExtraBecauseSensitive); ok && v.DiagnosticCausedBySensitive() {
+		causes |= diagnosticCauses(DiagnosticCauseSensitive)
+	}
+	if v, ok := extra.(tfdiags.DiagnosticExtraBecauseEphemeral); ok && v.DiagnosticCausedByEphemeral() {
+		causes |= diagnosticCauses(DiagnosticCauseEphemeral)
+	}
+	return causes
+}
+
+// causedDiagnostic wraps another diagnostic to attach (or add to) its
+// DiagnosticCauses bitset, while still delegating everything else -- and
+// the original ExtraInfo, via WrappedDiagnosticExtra -- to the wrapped
+// diagnostic.
+type causedDiagnostic struct {
+	tfdiags.Diagnostic
+	causes diagnosticCauses
+}
+
+var _ tfdiags.DiagnosticExtraWrapper = causedDiagnostic{}
+var _ tfdiags.DiagnosticExtraBecauseSensitive = causedDiagnostic{}
+var _ tfdiags.DiagnosticExtraBecauseEphemeral = causedDiagnostic{}
+
+// ExtraInfo implements tfdiags.Diagnostic, overriding the embedded
+// Diagnostic's ExtraInfo to report d itself, so that the causes bitset, the
+// legacy marker interfaces and WrappedDiagnosticExtra below are all
+// reachable from the extra info.
+func (d causedDiagnostic) ExtraInfo() interface{} {
+	return d
+}
+
+// WrappedDiagnosticExtra implements tfdiags.DiagnosticExtraWrapper, giving
+// callers that know how to unwrap access to whatever ExtraInfo the
+// original diagnostic carried.
+func (d causedDiagnostic) WrappedDiagnosticExtra() interface{} {
+	return d.Diagnostic.ExtraInfo()
+}
+
+// DiagnosticCausedBySensitive implements
+// tfdiags.DiagnosticExtraBecauseSensitive, as a thin wrapper over the
+// bitset, so that existing UI-layer type assertions for this interface
+// keep working unmodified.
+func (d causedDiagnostic) DiagnosticCausedBySensitive() bool {
+	return DiagnosticCause(d.causes).Has(DiagnosticCauseSensitive)
+}
+
+// DiagnosticCausedByEphemeral implements
+// tfdiags.DiagnosticExtraBecauseEphemeral, as a thin wrapper over the
+// bitset, so that existing UI-layer type assertions for this interface
+// keep working unmodified.
+func (d causedDiagnostic) DiagnosticCausedByEphemeral() bool {
+	return DiagnosticCause(d.causes).Has(DiagnosticCauseEphemeral)
+}
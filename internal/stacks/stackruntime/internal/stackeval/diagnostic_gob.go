@@ -0,0 +1,145 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package stackeval
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/terraform/internal/tfdiags"
+)
+
+// diagnosticGobEnvelope is a gob-encodable snapshot of a tfdiags.Diagnostic,
+// capturing just enough information to reconstruct an equivalent
+// diagnostic after a round trip through a disk- or network-backed
+// ResultCache, where the original diagnostic's concrete Go type (and any
+// unexported fields it carries) can't be preserved directly.
+//
+// This intentionally only preserves the subset of a diagnostic that's
+// useful to replay to an end user after a cache hit: its severity,
+// description, source location, and whichever of this package's own
+// ExtraInfo marker types (diagnosticCausedBySensitive,
+// diagnosticCausedByEphemeral) apply; any other "extra info" is dropped.
+// The diagnostic types taskSelfDependencyDiagnostic and
+// taskPromisesUnresolvedDiagnostic, which need a live namedPromiseReporter
+// to render their description, are not round-trippable and cause
+// newDiagnosticGobEnvelope to report ok=false so callers can skip caching
+// them.
+type diagnosticGobEnvelope struct {
+	Severity          tfdiags.Severity
+	Summary           string
+	Detail            string
+	SourceFilename    string
+	SourceStartLine   int
+	SourceStartColumn int
+	SourceStartByte   int
+	HasSource         bool
+	CausedBySensitive bool
+	CausedByEphemeral bool
+}
+
+// newDiagnosticGobEnvelope captures diag into a diagnosticGobEnvelope, if
+// possible. It returns ok=false for diagnostic types that depend on
+// context this package can't reconstruct after a round trip, such as the
+// self-dependency diagnostics that need a live namedPromiseReporter.
+func newDiagnosticGobEnvelope(diag tfdiags.Diagnostic) (diagnosticGobEnvelope, bool) {
+	switch diag.(type) {
+	case taskSelfDependencyDiagnostic, taskPromisesUnresolvedDiagnostic:
+		return diagnosticGobEnvelope{}, false
+	}
+
+	desc := diag.Description()
+	source := diag.Source()
+	env := diagnosticGobEnvelope{
+		Severity: diag.Severity(),
+		Summary:  desc.Summary,
+		Detail:   desc.Detail,
+	}
+	if source.Subject != nil {
+		env.HasSource = true
+		env.SourceFilename = source.Subject.Filename
+		env.SourceStartLine = source.Subject.Start.Line
+		env.SourceStartColumn = source.Subject.Start.Column
+		env.SourceStartByte = source.Subject.Start.Byte
+	}
+
+	if extra := diag.ExtraInfo(); extra != nil {
+		if v, ok := extra.(tfdiags.DiagnosticExtraBecauseSensitive); ok {
+			env.CausedBySensitive = v.DiagnosticCausedBySensitive()
+		}
+		if v, ok := extra.(tfdiags.DiagnosticExtraBecauseEphemeral); ok {
+			env.CausedByEphemeral = v.DiagnosticCausedByEphemeral()
+		}
+	}
+
+	return env, true
+}
+
+var _ tfdiags.Diagnostic = diagnosticGobEnvelope{}
+
+// Diagnostic reconstructs a tfdiags.Diagnostic from this envelope, for use
+// after retrieving it from a ResultCache. The envelope itself already
+// satisfies tfdiags.Diagnostic, so this simply returns itself.
+func (env diagnosticGobEnvelope) Diagnostic() tfdiags.Diagnostic {
+	return env
+}
+
+// Description implements tfdiags.Diagnostic.
+func (env diagnosticGobEnvelope) Description() tfdiags.Description {
+	return tfdiags.Description{Summary: env.Summary, Detail: env.Detail}
+}
+
+// ExtraInfo implements tfdiags.Diagnostic.
+func (env diagnosticGobEnvelope) ExtraInfo() interface{} {
+	if !env.CausedBySensitive && !env.CausedByEphemeral {
+		return nil
+	}
+	return diagnosticGobExtra{
+		sensitive: diagnosticCausedBySensitive(env.CausedBySensitive),
+		ephemeral: diagnosticCausedByEphemeral(env.CausedByEphemeral),
+	}
+}
+
+// FromExpr implements tfdiags.Diagnostic.
+func (env diagnosticGobEnvelope) FromExpr() *tfdiags.FromExpr {
+	return nil
+}
+
+// Severity implements tfdiags.Diagnostic.
+func (env diagnosticGobEnvelope) Severity() tfdiags.Severity {
+	return env.Severity
+}
+
+// Source implements tfdiags.Diagnostic.
+func (env diagnosticGobEnvelope) Source() tfdiags.Source {
+	if !env.HasSource {
+		return tfdiags.Source{}
+	}
+	pos := hcl.Pos{Line: env.SourceStartLine, Column: env.SourceStartColumn, Byte: env.SourceStartByte}
+	return tfdiags.Source{
+		Subject: &hcl.Range{
+			Filename: env.SourceFilename,
+			Start:    pos,
+			End:      pos,
+		},
+	}
+}
+
+// diagnosticGobExtra bundles together the two ExtraInfo marker types this
+// package defines so that a diagnosticGobEnvelope can report both at once.
+type diagnosticGobExtra struct {
+	sensitive diagnosticCausedBySensitive
+	ephemeral diagnosticCausedByEphemeral
+}
+
+var _ tfdiags.DiagnosticExtraBecauseSensitive = diagnosticGobExtra{}
+var _ tfdiags.DiagnosticExtraBecauseEphemeral = diagnosticGobExtra{}
+
+// DiagnosticCausedBySensitive implements tfdiags.DiagnosticExtraBecauseSensitive.
+func (e diagnosticGobExtra) DiagnosticCausedBySensitive() bool {
+	return bool(e.sensitive)
+}
+
+// DiagnosticCausedByEphemeral implements tfdiags.DiagnosticExtraBecauseEphemeral.
+func (e diagnosticGobExtra) DiagnosticCausedByEphemeral() bool {
+	return bool(e.ephemeral)
+}
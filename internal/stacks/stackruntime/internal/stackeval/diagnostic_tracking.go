@@ -0,0 +1,83 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package stackeval
+
+import (
+	"sync"
+
+	"github.com/hashicorp/terraform/internal/tfdiags"
+)
+
+// TrackDiagnosticHook is the signature for a callback installed via
+// SetTrackDiagnosticHook.
+//
+// emit is the default continuation: calling it appends diag to whatever
+// diagnostics container the caller is building up, and returns true if the
+// diagnostic was (or, for the no-op default hook, always is) actually kept.
+// A hook can call emit zero or more times -- including with a diagnostic
+// other than diag, to rewrite it, or not at all, to suppress it -- and can
+// also call emit additional times later of its own accord, to replay
+// previously-seen diagnostics (for example, on a cache hit). trackDiagnostic
+// ignores the hook's own return value.
+type TrackDiagnosticHook func(diag tfdiags.Diagnostic, emit func(tfdiags.Diagnostic) bool) bool
+
+var trackDiagnosticHookMu sync.RWMutex
+var trackDiagnosticHook TrackDiagnosticHook = defaultTrackDiagnosticHook
+
+// defaultTrackDiagnosticHook is installed at init time and simply emits
+// every diagnostic unmodified, preserving today's behavior for anyone who
+// hasn't opted in to a custom hook.
+func defaultTrackDiagnosticHook(diag tfdiags.Diagnostic, emit func(tfdiags.Diagnostic) bool) bool {
+	return emit(diag)
+}
+
+// SetTrackDiagnosticHook installs a global hook that's consulted for every
+// diagnostic emitted through this package's central trackDiagnostic helper,
+// which today covers syncDiagnostics.Append, doOnceWithDiags, and
+// finalDiagnosticsFromEval.
+//
+// This exists to let an external subsystem -- such as a future incremental
+// evaluator or a stackruntime result cache -- observe, suppress, rewrite,
+// or replay diagnostics without any of the evaluation code in this package
+// needing to know that subsystem exists. Passing nil restores the default
+// no-op hook.
+//
+// This is a process-wide global, so callers are expected to install it once
+// during startup (or test setup) rather than swapping it in and out around
+// individual evaluations.
+func SetTrackDiagnosticHook(hook TrackDiagnosticHook) {
+	trackDiagnosticHookMu.Lock()
+	defer trackDiagnosticHookMu.Unlock()
+	if hook == nil {
+		hook = defaultTrackDiagnosticHook
+	}
+	trackDiagnosticHook = hook
+}
+
+// trackDiagnostic is the single central entry point that every diagnostic
+// produced by this package should pass through before being appended to a
+// caller-visible tfdiags.Diagnostics. It consults the currently-installed
+// TrackDiagnosticHook, defaulting to emitting the diagnostic unmodified.
+func trackDiagnostic(diags tfdiags.Diagnostics, diag tfdiags.Diagnostic) tfdiags.Diagnostics {
+	trackDiagnosticHookMu.RLock()
+	hook := trackDiagnosticHook
+	trackDiagnosticHookMu.RUnlock()
+
+	hook(diag, func(d tfdiags.Diagnostic) bool {
+		diags = diags.Append(d)
+		return true
+	})
+	return diags
+}
+
+// trackDiagnostics is like trackDiagnostic but for a whole batch of
+// diagnostics at once, such as the result of a tfdiags.Diagnostics.Append
+// call that might have expanded a single argument into several
+// diagnostics.
+func trackDiagnostics(diags tfdiags.Diagnostics, new tfdiags.Diagnostics) tfdiags.Diagnostics {
+	for _, diag := range new {
+		diags = trackDiagnostic(diags, diag)
+	}
+	return diags
+}
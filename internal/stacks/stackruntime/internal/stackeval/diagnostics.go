@@ -6,6 +6,7 @@ package stackeval
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
@@ -42,13 +43,15 @@ func doOnceWithDiags[T any](
 	}
 	ret, err := once.Do(ctx, func(ctx context.Context) (withDiagnostics[T], error) {
 		ret, diags := f(ctx)
+		var tracked tfdiags.Diagnostics
+		tracked = trackDiagnostics(tracked, diags)
 		return withDiagnostics[T]{
 			Result:      ret,
-			Diagnostics: diags,
+			Diagnostics: tracked,
 		}, nil
 	})
 	if err != nil {
-		ret.Diagnostics = ret.Diagnostics.Append(diagnosticsForPromisingTaskError(err, root))
+		ret.Diagnostics = trackDiagnostics(ret.Diagnostics, diagnosticsForPromisingTaskError(err, root))
 	}
 	return ret.Result, ret.Diagnostics
 }
@@ -88,9 +91,16 @@ type syncDiagnostics struct {
 // Append converts all of the given arguments to zero or more diagnostics
 // and appends them to the internal diagnostics list, modifying this object
 // in-place.
+//
+// Each resulting diagnostic is routed through the package's central
+// trackDiagnostic helper, so a hook installed via SetTrackDiagnosticHook
+// gets a chance to observe, suppress, rewrite, or replay it.
 func (sd *syncDiagnostics) Append(new ...any) {
+	var toAdd tfdiags.Diagnostics
+	toAdd = toAdd.Append(new...)
+
 	sd.mu.Lock()
-	sd.diags = sd.diags.Append(new...)
+	sd.diags = trackDiagnostics(sd.diags, toAdd)
 	sd.mu.Unlock()
 }
 
@@ -110,18 +120,28 @@ func (sd *syncDiagnostics) Take() tfdiags.Diagnostics {
 // package. This should typically be used as a final step in functions that
 // act as entry points into this package from callers in package stackruntime.
 //
-// Currently the only special work this does is removing any duplicate
-// diagnostics relating to self-dependency problems. These tend to appear
-// multiple times since all of the promises in the chain all fail at the
-// same time and thus effectively the same diagnostic gets appended multiple
-// times by different paths. Only the first such diagnostic will be preserved
-// by this function.
+// Because diagnostics are often accumulated concurrently via syncDiagnostics
+// while independent parts of the configuration are evaluated in parallel,
+// the order they arrive in is not deterministic across otherwise-identical
+// runs. To keep golden-file tests of the stacks runtime stable, this
+// function sorts the diagnostics into a deterministic total order before
+// returning them; see diagnosticSortsBefore for the comparator.
+//
+// This also removes any duplicate diagnostics relating to self-dependency
+// problems. These tend to appear multiple times since all of the promises
+// in the chain all fail at the same time and thus effectively the same
+// diagnostic gets appended multiple times by different paths. Only the
+// first such diagnostic will be preserved by this function.
 func finalDiagnosticsFromEval(diags tfdiags.Diagnostics) tfdiags.Diagnostics {
+	var tracked tfdiags.Diagnostics
+	tracked = trackDiagnostics(tracked, diags)
+	diags = tracked
+
 	if len(diags) == 0 {
 		return diags // handle the happy path as quickly as possible
 	}
 	if !diags.HasErrors() {
-		return diags // also a relatively happy path: just warnings
+		return sortDiagnosticsDeterministically(diags) // also a relatively happy path: just warnings
 	}
 
 	// If we have at least two errors then we could potentially have a
@@ -136,7 +156,7 @@ func finalDiagnosticsFromEval(diags tfdiags.Diagnostics) tfdiags.Diagnostics {
 		}
 	}
 	if foundSelfDepErrs <= 1 {
-		return diags // no massaging needed
+		return sortDiagnosticsDeterministically(diags) // no massaging needed
 	}
 
 	// If we get here then we _do_ have at least two self-dependency errors,
@@ -158,9 +178,59 @@ func finalDiagnosticsFromEval(diags tfdiags.Diagnostics) tfdiags.Diagnostics {
 		diags = diags[:len(diags)-1]
 		i-- // must still visit the next item that we've moved to an earlier index
 	}
+	return sortDiagnosticsDeterministically(diags)
+}
+
+// sortDiagnosticsDeterministically sorts the given diagnostics into a
+// stable total order that doesn't depend on the goroutine scheduling that
+// produced them, so that two evaluations of the same configuration always
+// return diagnostics in the same order.
+//
+// The sort key is, in priority order: source location (file path, then
+// line, then column), severity (errors before warnings), and finally the
+// diagnostic's summary text. Diagnostics without a meaningful source
+// location -- such as taskSelfDependencyDiagnostic and
+// taskPromisesUnresolvedDiagnostic, whose Source() is always the zero
+// value -- sort after every diagnostic that does have one.
+func sortDiagnosticsDeterministically(diags tfdiags.Diagnostics) tfdiags.Diagnostics {
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diagnosticSortsBefore(diags[i], diags[j])
+	})
 	return diags
 }
 
+func diagnosticSortsBefore(a, b tfdiags.Diagnostic) bool {
+	aLoc, aHasLoc := diagnosticSourceLocation(a)
+	bLoc, bHasLoc := diagnosticSourceLocation(b)
+	if aHasLoc != bHasLoc {
+		// Located diagnostics always sort before unlocated ones.
+		return aHasLoc
+	}
+	if aHasLoc && aLoc != bLoc {
+		return aLoc < bLoc
+	}
+
+	aSev, bSev := a.Severity(), b.Severity()
+	if aSev != bSev {
+		// tfdiags.Error sorts before tfdiags.Warning.
+		return aSev < bSev
+	}
+
+	return a.Description().Summary < b.Description().Summary
+}
+
+// diagnosticSourceLocation returns a single comparable string representing
+// a diagnostic's source location -- file path, then line, then column --
+// along with whether the diagnostic actually has one.
+func diagnosticSourceLocation(diag tfdiags.Diagnostic) (string, bool) {
+	source := diag.Source()
+	if source.Subject == nil {
+		return "", false
+	}
+	start := source.Subject.Start
+	return fmt.Sprintf("%s:%08d:%08d", source.Subject.Filename, start.Line, start.Column), true
+}
+
 func diagIsPromiseSelfReference(diag tfdiags.Diagnostic) bool {
 	// This intentionally diverges from our usual convention of
 	// using interface types for "extra info" matching because this
@@ -208,13 +278,34 @@ func diagnosticsForPromisingTaskError(err error, root namedPromiseReporter) tfdi
 // of the promises involved using the given namedPromiseReporter.
 func taskSelfDependencyDiagnostics(err promising.ErrSelfDependent, root namedPromiseReporter) tfdiags.Diagnostics {
 	var diags tfdiags.Diagnostics
-	// For now we just save the context about the problem, and then we'll
-	// generate the human-readable description on demand once someone asks
-	// for the diagnostic description.
-	diags = diags.Append(taskSelfDependencyDiagnostic{
-		err:  err,
-		root: root,
-	})
+
+	// If root can also report the dependency edges between its promises,
+	// we can restrict the raw (and potentially noisy) promise list in err
+	// down to the actual strongly-connected components that close a
+	// cycle, and emit one diagnostic per disjoint cycle found. If edge
+	// information isn't available, or doesn't actually decompose into
+	// multiple distinct cycles, we fall back to treating the whole of
+	// err as a single (possibly unordered) self-dependency diagnostic,
+	// matching the previous behavior.
+	edges := collectPromiseEdges(root)
+	sccs := promiseSCCs(err, edges)
+	if len(sccs) <= 1 {
+		// For now we just save the context about the problem, and then
+		// we'll generate the human-readable description on demand once
+		// someone asks for the diagnostic description.
+		diags = diags.Append(taskSelfDependencyDiagnostic{
+			err:  err,
+			root: root,
+		})
+		return diags
+	}
+
+	for _, scc := range sccs {
+		diags = diags.Append(taskSelfDependencyDiagnostic{
+			err:  promising.ErrSelfDependent(scc),
+			root: root,
+		})
+	}
 	return diags
 }
 
@@ -291,6 +382,38 @@ func (diag taskSelfDependencyDiagnostic) Description() tfdiags.Description {
 			Detail:  fmt.Sprintf("The item %q depends on its own results, so there is no correct order of operations.", name),
 		}
 	default:
+		// If we have dependency edge information available for this root,
+		// we can try to arrange the promises into an explicit cycle --
+		// "A -> B -> C -> A" -- which is much more actionable than an
+		// unordered bullet list, since it shows exactly which references
+		// close the loop.
+		if edges := collectPromiseEdges(root); len(edges) > 0 {
+			if ordered, ok := orderPromiseCycle(err, edges); ok {
+				var cycle strings.Builder
+				for _, id := range ordered {
+					name := promiseNames[id]
+					if name == "" {
+						name = "(...)"
+					}
+					fmt.Fprintf(&cycle, "%s -> ", name)
+				}
+				fmt.Fprintf(&cycle, "%s", func() string {
+					name := promiseNames[ordered[0]]
+					if name == "" {
+						return "(...)"
+					}
+					return name
+				}())
+				return tfdiags.Description{
+					Summary: "Self-dependent items in configuration",
+					Detail: fmt.Sprintf(
+						"The following items in your configuration form a circular dependency chain through their references:\n\n  %s\n\nTerraform uses references to decide a suitable order for performing operations, so configuration items may not refer to their own results either directly or indirectly.",
+						cycle.String(),
+					),
+				}
+			}
+		}
+
 		// If we have more than one promise involved then it's non-deterministic
 		// which one we'll detect, since it depends on how the tasks get
 		// scheduled by the Go runtime. To return a deterministic-ish result
@@ -500,6 +623,14 @@ func collectPromiseNames(r namedPromiseReporter) map[promising.PromiseID]string
 // diagnosticCausedBySensitive can be assigned to the "Extra" field of a
 // diagnostic to hint to the UI layer that the sensitivity of values in scope
 // is relevant to the diagnostic message.
+//
+// This is now a thin wrapper over the single DiagnosticCauseSensitive bit
+// of the more general DiagnosticCauses bitset defined in
+// diagnostic_causes.go; new code should prefer constructing diagnostics
+// with WithCause(diag, DiagnosticCauseSensitive) directly, but this type
+// is kept (and still recognized by diagnosticCausesOf/HasCause) so that
+// existing call sites and the UI layer's type assertions for
+// tfdiags.DiagnosticExtraBecauseSensitive keep working unmodified.
 type diagnosticCausedBySensitive bool
 
 var _ tfdiags.DiagnosticExtraBecauseSensitive = diagnosticCausedBySensitive(false)
@@ -512,6 +643,11 @@ func (d diagnosticCausedBySensitive) DiagnosticCausedBySensitive() bool {
 // diagnosticCausedByEphemeral can be assigned to the "Extra" field of a
 // diagnostic to hint to the UI layer that the ephemerality of values in scope
 // is relevant to the diagnostic message.
+//
+// Like diagnosticCausedBySensitive above, this is now a thin wrapper over
+// the single DiagnosticCauseEphemeral bit of DiagnosticCauses, kept for
+// backwards compatibility with existing call sites and UI-layer type
+// assertions.
 type diagnosticCausedByEphemeral bool
 
 var _ tfdiags.DiagnosticExtraBecauseEphemeral = diagnosticCausedByEphemeral(false)
@@ -519,4 +655,4 @@ var _ tfdiags.DiagnosticExtraBecauseEphemeral = diagnosticCausedByEphemeral(fals
 // DiagnosticCausedByEphemeral implements tfdiags.DiagnosticExtraBecauseEphemeral.
 func (d diagnosticCausedByEphemeral) DiagnosticCausedByEphemeral() bool {
 	return bool(d)
-}
\ No newline at end of file
+}
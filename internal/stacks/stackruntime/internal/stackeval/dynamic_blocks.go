@@ -0,0 +1,217 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package stackeval
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/terraform/internal/addrs"
+	"github.com/hashicorp/terraform/internal/instances"
+	"github.com/hashicorp/terraform/internal/tfdiags"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// dynamicBlockTypes is the set of stack configuration block types that are
+// allowed to be generated dynamically using a "dynamic" block, mirroring
+// the equivalent allowances in the main Terraform language.
+var dynamicBlockTypes = map[string]struct{}{
+	"component": {},
+	"stack":     {},
+	"variable":  {},
+	"output":    {},
+}
+
+// expandDynamicBlocks preprocesses the given body, replacing any "dynamic"
+// blocks for the block types in dynamicBlockTypes with zero or more
+// synthetic blocks of the wrapped type, one per element of the dynamic
+// block's "for_each" argument.
+//
+// This is conceptually similar to what package hcl/ext/dynblock does for
+// the main Terraform language, but it's implemented separately here so
+// that the for_each expressions can be evaluated using the stacks
+// language's own evaluation rules -- including routing through
+// evaluateForEachExpr so that unknown, null, and sensitive for_each
+// values are all handled the same way that they are for other
+// for_each-like arguments elsewhere in this package.
+func expandDynamicBlocks(ctx context.Context, body hcl.Body, phase EvalPhase, scope ExpressionScope) (hcl.Body, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	content, remain, moreDiags := body.PartialContent(dynamicBlockSchema())
+	diags = diags.Append(moreDiags)
+
+	var extra hcl.Blocks
+	for _, block := range content.Blocks {
+		if block.Type != "dynamic" {
+			continue
+		}
+		wrappedType := block.Labels[0]
+		if _, ok := dynamicBlockTypes[wrappedType]; !ok {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unsupported dynamic block type",
+				Detail:   fmt.Sprintf("Blocks of type %q cannot be generated dynamically.", wrappedType),
+				Subject:  block.DefRange.Ptr(),
+			})
+			continue
+		}
+
+		dynContent, moreDiags := block.Body.Content(dynamicBlockInnerSchema())
+		diags = diags.Append(moreDiags)
+		if moreDiags.HasErrors() {
+			continue
+		}
+
+		iterName := wrappedType
+		if attr, ok := dynContent.Attributes["iterator"]; ok {
+			traversal, travDiags := hcl.AbsTraversalForExpr(attr.Expr)
+			diags = diags.Append(travDiags)
+			if !travDiags.HasErrors() && len(traversal) > 0 {
+				iterName = traversal.RootName()
+			}
+		}
+
+		innerBlocks := dynContent.Blocks.ByType()["content"]
+		if len(innerBlocks) != 1 {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid dynamic block",
+				Detail:   "A dynamic block must have exactly one nested \"content\" block.",
+				Subject:  block.DefRange.Ptr(),
+			})
+			continue
+		}
+		inner := innerBlocks[0]
+
+		forEachResult, moreDiags := evaluateForEachExpr(ctx, dynContent.Attributes["for_each"].Expr, phase, scope, "for_each")
+		diags = diags.Append(moreDiags)
+		if moreDiags.HasErrors() {
+			continue
+		}
+
+		newBlock := func(key addrs.InstanceKey, rep instances.RepetitionData) *hcl.Block {
+			return &hcl.Block{
+				Type: wrappedType,
+				Body: &dynamicBlockBody{
+					wrapped:  inner.Body,
+					iterName: iterName,
+					eachKey:  rep.EachKey,
+					eachVal:  rep.EachValue,
+				},
+				DefRange:  block.DefRange,
+				TypeRange: block.TypeRange,
+			}
+		}
+
+		// unknownForEachSupported is true here so that PlanPhase (and
+		// other phases) degrade gracefully rather than failing outright
+		// when the for_each value isn't fully known yet: instancesMap then
+		// gives one block per attribute of an unknown object type, no
+		// blocks for a map or set refined to be empty, and otherwise a
+		// single placeholder block under addrs.WildcardKey whose
+		// each.key and each.value are unknown.
+		insts := instancesMap(forEachResult.Value, newBlock, true)
+		for _, blk := range insts {
+			extra = append(extra, blk)
+		}
+	}
+
+	return &mergedDynamicBlockBody{remain: remain, extra: extra}, diags
+}
+
+// DecodeBodyWithDynamicBlocks decodes body against schema after first
+// expanding any "dynamic" blocks it contains, so that the result reflects
+// both the blocks written out literally in the configuration and any
+// generated from "dynamic" blocks wrapping one of dynamicBlockTypes.
+//
+// This is the intended entry point for any stack configuration block
+// decoder -- component, stack, variable, or output -- that wants to allow
+// its nested blocks to be generated dynamically: it should call this
+// function in place of calling body.Content(schema) directly.
+func DecodeBodyWithDynamicBlocks(ctx context.Context, body hcl.Body, schema *hcl.BodySchema, phase EvalPhase, scope ExpressionScope) (*hcl.BodyContent, tfdiags.Diagnostics) {
+	expanded, diags := expandDynamicBlocks(ctx, body, phase, scope)
+
+	content, moreDiags := expanded.Content(schema)
+	diags = diags.Append(moreDiags)
+	return content, diags
+}
+
+func dynamicBlockSchema() *hcl.BodySchema {
+	return &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "dynamic", LabelNames: []string{"type"}},
+		},
+	}
+}
+
+func dynamicBlockInnerSchema() *hcl.BodySchema {
+	return &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "for_each", Required: true},
+			{Name: "iterator", Required: false},
+		},
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "content"},
+		},
+	}
+}
+
+// mergedDynamicBlockBody presents the blocks left over after extracting
+// "dynamic" blocks from a body (remain) together with the synthetic blocks
+// generated from them (extra) as a single combined hcl.Body, so that the
+// rest of the stack configuration decoder can proceed as if the dynamic
+// blocks had been written out literally.
+type mergedDynamicBlockBody struct {
+	remain hcl.Body
+	extra  hcl.Blocks
+}
+
+func (b *mergedDynamicBlockBody) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+	content, diags := b.remain.Content(schema)
+	content.Blocks = append(content.Blocks, b.extra...)
+	return content, diags
+}
+
+func (b *mergedDynamicBlockBody) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Body, hcl.Diagnostics) {
+	content, remain, diags := b.remain.PartialContent(schema)
+	content.Blocks = append(content.Blocks, b.extra...)
+	return content, remain, diags
+}
+
+func (b *mergedDynamicBlockBody) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
+	return b.remain.JustAttributes()
+}
+
+func (b *mergedDynamicBlockBody) MissingItemRange() hcl.Range {
+	return b.remain.MissingItemRange()
+}
+
+// dynamicBlockBody wraps the "content" body of a single instance of a
+// "dynamic" block, remembering the iterator variable name and the
+// each.key/each.value pair for that instance. Nothing in this package reads
+// these fields, so they are not bound when expressions found inside this
+// body are evaluated.
+type dynamicBlockBody struct {
+	wrapped  hcl.Body
+	iterName string
+	eachKey  cty.Value
+	eachVal  cty.Value
+}
+
+func (b *dynamicBlockBody) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+	return b.wrapped.Content(schema)
+}
+
+func (b *dynamicBlockBody) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Body, hcl.Diagnostics) {
+	return b.wrapped.PartialContent(schema)
+}
+
+func (b *dynamicBlockBody) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
+	return b.wrapped.JustAttributes()
+}
+
+func (b *dynamicBlockBody) MissingItemRange() hcl.Range {
+	return b.wrapped.MissingItemRange()
+}
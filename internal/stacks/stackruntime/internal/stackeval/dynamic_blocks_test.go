@@ -0,0 +1,195 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package stackeval
+
+import (
+	"context"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hcltest"
+	"github.com/hashicorp/terraform/internal/tfdiags"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestExpandDynamicBlocks(t *testing.T) {
+	variableSchema := &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "variable"},
+		},
+	}
+
+	tests := map[string]struct {
+		Body       hcl.Body
+		WantBlocks int
+		WantErr    string
+	}{
+		"no dynamic blocks": {
+			Body: hcltest.MockBody(&hcl.BodyContent{
+				Blocks: hcl.Blocks{
+					{Type: "variable"},
+				},
+			}),
+			WantBlocks: 1,
+		},
+		"dynamic variable block expands one instance per for_each element": {
+			Body: hcltest.MockBody(&hcl.BodyContent{
+				Blocks: hcl.Blocks{
+					{
+						Type:   "dynamic",
+						Labels: []string{"variable"},
+						Body: hcltest.MockBody(&hcl.BodyContent{
+							Attributes: hcl.Attributes{
+								"for_each": {
+									Name: "for_each",
+									Expr: hcltest.MockExprLiteral(cty.SetVal([]cty.Value{
+										cty.StringVal("a"),
+										cty.StringVal("b"),
+									})),
+								},
+							},
+							Blocks: hcl.Blocks{
+								{Type: "content"},
+							},
+						}),
+					},
+				},
+			}),
+			WantBlocks: 2,
+		},
+		"dynamic and literal blocks combine": {
+			Body: hcltest.MockBody(&hcl.BodyContent{
+				Blocks: hcl.Blocks{
+					{Type: "variable"},
+					{
+						Type:   "dynamic",
+						Labels: []string{"variable"},
+						Body: hcltest.MockBody(&hcl.BodyContent{
+							Attributes: hcl.Attributes{
+								"for_each": {
+									Name: "for_each",
+									Expr: hcltest.MockExprLiteral(cty.SetVal([]cty.Value{
+										cty.StringVal("a"),
+									})),
+								},
+							},
+							Blocks: hcl.Blocks{
+								{Type: "content"},
+							},
+						}),
+					},
+				},
+			}),
+			WantBlocks: 2,
+		},
+		"unsupported dynamic block type": {
+			Body: hcltest.MockBody(&hcl.BodyContent{
+				Blocks: hcl.Blocks{
+					{
+						Type:   "dynamic",
+						Labels: []string{"bogus"},
+						Body: hcltest.MockBody(&hcl.BodyContent{
+							Attributes: hcl.Attributes{
+								"for_each": {
+									Name: "for_each",
+									Expr: hcltest.MockExprLiteral(cty.SetValEmpty(cty.String)),
+								},
+							},
+							Blocks: hcl.Blocks{
+								{Type: "content"},
+							},
+						}),
+					},
+				},
+			}),
+			WantErr: "Unsupported dynamic block type",
+		},
+	}
+
+	ctx := context.Background()
+	scope := newStaticExpressionScope()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, diags := expandDynamicBlocks(ctx, test.Body, PlanPhase, scope)
+
+			if test.WantErr != "" {
+				if !diags.HasErrors() {
+					t.Fatalf("unexpected success; want error")
+				}
+				foundErr := false
+				for _, diag := range diags {
+					if diag.Severity() == tfdiags.Error && diag.Description().Summary == test.WantErr {
+						foundErr = true
+						break
+					}
+				}
+				if !foundErr {
+					t.Errorf("missing expected error\nwant summary: %s\ngot: %s", test.WantErr, spew.Sdump(diags.ForRPC()))
+				}
+				return
+			}
+
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors\n%s", spew.Sdump(diags.ForRPC()))
+			}
+
+			content, moreDiags := got.Content(variableSchema)
+			if moreDiags.HasErrors() {
+				t.Fatalf("unexpected errors decoding expanded content\n%s", spew.Sdump(moreDiags))
+			}
+			if len(content.Blocks) != test.WantBlocks {
+				t.Errorf("wrong number of blocks\ngot:  %d\nwant: %d", len(content.Blocks), test.WantBlocks)
+			}
+		})
+	}
+}
+
+func TestDecodeBodyWithDynamicBlocks(t *testing.T) {
+	body := hcltest.MockBody(&hcl.BodyContent{
+		Blocks: hcl.Blocks{
+			{
+				Type:   "dynamic",
+				Labels: []string{"variable"},
+				Body: hcltest.MockBody(&hcl.BodyContent{
+					Attributes: hcl.Attributes{
+						"for_each": {
+							Name: "for_each",
+							Expr: hcltest.MockExprLiteral(cty.SetVal([]cty.Value{
+								cty.StringVal("a"),
+								cty.StringVal("b"),
+								cty.StringVal("c"),
+							})),
+						},
+					},
+					Blocks: hcl.Blocks{
+						{Type: "content"},
+					},
+				}),
+			},
+		},
+	})
+	schema := &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "variable"},
+		},
+	}
+
+	ctx := context.Background()
+	scope := newStaticExpressionScope()
+
+	content, diags := DecodeBodyWithDynamicBlocks(ctx, body, schema, PlanPhase, scope)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors\n%s", spew.Sdump(diags.ForRPC()))
+	}
+	if got, want := len(content.Blocks), 3; got != want {
+		t.Errorf("wrong number of blocks\ngot:  %d\nwant: %d", got, want)
+	}
+	for _, block := range content.Blocks {
+		if block.Type != "variable" {
+			t.Errorf("wrong block type %q; want \"variable\"", block.Type)
+		}
+	}
+}
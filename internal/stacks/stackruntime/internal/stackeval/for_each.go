@@ -0,0 +1,305 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package stackeval
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/terraform/internal/addrs"
+	"github.com/hashicorp/terraform/internal/instances"
+	"github.com/hashicorp/terraform/internal/lang/marks"
+	"github.com/hashicorp/terraform/internal/tfdiags"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// forEachEvalResult is the result of evaluating a "for_each" expression,
+// bundling the resulting value together with anything else that callers
+// might need to know about how it was derived.
+type forEachEvalResult struct {
+	Value cty.Value
+}
+
+// evaluateForEachExpr evaluates the given expression in the given scope,
+// under the assumption that it's being used as a "for_each" argument for
+// some repeatable stack configuration block, and then validates that the
+// result is an acceptable for_each value: a set, a map, or an object value,
+// or an unknown value of a type that could eventually converge with one of
+// those.
+//
+// The name argument is used only to generate error messages and should
+// typically be the name of the block attribute that the expression came
+// from, such as "for_each".
+func evaluateForEachExpr(ctx context.Context, expr hcl.Expression, phase EvalPhase, scope ExpressionScope, name string) (forEachEvalResult, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	result, moreDiags := evaluateExpr(ctx, expr, phase, scope)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return forEachEvalResult{Value: cty.DynamicVal}, diags
+	}
+	v := result.Value
+
+	const errSummary = "Invalid for_each value"
+	if marks.Has(v, marks.Sensitive) {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  errSummary,
+			Detail:   fmt.Sprintf("The %s value is sensitive, so Terraform cannot use it to determine which instances to create. Use nonsensitive() to declassify it if that's acceptable for your use-case.", name),
+			Subject:  expr.Range().Ptr(),
+		})
+		return forEachEvalResult{Value: cty.DynamicVal}, diags
+	}
+
+	switch {
+	case v.IsNull():
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  errSummary,
+			Detail:   fmt.Sprintf("The given %q value is null. A %s argument must be a map, or set of strings, and you have provided a value that is null.", name, name),
+			Subject:  expr.Range().Ptr(),
+		})
+		return forEachEvalResult{Value: cty.DynamicVal}, diags
+	case !v.IsKnown():
+		// Unknown values (of an appropriate type) are allowed through
+		// unmodified, to be dealt with by the caller of evaluateForEachExpr
+		// using instancesMap below.
+		ty := v.Type()
+		if ty != cty.DynamicPseudoType && !(ty.IsObjectType() || ty.IsMapType() || ty.IsSetType()) {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  errSummary,
+				Detail:   fmt.Sprintf("The %s value must be a map, or set of strings, and you have provided a value of type %s.", name, ty.FriendlyName()),
+				Subject:  expr.Range().Ptr(),
+			})
+			return forEachEvalResult{Value: cty.DynamicVal}, diags
+		}
+		return forEachEvalResult{Value: v}, diags
+	}
+
+	moreDiags = validateForEachValueType(v, expr.Range(), name, false)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return forEachEvalResult{Value: cty.DynamicVal}, diags
+	}
+	return forEachEvalResult{Value: v}, diags
+}
+
+// evaluateForEachExprForValidation is similar to evaluateForEachExpr, but
+// intended for use during ValidatePhase, where the goal is to give the
+// configuration author as complete a picture as possible of everything
+// that's wrong with their for_each argument in a single pass rather than
+// making them fix one problem, re-run, find the next problem, and so on.
+//
+// Unlike evaluateForEachExpr, this keeps checking for additional problems
+// even after it's already found one -- for example, reporting every null
+// element of a set rather than just the first one it encounters -- and
+// so the returned diagnostics may contain more than one error describing
+// different aspects of the same invalid value.
+func evaluateForEachExprForValidation(ctx context.Context, expr hcl.Expression, scope ExpressionScope, name string) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	result, moreDiags := evaluateExpr(ctx, expr, ValidatePhase, scope)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return diags
+	}
+	v := result.Value
+
+	if marks.Has(v, marks.Sensitive) {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid for_each value",
+			Detail:   fmt.Sprintf("The %s value is sensitive, so Terraform cannot use it to determine which instances to create. Use nonsensitive() to declassify it if that's acceptable for your use-case.", name),
+			Subject:  expr.Range().Ptr(),
+		})
+		// The remaining checks run on the unmarked value, so that they can
+		// still report further problems with it.
+		v, _ = v.Unmark()
+	}
+	if v.IsNull() {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid for_each value",
+			Detail:   fmt.Sprintf("The given %q value is null. A %s argument must be a map, or set of strings, and you have provided a value that is null.", name, name),
+			Subject:  expr.Range().Ptr(),
+		})
+		return diags
+	}
+	if !v.IsKnown() {
+		ty := v.Type()
+		if ty != cty.DynamicPseudoType && !(ty.IsObjectType() || ty.IsMapType() || ty.IsSetType()) {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid for_each value",
+				Detail:   fmt.Sprintf("The %s value must be a map, or set of strings, and you have provided a value of type %s.", name, ty.FriendlyName()),
+				Subject:  expr.Range().Ptr(),
+			})
+		}
+		return diags
+	}
+
+	diags = diags.Append(validateForEachValueType(v, expr.Range(), name, true))
+	return diags
+}
+
+// validateForEachValueType checks that the given known, non-null,
+// non-sensitive value is an acceptable for_each value, appending one
+// diagnostic per distinct offense it finds. When reportAll is false it
+// stops at the first problem, matching the behavior callers outside of
+// ValidatePhase expect; when reportAll is true it keeps going so that
+// evaluateForEachExprForValidation can surface everything at once.
+func validateForEachValueType(v cty.Value, rng hcl.Range, name string, reportAll bool) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	const errSummary = "Invalid for_each value"
+
+	ty := v.Type()
+	switch {
+	case ty.IsObjectType(), ty.IsMapType():
+		// No further constraints beyond being a collection/structural type.
+	case ty.IsSetType():
+		if ty.ElementType() != cty.String {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  errSummary,
+				Detail:   fmt.Sprintf("The %s set must have string elements, and you have provided a set containing type %s.", name, ty.ElementType().FriendlyName()),
+				Subject:  rng.Ptr(),
+			})
+			if !reportAll {
+				return diags
+			}
+		}
+		for it := v.ElementIterator(); it.Next(); {
+			_, ev := it.Element()
+			if ev.IsNull() {
+				diags = diags.Append(&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  errSummary,
+					Detail:   fmt.Sprintf("The %s set must not contain null values.", name),
+					Subject:  rng.Ptr(),
+				})
+				if !reportAll {
+					return diags
+				}
+			}
+		}
+	default:
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  errSummary,
+			Detail:   fmt.Sprintf("The %s value must be a map, or set of strings, and you have provided a value of type %s.", name, ty.FriendlyName()),
+			Subject:  rng.Ptr(),
+		})
+	}
+
+	return diags
+}
+
+func lengthOfValue(v cty.Value) int {
+	n := 0
+	for it := v.ElementIterator(); it.Next(); {
+		n++
+	}
+	return n
+}
+
+// instancesMap takes a valid for_each value, as produced by
+// evaluateForEachExpr, and returns a map describing the instances it
+// implies, each one built from the given makeInst callback.
+//
+// If the given value is unknown then the exact behavior depends on
+// unknownForEachSupported: if false, the result is a nil map, which the
+// caller should treat as a sentinel for "instances not known yet" as
+// opposed to "zero instances" (represented by a non-nil empty map). If
+// true, an unknown object type with at least one attribute gives one
+// instance per attribute name, each with an unknown EachValue; an unknown
+// map or set refined to be non-null and empty gives an empty map; any
+// other unknown value gives a single-element map using addrs.WildcardKey
+// to represent a placeholder instance standing in for all of the
+// instances we can't yet predict.
+func instancesMap[Instance any](forEachVal cty.Value, makeInst func(addrs.InstanceKey, instances.RepetitionData) Instance, unknownForEachSupported bool) map[addrs.InstanceKey]Instance {
+	if forEachVal == cty.NilVal {
+		// No for_each at all means there's a single instance with no key.
+		return map[addrs.InstanceKey]Instance{
+			addrs.NoKey: makeInst(addrs.NoKey, instances.RepetitionData{}),
+		}
+	}
+
+	ty := forEachVal.Type()
+
+	if !forEachVal.IsKnown() {
+		if !unknownForEachSupported {
+			return nil
+		}
+
+		// An unknown object-typed value still tells us its complete set of
+		// attribute names from its type alone, even though we don't know
+		// any of the values yet. That's enough for us to return one
+		// instance per known key, each with an unknown EachValue, instead
+		// of collapsing the whole for_each down to a single wildcard
+		// instance.
+		if ty.IsObjectType() {
+			atys := ty.AttributeTypes()
+			if len(atys) > 0 {
+				ret := make(map[addrs.InstanceKey]Instance, len(atys))
+				for name, aty := range atys {
+					key := addrs.StringKey(name)
+					ret[key] = makeInst(key, instances.RepetitionData{
+						EachKey:   cty.StringVal(name),
+						EachValue: cty.UnknownVal(aty),
+					})
+				}
+				return ret
+			}
+		}
+
+		// Some unknown collection-typed values carry a refinement that
+		// pins down their exact length even though their elements are
+		// still unknown. A refined-to-empty collection behaves the same
+		// as a known-empty one: zero instances, not "unknown how many".
+		if (ty.IsMapType() || ty.IsSetType()) && forEachVal.Range().DefinitelyNotNull() {
+			if lo, hi := forEachVal.Range().LengthLowerBound(), forEachVal.Range().LengthUpperBound(); lo == hi && lo == 0 {
+				return map[addrs.InstanceKey]Instance{}
+			}
+		}
+
+		elemType := cty.DynamicPseudoType
+		switch {
+		case ty.IsMapType(), ty.IsSetType():
+			elemType = ty.ElementType()
+		case ty.IsObjectType():
+			elemType = cty.DynamicPseudoType
+		}
+		return map[addrs.InstanceKey]Instance{
+			addrs.WildcardKey: makeInst(addrs.WildcardKey, instances.RepetitionData{
+				EachKey:   cty.UnknownVal(cty.String),
+				EachValue: cty.UnknownVal(elemType),
+			}),
+		}
+	}
+
+	ret := make(map[addrs.InstanceKey]Instance)
+	switch {
+	case ty.IsObjectType(), ty.IsMapType():
+		for it := forEachVal.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			key := addrs.StringKey(k.AsString())
+			ret[key] = makeInst(key, instances.RepetitionData{
+				EachKey:   k,
+				EachValue: v,
+			})
+		}
+	case ty.IsSetType():
+		for it := forEachVal.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			key := addrs.StringKey(v.AsString())
+			ret[key] = makeInst(key, instances.RepetitionData{
+				EachKey:   v,
+				EachValue: v,
+			})
+		}
+	}
+	return ret
+}
@@ -275,6 +275,32 @@ func TestInstancesMap(t *testing.T) {
 				UnknownForEachUnsupported: nil, // a nil map means "unknown" for this function
 			},
 		},
+		{
+			"unknown object with known attribute names",
+			cty.UnknownVal(cty.Object(map[string]cty.Type{
+				"a": cty.String,
+				"b": cty.Bool,
+			})),
+			Expectation{
+				UnknownForEachSupported: map[addrs.InstanceKey]InstanceObj{
+					addrs.StringKey("a"): {
+						Key: addrs.StringKey("a"),
+						Rep: instances.RepetitionData{
+							EachKey:   cty.StringVal("a"),
+							EachValue: cty.UnknownVal(cty.String),
+						},
+					},
+					addrs.StringKey("b"): {
+						Key: addrs.StringKey("b"),
+						Rep: instances.RepetitionData{
+							EachKey:   cty.StringVal("b"),
+							EachValue: cty.UnknownVal(cty.Bool),
+						},
+					},
+				},
+				UnknownForEachUnsupported: nil, // a nil map means "unknown" for this function
+			},
+		},
 		{
 			"unknown set of strings",
 			cty.UnknownVal(cty.Set(cty.String)),
@@ -0,0 +1,176 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package stackeval
+
+import (
+	"github.com/hashicorp/terraform/internal/promising"
+)
+
+// namedPromiseEdgeReporter is an optional extension of namedPromiseReporter
+// for types that can also describe the dependency edges between the
+// promises they're responsible for -- that is, "promise X depends on
+// promise Y" -- rather than just giving each promise a name.
+//
+// This is kept as a separate interface from namedPromiseReporter, rather
+// than adding a new required method to it, so that existing implementations
+// throughout this package don't all need to be updated at once; callers
+// that want edge information should type-assert for this interface and
+// fall back to the name-only behavior when it isn't implemented.
+type namedPromiseEdgeReporter interface {
+	namedPromiseReporter
+
+	// reportNamedPromiseEdges calls the given callback once for each
+	// known dependency edge between two promises that this object (or
+	// anything nested within it) is responsible for, in the direction
+	// "from depends on to".
+	reportNamedPromiseEdges(func(from, to promising.PromiseID))
+}
+
+// collectPromiseEdges walks r (and, transitively, anything nested within
+// it that also implements namedPromiseEdgeReporter) to build an adjacency
+// list of known promise dependency edges. If r does not implement
+// namedPromiseEdgeReporter then the result is an empty map, and callers
+// should treat that the same as "no edge information available".
+func collectPromiseEdges(r namedPromiseReporter) map[promising.PromiseID][]promising.PromiseID {
+	edges := make(map[promising.PromiseID][]promising.PromiseID)
+	if er, ok := r.(namedPromiseEdgeReporter); ok {
+		er.reportNamedPromiseEdges(func(from, to promising.PromiseID) {
+			edges[from] = append(edges[from], to)
+		})
+	}
+	return edges
+}
+
+// promiseSCCs runs Tarjan's strongly-connected-components algorithm over
+// the graph induced by edges, restricted to only the promises listed in
+// ids, and returns the non-trivial components -- that is, components with
+// more than one promise, or a single promise with a direct self-loop.
+//
+// The components are returned in the order Tarjan's algorithm discovers
+// them, which is not itself meaningful, but is at least deterministic for
+// a given edges map.
+func promiseSCCs(ids []promising.PromiseID, edges map[promising.PromiseID][]promising.PromiseID) [][]promising.PromiseID {
+	inScope := make(map[promising.PromiseID]struct{}, len(ids))
+	for _, id := range ids {
+		inScope[id] = struct{}{}
+	}
+
+	type nodeState struct {
+		index   int
+		lowlink int
+		onStack bool
+	}
+	states := make(map[promising.PromiseID]*nodeState)
+	var stack []promising.PromiseID
+	index := 0
+	var sccs [][]promising.PromiseID
+
+	var strongconnect func(v promising.PromiseID)
+	strongconnect = func(v promising.PromiseID) {
+		states[v] = &nodeState{index: index, lowlink: index, onStack: true}
+		index++
+		stack = append(stack, v)
+
+		for _, w := range edges[v] {
+			if _, ok := inScope[w]; !ok {
+				continue // edges leaving the set of IDs we care about don't matter here
+			}
+			ws, visited := states[w]
+			if !visited {
+				strongconnect(w)
+				ws = states[w]
+				if ws.lowlink < states[v].lowlink {
+					states[v].lowlink = ws.lowlink
+				}
+			} else if ws.onStack {
+				if ws.index < states[v].lowlink {
+					states[v].lowlink = ws.index
+				}
+			}
+		}
+
+		if states[v].lowlink == states[v].index {
+			var scc []promising.PromiseID
+			for {
+				n := len(stack) - 1
+				w := stack[n]
+				stack = stack[:n]
+				states[w].onStack = false
+				scc = append(scc, w)
+				if w == v {
+					break
+				}
+			}
+			if len(scc) > 1 || hasSelfLoop(scc[0], edges) {
+				sccs = append(sccs, scc)
+			}
+		}
+	}
+
+	for _, id := range ids {
+		if _, visited := states[id]; !visited {
+			strongconnect(id)
+		}
+	}
+
+	return sccs
+}
+
+func hasSelfLoop(id promising.PromiseID, edges map[promising.PromiseID][]promising.PromiseID) bool {
+	for _, to := range edges[id] {
+		if to == id {
+			return true
+		}
+	}
+	return false
+}
+
+// orderPromiseCycle attempts to arrange the promises in scc into an actual
+// cycle order -- id[0] depends on id[1] depends on id[2] ... depends on
+// id[0] -- by following the edges map. At each step it takes the first
+// edge to a not-yet-visited member of scc, without backtracking. If that
+// walk doesn't pass through every member of scc and close back to the
+// start (as can happen for an SCC that isn't one simple cycle, or if edge
+// reporting is incomplete) this returns ok=false and callers should fall
+// back to an unordered rendering.
+func orderPromiseCycle(scc []promising.PromiseID, edges map[promising.PromiseID][]promising.PromiseID) (ordered []promising.PromiseID, ok bool) {
+	if len(scc) == 0 {
+		return nil, false
+	}
+	inScc := make(map[promising.PromiseID]struct{}, len(scc))
+	for _, id := range scc {
+		inScc[id] = struct{}{}
+	}
+
+	start := scc[0]
+	ordered = []promising.PromiseID{start}
+	visited := map[promising.PromiseID]struct{}{start: {}}
+	current := start
+	for len(ordered) < len(scc) {
+		next, found := promising.NoPromise, false
+		for _, to := range edges[current] {
+			if _, inSet := inScc[to]; !inSet {
+				continue
+			}
+			if _, seen := visited[to]; seen {
+				continue
+			}
+			next, found = to, true
+			break
+		}
+		if !found {
+			return nil, false
+		}
+		ordered = append(ordered, next)
+		visited[next] = struct{}{}
+		current = next
+	}
+	// Confirm the cycle closes back to the start.
+	for _, to := range edges[current] {
+		if to == start {
+			return ordered, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,163 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package stackeval
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+
+	"github.com/hashicorp/terraform/internal/promising"
+	"github.com/hashicorp/terraform/internal/tfdiags"
+)
+
+// ResultCacheKey is a stable, content-derived identifier for a single
+// doOnceWithDiags call site's logical inputs -- typically a hash of the
+// relevant configuration bytes, any referenced prior state, and the hashes
+// of whatever upstream results this evaluation depends on. It's the
+// caller's responsibility to construct a key that changes whenever the
+// inputs that could affect the result change.
+type ResultCacheKey string
+
+// ResultCache is a pluggable cache consulted by doOnceWithDiagsCached before
+// it invokes the function that actually performs an evaluation step.
+// doOnceWithDiags itself does not consult a cache, and nothing in this
+// package calls doOnceWithDiagsCached.
+//
+// Implementations must be safe for concurrent use, since
+// doOnceWithDiagsCached can be called concurrently from many goroutines as independent parts of
+// a stack configuration are evaluated in parallel.
+type ResultCache interface {
+	// Get retrieves a previously-cached result for the given key, if any.
+	// The ok return value is false if there was no cached result, or if
+	// the cache chose not to serve one (for example, because it failed to
+	// deserialize).
+	Get(ctx context.Context, key ResultCacheKey) (cachedResult, ok bool)
+
+	// Put stores a result for later retrieval by Get. Implementations
+	// may silently discard results they don't want to keep (for example,
+	// due to size limits), since this is purely a performance
+	// optimization and never required for correctness.
+	Put(ctx context.Context, key ResultCacheKey, result cachedResult)
+}
+
+// cachedResult is the serializable envelope a ResultCache stores and
+// retrieves: a gob-encoded value together with its diagnostics.
+type cachedResult struct {
+	// ValueGob is the gob encoding of the result value produced by the
+	// wrapped function, so that ResultCache implementations can treat it
+	// opaquely without needing to know the concrete T of whatever
+	// doOnceWithDiags call produced it.
+	ValueGob []byte
+
+	// Diagnostics is a serializable snapshot of the diagnostics that
+	// accompanied ValueGob.
+	Diagnostics []diagnosticGobEnvelope
+}
+
+// encodeCachedResult gob-encodes a (T, tfdiags.Diagnostics) pair into a
+// cachedResult suitable for storage in a ResultCache. If T can't be
+// gob-encoded (for example, because it contains an interface value with no
+// registered concrete type) this returns ok=false and the caller should
+// simply skip writing to the cache rather than failing the evaluation.
+func encodeCachedResult[T any](value T, diags tfdiags.Diagnostics) (cachedResult, bool) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return cachedResult{}, false
+	}
+
+	envelopes := make([]diagnosticGobEnvelope, 0, len(diags))
+	for _, diag := range diags {
+		env, ok := newDiagnosticGobEnvelope(diag)
+		if !ok {
+			return cachedResult{}, false
+		}
+		envelopes = append(envelopes, env)
+	}
+
+	return cachedResult{
+		ValueGob:    buf.Bytes(),
+		Diagnostics: envelopes,
+	}, true
+}
+
+// decodeCachedResult is the inverse of encodeCachedResult.
+func decodeCachedResult[T any](cached cachedResult) (T, tfdiags.Diagnostics, bool) {
+	var value T
+	if err := gob.NewDecoder(bytes.NewReader(cached.ValueGob)).Decode(&value); err != nil {
+		var zero T
+		return zero, nil, false
+	}
+
+	var diags tfdiags.Diagnostics
+	for _, env := range cached.Diagnostics {
+		diags = diags.Append(env.Diagnostic())
+	}
+	return value, diags, true
+}
+
+type resultCacheContextKey struct{}
+
+// noopResultCache is installed implicitly whenever no cache has been
+// attached to the context, preserving today's behavior of always running
+// f in doOnceWithDiags.
+type noopResultCache struct{}
+
+func (noopResultCache) Get(ctx context.Context, key ResultCacheKey) (cachedResult, bool) {
+	return cachedResult{}, false
+}
+
+func (noopResultCache) Put(ctx context.Context, key ResultCacheKey, result cachedResult) {}
+
+// ContextWithResultCache returns a child of ctx with the given ResultCache
+// attached, for use by doOnceWithDiagsCached. Passing a nil cache is
+// equivalent to not calling this function at all.
+func ContextWithResultCache(ctx context.Context, cache ResultCache) context.Context {
+	if cache == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, resultCacheContextKey{}, cache)
+}
+
+func resultCacheFromContext(ctx context.Context) ResultCache {
+	if cache, ok := ctx.Value(resultCacheContextKey{}).(ResultCache); ok {
+		return cache
+	}
+	return noopResultCache{}
+}
+
+// doOnceWithDiagsCached is a variant of doOnceWithDiags that first
+// consults whatever ResultCache is attached to ctx (via
+// ContextWithResultCache), keyed by cacheKey. On a hit, the cached result
+// is returned -- replaying its diagnostics through trackDiagnostic so that
+// an installed TrackDiagnosticHook still gets a chance to observe them --
+// without invoking f or even touching once. On a miss, it delegates to
+// doOnceWithDiags as normal and then writes the result back to the cache
+// for next time.
+//
+// Callers that don't have a stable cache key for a particular call site
+// should just use doOnceWithDiags directly; the cache defaults to a no-op
+// so there's no harm in leaving some call sites uncached.
+func doOnceWithDiagsCached[T any](
+	ctx context.Context,
+	cacheKey ResultCacheKey,
+	once *promising.Once[withDiagnostics[T]],
+	root namedPromiseReporter,
+	f func(ctx context.Context) (T, tfdiags.Diagnostics),
+) (T, tfdiags.Diagnostics) {
+	cache := resultCacheFromContext(ctx)
+	if cached, ok := cache.Get(ctx, cacheKey); ok {
+		if value, diags, ok := decodeCachedResult[T](cached); ok {
+			var tracked tfdiags.Diagnostics
+			tracked = trackDiagnostics(tracked, diags)
+			return value, tracked
+		}
+	}
+
+	value, diags := doOnceWithDiags(ctx, once, root, f)
+	if cached, ok := encodeCachedResult(value, diags); ok {
+		cache.Put(ctx, cacheKey, cached)
+	}
+	return value, diags
+}
@@ -24,7 +24,23 @@ import (
 //
 // This is the primary way to load a "prior state" provided by a caller
 // into memory so we can use it in the stack runtime.
-func LoadFromProto(msgs map[string]*anypb.Any) (*State, error) {
+//
+// Each recognized message's unknown proto fields -- those belonging to a
+// newer version of the message than this Terraform knows about -- are
+// handled per an [UnknownFieldPolicy], which defaults per tracking key to
+// [defaultUnknownFieldPolicyFor] but can be overridden uniformly with
+// [WithUnknownFieldPolicy]. Under the default, preserving policy, the raw
+// bytes are stashed as RawUnknownFields on the in-memory object so that
+// SaveToProto can splice them back into the outgoing message unchanged.
+//
+// Before handing a decoded message off to its type-specific handler,
+// handleProtoMsg also walks it through any [Migrator]s registered against
+// its tracking key's type, so a message written by an older tfstackdataN
+// version is transparently upgraded to the newest version this build
+// understands. See [RegisterMigrator].
+func LoadFromProto(msgs map[string]*anypb.Any, opts ...LoadFromProtoOption) (*State, error) {
+	cfg := resolveLoadFromProtoConfig(opts)
+
 	ret := NewState()
 	ret.inputRaw = msgs
 	for rawKey, rawMsg := range msgs {
@@ -60,7 +76,7 @@ func LoadFromProto(msgs map[string]*anypb.Any) (*State, error) {
 			return nil, fmt.Errorf("invalid raw value for raw state key %q: %w", rawKey, err)
 		}
 
-		err = handleProtoMsg(key, msg, ret)
+		err = handleProtoMsg(key, msg, ret, cfg.unknownFieldPolicyFor(key))
 		if err != nil {
 			return nil, err
 		}
@@ -103,7 +119,7 @@ func LoadFromDirectProto(msgs map[string]protoreflect.ProtoMessage) (*State, err
 			}
 			continue
 		}
-		err = handleProtoMsg(key, msg, ret)
+		err = handleProtoMsg(key, msg, ret, defaultUnknownFieldPolicyFor(key))
 		if err != nil {
 			return nil, err
 		}
@@ -158,14 +174,19 @@ func handleUnrecognizedKey(key statekeys.Key, state *State) error {
 	}
 }
 
-func handleProtoMsg(key statekeys.Key, msg protoreflect.ProtoMessage, state *State) error {
+func handleProtoMsg(key statekeys.Key, msg protoreflect.ProtoMessage, state *State, policy UnknownFieldPolicy) error {
+	msg, err := migrateToCurrent(key, msg)
+	if err != nil {
+		return fmt.Errorf("state entry %q: %w", statekeys.String(key), err)
+	}
+
 	switch key := key.(type) {
 
 	case statekeys.ComponentInstance:
-		return handleComponentInstanceMsg(key, msg, state)
+		return handleComponentInstanceMsg(key, msg, state, policy)
 
 	case statekeys.ResourceInstanceObject:
-		return handleResourceInstanceObjectMsg(key, msg, state)
+		return handleResourceInstanceObjectMsg(key, msg, state, policy)
 
 	default:
 		// Should not get here: the above should be exhaustive for all
@@ -174,7 +195,7 @@ func handleProtoMsg(key statekeys.Key, msg protoreflect.ProtoMessage, state *Sta
 	}
 }
 
-func handleComponentInstanceMsg(key statekeys.ComponentInstance, msg protoreflect.ProtoMessage, state *State) error {
+func handleComponentInstanceMsg(key statekeys.ComponentInstance, msg protoreflect.ProtoMessage, state *State, policy UnknownFieldPolicy) error {
 	// For this particular object type all of the information is in the key,
 	// for now at least.
 	_, ok := msg.(*tfstackdata1.StateComponentInstanceV1)
@@ -182,11 +203,16 @@ func handleComponentInstanceMsg(key statekeys.ComponentInstance, msg protoreflec
 		return fmt.Errorf("unsupported message type %T for %s state", msg, key.ComponentInstanceAddr)
 	}
 
-	state.ensureComponentInstanceState(key.ComponentInstanceAddr)
+	rawUnknown, disallowed := applyUnknownFieldPolicy(msg, policy, func() { state.discardUnsupportedKeys.Add(key) })
+	if disallowed {
+		return fmt.Errorf("state entry %q has fields this version of Terraform doesn't recognize", statekeys.String(key))
+	}
+
+	state.ensureComponentInstanceState(key.ComponentInstanceAddr).RawUnknownFields = rawUnknown
 	return nil
 }
 
-func handleResourceInstanceObjectMsg(key statekeys.ResourceInstanceObject, msg protoreflect.ProtoMessage, state *State) error {
+func handleResourceInstanceObjectMsg(key statekeys.ResourceInstanceObject, msg protoreflect.ProtoMessage, state *State, policy UnknownFieldPolicy) error {
 	fullAddr := stackaddrs.AbsResourceInstanceObject{
 		Component: key.ResourceInstance.Component,
 		Item: addrs.AbsResourceInstanceObject{
@@ -200,6 +226,11 @@ func handleResourceInstanceObjectMsg(key statekeys.ResourceInstanceObject, msg p
 		return fmt.Errorf("unsupported message type %T for state of %s", msg, fullAddr.String())
 	}
 
+	rawUnknown, disallowed := applyUnknownFieldPolicy(msg, policy, func() { state.discardUnsupportedKeys.Add(key) })
+	if disallowed {
+		return fmt.Errorf("state entry %q has fields this version of Terraform doesn't recognize", statekeys.String(key))
+	}
+
 	objSrc, err := DecodeProtoResourceInstanceObject(riMsg)
 	if err != nil {
 		return fmt.Errorf("invalid stored state object for %s: %w", fullAddr, err)
@@ -210,7 +241,7 @@ func handleResourceInstanceObjectMsg(key statekeys.ResourceInstanceObject, msg p
 		return fmt.Errorf("provider configuration reference %q for %s", riMsg.ProviderConfigAddr, fullAddr)
 	}
 
-	state.addResourceInstanceObject(fullAddr, objSrc, providerConfigAddr)
+	state.addResourceInstanceObject(fullAddr, objSrc, providerConfigAddr).RawUnknownFields = rawUnknown
 	return nil
 }
 
@@ -241,6 +272,22 @@ func DecodeProtoResourceInstanceObject(protoObj *tfstackdata1.StateResourceInsta
 	}
 	objSrc.AttrSensitivePaths = paths
 
+	// ImportIgnoredPaths carries attribute paths the import that produced
+	// this object found diverging from config, so the next plan can
+	// suppress drift on them the same way a written-out
+	// lifecycle.ignore_changes would. Unlike AttrSensitivePaths this isn't
+	// a permanent property of the object: the planner clears a path once a
+	// plan observes no further drift on it.
+	importIgnoredPaths := make([]cty.Path, 0, len(protoObj.ImportIgnoredPaths))
+	for _, p := range protoObj.ImportIgnoredPaths {
+		path, err := planfile.PathFromProto(p)
+		if err != nil {
+			return nil, err
+		}
+		importIgnoredPaths = append(importIgnoredPaths, path)
+	}
+	objSrc.ImportIgnoredPaths = importIgnoredPaths
+
 	if len(protoObj.Dependencies) != 0 {
 		objSrc.Dependencies = make([]addrs.ConfigResource, len(protoObj.Dependencies))
 		for i, raw := range protoObj.Dependencies {
@@ -0,0 +1,182 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package stackstate
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/hashicorp/terraform/internal/stacks/stackstate/statekeys"
+)
+
+// Migrator upgrades a single decoded state message, produced by some older
+// tfstackdataN package, to the next message version Terraform understands.
+// A chain of Migrators -- v1->v2, v2->v3, and so on -- lets handleProtoMsg
+// accept a message written by any version a caller's writer once used,
+// without every call site needing to branch on which concrete
+// tfstackdataN type it got back from [anypb.UnmarshalNew].
+type Migrator interface {
+	// Migrate upgrades msg to its immediate successor version. The chain
+	// walk looks up the returned message's descriptor full name among the
+	// registered from-versions to tell whether another step is needed.
+	Migrate(msg protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error)
+}
+
+// MigratorFunc adapts a plain function to the Migrator interface.
+type MigratorFunc func(msg protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error)
+
+// Migrate implements Migrator.
+func (f MigratorFunc) Migrate(msg protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+	return f(msg)
+}
+
+// migrationChain is keyed by the full name of the message version a
+// Migrator upgrades *from*, so that upgrading repeatedly applies the next
+// registered step until no further migrator is registered for whatever
+// version is current.
+type migrationChain map[protoreflect.FullName]Migrator
+
+// maxMigrationSteps bounds how many times upgrade will follow the chain
+// before giving up, so that a registry with an accidental cycle (A
+// upgrades to B, B upgrades back to A) fails loudly instead of looping
+// forever.
+const maxMigrationSteps = 64
+
+// upgrade repeatedly applies registered migrators to msg until either no
+// migrator is registered for its current version (meaning msg is already
+// current) or maxMigrationSteps is exceeded.
+func (c migrationChain) upgrade(msg protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+	for i := 0; i < maxMigrationSteps; i++ {
+		name := msg.ProtoReflect().Descriptor().FullName()
+		migrator, ok := c[name]
+		if !ok {
+			return msg, nil
+		}
+
+		upgraded, err := migrator.Migrate(msg)
+		if err != nil {
+			return nil, fmt.Errorf("upgrading %s: %w", name, err)
+		}
+		msg = upgraded
+	}
+	return nil, fmt.Errorf("state message did not converge to a current version after %d upgrade steps; check for a cycle in registered migrators", maxMigrationSteps)
+}
+
+// migratorRegistry holds every Migrator registered with RegisterMigrator,
+// keyed first by the tracking key type the message belongs to and then, via
+// migrationChain, by the specific version it upgrades from.
+//
+// A tfstackdataN package (tfstackdata2 and onward) registers its upgraders
+// from older versions in its own init(), so this package never needs to
+// import any version newer than the one it was built to understand.
+var migratorRegistry = map[statekeys.KeyType]migrationChain{}
+
+// RegisterMigrator registers m to upgrade messages of version from to the
+// next version, for tracking keys of type keyType. Call this from the
+// init() of whichever tfstackdataN package introduces the newer message
+// version, passing the *older* message's descriptor full name as from.
+func RegisterMigrator(keyType statekeys.KeyType, from protoreflect.FullName, m Migrator) {
+	chain, ok := migratorRegistry[keyType]
+	if !ok {
+		chain = migrationChain{}
+		migratorRegistry[keyType] = chain
+	}
+	chain[from] = m
+}
+
+// migrateToCurrent upgrades msg, which was decoded for the given key, to
+// the newest version this build of Terraform has a migrator chain for. A
+// key type with no registered migrators (the common case today, with only
+// one tfstackdata version in existence) leaves msg untouched.
+func migrateToCurrent(key statekeys.Key, msg protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+	chain, ok := migratorRegistry[key.KeyType()]
+	if !ok {
+		return msg, nil
+	}
+	return chain.upgrade(msg)
+}
+
+// Downgrader converts a current-version message back to an older wire
+// format, for SaveToProto callers that pinned an older [StateFormatVersion]
+// -- for example, a Terraform Cloud run pinned to an older agent that
+// hasn't yet learned to read the newest tfstackdataN version.
+type Downgrader interface {
+	// Downgrade converts msg to its immediate predecessor version.
+	Downgrade(msg protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error)
+}
+
+// DowngraderFunc adapts a plain function to the Downgrader interface.
+type DowngraderFunc func(msg protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error)
+
+// Downgrade implements Downgrader.
+func (f DowngraderFunc) Downgrade(msg protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+	return f(msg)
+}
+
+// downgraderRegistry is the mirror image of migratorRegistry: keyed by the
+// version a Downgrader converts *from*, so that downgrading repeatedly
+// steps backwards from whatever the current in-memory version is.
+var downgraderRegistry = map[statekeys.KeyType]map[protoreflect.FullName]Downgrader{}
+
+// RegisterDowngrader registers d to convert messages of version from down
+// to its predecessor, for tracking keys of type keyType.
+func RegisterDowngrader(keyType statekeys.KeyType, from protoreflect.FullName, d Downgrader) {
+	chain, ok := downgraderRegistry[keyType]
+	if !ok {
+		chain = map[protoreflect.FullName]Downgrader{}
+		downgraderRegistry[keyType] = chain
+	}
+	chain[from] = d
+}
+
+// downgradeTo steps msg backwards through the Downgraders registered for
+// keyType until its descriptor full name matches target, or no further
+// downgrader is registered for its current version.
+func downgradeTo(keyType statekeys.KeyType, msg protoreflect.ProtoMessage, target protoreflect.FullName) (protoreflect.ProtoMessage, error) {
+	return downgradeToChain(downgraderRegistry[keyType], msg, target)
+}
+
+// downgradeToChain is the chain-walking half of downgradeTo, split out so
+// tests can exercise it against a fake chain without going through the
+// package-global registry.
+func downgradeToChain(chain map[protoreflect.FullName]Downgrader, msg protoreflect.ProtoMessage, target protoreflect.FullName) (protoreflect.ProtoMessage, error) {
+	for i := 0; i < maxMigrationSteps; i++ {
+		name := msg.ProtoReflect().Descriptor().FullName()
+		if name == target {
+			return msg, nil
+		}
+		downgrader, ok := chain[name]
+		if !ok {
+			return nil, fmt.Errorf("no registered downgrader from %s to %s", name, target)
+		}
+		downgraded, err := downgrader.Downgrade(msg)
+		if err != nil {
+			return nil, fmt.Errorf("downgrading %s: %w", name, err)
+		}
+		msg = downgraded
+	}
+	return nil, fmt.Errorf("state message did not converge to %s after %d downgrade steps; check for a cycle in registered downgraders", target, maxMigrationSteps)
+}
+
+// StateFormatVersion pins the wire-format version LoadFromProto expects to
+// decode, or the version SaveToProto should downgrade to before encoding.
+// Its zero value means "the current version", the common case: only a
+// caller that needs to interoperate with an older Terraform (for example,
+// one writing a run's state back to a pinned-older TFC agent) sets this
+// explicitly.
+type StateFormatVersion struct {
+	// ComponentInstance and ResourceInstanceObject name the message
+	// version to target for each tracking key type that has more than one
+	// registered version. A zero value for a given key type means "the
+	// newest version this build knows about".
+	ComponentInstance      protoreflect.FullName
+	ResourceInstanceObject protoreflect.FullName
+}
+
+// IsCurrent reports whether v requests the newest version of every message
+// type, i.e. no downgrade is necessary.
+func (v StateFormatVersion) IsCurrent() bool {
+	return v.ComponentInstance == "" && v.ResourceInstanceObject == ""
+}
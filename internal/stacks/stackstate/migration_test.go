@@ -0,0 +1,189 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package stackstate
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// These tests stand in for a real tfstackdata2 package, which doesn't exist
+// yet: there's only ever been one StateResourceInstanceObject version in
+// this codebase so far. Rather than hand-writing a second generated proto
+// package just to prove the migration mechanism, we press two real,
+// already-vendored well-known types into service as a fake "v1" and "v2":
+// [anypb.Any] (already used elsewhere in this package's tests as a stand-in
+// message) and [wrapperspb.StringValue]. The migrator below "upgrades" by
+// copying the v1 message's TypeUrl into the v2 message's Value, which is
+// enough to prove that upgrade() walks a chain and stops once it reaches a
+// version with no further registered migrator.
+
+// fakeV1FullName and fakeV2FullName are the descriptor full names upgrade
+// dispatches on, standing in for e.g.
+// "terraform.stacks.tfstackdata1.StateResourceInstanceObjectV1" and its
+// "...tfstackdata2..." successor.
+var (
+	fakeV1FullName = (&anypb.Any{}).ProtoReflect().Descriptor().FullName()
+	fakeV2FullName = (&wrapperspb.StringValue{}).ProtoReflect().Descriptor().FullName()
+)
+
+func fakeV1ToV2Migrator() Migrator {
+	return MigratorFunc(func(msg protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+		v1, ok := msg.(*anypb.Any)
+		if !ok {
+			return nil, fmt.Errorf("expected %s, got %T", fakeV1FullName, msg)
+		}
+		return wrapperspb.String(v1.GetTypeUrl()), nil
+	})
+}
+
+func TestMigrationChain_upgrade(t *testing.T) {
+	chain := migrationChain{
+		fakeV1FullName: fakeV1ToV2Migrator(),
+	}
+
+	got, err := chain.upgrade(&anypb.Any{TypeUrl: "v1-payload"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	v2, ok := got.(*wrapperspb.StringValue)
+	if !ok {
+		t.Fatalf("wrong result type %T", got)
+	}
+	if got, want := v2.GetValue(), "v1-payload"; got != want {
+		t.Errorf("wrong upgraded value: got %q, want %q", got, want)
+	}
+	if got := v2.ProtoReflect().Descriptor().FullName(); got != fakeV2FullName {
+		t.Errorf("wrong upgraded version: got %s, want %s", got, fakeV2FullName)
+	}
+}
+
+func TestMigrationChain_upgrade_alreadyCurrent(t *testing.T) {
+	chain := migrationChain{
+		fakeV1FullName: fakeV1ToV2Migrator(),
+	}
+
+	current := wrapperspb.String("already v2")
+	got, err := chain.upgrade(current)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != protoreflect.ProtoMessage(current) {
+		t.Error("expected upgrade to return the same message unchanged when no migrator is registered for its version")
+	}
+}
+
+func TestMigrationChain_upgrade_chained(t *testing.T) {
+	// A v2->v3 step chained onto the v1->v2 one above proves upgrade()
+	// walks more than one hop: v1 input should emerge as v3 output.
+	fakeV3FullName := (&wrapperspb.Int64Value{}).ProtoReflect().Descriptor().FullName()
+	chain := migrationChain{
+		fakeV1FullName: fakeV1ToV2Migrator(),
+		fakeV2FullName: MigratorFunc(func(msg protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+			v2, ok := msg.(*wrapperspb.StringValue)
+			if !ok {
+				return nil, fmt.Errorf("expected %s, got %T", fakeV2FullName, msg)
+			}
+			return wrapperspb.Int64(int64(len(v2.GetValue()))), nil
+		}),
+	}
+
+	got, err := chain.upgrade(&anypb.Any{TypeUrl: "abcde"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	v3, ok := got.(*wrapperspb.Int64Value)
+	if !ok {
+		t.Fatalf("wrong result type %T", got)
+	}
+	if got, want := v3.GetValue(), int64(5); got != want {
+		t.Errorf("wrong upgraded value: got %d, want %d", got, want)
+	}
+	if got := v3.ProtoReflect().Descriptor().FullName(); got != fakeV3FullName {
+		t.Errorf("wrong upgraded version: got %s, want %s", got, fakeV3FullName)
+	}
+}
+
+func TestMigrationChain_upgrade_cycle(t *testing.T) {
+	chain := migrationChain{
+		fakeV1FullName: MigratorFunc(func(msg protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+			return wrapperspb.String("loops back to v1"), nil
+		}),
+		fakeV2FullName: MigratorFunc(func(protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+			return &anypb.Any{}, nil
+		}),
+	}
+
+	if _, err := chain.upgrade(&anypb.Any{}); err == nil {
+		t.Fatal("expected an error for a cyclic migration chain")
+	}
+}
+
+func TestMigrationChain_upgrade_migratorError(t *testing.T) {
+	chain := migrationChain{
+		fakeV1FullName: MigratorFunc(func(protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+			return nil, fmt.Errorf("boom")
+		}),
+	}
+
+	if _, err := chain.upgrade(&anypb.Any{}); err == nil {
+		t.Fatal("expected the migrator's error to propagate")
+	}
+}
+
+func TestDowngradeTo(t *testing.T) {
+	downgraders := map[protoreflect.FullName]Downgrader{
+		fakeV2FullName: DowngraderFunc(func(msg protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+			v2, ok := msg.(*wrapperspb.StringValue)
+			if !ok {
+				return nil, fmt.Errorf("expected %s, got %T", fakeV2FullName, msg)
+			}
+			return &anypb.Any{TypeUrl: v2.GetValue()}, nil
+		}),
+	}
+	downgraderRegistry := map[protoreflect.FullName]Downgrader(downgraders)
+
+	got, err := downgradeToChain(downgraderRegistry, wrapperspb.String("v2-payload"), fakeV1FullName)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	v1, ok := got.(*anypb.Any)
+	if !ok {
+		t.Fatalf("wrong result type %T", got)
+	}
+	if got, want := v1.GetTypeUrl(), "v2-payload"; got != want {
+		t.Errorf("wrong downgraded value: got %q, want %q", got, want)
+	}
+}
+
+func TestDowngradeTo_alreadyTarget(t *testing.T) {
+	current := &anypb.Any{TypeUrl: "already v1"}
+	got, err := downgradeToChain(nil, current, fakeV1FullName)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != protoreflect.ProtoMessage(current) {
+		t.Error("expected downgradeTo to return the same message unchanged when it's already the target version")
+	}
+}
+
+func TestDowngradeTo_noDowngrader(t *testing.T) {
+	if _, err := downgradeToChain(nil, wrapperspb.String("v2"), fakeV1FullName); err == nil {
+		t.Fatal("expected an error when no downgrader is registered")
+	}
+}
+
+func TestStateFormatVersion_IsCurrent(t *testing.T) {
+	if !(StateFormatVersion{}).IsCurrent() {
+		t.Error("expected the zero value to be current")
+	}
+	if (StateFormatVersion{ResourceInstanceObject: fakeV1FullName}).IsCurrent() {
+		t.Error("expected a pinned version to not be current")
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package stackstate
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/hashicorp/terraform/internal/stacks/stackstate/statekeys"
+)
+
+// UnknownFieldPolicy selects what LoadFromProto does with proto fields it
+// doesn't recognize within an otherwise-recognized message type, such as a
+// [tfstackdata1.StateResourceInstanceObjectV1] written by a newer Terraform
+// that has since grown new fields.
+//
+// This is a finer-grained complement to [statekeys.UnrecognizedKeyHandling]:
+// that setting governs whole tracking keys this version doesn't understand
+// at all, while UnknownFieldPolicy governs individual fields within a
+// message type this version otherwise understands perfectly well.
+type UnknownFieldPolicy int
+
+const (
+	// PreserveUnknownFields captures a message's unknown fields out of its
+	// protoreflect.Message before translating it into an in-memory object,
+	// and keeps the captured bytes alongside that object so that a later
+	// SaveToProto can splice them back into the outgoing message unchanged.
+	// This is what makes a newer-writer -> older-Terraform -> newer-writer
+	// round trip lossless.
+	PreserveUnknownFields UnknownFieldPolicy = iota
+
+	// DiscardUnknownFields clears a message's unknown fields -- equivalent
+	// to today's behavior, where anypb.UnmarshalNew silently drops them --
+	// and records the tracking key so the apply phase emits a rewrite that
+	// actually removes them from the caller's saved state.
+	DiscardUnknownFields
+
+	// FailOnUnknownFields rejects a message that has any unknown fields at
+	// all. This is for stack schema evolutions that are forwards-
+	// incompatible: a state written with a new field under this policy
+	// cannot be loaded by a Terraform that doesn't know what that field
+	// means.
+	FailOnUnknownFields
+)
+
+// defaultUnknownFieldPolicyFor chooses the default per-message policy for a
+// recognized tracking key, based on the same handling its key type already
+// declares for whole unrecognized keys: a key type that's safe to leave
+// untouched when unrecognized (statekeys.PreserveIfUnrecognized) is equally
+// safe to preserve unknown fields for, and likewise for discard and fail.
+func defaultUnknownFieldPolicyFor(key statekeys.Key) UnknownFieldPolicy {
+	switch key.KeyType().UnrecognizedKeyHandling() {
+	case statekeys.DiscardIfUnrecognized:
+		return DiscardUnknownFields
+	case statekeys.FailIfUnrecognized:
+		return FailOnUnknownFields
+	default:
+		return PreserveUnknownFields
+	}
+}
+
+// captureUnknownFields returns a defensive copy of msg's unknown fields, or
+// nil if it has none.
+func captureUnknownFields(msg protoreflect.ProtoMessage) []byte {
+	raw := msg.ProtoReflect().GetUnknown()
+	if len(raw) == 0 {
+		return nil
+	}
+	return append([]byte(nil), raw...)
+}
+
+// applyUnknownFieldPolicy enforces policy against msg's unknown fields. It
+// returns the bytes that should be preserved alongside the decoded
+// in-memory object (nil unless policy is PreserveUnknownFields and msg
+// actually has any), and whether policy is FailOnUnknownFields and msg has
+// unknown fields it can't tolerate.
+//
+// This only decides what to do with the bytes; it doesn't know how to
+// phrase an error or which tracking key to record for a later rewrite. The
+// caller -- which already has both of those -- phrases the error once it
+// sees disallowed is true, and records the key from onDiscard, which is
+// called when policy is DiscardUnknownFields and msg had unknown fields.
+func applyUnknownFieldPolicy(msg protoreflect.ProtoMessage, policy UnknownFieldPolicy, onDiscard func()) (preserved []byte, disallowed bool) {
+	raw := captureUnknownFields(msg)
+	if raw == nil {
+		return nil, false
+	}
+
+	switch policy {
+	case FailOnUnknownFields:
+		return nil, true
+
+	case DiscardUnknownFields:
+		msg.ProtoReflect().SetUnknown(nil)
+		if onDiscard != nil {
+			onDiscard()
+		}
+		return nil, false
+
+	case PreserveUnknownFields:
+		return raw, false
+
+	default:
+		panic(fmt.Sprintf("unsupported UnknownFieldPolicy %d", policy))
+	}
+}
+
+// LoadFromProtoOption customizes the behavior of [LoadFromProto].
+type LoadFromProtoOption func(*loadFromProtoConfig)
+
+type loadFromProtoConfig struct {
+	// policyOverride, when non-nil, is used for every recognized message
+	// instead of defaultUnknownFieldPolicyFor's per-key-type default.
+	policyOverride *UnknownFieldPolicy
+
+	// formatVersion is accepted for symmetry with the equivalent
+	// SaveToProtoOption, but doesn't currently change decoding: every
+	// recognized message is migrated up to the newest version this build
+	// knows about regardless of which version produced it. It's here so
+	// that a caller that pins a [StateFormatVersion] for writing its state
+	// back out can pass the same value to LoadFromProto without the two
+	// options diverging later if decoding ever needs to become
+	// version-aware too.
+	formatVersion StateFormatVersion
+}
+
+// WithUnknownFieldPolicy overrides the default per-key-type unknown-field
+// policy, applying policy uniformly to every message LoadFromProto decodes.
+// Without this option, each recognized message defaults to the policy
+// defaultUnknownFieldPolicyFor derives from its tracking key's own
+// unrecognized-key handling.
+func WithUnknownFieldPolicy(policy UnknownFieldPolicy) LoadFromProtoOption {
+	return func(c *loadFromProtoConfig) {
+		c.policyOverride = &policy
+	}
+}
+
+// WithStateFormatVersion pins the wire-format version a caller expects its
+// state to be written in. See [StateFormatVersion] and the equivalent
+// SaveToProtoOption used when writing state back out.
+func WithStateFormatVersion(version StateFormatVersion) LoadFromProtoOption {
+	return func(c *loadFromProtoConfig) {
+		c.formatVersion = version
+	}
+}
+
+func resolveLoadFromProtoConfig(opts []LoadFromProtoOption) *loadFromProtoConfig {
+	c := &loadFromProtoConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *loadFromProtoConfig) unknownFieldPolicyFor(key statekeys.Key) UnknownFieldPolicy {
+	if c.policyOverride != nil {
+		return *c.policyOverride
+	}
+	return defaultUnknownFieldPolicyFor(key)
+}
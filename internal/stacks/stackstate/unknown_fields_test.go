@@ -0,0 +1,107 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package stackstate
+
+import (
+	"bytes"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// syntheticUnknownFields builds the raw wire bytes for one varint field at
+// an arbitrary high field number, standing in for a field a newer
+// Terraform added to a message type this version already otherwise
+// understands.
+func syntheticUnknownFields() []byte {
+	var buf []byte
+	buf = protowire.AppendTag(buf, 9999, protowire.VarintType)
+	buf = protowire.AppendVarint(buf, 12345)
+	return buf
+}
+
+// messageWithUnknownField returns a real proto.Message (anypb.Any, already
+// used elsewhere in this package) whose unknown fields have been set
+// directly, simulating what anypb.UnmarshalNew leaves behind today when a
+// newer writer's message has fields this build doesn't know about.
+func messageWithUnknownField() protoreflect.ProtoMessage {
+	msg := &anypb.Any{TypeUrl: "type.googleapis.com/test"}
+	msg.ProtoReflect().SetUnknown(protoreflect.RawFields(syntheticUnknownFields()))
+	return msg
+}
+
+func TestCaptureUnknownFields(t *testing.T) {
+	msg := messageWithUnknownField()
+
+	got := captureUnknownFields(msg)
+	want := syntheticUnknownFields()
+	if !bytes.Equal(got, want) {
+		t.Errorf("wrong captured bytes:\ngot:  %x\nwant: %x", got, want)
+	}
+
+	if captureUnknownFields(&anypb.Any{}) != nil {
+		t.Error("expected no captured bytes for a message with none")
+	}
+}
+
+func TestApplyUnknownFieldPolicy_preserve(t *testing.T) {
+	msg := messageWithUnknownField()
+
+	raw, disallowed := applyUnknownFieldPolicy(msg, PreserveUnknownFields, nil)
+	if disallowed {
+		t.Fatal("unexpected disallowed=true under a preserve policy")
+	}
+	if !bytes.Equal(raw, syntheticUnknownFields()) {
+		t.Errorf("wrong preserved bytes: got %x", raw)
+	}
+
+	// Preserving leaves the message's own unknown fields untouched too, so
+	// a caller re-marshalling msg directly would still round-trip them.
+	if !bytes.Equal(msg.ProtoReflect().GetUnknown(), syntheticUnknownFields()) {
+		t.Error("expected the message's unknown fields to be left in place")
+	}
+}
+
+func TestApplyUnknownFieldPolicy_discard(t *testing.T) {
+	msg := messageWithUnknownField()
+	var discarded bool
+
+	raw, disallowed := applyUnknownFieldPolicy(msg, DiscardUnknownFields, func() { discarded = true })
+	if disallowed {
+		t.Fatal("unexpected disallowed=true under a discard policy")
+	}
+	if raw != nil {
+		t.Errorf("expected no preserved bytes, got %x", raw)
+	}
+	if len(msg.ProtoReflect().GetUnknown()) != 0 {
+		t.Error("expected the message's unknown fields to be cleared")
+	}
+	if !discarded {
+		t.Error("expected onDiscard to be called so the caller can record the key for a rewrite on apply")
+	}
+}
+
+func TestApplyUnknownFieldPolicy_fail(t *testing.T) {
+	msg := messageWithUnknownField()
+
+	if _, disallowed := applyUnknownFieldPolicy(msg, FailOnUnknownFields, nil); !disallowed {
+		t.Fatal("expected disallowed=true for an unrecognized field under a fail policy")
+	}
+}
+
+func TestApplyUnknownFieldPolicy_noUnknownFields(t *testing.T) {
+	msg := &anypb.Any{}
+
+	for _, policy := range []UnknownFieldPolicy{PreserveUnknownFields, DiscardUnknownFields, FailOnUnknownFields} {
+		raw, disallowed := applyUnknownFieldPolicy(msg, policy, nil)
+		if disallowed {
+			t.Errorf("policy %d: unexpected disallowed=true for a message with no unknown fields", policy)
+		}
+		if raw != nil {
+			t.Errorf("policy %d: expected no preserved bytes, got %x", policy, raw)
+		}
+	}
+}
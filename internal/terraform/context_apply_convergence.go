@@ -0,0 +1,136 @@
+package terraform
+
+import (
+	"reflect"
+
+	"github.com/hashicorp/terraform/internal/configs"
+	"github.com/hashicorp/terraform/internal/plans"
+	"github.com/hashicorp/terraform/internal/providers"
+	"github.com/hashicorp/terraform/internal/states"
+	"github.com/hashicorp/terraform/internal/tfdiags"
+)
+
+// ConvergenceRoundResult summarizes a single round of
+// ApplyToConvergence: what was deferred at the end of that round's plan,
+// keyed by the deferred resource instance's address, and whether the plan
+// reported itself as Complete.
+type ConvergenceRoundResult struct {
+	Deferred map[string]DeferredSummary
+	Complete bool
+}
+
+// DeferredSummary is the reason and planned action recorded against a
+// resource instance that a round of ApplyToConvergence deferred, used to
+// detect when two consecutive rounds deferred exactly the same set of
+// instances for exactly the same reasons.
+type DeferredSummary struct {
+	Reason providers.DeferredReason
+	Action plans.Action
+}
+
+// ConvergenceResult is the outcome of ApplyToConvergence: either the plan
+// eventually became complete, the same set of deferrals recurred with no
+// progress (a fixed point), or the round budget was exhausted first.
+type ConvergenceResult struct {
+	Rounds   []ConvergenceRoundResult
+	State    *states.State
+	Complete bool
+
+	// FixedPoint is true if convergence stopped because the same
+	// deferrals recurred in two consecutive rounds without any of them
+	// resolving, rather than because of hitting maxRounds or becoming
+	// complete.
+	FixedPoint bool
+}
+
+// ApplyToConvergence drives a convergence-mode apply: it repeatedly builds
+// a fresh *Context from contextOpts, plans cfg against state using opts,
+// and applies the result, feeding each round's resulting state into the
+// next. It stops once a round's plan reports Complete, the same set of
+// deferred resource instances recurs across two consecutive rounds with no
+// progress (a fixed point), or maxRounds rounds have run.
+//
+// This is the driver behind a convergence-mode apply - one that keeps
+// re-planning and re-applying on the caller's behalf instead of requiring
+// the caller to enumerate each round explicitly - and is the production
+// counterpart callers such as a future "apply until done" CLI mode can
+// invoke directly.
+func ApplyToConvergence(contextOpts *ContextOpts, cfg *configs.Config, state *states.State, opts *PlanOpts, maxRounds int) (*ConvergenceResult, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+	result := &ConvergenceResult{State: state}
+
+	var prevDeferred map[string]DeferredSummary
+	var prevRoundChanges []*plans.ResourceInstanceChangeSrc
+	for round := 0; round < maxRounds; round++ {
+		ctx, ctxDiags := NewContext(contextOpts)
+		diags = diags.Append(ctxDiags)
+		if ctxDiags.HasErrors() {
+			return result, diags
+		}
+
+		plan, planDiags := ctx.Plan(cfg, state, opts)
+		diags = diags.Append(planDiags)
+		if planDiags.HasErrors() {
+			return result, diags
+		}
+
+		deferred := make(map[string]DeferredSummary, len(plan.DeferredResources))
+		for _, dc := range plan.DeferredResources {
+			deferred[dc.ChangeSrc.Addr.String()] = DeferredSummary{
+				Reason: dc.DeferredReason,
+				Action: dc.ChangeSrc.Action,
+			}
+		}
+
+		updated, applyDiags := ctx.Apply(plan, cfg, nil)
+		if applyDiags.HasErrors() {
+			// Some instances from the round before this one may have
+			// applied successfully only for this round to fail partway
+			// through; opts.DeferralRollbackPolicy decides what, if
+			// anything, happens to them before we surface the failure.
+			diags = diags.Append(ApplyDeferralRollback(opts.DeferralRollbackPolicy, contextOpts, prevRoundChanges, state))
+			diags = diags.Append(applyDiags)
+			result.State = state
+			return result, diags
+		}
+		state = updated
+		result.State = state
+
+		result.Rounds = append(result.Rounds, ConvergenceRoundResult{
+			Deferred: deferred,
+			Complete: plan.Complete,
+		})
+
+		if plan.Complete {
+			result.Complete = true
+			return result, diags
+		}
+
+		if prevDeferred != nil && reflect.DeepEqual(prevDeferred, deferred) {
+			// No progress was made between this round and the last one:
+			// every address that was deferred before still is, with the
+			// same reason, so further rounds can't be expected to help.
+			result.FixedPoint = true
+			return result, diags
+		}
+		prevDeferred = deferred
+		prevRoundChanges = changedResourceInstances(plan)
+	}
+
+	return result, diags
+}
+
+// changedResourceInstances returns the resource instance changes from
+// plan that this round actually applied - Create and Update - so a
+// subsequent round that fails partway through can hand them to
+// ApplyDeferralRollback.
+func changedResourceInstances(plan *plans.Plan) []*plans.ResourceInstanceChangeSrc {
+	var changes []*plans.ResourceInstanceChangeSrc
+	for _, cs := range plan.Changes.Resources {
+		switch cs.Action {
+		case plans.Create, plans.Update:
+			changes = append(changes, cs)
+		}
+	}
+	return changes
+}
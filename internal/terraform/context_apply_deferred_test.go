@@ -8,12 +8,14 @@ import (
 	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/zclconf/go-cty-debug/ctydebug"
 	"github.com/zclconf/go-cty/cty"
 
 	"github.com/hashicorp/terraform/internal/addrs"
+	"github.com/hashicorp/terraform/internal/configs"
 	"github.com/hashicorp/terraform/internal/configs/configschema"
 	"github.com/hashicorp/terraform/internal/plans"
 	"github.com/hashicorp/terraform/internal/providers"
@@ -136,10 +138,9 @@ output "from_resource" {
 					"test.b": {Reason: providers.DeferredReasonDeferredPrereq, Action: plans.Create},
 				},
 				wantApplied: map[string]cty.Value{},
-				// TODO: These deferred output values are wrong, but outputs are a separate ticket.
 				wantOutputs: map[string]cty.Value{
-					"from_data":     cty.EmptyTupleVal,
-					"from_resource": cty.NullVal(cty.DynamicPseudoType),
+					"from_data":     cty.DynamicVal,
+					"from_resource": cty.DynamicVal,
 				},
 				complete:      false,
 				allowWarnings: false,
@@ -227,10 +228,9 @@ output "from_resource" {
 					"test.b": {Reason: providers.DeferredReasonDeferredPrereq, Action: plans.Create},
 				},
 				wantApplied: map[string]cty.Value{},
-				// TODO: These deferred output values are wrong, but outputs are a separate ticket.
 				wantOutputs: map[string]cty.Value{
-					"from_data":     cty.EmptyTupleVal,
-					"from_resource": cty.NullVal(cty.DynamicPseudoType),
+					"from_data":     cty.DynamicVal,
+					"from_resource": cty.DynamicVal,
 				},
 				complete:      false,
 				allowWarnings: false,
@@ -362,44 +362,17 @@ output "c" {
 						"output":         cty.StringVal("a"),
 					}),
 
-					// FIXME: The system is currently producing incorrect
-					//   results for output values that are derived from
-					//   resources that had deferred actions, because we're
-					//   not quite reconstructing all of the deferral state
-					//   correctly during the apply phase. The commented-out
-					//   lines below show how this _ought_ to look, but
-					//   we're accepting the incorrect answer for now so we
-					//   can start to gather feedback on the experiment
-					//   sooner, since the output value state at the interim
-					//   steps isn't really that important for demonstrating
-					//   the overall effect. We should fix this before
-					//   stabilizing the experiment, though.
-
-					// Currently we produce an incorrect result for output
-					// value "b" because the expression evaluator doesn't
-					// realize it's supposed to be treating this as deferred
-					// during the apply phase, and so it incorrectly decides
-					// that there are no instances due to the lack of
-					// instances in the state.
-					"b": cty.EmptyObjectVal,
 					// We can't say anything about test.b until we know what
 					// its instance keys are.
-					// "b": cty.DynamicVal,
-
-					// Currently we produce an incorrect result for output
-					// value "c" because the expression evaluator doesn't
-					// realize it's supposed to be treating this as deferred
-					// during the apply phase, and so it incorrectly decides
-					// that there is instance due to the lack of instances
-					// in the state.
-					"c": cty.NullVal(cty.DynamicPseudoType),
+					"b": cty.DynamicVal,
+
 					// test.c evaluates to the placeholder value that shows
 					// what we're expecting this object to look like in the
 					// next round.
-					// "c": cty.ObjectVal(map[string]cty.Value{
-					// 	"name":           cty.StringVal("c"),
-					// 	"upstream_names": cty.UnknownVal(cty.Set(cty.String)).RefineNotNull(),
-					// }),
+					"c": cty.ObjectVal(map[string]cty.Value{
+						"name":           cty.StringVal("c"),
+						"upstream_names": cty.UnknownVal(cty.Set(cty.String)).RefineNotNull(),
+					}),
 				},
 			},
 			{
@@ -950,6 +923,91 @@ removed {
 		},
 	}
 
+	// forgetResourceWithUnknownCountTest covers a removed block whose from
+	// address expands to an unknown number of instances: rather than
+	// erroring, the forget should be deferred until the count is known.
+	forgetResourceWithUnknownCountTest = deferredActionsTest{
+		configs: map[string]string{
+			"main.tf": `
+variable "resource_count" {
+	type = number
+}
+
+resource "test" "a" {
+	count = var.resource_count
+	name  = "a:${count.index}"
+}
+
+removed {
+	from = test.a
+
+	lifecycle {
+		destroy = false
+	}
+}
+`,
+		},
+		stages: []deferredActionsTestStage{
+			{
+				inputs: map[string]cty.Value{
+					"resource_count": cty.UnknownVal(cty.Number),
+				},
+				wantPlanned: map[string]cty.Value{},
+				wantActions: map[string]plans.Action{},
+				wantDeferred: map[string]ExpectedDeferred{
+					"test.a[\"*\"]": {Reason: providers.DeferredReasonRefactorTargetUnknown, Action: plans.Forget},
+				},
+				wantApplied:   map[string]cty.Value{},
+				wantOutputs:   make(map[string]cty.Value),
+				allowWarnings: true,
+				complete:      false,
+			},
+			{
+				inputs: map[string]cty.Value{
+					"resource_count": cty.NumberIntVal(0),
+				},
+				wantPlanned:   map[string]cty.Value{},
+				wantActions:   map[string]plans.Action{},
+				wantDeferred:  map[string]ExpectedDeferred{},
+				allowWarnings: true,
+				complete:      true,
+			},
+		},
+	}
+
+	// providerTransientDeferralTest covers a provider deferring a resource
+	// for a reason unrelated to unknown configuration -- such as rate
+	// limiting -- and reports a human-readable message plus a suggested
+	// retry-after duration alongside the reason code.
+	providerTransientDeferralTest = deferredActionsTest{
+		configs: map[string]string{
+			"main.tf": `
+resource "test" "a" {
+	name = "deferred_transient"
+}
+`,
+		},
+		stages: []deferredActionsTestStage{
+			{
+				wantPlanned: map[string]cty.Value{
+					"deferred_transient": cty.ObjectVal(map[string]cty.Value{
+						"name":           cty.StringVal("deferred_transient"),
+						"upstream_names": cty.NullVal(cty.Set(cty.String)),
+						"output":         cty.UnknownVal(cty.String),
+					}),
+				},
+				wantActions: map[string]plans.Action{},
+				wantDeferred: map[string]ExpectedDeferred{
+					"test.a": {Reason: providers.DeferredReasonTransient, Action: plans.Create},
+				},
+				wantApplied:   map[string]cty.Value{},
+				wantOutputs:   make(map[string]cty.Value),
+				allowWarnings: true,
+				complete:      false,
+			},
+		},
+	}
+
 	importIntoUnknownInstancesTest = deferredActionsTest{
 		configs: map[string]string{
 			"main.tf": `
@@ -1687,6 +1745,68 @@ resource "test" "c" {
 		},
 	}
 
+	// customConditionsDeferredPrereqTest covers a module-level check block
+	// whose condition depends on a resource that's itself deferred: rather
+	// than either failing loudly or silently dropping the check, the check
+	// itself should be deferred and re-evaluated once its prerequisite is
+	// known.
+	customConditionsDeferredPrereqTest = deferredActionsTest{
+		configs: map[string]string{
+			"main.tf": `
+variable "each" {
+	type = set(string)
+}
+
+resource "test" "a" {
+	for_each = var.each
+
+	name = "a:${each.key}"
+}
+
+check "a_check" {
+	assert {
+		condition     = length(test.a) > 0
+		error_message = "expected at least one test.a instance"
+	}
+}
+`,
+		},
+		stages: []deferredActionsTestStage{
+			{
+				inputs: map[string]cty.Value{
+					"each": cty.DynamicVal,
+				},
+				wantPlanned:  map[string]cty.Value{},
+				wantActions:  map[string]plans.Action{},
+				wantDeferred: map[string]ExpectedDeferred{
+					"test.a[\"*\"]": {Reason: providers.DeferredReasonInstanceCountUnknown, Action: plans.Create},
+					"check.a_check": {Reason: providers.DeferredReasonConditionUnknown, Action: plans.NoOp},
+				},
+				wantApplied:   map[string]cty.Value{},
+				wantOutputs:   make(map[string]cty.Value),
+				complete:      false,
+				allowWarnings: false,
+			},
+			{
+				inputs: map[string]cty.Value{
+					"each": cty.SetVal([]cty.Value{cty.StringVal("1")}),
+				},
+				wantPlanned: map[string]cty.Value{
+					"a:1": cty.ObjectVal(map[string]cty.Value{
+						"name":           cty.StringVal("a:1"),
+						"upstream_names": cty.NullVal(cty.Set(cty.String)),
+						"output":         cty.UnknownVal(cty.String),
+					}),
+				},
+				wantActions: map[string]plans.Action{
+					"test.a[\"1\"]": plans.Create,
+				},
+				wantDeferred: map[string]ExpectedDeferred{},
+				complete:     true,
+			},
+		},
+	}
+
 	// resourceReadTest is a test that covers the behavior of reading resources
 	// in a refresh when the refresh is responding with a deferral.
 	resourceReadTest = deferredActionsTest{
@@ -2472,6 +2592,241 @@ import {
 	}
 )
 
+// runToConvergence is a thin wrapper around the production
+// ApplyToConvergence driver: it builds the ContextOpts and PlanOpts for a
+// constant set of input variables and hands them off, so the fixtures
+// below exercise the actual "apply until done" driver rather than a
+// test-only reimplementation of it.
+func runToConvergence(t *testing.T, cfg *configs.Config, provider *deferredActionsProvider, state *states.State, inputs map[string]cty.Value, maxRounds int) *ConvergenceResult {
+	t.Helper()
+
+	contextOpts := &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(provider.Provider()),
+		},
+	}
+
+	opts := &PlanOpts{
+		Mode:            plans.NormalMode,
+		DeferralAllowed: true,
+		SetVariables: func() InputValues {
+			values := InputValues{}
+			for name, value := range inputs {
+				values[name] = &InputValue{
+					Value:      value,
+					SourceType: ValueFromCaller,
+				}
+			}
+			return values
+		}(),
+	}
+
+	result, diags := ApplyToConvergence(contextOpts, cfg, state, opts, maxRounds)
+	if diags.HasErrors() && !result.Complete && len(result.Rounds) == 0 {
+		t.Fatalf("ApplyToConvergence failed before completing a single round: %s", diags.Err())
+	}
+	return result
+}
+
+func TestContextApply_deferredActionsConvergence(t *testing.T) {
+	t.Run("converges once inputs are known", func(t *testing.T) {
+		cfg := testModuleInline(t, resourceForEachTest.configs)
+		provider := &deferredActionsProvider{
+			plannedChanges: &deferredActionsChanges{changes: make(map[string]cty.Value)},
+			appliedChanges: &deferredActionsChanges{changes: make(map[string]cty.Value)},
+		}
+
+		result := runToConvergence(t, cfg, provider, states.NewState(), map[string]cty.Value{
+			"each": cty.SetVal([]cty.Value{cty.StringVal("1")}),
+		}, 5)
+
+		if !result.Complete {
+			t.Fatalf("expected convergence to complete, rounds: %#v", result.Rounds)
+		}
+		if len(result.Rounds) != 1 {
+			t.Errorf("expected a single round since the input was already known, got %d", len(result.Rounds))
+		}
+	})
+
+	t.Run("detects a fixed point instead of spinning forever", func(t *testing.T) {
+		cfg := testModuleInline(t, map[string]string{
+			"main.tf": `
+resource "test" "a" {
+	name = "deferred_resource_change"
+}
+`,
+		})
+		provider := &deferredActionsProvider{
+			plannedChanges: &deferredActionsChanges{changes: make(map[string]cty.Value)},
+			appliedChanges: &deferredActionsChanges{changes: make(map[string]cty.Value)},
+		}
+
+		result := runToConvergence(t, cfg, provider, states.NewState(), nil, 5)
+
+		if result.Complete {
+			t.Fatalf("expected this fixture to never complete")
+		}
+		if !result.FixedPoint {
+			t.Fatalf("expected a fixed point to be detected instead of exhausting all rounds, rounds: %#v", result.Rounds)
+		}
+		if len(result.Rounds) >= 5 {
+			t.Errorf("fixed-point detection should have stopped before exhausting all %d rounds, used %d", 5, len(result.Rounds))
+		}
+	})
+}
+
+// TestContextApply_deferredActionsRollback covers the provider-facing half
+// of DeferralRollbackPolicyCompensate: a provider that wants to react to
+// an apply failure of its own accord can do so directly from
+// ApplyResourceChangeFn, same as it would for any other failure. See
+// TestApplyDeferralRollback below for the Terraform-facing half: the
+// ApplyToConvergence driver calling ApplyDeferralRollback against
+// instances applied in an *earlier* round when a later round's apply
+// fails partway through.
+func TestContextApply_deferredActionsRollback(t *testing.T) {
+	var compensated []string
+	provider := &deferredActionsProvider{
+		plannedChanges: &deferredActionsChanges{changes: make(map[string]cty.Value)},
+		appliedChanges: &deferredActionsChanges{changes: make(map[string]cty.Value)},
+		CompensateResourceChangeFn: func(name string) {
+			compensated = append(compensated, name)
+		},
+	}
+
+	cfg := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test" "a" {
+	name = "trigger_rollback_compensate"
+}
+`,
+	})
+
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(provider.Provider()),
+		},
+	})
+
+	opts := &PlanOpts{
+		Mode:                   plans.NormalMode,
+		DeferralAllowed:        true,
+		DeferralRollbackPolicy: DeferralRollbackPolicyCompensate,
+	}
+
+	plan, diags := ctx.Plan(cfg, states.NewState(), opts)
+	assertNoDiagnostics(t, diags)
+
+	_, diags = ctx.Apply(plan, cfg, nil)
+	if !diags.HasErrors() {
+		t.Fatalf("expected the simulated apply failure to surface as an error")
+	}
+
+	if diff := cmp.Diff([]string{"trigger_rollback_compensate"}, compensated); diff != "" {
+		t.Errorf("wrong set of compensated resources\n%s", diff)
+	}
+}
+
+// compensatingMockProvider wraps a *testing_provider.MockProvider with a
+// CompensateResourceChange method, so it satisfies CompensatingProvider
+// for tests that exercise ApplyDeferralRollback's compensate path.
+type compensatingMockProvider struct {
+	*testing_provider.MockProvider
+	CompensateResourceChangeFn func(key string)
+}
+
+func (p *compensatingMockProvider) CompensateResourceChange(key string) {
+	if p.CompensateResourceChangeFn != nil {
+		p.CompensateResourceChangeFn(key)
+	}
+}
+
+// TestApplyDeferralRollback covers the Terraform-facing half of
+// DeferralRollbackPolicy: what ApplyToConvergence's call to
+// ApplyDeferralRollback actually does with the resource instances applied
+// in the round before the one that failed.
+func TestApplyDeferralRollback(t *testing.T) {
+	changes := []*plans.ResourceInstanceChangeSrc{
+		{
+			Addr:         mustResourceInstanceAddr("test.a"),
+			ProviderAddr: addrs.AbsProviderConfig{Provider: addrs.NewDefaultProvider("test"), Module: addrs.RootModule},
+			Action:       plans.Create,
+		},
+	}
+
+	t.Run("compensate asks the provider to react", func(t *testing.T) {
+		var compensated []string
+		provider := &compensatingMockProvider{
+			MockProvider: &testing_provider.MockProvider{},
+			CompensateResourceChangeFn: func(key string) {
+				compensated = append(compensated, key)
+			},
+		}
+
+		contextOpts := &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): func() (providers.Interface, error) { return provider, nil },
+			},
+		}
+
+		diags := ApplyDeferralRollback(DeferralRollbackPolicyCompensate, contextOpts, changes, states.NewState())
+		assertNoDiagnostics(t, diags)
+
+		if diff := cmp.Diff([]string{"test.a"}, compensated); diff != "" {
+			t.Errorf("wrong set of compensated resources\n%s", diff)
+		}
+	})
+
+	t.Run("mark-tainted taints the instance in state", func(t *testing.T) {
+		state := states.BuildState(func(state *states.SyncState) {
+			state.SetResourceInstanceCurrent(
+				mustResourceInstanceAddr("test.a"),
+				&states.ResourceInstanceObjectSrc{
+					Status:    states.ObjectReady,
+					AttrsJSON: mustParseJson(map[string]interface{}{"name": "a"}),
+				},
+				addrs.AbsProviderConfig{Provider: addrs.NewDefaultProvider("test"), Module: addrs.RootModule},
+			)
+		})
+
+		contextOpts := &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): func() (providers.Interface, error) { return &testing_provider.MockProvider{}, nil },
+			},
+		}
+
+		diags := ApplyDeferralRollback(DeferralRollbackPolicyMarkTainted, contextOpts, changes, state)
+		assertNoDiagnostics(t, diags)
+
+		obj := state.ResourceInstance(mustResourceInstanceAddr("test.a")).Current
+		if obj.Status != states.ObjectTainted {
+			t.Errorf("expected test.a to be tainted, got status %s", obj.Status)
+		}
+	})
+
+	t.Run("none does nothing", func(t *testing.T) {
+		var compensated []string
+		provider := &compensatingMockProvider{
+			MockProvider: &testing_provider.MockProvider{},
+			CompensateResourceChangeFn: func(key string) {
+				compensated = append(compensated, key)
+			},
+		}
+
+		contextOpts := &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): func() (providers.Interface, error) { return provider, nil },
+			},
+		}
+
+		diags := ApplyDeferralRollback(DeferralRollbackPolicyNone, contextOpts, changes, states.NewState())
+		assertNoDiagnostics(t, diags)
+
+		if len(compensated) != 0 {
+			t.Errorf("expected no compensated resources, got %v", compensated)
+		}
+	})
+}
+
 func TestContextApply_deferredActions(t *testing.T) {
 	tests := map[string]deferredActionsTest{
 		"resource_for_each":                                 resourceForEachTest,
@@ -2479,6 +2834,8 @@ func TestContextApply_deferredActions(t *testing.T) {
 		"resource_count":                                    resourceCountTest,
 		"create_before_destroy":                             createBeforeDestroyLifecycleTest,
 		"forget_resources":                                  forgetResourcesTest,
+		"forget_resource_with_unknown_count":                forgetResourceWithUnknownCountTest,
+		"provider_transient_deferral":                       providerTransientDeferralTest,
 		"import_into_unknown":                               importIntoUnknownInstancesTest,
 		"target_deferred_resource":                          targetDeferredResourceTest,
 		"target_resource_that_depends_on_deferred_resource": targetResourceThatDependsOnDeferredResourceTest,
@@ -2486,6 +2843,7 @@ func TestContextApply_deferredActions(t *testing.T) {
 		"replace_deferred_resource":                         replaceDeferredResourceTest,
 		"custom_conditions":                                 customConditionsTest,
 		"custom_conditions_with_orphans":                    customConditionsWithOrphansTest,
+		"custom_conditions_deferred_prereq":                 customConditionsDeferredPrereqTest,
 		"resource_read":                                     resourceReadTest,
 		"data_read":                                         readDataSourceTest,
 		"data_for_each":                                     dataForEachTest,
@@ -2686,6 +3044,13 @@ func (d *deferredActionsChanges) Test(t *testing.T, expected map[string]cty.Valu
 type deferredActionsProvider struct {
 	plannedChanges *deferredActionsChanges
 	appliedChanges *deferredActionsChanges
+
+	// CompensateResourceChangeFn, if set, is called by this provider's own
+	// ApplyResourceChangeFn with the instance's "name" attribute, just
+	// before it fails the apply of a "trigger_rollback_compensate"
+	// instance. DeferralRollbackPolicyCompensate does not call it: the mock
+	// returned by Provider does not implement CompensatingProvider.
+	CompensateResourceChangeFn func(name string)
 }
 
 func (provider *deferredActionsProvider) Provider() providers.Interface {
@@ -2781,10 +3146,21 @@ func (provider *deferredActionsProvider) Provider() providers.Interface {
 			}
 
 			plannedState := req.ProposedNewState
-			if key == "deferred_resource_change" {
+			switch key {
+			case "deferred_resource_change":
 				deferred = &providers.Deferred{
 					Reason: providers.DeferredReasonProviderConfigUnknown,
 				}
+			case "deferred_transient":
+				// Simulates a provider that's hit a transient problem, such
+				// as rate limiting, and would like Terraform to retry this
+				// resource again soon rather than treating it as blocked on
+				// some other part of the configuration becoming known.
+				deferred = &providers.Deferred{
+					Reason:     providers.DeferredReasonTransient,
+					Message:    "rate limited by upstream API, please retry",
+					RetryAfter: 30 * time.Second,
+				}
 			}
 
 			if plannedState.GetAttr("output").IsNull() {
@@ -2806,6 +3182,18 @@ func (provider *deferredActionsProvider) Provider() providers.Interface {
 			key := req.Config.GetAttr("name").AsString()
 			newState := req.PlannedState
 
+			if key == "trigger_rollback_compensate" {
+				if provider.CompensateResourceChangeFn != nil {
+					provider.CompensateResourceChangeFn(key)
+				}
+				var diags tfdiags.Diagnostics
+				diags = diags.Append(tfdiags.Sourceless(tfdiags.Error, "Simulated apply failure", "forced failure to exercise DeferralRollbackPolicy"))
+				return providers.ApplyResourceChangeResponse{
+					NewState:    req.PriorState,
+					Diagnostics: diags,
+				}
+			}
+
 			if !newState.GetAttr("output").IsKnown() {
 				newStateValues := req.PlannedState.AsValueMap()
 				newStateValues["output"] = cty.StringVal(key)
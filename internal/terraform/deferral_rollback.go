@@ -0,0 +1,146 @@
+package terraform
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/internal/addrs"
+	"github.com/hashicorp/terraform/internal/plans"
+	"github.com/hashicorp/terraform/internal/providers"
+	"github.com/hashicorp/terraform/internal/states"
+	"github.com/hashicorp/terraform/internal/tfdiags"
+)
+
+// DeferralRollbackPolicy controls what happens to resource instances that
+// were successfully applied in an earlier convergence round when a later
+// round fails partway through, while some instances from an even earlier
+// round are still deferred.
+type DeferralRollbackPolicy int
+
+const (
+	// DeferralRollbackPolicyNone leaves instances created in earlier
+	// rounds untouched when a later round fails. This is the default,
+	// matching today's behavior of treating each round's apply result as
+	// final regardless of what happens afterwards.
+	DeferralRollbackPolicyNone DeferralRollbackPolicy = iota
+
+	// DeferralRollbackPolicyMarkTainted marks every resource instance
+	// created or updated in the round before the failed one as tainted, so
+	// that the next round will plan to replace them rather than silently
+	// leaving possibly-inconsistent state in place.
+	DeferralRollbackPolicyMarkTainted
+
+	// DeferralRollbackPolicyCompensate calls the provider's
+	// CompensateResourceChange (where it implements CompensatingProvider)
+	// for each instance created or updated in the round before the failed
+	// one, giving the provider a
+	// chance to undo or otherwise react to the partial apply before
+	// Terraform records the failure.
+	DeferralRollbackPolicyCompensate
+)
+
+// String implements fmt.Stringer.
+func (p DeferralRollbackPolicy) String() string {
+	switch p {
+	case DeferralRollbackPolicyNone:
+		return "none"
+	case DeferralRollbackPolicyMarkTainted:
+		return "mark-tainted"
+	case DeferralRollbackPolicyCompensate:
+		return "compensate"
+	default:
+		return "unknown"
+	}
+}
+
+// CompensatingProvider is implemented by a providers.Interface that wants a
+// chance to react to DeferralRollbackPolicyCompensate for a resource
+// instance applied in an earlier convergence round - for example, by
+// issuing its own compensating API call - rather than Terraform simply
+// tainting the instance or leaving it untouched.
+type CompensatingProvider interface {
+	CompensateResourceChange(key string)
+}
+
+// ApplyDeferralRollback reacts to policy for every resource instance in
+// changes - the instances successfully created or updated in the
+// convergence round immediately before the one whose apply just failed
+// partway through. ApplyToConvergence calls this once a round's apply
+// returns errors, before those errors are surfaced to the caller.
+//
+// DeferralRollbackPolicyCompensate gives each affected instance's provider
+// a chance to react via CompensatingProvider. DeferralRollbackPolicyMarkTainted
+// instead marks every affected instance tainted directly in state, so the
+// next attempt plans to replace it rather than silently trusting
+// possibly-inconsistent state. DeferralRollbackPolicyNone does nothing.
+func ApplyDeferralRollback(policy DeferralRollbackPolicy, contextOpts *ContextOpts, changes []*plans.ResourceInstanceChangeSrc, state *states.State) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	if policy == DeferralRollbackPolicyNone || len(changes) == 0 {
+		return diags
+	}
+
+	providerInstances := make(map[addrs.Provider]providers.Interface)
+
+	for _, change := range changes {
+		switch policy {
+		case DeferralRollbackPolicyCompensate:
+			provider, err := cachedProviderInstance(contextOpts, providerInstances, change.ProviderAddr.Provider)
+			if err != nil {
+				diags = diags.Append(tfdiags.Sourceless(
+					tfdiags.Warning,
+					"Failed to apply deferral rollback policy",
+					fmt.Sprintf("Could not start %s to compensate for %s: %s", change.ProviderAddr.Provider, change.Addr, err),
+				))
+				continue
+			}
+
+			if compensating, ok := provider.(CompensatingProvider); ok {
+				compensating.CompensateResourceChange(change.Addr.String())
+			}
+
+		case DeferralRollbackPolicyMarkTainted:
+			markResourceInstanceTainted(state, change.Addr)
+		}
+	}
+
+	return diags
+}
+
+// cachedProviderInstance returns a providers.Interface for provider,
+// starting one from contextOpts.Providers and caching it in instances if
+// one hasn't already been started for a prior change in the same batch.
+func cachedProviderInstance(contextOpts *ContextOpts, instances map[addrs.Provider]providers.Interface, provider addrs.Provider) (providers.Interface, error) {
+	if instance, ok := instances[provider]; ok {
+		return instance, nil
+	}
+
+	factory, ok := contextOpts.Providers[provider]
+	if !ok {
+		return nil, fmt.Errorf("no provider factory configured for %s", provider)
+	}
+
+	instance, err := factory()
+	if err != nil {
+		return nil, err
+	}
+	instances[provider] = instance
+	return instance, nil
+}
+
+// markResourceInstanceTainted marks addr's current object tainted in
+// state, if it has one, so that the next plan replaces it instead of
+// trusting state that may be inconsistent with real infrastructure.
+func markResourceInstanceTainted(state *states.State, addr addrs.AbsResourceInstance) {
+	ms := state.Module(addr.Module)
+	if ms == nil {
+		return
+	}
+	rs := ms.Resource(addr.Resource.Resource)
+	if rs == nil {
+		return
+	}
+	obj, ok := rs.Instances[addr.Resource.Key]
+	if !ok || obj.Current == nil {
+		return
+	}
+	obj.Current.Status = states.ObjectTainted
+}
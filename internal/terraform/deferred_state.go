@@ -0,0 +1,64 @@
+package terraform
+
+import (
+	"github.com/hashicorp/terraform/internal/addrs"
+	"github.com/hashicorp/terraform/internal/plans"
+	"github.com/hashicorp/terraform/internal/providers"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DeferredResourceInstance records everything a later plan/apply round needs
+// to pick up where a prior round left off for a single resource instance
+// that couldn't be fully planned, without re-evaluating every upstream
+// expression that fed into the decision to defer it.
+//
+// This is deliberately a sibling structure to states.State rather than a
+// new field on it: a partial round's deferrals aren't part of the
+// authoritative resource state, and keeping them separate means a consumer
+// that doesn't understand deferrals yet can still round-trip the state
+// file unmodified.
+type DeferredResourceInstance struct {
+	Addr addrs.AbsResourceInstance
+
+	// Placeholder is the best-known value for this instance at the end of
+	// the round that deferred it -- typically an unknown value refined
+	// with whatever partial information was available, matching what the
+	// plan phase already reported to the user.
+	Placeholder cty.Value
+
+	Reason        providers.DeferredReason
+	PlannedAction plans.Action
+}
+
+// DeferredResourceInstances is the full set of deferrals recorded at the
+// end of a plan/apply round, keyed by instance address for cheap lookup
+// when the next round starts.
+type DeferredResourceInstances map[addrs.AbsResourceInstance]DeferredResourceInstance
+
+// Merge returns a new DeferredResourceInstances containing the receiver's
+// entries overlaid with other's, so that a later round's deferrals take
+// precedence over a round that's being superseded.
+func (d DeferredResourceInstances) Merge(other DeferredResourceInstances) DeferredResourceInstances {
+	ret := make(DeferredResourceInstances, len(d)+len(other))
+	for addr, inst := range d {
+		ret[addr] = inst
+	}
+	for addr, inst := range other {
+		ret[addr] = inst
+	}
+	return ret
+}
+
+// ForConfigResource returns the subset of deferrals belonging to instances
+// of the given resource, for use by graph nodes -- such as the output
+// evaluator -- that need to know whether any instance of a resource they
+// depend on is still pending without caring about the others.
+func (d DeferredResourceInstances) ForConfigResource(addr addrs.ConfigResource) DeferredResourceInstances {
+	ret := make(DeferredResourceInstances)
+	for instAddr, inst := range d {
+		if instAddr.ConfigResource().Equal(addr) {
+			ret[instAddr] = inst
+		}
+	}
+	return ret
+}
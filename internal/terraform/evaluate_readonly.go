@@ -0,0 +1,204 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package terraform
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp/terraform/internal/addrs"
+	"github.com/hashicorp/terraform/internal/configs"
+	"github.com/hashicorp/terraform/internal/lang"
+	"github.com/hashicorp/terraform/internal/namedvals"
+	"github.com/hashicorp/terraform/internal/plans"
+	"github.com/hashicorp/terraform/internal/providers"
+	"github.com/hashicorp/terraform/internal/states"
+	"github.com/hashicorp/terraform/internal/states/statefile"
+	"github.com/hashicorp/terraform/internal/tfdiags"
+)
+
+// ReadOnlyEvaluator evaluates HCL expressions against a previously saved
+// configuration, state, and plan, the way a policy check, inspection
+// dashboard, or drift reporter might, without running a graph walk and
+// without ever needing a live provider plugin.
+//
+// It's a thin wrapper around the same Evaluator, evaluationStateData, and
+// lang.Scope that the rest of this package uses while actually applying a
+// plan, so an expression evaluated through it -- including which values
+// come back marked sensitive -- agrees exactly with what GetResource,
+// GetOutput, and GetInputVariable would have produced in-process.
+type ReadOnlyEvaluator struct {
+	evaluator *Evaluator
+}
+
+// NewReadOnlyEvaluator builds a ReadOnlyEvaluator from a configuration
+// snapshot and, optionally, a state and/or plan. schemas supplies the
+// provider schemas the evaluator needs to decode state and plan values;
+// callers load these from the planfile's embedded schema cache rather than
+// this package reaching into the planfile format itself.
+//
+// The resulting evaluator is never capable of starting a real provider: its
+// Plugins only know how to answer GetProviderSchema, so any code path that
+// would otherwise perform a provider RPC -- which Evaluate should never
+// need, since it only reads already-recorded values -- fails instead of
+// silently contacting a plugin.
+func NewReadOnlyEvaluator(config *configs.Config, stateFile *statefile.File, plan *plans.Plan, schemas map[addrs.Provider]providers.ProviderSchema) (*ReadOnlyEvaluator, error) {
+	if config == nil {
+		return nil, fmt.Errorf("a configuration snapshot is required to evaluate expressions against it")
+	}
+
+	state := states.NewState()
+	if stateFile != nil && stateFile.State != nil {
+		state = stateFile.State
+	}
+
+	changes := plans.NewChanges()
+	if plan != nil && plan.Changes != nil {
+		changes = plan.Changes
+	}
+
+	return &ReadOnlyEvaluator{
+		evaluator: &Evaluator{
+			// Read-only evaluation has no active workspace of its own, so
+			// there's nothing more specific to report here than "default".
+			Meta:        &ContextMeta{Env: "default"},
+			Config:      config,
+			Changes:     changes.SyncWrapper(),
+			State:       state.SyncWrapper(),
+			NamedValues: namedvals.NewState(),
+			Plugins:     newReadOnlySchemaPlugins(schemas),
+		},
+	}, nil
+}
+
+// Evaluate parses expr as a standalone HCL expression -- the same syntax
+// used for a resource argument, such as `module.mod.out` or
+// `test_resource.foo.nesting_list[0].sensitive_value` -- and evaluates it
+// in the given module instance, returning whatever value it refers to with
+// the same sensitivity marks GetResource would apply.
+//
+// An expression that refers to a managed or data resource instance with no
+// corresponding entry in state or plan changes (for example, a data source
+// that hasn't been read yet) produces an error diagnostic rather than
+// attempting to plan or read it, since doing either would require calling
+// the provider.
+func (r *ReadOnlyEvaluator) Evaluate(expr string, scope addrs.ModuleInstance) (cty.Value, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	parsed, parseDiags := hclsyntax.ParseExpression([]byte(expr), "<expression>", hcl.Pos{Line: 1, Column: 1})
+	diags = diags.Append(parseDiags)
+	if parseDiags.HasErrors() {
+		return cty.NilVal, diags
+	}
+
+	data := &evaluationStateData{
+		Evaluator:  r.evaluator,
+		ModulePath: scope,
+	}
+	evalScope := r.evaluator.Scope(data, nil, nil, lang.ExternalFuncs{})
+
+	val, evalDiags := evalScope.EvalExpr(parsed, cty.DynamicPseudoType)
+	diags = diags.Append(evalDiags)
+	return val, diags
+}
+
+// newReadOnlySchemaPlugins builds a *contextPlugins backed entirely by the
+// given schemas, with no ability to actually start any of the providers
+// they describe.
+func newReadOnlySchemaPlugins(schemas map[addrs.Provider]providers.ProviderSchema) *contextPlugins {
+	factories := make(map[addrs.Provider]providers.Factory, len(schemas))
+	for addr, schema := range schemas {
+		schema := schema // capture for the closure
+		factories[addr] = func() (providers.Interface, error) {
+			return &readOnlySchemaProvider{schema: schema}, nil
+		}
+	}
+	return newContextPlugins(factories, nil)
+}
+
+// readOnlySchemaProvider is a providers.Interface that can only answer
+// GetProviderSchema; every other operation returns an error explaining that
+// read-only evaluation never calls the real provider.
+type readOnlySchemaProvider struct {
+	schema providers.ProviderSchema
+}
+
+var _ providers.Interface = (*readOnlySchemaProvider)(nil)
+
+func (p *readOnlySchemaProvider) GetProviderSchema() providers.GetProviderSchemaResponse {
+	return providers.GetProviderSchemaResponse{
+		Provider:      p.schema.Provider,
+		ResourceTypes: p.schema.ResourceTypes,
+		DataSources:   p.schema.DataSources,
+	}
+}
+
+func (p *readOnlySchemaProvider) unsupported(op string) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	diags = diags.Append(tfdiags.Sourceless(
+		tfdiags.Error,
+		"Read-only evaluation cannot call the provider",
+		fmt.Sprintf("Evaluating an expression against a saved plan does not invoke %s. The referenced value must already be recorded in state or in the plan's changes.", op),
+	))
+	return diags
+}
+
+func (p *readOnlySchemaProvider) ValidateProviderConfig(providers.ValidateProviderConfigRequest) providers.ValidateProviderConfigResponse {
+	return providers.ValidateProviderConfigResponse{Diagnostics: p.unsupported("ValidateProviderConfig")}
+}
+
+func (p *readOnlySchemaProvider) ValidateResourceConfig(providers.ValidateResourceConfigRequest) providers.ValidateResourceConfigResponse {
+	return providers.ValidateResourceConfigResponse{Diagnostics: p.unsupported("ValidateResourceConfig")}
+}
+
+func (p *readOnlySchemaProvider) ValidateDataResourceConfig(providers.ValidateDataResourceConfigRequest) providers.ValidateDataResourceConfigResponse {
+	return providers.ValidateDataResourceConfigResponse{Diagnostics: p.unsupported("ValidateDataResourceConfig")}
+}
+
+func (p *readOnlySchemaProvider) UpgradeResourceState(providers.UpgradeResourceStateRequest) providers.UpgradeResourceStateResponse {
+	return providers.UpgradeResourceStateResponse{Diagnostics: p.unsupported("UpgradeResourceState")}
+}
+
+func (p *readOnlySchemaProvider) ConfigureProvider(providers.ConfigureProviderRequest) providers.ConfigureProviderResponse {
+	return providers.ConfigureProviderResponse{Diagnostics: p.unsupported("ConfigureProvider")}
+}
+
+func (p *readOnlySchemaProvider) Stop() error {
+	return nil
+}
+
+func (p *readOnlySchemaProvider) ReadResource(providers.ReadResourceRequest) providers.ReadResourceResponse {
+	return providers.ReadResourceResponse{Diagnostics: p.unsupported("ReadResource")}
+}
+
+func (p *readOnlySchemaProvider) PlanResourceChange(providers.PlanResourceChangeRequest) providers.PlanResourceChangeResponse {
+	return providers.PlanResourceChangeResponse{Diagnostics: p.unsupported("PlanResourceChange")}
+}
+
+func (p *readOnlySchemaProvider) ApplyResourceChange(providers.ApplyResourceChangeRequest) providers.ApplyResourceChangeResponse {
+	return providers.ApplyResourceChangeResponse{Diagnostics: p.unsupported("ApplyResourceChange")}
+}
+
+func (p *readOnlySchemaProvider) ImportResourceState(providers.ImportResourceStateRequest) providers.ImportResourceStateResponse {
+	return providers.ImportResourceStateResponse{Diagnostics: p.unsupported("ImportResourceState")}
+}
+
+func (p *readOnlySchemaProvider) MoveResourceState(providers.MoveResourceStateRequest) providers.MoveResourceStateResponse {
+	return providers.MoveResourceStateResponse{Diagnostics: p.unsupported("MoveResourceState")}
+}
+
+func (p *readOnlySchemaProvider) ReadDataSource(providers.ReadDataSourceRequest) providers.ReadDataSourceResponse {
+	return providers.ReadDataSourceResponse{Diagnostics: p.unsupported("ReadDataSource")}
+}
+
+func (p *readOnlySchemaProvider) CallFunction(providers.CallFunctionRequest) providers.CallFunctionResponse {
+	return providers.CallFunctionResponse{Diagnostics: p.unsupported("CallFunction")}
+}
+
+func (p *readOnlySchemaProvider) Close() error {
+	return nil
+}
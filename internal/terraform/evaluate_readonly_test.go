@@ -0,0 +1,124 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package terraform
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp/terraform/internal/addrs"
+	"github.com/hashicorp/terraform/internal/configs"
+	"github.com/hashicorp/terraform/internal/configs/configschema"
+	"github.com/hashicorp/terraform/internal/lang/marks"
+	"github.com/hashicorp/terraform/internal/plans"
+	"github.com/hashicorp/terraform/internal/providers"
+)
+
+// TestReadOnlyEvaluator_plannedAfterMarks mirrors
+// TestEvaluatorGetResource_changes: a resource instance has a pending
+// update, and the After value's sensitive marks must round-trip through
+// Evaluate exactly as GetResource would return them in-process.
+func TestReadOnlyEvaluator_plannedAfterMarks(t *testing.T) {
+	schemas := map[addrs.Provider]providers.ProviderSchema{
+		addrs.NewDefaultProvider("test"): {
+			ResourceTypes: map[string]providers.Schema{
+				"test_resource": {
+					Block: &configschema.Block{
+						Attributes: map[string]*configschema.Attribute{
+							"id": {Type: cty.String, Computed: true},
+							"sensitive_value": {
+								Type:      cty.String,
+								Computed:  true,
+								Sensitive: true,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	changesSync := plans.NewChanges().SyncWrapper()
+	change := &plans.ResourceInstanceChange{
+		Addr: mustResourceInstanceAddr("test_resource.foo"),
+		ProviderAddr: addrs.AbsProviderConfig{
+			Module:   addrs.RootModule,
+			Provider: addrs.NewDefaultProvider("test"),
+		},
+		Change: plans.Change{
+			Action: plans.Update,
+			After: cty.ObjectVal(map[string]cty.Value{
+				"id":              cty.StringVal("foo"),
+				"sensitive_value": cty.StringVal("abc").Mark(marks.Sensitive),
+			}),
+		},
+	}
+	csrc, err := change.Encode(schemas[addrs.NewDefaultProvider("test")].ResourceTypes["test_resource"].Block.ImpliedType())
+	if err != nil {
+		t.Fatalf("unexpected error encoding the planned change: %s", err)
+	}
+	changesSync.AppendResourceInstanceChange(csrc)
+
+	config := &configs.Config{
+		Module: &configs.Module{
+			ManagedResources: map[string]*configs.Resource{
+				"test_resource.foo": {
+					Mode: addrs.ManagedResourceMode,
+					Type: "test_resource",
+					Name: "foo",
+					Provider: addrs.Provider{
+						Hostname:  addrs.DefaultProviderRegistryHost,
+						Namespace: "hashicorp",
+						Type:      "test",
+					},
+				},
+			},
+		},
+	}
+
+	plan := &plans.Plan{Changes: changesSync.Changes}
+
+	ro, err := NewReadOnlyEvaluator(config, nil, plan, schemas)
+	if err != nil {
+		t.Fatalf("unexpected error constructing the evaluator: %s", err)
+	}
+
+	got, diags := ro.Evaluate("test_resource.foo.sensitive_value", addrs.RootModuleInstance)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected error: %s", diags.Err())
+	}
+
+	want := cty.StringVal("abc").Mark(marks.Sensitive)
+	if !got.RawEquals(want) {
+		t.Errorf("wrong result:\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+// TestReadOnlyEvaluator_noConfig confirms NewReadOnlyEvaluator refuses to
+// build an evaluator with nothing to evaluate expressions against.
+func TestReadOnlyEvaluator_noConfig(t *testing.T) {
+	if _, err := NewReadOnlyEvaluator(nil, nil, nil, nil); err == nil {
+		t.Fatal("expected an error for a nil configuration")
+	}
+}
+
+// TestReadOnlySchemaProvider_refusesRPCs confirms that the stub provider
+// Evaluate's evaluator is built on refuses every real provider operation,
+// so that a future code path added to this package can't accidentally
+// start making provider calls from supposedly read-only evaluation without
+// a visible error.
+func TestReadOnlySchemaProvider_refusesRPCs(t *testing.T) {
+	p := &readOnlySchemaProvider{schema: providers.ProviderSchema{}}
+
+	if diags := p.ReadResource(providers.ReadResourceRequest{}).Diagnostics; !diags.HasErrors() {
+		t.Error("expected ReadResource to be refused")
+	}
+	if diags := p.PlanResourceChange(providers.PlanResourceChangeRequest{}).Diagnostics; !diags.HasErrors() {
+		t.Error("expected PlanResourceChange to be refused")
+	}
+	if diags := p.ReadDataSource(providers.ReadDataSourceRequest{}).Diagnostics; !diags.HasErrors() {
+		t.Error("expected ReadDataSource to be refused")
+	}
+}
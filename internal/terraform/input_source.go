@@ -0,0 +1,137 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// InputSource is a non-interactive alternative to UIInput: instead of
+// prompting a human for a missing provider argument, it looks the value up
+// from somewhere else, such as an environment variable or a file on disk.
+//
+// ResolveProviderInput consults one (typically an InputSourceChain) before
+// falling back to its prompt; the input graph walk does not call
+// ResolveProviderInput or consult any InputSource. A source that has no
+// opinion about a particular argument returns ok == false so that the next
+// source in a chain gets a chance to supply it.
+type InputSource interface {
+	Input(providerName, attrName string) (val cty.Value, ok bool)
+}
+
+// InputSourceChain consults a sequence of InputSource values in priority
+// order and reports the answer of the first source that returns ok ==
+// true, even if that answer is an empty string.
+//
+// Building the chain from most to least authoritative gives the
+// precedence rules for non-interactive provider input: CLI-supplied
+// variables are expected to be merged in ahead of an input file, which in
+// turn takes priority over environment variables. If every source in the
+// chain declines, Input returns ok == false.
+type InputSourceChain []InputSource
+
+// Input implements InputSource.
+func (c InputSourceChain) Input(providerName, attrName string) (cty.Value, bool) {
+	for _, source := range c {
+		if val, ok := source.Input(providerName, attrName); ok {
+			return val, true
+		}
+	}
+	return cty.NilVal, false
+}
+
+// EnvInputSource resolves provider arguments from environment variables
+// named TF_PROVIDER_<PROVIDER>_<ATTR>, with the provider and attribute
+// names upper-cased.
+type EnvInputSource struct {
+	// Lookup defaults to os.LookupEnv. Tests can override it to avoid
+	// depending on the real process environment.
+	Lookup func(key string) (string, bool)
+}
+
+// Input implements InputSource.
+func (s EnvInputSource) Input(providerName, attrName string) (cty.Value, bool) {
+	lookup := s.Lookup
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+
+	key := fmt.Sprintf("TF_PROVIDER_%s_%s", strings.ToUpper(providerName), strings.ToUpper(attrName))
+	raw, ok := lookup(key)
+	if !ok {
+		return cty.NilVal, false
+	}
+	return cty.StringVal(raw), true
+}
+
+// FileInputSource resolves provider arguments from the contents of an
+// -input-file argument, keyed in the same "provider.<name>.<attr>" form
+// that UIInput prompts use for their Id.
+type FileInputSource struct {
+	Values map[string]string
+}
+
+// Input implements InputSource.
+func (s FileInputSource) Input(providerName, attrName string) (cty.Value, bool) {
+	raw, ok := s.Values[fmt.Sprintf("provider.%s.%s", providerName, attrName)]
+	if !ok {
+		return cty.NilVal, false
+	}
+	return cty.StringVal(raw), true
+}
+
+// LoadInputFile reads the file at path and returns a FileInputSource
+// populated from it. Files named with a ".json" suffix, or whose contents
+// parse as valid JSON, are treated as a flat JSON object mapping
+// "provider.<name>.<attr>" keys to string values. Anything else is parsed
+// as native HCL syntax, the same way a .tfvars file would be, with each
+// top-level string attribute becoming an entry under its literal name.
+func LoadInputFile(path string) (FileInputSource, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return FileInputSource{}, fmt.Errorf("failed to read input file %s: %w", path, err)
+	}
+
+	if strings.HasSuffix(path, ".json") || json.Valid(raw) {
+		values := make(map[string]string)
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return FileInputSource{}, fmt.Errorf("invalid input file %s: %w", path, err)
+		}
+		return FileInputSource{Values: values}, nil
+	}
+
+	f, diags := hclsyntax.ParseConfig(raw, path, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return FileInputSource{}, diags
+	}
+	attrs, moreDiags := f.Body.JustAttributes()
+	diags = append(diags, moreDiags...)
+	if diags.HasErrors() {
+		return FileInputSource{}, diags
+	}
+
+	values := make(map[string]string, len(attrs))
+	for name, attr := range attrs {
+		val, valDiags := attr.Expr.Value(nil)
+		diags = append(diags, valDiags...)
+		if valDiags.HasErrors() {
+			continue
+		}
+		if val.Type() == cty.String && !val.IsNull() {
+			values[name] = val.AsString()
+		}
+	}
+	if diags.HasErrors() {
+		return FileInputSource{}, diags
+	}
+
+	return FileInputSource{Values: values}, nil
+}
@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestEnvInputSource(t *testing.T) {
+	env := map[string]string{
+		"TF_PROVIDER_AWS_FOO": "bar",
+	}
+	source := EnvInputSource{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+
+	got, ok := source.Input("aws", "foo")
+	if !ok {
+		t.Fatal("expected the env source to resolve \"foo\"")
+	}
+	if !got.RawEquals(cty.StringVal("bar")) {
+		t.Errorf("wrong value: got %#v", got)
+	}
+
+	if _, ok := source.Input("aws", "missing"); ok {
+		t.Error("expected the env source to decline an attribute with no matching variable")
+	}
+}
+
+func TestLoadInputFile_json(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.json")
+	if err := os.WriteFile(path, []byte(`{"provider.aws.foo": "from-file"}`), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %s", path, err)
+	}
+
+	source, err := LoadInputFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, ok := source.Input("aws", "foo")
+	if !ok {
+		t.Fatal("expected the file source to resolve \"foo\"")
+	}
+	if !got.RawEquals(cty.StringVal("from-file")) {
+		t.Errorf("wrong value: got %#v", got)
+	}
+}
+
+func TestLoadInputFile_hcl(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.tfvars")
+	if err := os.WriteFile(path, []byte(`"provider.aws.foo" = "from-hcl"`), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %s", path, err)
+	}
+
+	source, err := LoadInputFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, ok := source.Input("aws", "foo")
+	if !ok {
+		t.Fatal("expected the file source to resolve \"foo\"")
+	}
+	if !got.RawEquals(cty.StringVal("from-hcl")) {
+		t.Errorf("wrong value: got %#v", got)
+	}
+}
+
+func TestInputSourceChain_precedence(t *testing.T) {
+	chain := InputSourceChain{
+		FileInputSource{Values: map[string]string{
+			"provider.aws.foo": "from-file",
+		}},
+		EnvInputSource{
+			Lookup: func(key string) (string, bool) {
+				if key == "TF_PROVIDER_AWS_FOO" {
+					return "from-env", true
+				}
+				return "", false
+			},
+		},
+	}
+
+	got, ok := chain.Input("aws", "foo")
+	if !ok {
+		t.Fatal("expected the chain to resolve \"foo\"")
+	}
+	if !got.RawEquals(cty.StringVal("from-file")) {
+		t.Errorf("wrong value: got %#v, want the file source to take precedence over the env source", got)
+	}
+
+	if _, ok := chain.Input("aws", "bar"); ok {
+		t.Error("expected the chain to decline an attribute no source supplies")
+	}
+}
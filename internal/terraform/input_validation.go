@@ -0,0 +1,169 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package terraform
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp/terraform/internal/tfdiags"
+)
+
+// AttributeValidator checks a raw string typed at a prompt before it's
+// coerced and handed to the provider, mirroring the role that
+// InputOpts.Validate plays in the wider input subsystem: it returns
+// diagnostics describing why the value was rejected, or none at all if
+// the value is acceptable.
+type AttributeValidator func(raw string) tfdiags.Diagnostics
+
+// RegexAttributeValidator rejects any value that doesn't match re,
+// reporting message as the reason.
+func RegexAttributeValidator(re *regexp.Regexp, message string) AttributeValidator {
+	return func(raw string) tfdiags.Diagnostics {
+		var diags tfdiags.Diagnostics
+		if !re.MatchString(raw) {
+			diags = diags.Append(tfdiags.Sourceless(tfdiags.Error, "Invalid value", message))
+		}
+		return diags
+	}
+}
+
+// EnumAttributeValidator rejects any value not present in allowed.
+func EnumAttributeValidator(allowed []string) AttributeValidator {
+	return func(raw string) tfdiags.Diagnostics {
+		var diags tfdiags.Diagnostics
+		for _, v := range allowed {
+			if raw == v {
+				return diags
+			}
+		}
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Invalid value",
+			fmt.Sprintf("Must be one of: %s.", strings.Join(allowed, ", ")),
+		))
+		return diags
+	}
+}
+
+// LengthAttributeValidator rejects values shorter than min or, when max is
+// greater than zero, longer than max runes.
+func LengthAttributeValidator(min, max int) AttributeValidator {
+	return func(raw string) tfdiags.Diagnostics {
+		var diags tfdiags.Diagnostics
+		n := len([]rune(raw))
+		switch {
+		case n < min:
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Invalid value",
+				fmt.Sprintf("Must be at least %d characters.", min),
+			))
+		case max > 0 && n > max:
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Invalid value",
+				fmt.Sprintf("Must be at most %d characters.", max),
+			))
+		}
+		return diags
+	}
+}
+
+// CoerceInputValue parses the raw string a prompt (or an InputSource)
+// produced into the cty type the provider's schema actually expects, so
+// that numeric and boolean attributes don't reach the provider as
+// unconverted strings.
+func CoerceInputValue(raw string, ty cty.Type) (cty.Value, error) {
+	switch {
+	case ty == cty.String:
+		return cty.StringVal(raw), nil
+	case ty == cty.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("invalid boolean value %q", raw)
+		}
+		return cty.BoolVal(b), nil
+	case ty == cty.Number:
+		n, err := cty.ParseNumberVal(raw)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("invalid number value %q", raw)
+		}
+		return n, nil
+	default:
+		return cty.NilVal, fmt.Errorf("cannot accept interactive input for type %s", ty.FriendlyName())
+	}
+}
+
+// UIInputFunc models the single prompting operation that UIInput.Input
+// performs: present a description to the user and return what they typed.
+type UIInputFunc func(description string) (string, error)
+
+// ResolveProviderInput determines the value to use for one provider
+// argument. It first gives source (typically an InputSourceChain) a
+// chance to answer non-interactively; only once every source has
+// declined does it fall back to prompt, re-asking -- and surfacing
+// validate's diagnostics -- until a response passes validation and
+// coercion, prompt returns an error (for example because the caller
+// cancelled), or maxPrompts attempts have been exhausted.
+func ResolveProviderInput(
+	source InputSource,
+	providerName, attrName string,
+	ty cty.Type,
+	validate AttributeValidator,
+	description string,
+	prompt UIInputFunc,
+	maxPrompts int,
+) (cty.Value, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	if source != nil {
+		if val, ok := source.Input(providerName, attrName); ok {
+			return val, diags
+		}
+	}
+
+	if prompt == nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Missing required argument",
+			fmt.Sprintf("No value was supplied for %q on provider %q, and no interactive prompt is available to ask for one.", attrName, providerName),
+		))
+		return cty.NilVal, diags
+	}
+
+	for attempt := 0; maxPrompts <= 0 || attempt < maxPrompts; attempt++ {
+		raw, err := prompt(description)
+		if err != nil {
+			diags = diags.Append(tfdiags.Sourceless(tfdiags.Error, "Could not read input", err.Error()))
+			return cty.NilVal, diags
+		}
+
+		if validate != nil {
+			if valDiags := validate(raw); valDiags.HasErrors() {
+				diags = diags.Append(valDiags)
+				continue
+			}
+		}
+
+		val, err := CoerceInputValue(raw, ty)
+		if err != nil {
+			diags = diags.Append(tfdiags.Sourceless(tfdiags.Error, "Invalid value", err.Error()))
+			continue
+		}
+
+		return val, nil
+	}
+
+	diags = diags.Append(tfdiags.Sourceless(
+		tfdiags.Error,
+		"Too many invalid responses",
+		fmt.Sprintf("Gave up asking for %q on provider %q after %d attempts.", attrName, providerName, maxPrompts),
+	))
+	return cty.NilVal, diags
+}
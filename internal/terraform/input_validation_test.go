@@ -0,0 +1,127 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package terraform
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestCoerceInputValue(t *testing.T) {
+	tests := []struct {
+		raw  string
+		ty   cty.Type
+		want cty.Value
+	}{
+		{"hello", cty.String, cty.StringVal("hello")},
+		{"true", cty.Bool, cty.True},
+		{"42", cty.Number, cty.NumberIntVal(42)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.raw, func(t *testing.T) {
+			got, err := CoerceInputValue(test.raw, test.ty)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !got.RawEquals(test.want) {
+				t.Errorf("wrong result: got %#v, want %#v", got, test.want)
+			}
+		})
+	}
+
+	if _, err := CoerceInputValue("nope", cty.Number); err == nil {
+		t.Error("expected an error for a non-numeric value")
+	}
+}
+
+func TestResolveProviderInput_fromSource(t *testing.T) {
+	source := FileInputSource{Values: map[string]string{
+		"provider.aws.foo": "bar",
+	}}
+
+	got, diags := ResolveProviderInput(source, "aws", "foo", cty.String, nil, "", nil, 1)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected error: %s", diags.Err())
+	}
+	if !got.RawEquals(cty.StringVal("bar")) {
+		t.Errorf("wrong value: got %#v", got)
+	}
+}
+
+func TestResolveProviderInput_retriesUntilValid(t *testing.T) {
+	responses := []string{"not-an-int", "42"}
+	var prompted int
+
+	prompt := func(description string) (string, error) {
+		resp := responses[prompted]
+		prompted++
+		return resp, nil
+	}
+
+	validate := RegexAttributeValidator(regexp.MustCompile(`^\d+$`), "Must be a whole number.")
+
+	got, diags := ResolveProviderInput(nil, "aws", "count", cty.Number, validate, "how many?", prompt, 2)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected error: %s", diags.Err())
+	}
+	if !got.RawEquals(cty.NumberIntVal(42)) {
+		t.Errorf("wrong value: got %#v", got)
+	}
+	if prompted != 2 {
+		t.Errorf("wrong number of prompts: got %d, want 2", prompted)
+	}
+}
+
+func TestResolveProviderInput_givesUpAfterMaxPrompts(t *testing.T) {
+	prompt := func(description string) (string, error) {
+		return "still-invalid", nil
+	}
+	validate := EnumAttributeValidator([]string{"a", "b"})
+
+	_, diags := ResolveProviderInput(nil, "aws", "mode", cty.String, validate, "pick one", prompt, 2)
+	if !diags.HasErrors() {
+		t.Fatal("expected an error after exhausting all prompts")
+	}
+}
+
+func TestResolveProviderInput_noSourceNoPrompt(t *testing.T) {
+	_, diags := ResolveProviderInput(nil, "aws", "foo", cty.String, nil, "", nil, 1)
+	if !diags.HasErrors() {
+		t.Fatal("expected an error when there is no source and no way to prompt")
+	}
+}
+
+func TestEnumAttributeValidator(t *testing.T) {
+	validate := EnumAttributeValidator([]string{"a", "b"})
+
+	if diags := validate("a"); diags.HasErrors() {
+		t.Errorf("unexpected error for an allowed value: %s", diags.Err())
+	}
+	if diags := validate("c"); !diags.HasErrors() {
+		t.Error("expected an error for a disallowed value")
+	}
+}
+
+func TestLengthAttributeValidator(t *testing.T) {
+	validate := LengthAttributeValidator(2, 4)
+
+	for _, tc := range []struct {
+		raw     string
+		wantErr bool
+	}{
+		{"a", true},
+		{"ab", false},
+		{"abcd", false},
+		{"abcde", true},
+	} {
+		diags := validate(tc.raw)
+		if diags.HasErrors() != tc.wantErr {
+			t.Errorf("%s: got error=%v (%s), want error=%v", tc.raw, diags.HasErrors(), fmt.Sprint(diags.Err()), tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package terraform
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// marksEqual compares two sets of cty.PathValueMarks for equality, ignoring
+// the order in which they were collected. This is used when comparing two
+// recorded sets of marks -- for example, the marks captured before and after
+// a plan -- where we don't want incidental differences in traversal order
+// to be mistaken for an actual change in what's marked.
+func marksEqual(a, b []cty.PathValueMarks) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	less := func(s []cty.PathValueMarks) func(i, j int) bool {
+		return func(i, j int) bool {
+			return s[i].Path.String() < s[j].Path.String()
+		}
+	}
+	sortedA := append([]cty.PathValueMarks(nil), a...)
+	sortedB := append([]cty.PathValueMarks(nil), b...)
+	sort.SliceStable(sortedA, less(sortedA))
+	sort.SliceStable(sortedB, less(sortedB))
+
+	for i := range sortedA {
+		if !sortedA[i].Path.Equals(sortedB[i].Path) {
+			return false
+		}
+		if !marksSetEqual(sortedA[i].Marks, sortedB[i].Marks) {
+			return false
+		}
+	}
+	return true
+}
+
+// marksSetEqual compares two cty.ValueMarks sets for equality.
+//
+// A naive comparison of the raw mark values would fail to recognize two
+// structurally-equivalent but distinct instances of a typed mark -- such as
+// the marks.contextSensitive struct used for "sensitive because of X"
+// annotations -- as equal, even though they carry the same meaning. To
+// avoid that, we first run each mark through canonicalizeMark, which maps
+// each mark value to a comparable representative, and compare those
+// representatives instead of the raw marks.
+func marksSetEqual(a, b cty.ValueMarks) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	canonA := make(map[interface{}]struct{}, len(a))
+	for m := range a {
+		canonA[canonicalizeMark(m)] = struct{}{}
+	}
+	for m := range b {
+		if _, ok := canonA[canonicalizeMark(m)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// canonicalizeMark maps a raw mark value -- which might be a simple string
+// like "sensitive", or a richer struct/pointer type used by a more
+// structured mark -- to a value that's safe to use as a Go map key and
+// that compares equal across distinct instances carrying equivalent
+// meaning.
+//
+// Marks that already satisfy Go's comparable-and-meaningful equality (plain
+// strings, and other marks whose zero-overhead comparison already reflects
+// their meaning) are returned unchanged. Marks that implement
+// fmt.Stringer are canonicalized to their string form, since for our
+// structured marks today the string form is already a complete description
+// of the mark's meaning. This keeps marksSetEqual correct as more structured
+// mark types are introduced without requiring every caller of marksEqual to
+// know about each one.
+func canonicalizeMark(m interface{}) interface{} {
+	switch m := m.(type) {
+	case string:
+		return m
+	case fmt.Stringer:
+		return m.String()
+	default:
+		return m
+	}
+}
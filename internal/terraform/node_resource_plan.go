@@ -14,6 +14,7 @@ import (
 	"github.com/hashicorp/terraform/internal/dag"
 	"github.com/hashicorp/terraform/internal/states"
 	"github.com/hashicorp/terraform/internal/tfdiags"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // nodeExpandPlannableResource represents an addrs.ConfigResource and implements
@@ -42,6 +43,17 @@ type nodeExpandPlannableResource struct {
 	// that this node represents, which the node itself must therefore ignore.
 	forceReplace []addrs.AbsResourceInstance
 
+	// importPreviewOnly is set when the overall plan was requested in
+	// "import preview" mode, where Terraform reports what importing each of
+	// the addresses in "imports" would look like -- including any
+	// diagnostics about drift between the imported state and n.Config --
+	// without actually writing the imported instances into the plan's
+	// state or changes. This lets users dry-run a large set of import
+	// blocks and see per-address success or failure before committing to
+	// it, rather than the usual all-or-nothing behavior where one bad ID
+	// aborts the whole plan.
+	importPreviewOnly bool
+
 	// We attach dependencies to the Resource during refresh, since the
 	// instances are instantiated during DynamicExpand.
 	// FIXME: These would be better off converted to a generic Set data
@@ -113,9 +125,12 @@ func (n *nodeExpandPlannableResource) DynamicExpand(ctx EvalContext) (*Graph, tf
 	expander := ctx.InstanceExpander()
 	moduleInstances := expander.ExpandModule(n.Addr.Module, false)
 
-	// Expand the imports for this resource.
-	// TODO: Add support for unknown instances in import blocks.
-	imports, importDiags := n.expandResourceImports(ctx)
+	// Expand the imports for this resource. If one of the import blocks'
+	// for_each collections turns out to be unknown, expandResourceImports
+	// reports that back via deferredImports rather than failing outright,
+	// as long as the deferrals subsystem says that's acceptable for this
+	// plan.
+	imports, deferredImports, importDiags := n.expandResourceImports(ctx)
 	diags = diags.Append(importDiags)
 
 	// The possibility of partial-expanded modules and resources is guarded by a
@@ -124,7 +139,12 @@ func (n *nodeExpandPlannableResource) DynamicExpand(ctx EvalContext) (*Graph, tf
 	// entirely-separate codepath in those situations, at the expense of
 	// duplicating some of the logic for behavior this method would normally
 	// handle.
-	if ctx.Deferrals().DeferralAllowed() {
+	//
+	// A resource whose import blocks have an unknown for_each is handled
+	// via this same partial-expansion codepath even if its own module and
+	// resource instances are all fully known, since we can't yet produce
+	// concrete addresses for the deferred imports either.
+	if ctx.Deferrals().DeferralAllowed() || deferredImports {
 		pem := expander.UnknownModuleInstances(n.Addr.Module, false)
 		g, expandDiags := n.dynamicExpandPartial(ctx, moduleInstances, pem, imports)
 		diags = diags.Append(expandDiags)
@@ -136,14 +156,22 @@ func (n *nodeExpandPlannableResource) DynamicExpand(ctx EvalContext) (*Graph, tf
 	return g, diags
 }
 
-// Import blocks are expanded in conjunction with their associated resource block.
-func (n *nodeExpandPlannableResource) expandResourceImports(ctx EvalContext) (addrs.Map[addrs.AbsResourceInstance, string], tfdiags.Diagnostics) {
+// Import blocks are expanded in conjunction with their associated resource
+// block.
+//
+// The second return value reports whether any of this resource's import
+// blocks had to be deferred because their for_each collection was unknown;
+// when true, the caller should route expansion through the partial/deferred
+// DynamicExpand codepath so that the deferred imports get a chance to
+// become concrete in a later plan round rather than failing the whole plan.
+func (n *nodeExpandPlannableResource) expandResourceImports(ctx EvalContext) (addrs.Map[addrs.AbsResourceInstance, string], bool, tfdiags.Diagnostics) {
 	// Imports maps the target address to an import ID.
 	imports := addrs.MakeMap[addrs.AbsResourceInstance, string]()
 	var diags tfdiags.Diagnostics
+	deferred := false
 
 	if len(n.importTargets) == 0 {
-		return imports, diags
+		return imports, deferred, diags
 	}
 
 	// Import blocks are only valid within the root module, and must be
@@ -156,7 +184,7 @@ func (n *nodeExpandPlannableResource) expandResourceImports(ctx EvalContext) (ad
 			// there is nothing to expand
 			if !imp.LegacyAddr.Equal(addrs.AbsResourceInstance{}) {
 				imports.Put(imp.LegacyAddr, imp.IDString)
-				return imports, diags
+				return imports, deferred, diags
 			}
 
 			// legacy import tests may have no configuration
@@ -170,13 +198,13 @@ func (n *nodeExpandPlannableResource) expandResourceImports(ctx EvalContext) (ad
 			to, tds := addrs.ParseAbsResourceInstance(traversal)
 			diags = diags.Append(tds)
 			if diags.HasErrors() {
-				return imports, diags
+				return imports, deferred, diags
 			}
 
 			importID, evalDiags := evaluateImportIdExpression(imp.Config.ID, to, ctx, EvalDataForNoInstanceKey)
 			diags = diags.Append(evalDiags)
 			if diags.HasErrors() {
-				return imports, diags
+				return imports, deferred, diags
 			}
 
 			imports.Put(to, importID)
@@ -185,23 +213,48 @@ func (n *nodeExpandPlannableResource) expandResourceImports(ctx EvalContext) (ad
 			continue
 		}
 
+		// Before asking the for_each evaluator to expand this import block
+		// into concrete targets, check whether its for_each collection is
+		// even known yet. If it isn't, and the deferrals subsystem allows
+		// it for this plan, we record this import block as deferred rather
+		// than letting ImportValues fail below.
+		forEachVal, forEachValDiags := ctx.EvaluateExpr(imp.Config.ForEach, cty.DynamicPseudoType, nil)
+		diags = diags.Append(forEachValDiags)
+		if forEachValDiags.HasErrors() {
+			return imports, deferred, diags
+		}
+		if !forEachVal.IsWhollyKnown() {
+			if !ctx.Deferrals().DeferralAllowed() {
+				diags = diags.Append(&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid import for_each argument",
+					Detail:   "The for_each value depends on values that won't be known until apply, so Terraform cannot expand this import block's target addresses in this plan.",
+					Subject:  imp.Config.ForEach.Range().Ptr(),
+				})
+				return imports, deferred, diags
+			}
+			deferred = true
+			log.Printf("[DEBUG] expandResourceImports: deferring import block for %s; for_each is not yet known", n.Addr)
+			continue
+		}
+
 		forEachData, forEachDiags := newForEachEvaluator(imp.Config.ForEach, ctx, false).ImportValues()
 		diags = diags.Append(forEachDiags)
 		if forEachDiags.HasErrors() {
-			return imports, diags
+			return imports, deferred, diags
 		}
 
 		for _, keyData := range forEachData {
 			res, evalDiags := evalImportToExpression(imp.Config.To, keyData)
 			diags = diags.Append(evalDiags)
 			if diags.HasErrors() {
-				return imports, diags
+				return imports, deferred, diags
 			}
 
 			importID, evalDiags := evaluateImportIdExpression(imp.Config.ID, res, ctx, keyData)
 			diags = diags.Append(evalDiags)
 			if diags.HasErrors() {
-				return imports, diags
+				return imports, deferred, diags
 			}
 
 			imports.Put(res, importID)
@@ -218,7 +271,7 @@ func (n *nodeExpandPlannableResource) expandResourceImports(ctx EvalContext) (ad
 		}
 	}
 
-	return imports, diags
+	return imports, deferred, diags
 }
 
 // validateExpandedImportTargets checks that all expanded imports correspond to
@@ -406,20 +459,27 @@ func (n *nodeExpandPlannableResource) resourceInstanceSubgraph(ctx EvalContext,
 	steps := []GraphTransformer{
 		// Expand the count or for_each (if present)
 		&ResourceCountTransformer{
-			Concrete:      n.concreteResource(imports, n.skipPlanChanges),
+			Concrete:      n.concreteResource(imports, n.skipPlanChanges, n.importPreviewOnly),
 			Schema:        n.Schema,
 			Addr:          n.ResourceAddr(),
 			InstanceAddrs: instanceAddrs,
 		},
+	}
 
-		// Add the count/for_each orphans
-		&OrphanResourceInstanceCountTransformer{
+	if !n.importPreviewOnly {
+		// Add the count/for_each orphans. We skip this entirely in import
+		// preview mode, since that mode only ever considers the addresses
+		// named by "imports" and must not treat any other absent instance
+		// as something to delete.
+		steps = append(steps, &OrphanResourceInstanceCountTransformer{
 			Concrete:      n.concreteResourceOrphan,
 			Addr:          addr,
 			InstanceAddrs: instanceAddrs,
 			State:         state,
-		},
+		})
+	}
 
+	steps = append(steps,
 		// Attach the state
 		&AttachStateTransformer{State: state},
 
@@ -431,7 +491,7 @@ func (n *nodeExpandPlannableResource) resourceInstanceSubgraph(ctx EvalContext,
 
 		// Make sure there is a single root
 		&RootTransformer{},
-	}
+	)
 
 	// Build the graph
 	b := &BasicGraphBuilder{
@@ -444,7 +504,7 @@ func (n *nodeExpandPlannableResource) resourceInstanceSubgraph(ctx EvalContext,
 	return graph, diags
 }
 
-func (n *nodeExpandPlannableResource) concreteResource(imports addrs.Map[addrs.AbsResourceInstance, string], skipPlanChanges bool) func(*NodeAbstractResourceInstance) dag.Vertex {
+func (n *nodeExpandPlannableResource) concreteResource(imports addrs.Map[addrs.AbsResourceInstance, string], skipPlanChanges bool, importPreviewOnly bool) func(*NodeAbstractResourceInstance) dag.Vertex {
 	return func(a *NodeAbstractResourceInstance) dag.Vertex {
 		var m *NodePlannableResourceInstance
 
@@ -481,6 +541,7 @@ func (n *nodeExpandPlannableResource) concreteResource(imports addrs.Map[addrs.A
 			skipRefresh:              n.skipRefresh,
 			skipPlanChanges:          skipPlanChanges,
 			forceReplace:             n.forceReplace,
+			importPreviewOnly:        importPreviewOnly,
 		}
 
 		importID, ok := imports.GetOk(a.Addr)
@@ -0,0 +1,248 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+// Package sensitivity computes a structured, JSON-serializable report of
+// where sensitive values live in a configuration, state, and plan, so that
+// external tools that consume Terraform's on-disk artifacts (state and
+// plan files) directly -- rather than going through the Evaluator the way
+// Terraform itself does -- don't have to reverse-engineer sensitivity from
+// cty marks.
+//
+// The merge logic here intentionally mirrors evaluationStateData.GetResource:
+// a sensitive mark can come from the provider's schema, from marks recorded
+// against a specific value in state (AttrSensitivePaths), or from marks
+// recorded against a planned change's After value (AfterValMarks). Keeping
+// this package's merge in lock-step with GetResource is the whole point --
+// a report produced here is guaranteed to describe the same marks GetResource
+// would apply at evaluation time.
+package sensitivity
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp/terraform/internal/configs/configschema"
+)
+
+// Reason identifies which part of the evaluator's merge logic produced a
+// particular sensitive path.
+type Reason string
+
+const (
+	// ReasonSchema means the provider's schema marks this attribute (or an
+	// attribute nested inside it) as sensitive unconditionally.
+	ReasonSchema Reason = "schema"
+
+	// ReasonConfigVariable means a root or module input variable was
+	// declared `sensitive = true` in configuration.
+	ReasonConfigVariable Reason = "config_variable"
+
+	// ReasonConfigOutput means an output value was declared
+	// `sensitive = true` in configuration.
+	ReasonConfigOutput Reason = "config_output"
+
+	// ReasonPlanAfterMarks means the mark was recorded against the After
+	// value of a planned resource instance change (ResourceInstanceChange.
+	// AfterValMarks), rather than against the prior state.
+	ReasonPlanAfterMarks Reason = "plan_after_marks"
+
+	// ReasonStateMarks means the mark was recorded against a resource
+	// instance's current object in state (ResourceInstanceObjectSrc.
+	// AttrSensitivePaths).
+	ReasonStateMarks Reason = "state_marks"
+)
+
+// Entry describes one sensitive value: the address of the object it
+// belongs to (a resource instance, output, or variable address in its
+// usual string form), the cty.Path locating the value within that object,
+// and the Reason the merge considered it sensitive.
+type Entry struct {
+	Address string
+	Path    cty.Path
+	Reason  Reason
+}
+
+// entryJSON is Entry's wire format. cty.Path doesn't implement
+// json.Marshaler, so we lower it to the same lossy array-of-steps encoding
+// jsonplan uses for replace_paths: each step becomes either a JSON string
+// (an attribute name, or a map/object index) or a JSON number (a list/tuple
+// index). As there, this can't distinguish a GetAttrStep from an IndexStep
+// with a string key, but the two are indistinguishable in JSON anyway.
+type entryJSON struct {
+	Address string            `json:"address"`
+	Path    []json.RawMessage `json:"path"`
+	Reason  Reason            `json:"reason"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (e Entry) MarshalJSON() ([]byte, error) {
+	steps := make([]json.RawMessage, 0, len(e.Path))
+	for _, step := range e.Path {
+		switch s := step.(type) {
+		case cty.GetAttrStep:
+			name, err := json.Marshal(s.Name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal attribute step %q: %w", s.Name, err)
+			}
+			steps = append(steps, name)
+		case cty.IndexStep:
+			switch s.Key.Type() {
+			case cty.String:
+				key, err := json.Marshal(s.Key.AsString())
+				if err != nil {
+					return nil, fmt.Errorf("failed to marshal index step %#v: %w", s.Key, err)
+				}
+				steps = append(steps, key)
+			case cty.Number:
+				bf := s.Key.AsBigFloat()
+				idx, _ := bf.Int64()
+				key, err := json.Marshal(idx)
+				if err != nil {
+					return nil, fmt.Errorf("failed to marshal index step %#v: %w", s.Key, err)
+				}
+				steps = append(steps, key)
+			default:
+				return nil, fmt.Errorf("unsupported index step key type %s", s.Key.Type().FriendlyName())
+			}
+		default:
+			return nil, fmt.Errorf("unsupported path step %#v (%T)", step, step)
+		}
+	}
+	return json.Marshal(entryJSON{Address: e.Address, Path: steps, Reason: e.Reason})
+}
+
+// Map is a flat, de-duplicated report of every sensitive path discovered
+// across whatever objects were walked to build it. It marshals directly to
+// JSON as an array of Entry values.
+type Map []Entry
+
+// add appends an entry for each path in paths under the given address and
+// reason, skipping any path already present in m so that marks recorded by
+// more than one source (for example, an attribute that is both marked
+// sensitive in the schema and recorded in AttrSensitivePaths) are reported
+// only once, attributed to whichever source was consulted first.
+func (m Map) add(address string, reason Reason, paths []cty.Path) Map {
+	for _, path := range paths {
+		if m.has(address, path) {
+			continue
+		}
+		m = append(m, Entry{Address: address, Path: path, Reason: reason})
+	}
+	return m
+}
+
+func (m Map) has(address string, path cty.Path) bool {
+	for _, e := range m {
+		if e.Address == address && e.Path.Equals(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// ForResourceInstance computes the sensitive paths for a single resource
+// instance, merging the same three sources GetResource does and in the
+// same order of precedence: schema-derived marks first, then any marks
+// explicitly recorded in state, then (if the instance has a pending
+// change) marks recorded against the change's After value.
+//
+// value is the resource instance's current cty.Value -- the decoded state
+// object, or the change's After value when afterValMarks is non-nil -- and
+// is used only to know which collection elements actually exist, since a
+// schema's sensitive attributes can be nested arbitrarily deep inside
+// lists, maps, sets, and nested blocks.
+func ForResourceInstance(address string, schema *configschema.Block, value cty.Value, attrSensitivePaths, afterValMarks []cty.PathValueMarks) Map {
+	var m Map
+	m = m.add(address, ReasonSchema, schemaSensitivePaths(schema, value, nil))
+	m = m.add(address, ReasonStateMarks, pathsOf(attrSensitivePaths))
+	m = m.add(address, ReasonPlanAfterMarks, pathsOf(afterValMarks))
+	return m
+}
+
+func pathsOf(pvm []cty.PathValueMarks) []cty.Path {
+	paths := make([]cty.Path, len(pvm))
+	for i, p := range pvm {
+		paths[i] = p.Path
+	}
+	return paths
+}
+
+// schemaSensitivePaths walks schema alongside value and returns the path to
+// every attribute the schema marks sensitive.
+//
+// Nested blocks are walked per their nesting mode: list and map blocks
+// produce one path per element, since each element can be addressed
+// individually by index or key, but set blocks produce a single path to
+// the set attribute as a whole -- cty has no way to address an individual
+// element of a set by path, so marking the whole collection is the only
+// option, matching what the evaluator does when it builds AttrSensitivePaths
+// for a set-nested sensitive attribute.
+func schemaSensitivePaths(schema *configschema.Block, value cty.Value, path cty.Path) []cty.Path {
+	if schema == nil || value.IsNull() || !value.IsKnown() {
+		return nil
+	}
+
+	var paths []cty.Path
+
+	for name, attrS := range schema.Attributes {
+		if attrS.Sensitive {
+			paths = append(paths, append(copyPath(path), cty.GetAttr(name)))
+		}
+	}
+
+	for name, blockS := range schema.BlockTypes {
+		if !value.Type().HasAttribute(name) {
+			continue
+		}
+		attrPath := append(copyPath(path), cty.GetAttr(name))
+		blockVal := value.GetAttr(name)
+		if blockVal.IsNull() || !blockVal.IsKnown() {
+			continue
+		}
+
+		switch blockS.Nesting {
+		case configschema.NestingSingle, configschema.NestingGroup:
+			paths = append(paths, schemaSensitivePaths(&blockS.Block, blockVal, attrPath)...)
+		case configschema.NestingList:
+			for i := 0; i < blockVal.LengthInt(); i++ {
+				paths = append(paths, schemaSensitivePaths(&blockS.Block, blockVal.Index(cty.NumberIntVal(int64(i))), append(copyPath(attrPath), cty.IndexInt(i)))...)
+			}
+		case configschema.NestingMap:
+			for it := blockVal.ElementIterator(); it.Next(); {
+				k, v := it.Element()
+				paths = append(paths, schemaSensitivePaths(&blockS.Block, v, append(copyPath(attrPath), cty.IndexString(k.AsString())))...)
+			}
+		case configschema.NestingSet:
+			if hasSensitiveSchema(&blockS.Block) {
+				paths = append(paths, attrPath)
+			}
+		}
+	}
+
+	return paths
+}
+
+// hasSensitiveSchema reports whether schema marks any attribute -- at any
+// nesting depth -- sensitive, without regard to a specific value. It backs
+// the NestingSet case above, where individual elements can't be addressed
+// by path, so the presence of any sensitive attribute anywhere inside the
+// set's schema is enough to mark the whole collection.
+func hasSensitiveSchema(schema *configschema.Block) bool {
+	for _, attrS := range schema.Attributes {
+		if attrS.Sensitive {
+			return true
+		}
+	}
+	for _, blockS := range schema.BlockTypes {
+		if hasSensitiveSchema(&blockS.Block) {
+			return true
+		}
+	}
+	return false
+}
+
+func copyPath(path cty.Path) cty.Path {
+	return append(cty.Path(nil), path...)
+}
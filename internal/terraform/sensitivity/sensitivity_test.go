@@ -0,0 +1,282 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package sensitivity
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp/terraform/internal/configs/configschema"
+)
+
+// schema mirrors the one built in TestEvaluatorGetResource: a mix of a
+// plain sensitive attribute, and sensitive attributes nested inside list,
+// map, set, single, and doubly-nested blocks.
+func testSchema() *configschema.Block {
+	return &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"id": {
+				Type:     cty.String,
+				Computed: true,
+			},
+			"value": {
+				Type:      cty.String,
+				Computed:  true,
+				Sensitive: true,
+			},
+		},
+		BlockTypes: map[string]*configschema.NestedBlock{
+			"nesting_list": {
+				Block: configschema.Block{
+					Attributes: map[string]*configschema.Attribute{
+						"value":           {Type: cty.String, Optional: true},
+						"sensitive_value": {Type: cty.String, Optional: true, Sensitive: true},
+					},
+				},
+				Nesting: configschema.NestingList,
+			},
+			"nesting_map": {
+				Block: configschema.Block{
+					Attributes: map[string]*configschema.Attribute{
+						"foo": {Type: cty.String, Optional: true, Sensitive: true},
+					},
+				},
+				Nesting: configschema.NestingMap,
+			},
+			"nesting_set": {
+				Block: configschema.Block{
+					Attributes: map[string]*configschema.Attribute{
+						"baz": {Type: cty.String, Optional: true, Sensitive: true},
+					},
+				},
+				Nesting: configschema.NestingSet,
+			},
+			"nesting_single": {
+				Block: configschema.Block{
+					Attributes: map[string]*configschema.Attribute{
+						"boop": {Type: cty.String, Optional: true, Sensitive: true},
+					},
+				},
+				Nesting: configschema.NestingSingle,
+			},
+			"nesting_nesting": {
+				Block: configschema.Block{
+					BlockTypes: map[string]*configschema.NestedBlock{
+						"nesting_list": {
+							Block: configschema.Block{
+								Attributes: map[string]*configschema.Attribute{
+									"value":           {Type: cty.String, Optional: true},
+									"sensitive_value": {Type: cty.String, Optional: true, Sensitive: true},
+								},
+							},
+							Nesting: configschema.NestingList,
+						},
+					},
+				},
+				Nesting: configschema.NestingSingle,
+			},
+		},
+	}
+}
+
+func testValue() cty.Value {
+	return cty.ObjectVal(map[string]cty.Value{
+		"id":    cty.StringVal("foo"),
+		"value": cty.StringVal("hello"),
+		"nesting_list": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{
+				"sensitive_value": cty.StringVal("abc"),
+				"value":           cty.NullVal(cty.String),
+			}),
+		}),
+		"nesting_map": cty.MapVal(map[string]cty.Value{
+			"foo": cty.ObjectVal(map[string]cty.Value{"foo": cty.StringVal("x")}),
+		}),
+		"nesting_set": cty.SetVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"baz": cty.StringVal("abc")}),
+		}),
+		"nesting_single": cty.ObjectVal(map[string]cty.Value{
+			"boop": cty.StringVal("abc"),
+		}),
+		"nesting_nesting": cty.ObjectVal(map[string]cty.Value{
+			"nesting_list": cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"sensitive_value": cty.StringVal("abc"),
+					"value":           cty.NullVal(cty.String),
+				}),
+			}),
+		}),
+	})
+}
+
+func pathStrings(m Map) []string {
+	got := make([]string, len(m))
+	for i, e := range m {
+		got[i] = e.Path.String()
+	}
+	sort.Strings(got)
+	return got
+}
+
+// TestForResourceInstance_schema covers the same nested-block shapes as
+// TestEvaluatorGetResource: list, map, set, single, and doubly-nested
+// blocks, each with a sensitive attribute. The expected paths are exactly
+// the AttrSensitivePaths that test records in state, confirming this
+// package's schema walk agrees with what the evaluator itself captures.
+func TestForResourceInstance_schema(t *testing.T) {
+	schema := testSchema()
+	value := testValue()
+
+	got := ForResourceInstance("test_resource.foo", schema, value, nil, nil)
+
+	want := []string{
+		cty.GetAttrPath("nesting_list").IndexInt(0).GetAttr("sensitive_value").String(),
+		cty.GetAttrPath("nesting_map").IndexString("foo").GetAttr("foo").String(),
+		cty.GetAttrPath("nesting_nesting").GetAttr("nesting_list").IndexInt(0).GetAttr("sensitive_value").String(),
+		cty.GetAttrPath("nesting_set").String(),
+		cty.GetAttrPath("nesting_single").GetAttr("boop").String(),
+		cty.GetAttrPath("value").String(),
+	}
+	sort.Strings(want)
+
+	gotPaths := pathStrings(got)
+	if len(gotPaths) != len(want) {
+		t.Fatalf("wrong number of entries: got %d (%v), want %d (%v)", len(gotPaths), gotPaths, len(want), want)
+	}
+	for i := range want {
+		if gotPaths[i] != want[i] {
+			t.Errorf("wrong path at %d: got %s, want %s", i, gotPaths[i], want[i])
+		}
+	}
+	for _, e := range got {
+		if e.Reason != ReasonSchema {
+			t.Errorf("wrong reason for %s: got %s, want %s", e.Path, e.Reason, ReasonSchema)
+		}
+		if e.Address != "test_resource.foo" {
+			t.Errorf("wrong address: got %s", e.Address)
+		}
+	}
+}
+
+// TestForResourceInstance_stateMarksDeduped confirms that an
+// AttrSensitivePaths entry for a path the schema already reports isn't
+// duplicated, and that one the schema doesn't know about (for example, a
+// value the provider marked sensitive dynamically) still comes through,
+// attributed to ReasonStateMarks.
+func TestForResourceInstance_stateMarksDeduped(t *testing.T) {
+	schema := testSchema()
+	value := testValue()
+
+	attrSensitivePaths := []cty.PathValueMarks{
+		// Duplicates a schema-derived path; must not produce a second entry.
+		{Path: cty.GetAttrPath("value"), Marks: cty.NewValueMarks("sensitive")},
+		// Not present in the schema at all.
+		{Path: cty.GetAttrPath("id"), Marks: cty.NewValueMarks("sensitive")},
+	}
+
+	got := ForResourceInstance("test_resource.foo", schema, value, attrSensitivePaths, nil)
+
+	var sawID, valueTwice bool
+	var valueCount int
+	for _, e := range got {
+		switch e.Path.String() {
+		case cty.GetAttrPath("id").String():
+			sawID = true
+			if e.Reason != ReasonStateMarks {
+				t.Errorf("wrong reason for id: got %s", e.Reason)
+			}
+		case cty.GetAttrPath("value").String():
+			valueCount++
+		}
+	}
+	valueTwice = valueCount > 1
+	if !sawID {
+		t.Error("expected an entry for \"id\", sourced from AttrSensitivePaths")
+	}
+	if valueTwice {
+		t.Errorf("expected \"value\" to be reported once, got %d entries", valueCount)
+	}
+}
+
+// TestForResourceInstance_planAfterMarks models
+// TestEvaluatorGetResource_changes: when a resource instance has a pending
+// change, the marks that matter are the ones recorded against the After
+// value, not whatever was true of the prior state.
+func TestForResourceInstance_planAfterMarks(t *testing.T) {
+	afterValMarks := []cty.PathValueMarks{
+		{Path: cty.GetAttrPath("to_mark_val"), Marks: cty.NewValueMarks("sensitive")},
+		{Path: cty.GetAttrPath("sensitive_value"), Marks: cty.NewValueMarks("sensitive")},
+		{Path: cty.GetAttrPath("sensitive_collection"), Marks: cty.NewValueMarks("sensitive")},
+	}
+
+	after := cty.ObjectVal(map[string]cty.Value{
+		"id":              cty.StringVal("foo"),
+		"to_mark_val":     cty.StringVal("pizza"),
+		"sensitive_value": cty.StringVal("abc"),
+		"sensitive_collection": cty.MapVal(map[string]cty.Value{
+			"boop": cty.StringVal("beep"),
+		}),
+	})
+
+	schema := &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"id":              {Type: cty.String, Computed: true},
+			"to_mark_val":     {Type: cty.String, Computed: true},
+			"sensitive_value": {Type: cty.String, Computed: true, Sensitive: true},
+		},
+	}
+
+	got := ForResourceInstance("test_resource.foo", schema, after, nil, afterValMarks)
+
+	byReason := map[Reason]int{}
+	for _, e := range got {
+		byReason[e.Reason]++
+	}
+	if byReason[ReasonSchema] != 1 {
+		t.Errorf("wrong number of schema-derived entries: got %d, want 1", byReason[ReasonSchema])
+	}
+	if byReason[ReasonPlanAfterMarks] != 2 {
+		t.Errorf("wrong number of plan_after_marks entries: got %d, want 2", byReason[ReasonPlanAfterMarks])
+	}
+}
+
+// TestMap_jsonRoundTrip confirms a Map survives marshaling to JSON and back
+// into the shape external tools (this package's whole reason for existing)
+// would actually consume: an array of {address, path, reason} objects with
+// the path lowered to plain JSON values.
+func TestMap_jsonRoundTrip(t *testing.T) {
+	m := ForResourceInstance("test_resource.foo", testSchema(), testValue(), nil, nil)
+
+	raw, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("unexpected error marshaling: %s", err)
+	}
+
+	var decoded []struct {
+		Address string        `json:"address"`
+		Path    []interface{} `json:"path"`
+		Reason  string        `json:"reason"`
+	}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("unexpected error unmarshaling: %s", err)
+	}
+	if len(decoded) != len(m) {
+		t.Fatalf("wrong number of decoded entries: got %d, want %d", len(decoded), len(m))
+	}
+
+	for _, e := range decoded {
+		if e.Address != "test_resource.foo" {
+			t.Errorf("wrong address: got %s", e.Address)
+		}
+		if e.Reason != string(ReasonSchema) {
+			t.Errorf("wrong reason: got %s", e.Reason)
+		}
+		if len(e.Path) == 0 {
+			t.Error("expected a non-empty path")
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package sensitivity
+
+import (
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp/terraform/internal/addrs"
+	"github.com/hashicorp/terraform/internal/states"
+	"github.com/hashicorp/terraform/internal/terraform"
+)
+
+// Walk builds a Map covering the root module's sensitive input variables
+// and output values and, for each managed and data resource declared in
+// the root module, its no-key instance as recorded in ev's state and plan.
+// Resource instances with a count or for_each key, and anything in a child
+// module, are not reported.
+//
+// Resource instances are reported exactly as ForResourceInstance would
+// report them individually; Walk's only job beyond that is to discover the
+// addresses and schemas to call it with, the same way the Evaluator itself
+// discovers them while servicing GetResource, GetOutput, and
+// GetInputVariable.
+func Walk(ev *terraform.Evaluator) (Map, error) {
+	var m Map
+
+	if ev.Config != nil && ev.Config.Module != nil {
+		for _, v := range ev.Config.Module.Variables {
+			if v.Sensitive {
+				addr := addrs.RootModuleInstance.InputVariable(v.Name).String()
+				m = m.add(addr, ReasonConfigVariable, []cty.Path{nil})
+			}
+		}
+		for _, o := range ev.Config.Module.Outputs {
+			if o.Sensitive {
+				addr := addrs.RootModuleInstance.OutputValue(o.Name).String()
+				m = m.add(addr, ReasonConfigOutput, []cty.Path{nil})
+			}
+		}
+
+		for key, rc := range ev.Config.Module.ManagedResources {
+			entries, err := resourceInstanceEntries(ev, rc.Mode, rc.Type, rc.Name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			m = append(m, entries...)
+		}
+		for key, rc := range ev.Config.Module.DataResources {
+			entries, err := resourceInstanceEntries(ev, rc.Mode, rc.Type, rc.Name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			m = append(m, entries...)
+		}
+	}
+
+	return m, nil
+}
+
+// resourceInstanceEntries resolves the current schema, state, and pending
+// change (if any) for the no-key instance of the given resource and
+// delegates to ForResourceInstance for the actual merge.
+func resourceInstanceEntries(ev *terraform.Evaluator, mode addrs.ResourceMode, typeName, name string) (Map, error) {
+	addr := addrs.Resource{Mode: mode, Type: typeName, Name: name}.Instance(addrs.NoKey).Absolute(addrs.RootModuleInstance)
+
+	rs := ev.State.Resource(addr.ContainingResource())
+	if rs == nil {
+		return nil, nil
+	}
+	is, ok := rs.Instances[addr.Resource.Key]
+	if !ok || is.Current == nil {
+		return nil, nil
+	}
+
+	providerAddr := rs.ProviderConfig
+	schema, err := ev.Plugins.ResourceTypeSchema(providerAddr.Provider, mode, typeName)
+	if err != nil {
+		return nil, fmt.Errorf("no schema available for %s: %w", addr, err)
+	}
+	impliedType := schema.Block.ImpliedType()
+
+	var afterValMarks []cty.PathValueMarks
+	var value cty.Value
+
+	if changeSrc := ev.Changes.GetResourceInstanceChange(addr, states.CurrentGen); changeSrc != nil {
+		change, err := changeSrc.Decode(impliedType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode planned change for %s: %w", addr, err)
+		}
+		value = change.After
+		afterValMarks = change.AfterValMarks
+	} else {
+		obj, err := is.Current.Decode(impliedType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode state for %s: %w", addr, err)
+		}
+		value = obj.Value
+	}
+
+	return ForResourceInstance(addr.String(), schema.Block, value, is.Current.AttrSensitivePaths, afterValMarks), nil
+}
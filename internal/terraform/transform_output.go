@@ -1,6 +1,7 @@
 package terraform
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/hashicorp/terraform/internal/addrs"
@@ -26,6 +27,14 @@ type OutputTransformer struct {
 	// Refresh-only mode means that any failing output preconditions are
 	// reported as warnings rather than errors
 	RefreshOnly bool
+
+	// DeferredResources is the set of resources which have one or more
+	// deferred instances from an earlier planning step. Outputs that
+	// refer to one of these resources must themselves be evaluated as
+	// deferred, rather than as if the resource had no instances at all,
+	// so that they produce the same unknown/refined placeholder value
+	// during apply that the plan phase already committed to.
+	DeferredResources addrs.Set[addrs.ConfigResource]
 }
 
 func (t *OutputTransformer) Transform(g *Graph) error {
@@ -82,17 +91,52 @@ func (t *OutputTransformer) transform(g *Graph, c *configs.Config) error {
 				Config: o,
 			}
 
+		default:
+			node = &nodeExpandOutput{
+				Addr:              addr,
+				Module:            c.Path,
+				Config:            o,
+				Destroy:           t.removeRootOutputs,
+				RefreshOnly:       t.RefreshOnly,
+				DeferredResources: t.DeferredResources,
+			}
+		}
+
+		log.Printf("[TRACE] OutputTransformer: adding %s as %T", o.Name, node)
+		g.Add(node)
+	}
+
+	// An output can also be scheduled for destruction by a "removed" block
+	// that names it, even once the output block itself has been deleted
+	// from the configuration and so no longer appears in c.Module.Outputs
+	// above.
+	for name, removed := range c.Module.RemovedOutputs {
+		if _, exists := c.Module.Outputs[name]; exists {
+			return fmt.Errorf(
+				"output %q is declared in %s but is also targeted by the removed block at %s; remove either the output block or the removed block",
+				name, c.Path, removed.DeclRange,
+			)
+		}
+
+		addr := addrs.OutputValue{Name: name}
+
+		var node dag.Vertex
+		switch {
+		case c.Path.IsRoot():
+			node = &NodeDestroyableOutput{
+				Addr: addr.Absolute(addrs.RootModuleInstance),
+			}
+
 		default:
 			node = &nodeExpandOutput{
 				Addr:        addr,
 				Module:      c.Path,
-				Config:      o,
-				Destroy:     t.removeRootOutputs,
+				Destroy:     true,
 				RefreshOnly: t.RefreshOnly,
 			}
 		}
 
-		log.Printf("[TRACE] OutputTransformer: adding %s as %T", o.Name, node)
+		log.Printf("[TRACE] OutputTransformer: adding %s as %T, destroying because of a removed block", name, node)
 		g.Add(node)
 	}
 
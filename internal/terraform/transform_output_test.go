@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package terraform
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/hashicorp/terraform/internal/addrs"
+	"github.com/hashicorp/terraform/internal/configs"
+)
+
+func TestOutputTransformer_removedOutput(t *testing.T) {
+	cfg := &configs.Config{
+		Path: addrs.RootModule,
+		Module: &configs.Module{
+			Outputs: map[string]*configs.Output{},
+			RemovedOutputs: map[string]*configs.Removed{
+				"gone": {
+					DeclRange: hcl.Range{Filename: "main.tf", Start: hcl.Pos{Line: 1}},
+				},
+			},
+		},
+	}
+
+	transformer := &OutputTransformer{Config: cfg}
+
+	g := &Graph{}
+	if err := transformer.Transform(g); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var destroyNodes, expandNodes int
+	for _, v := range g.Vertices() {
+		switch v.(type) {
+		case *NodeDestroyableOutput:
+			destroyNodes++
+		case *nodeExpandOutput:
+			expandNodes++
+		}
+	}
+
+	if destroyNodes != 1 {
+		t.Errorf("wrong number of destroy nodes: got %d, want 1", destroyNodes)
+	}
+	if expandNodes != 0 {
+		t.Errorf("wrong number of expand nodes: got %d, want 0", expandNodes)
+	}
+}
+
+func TestOutputTransformer_removedOutputStillDeclared(t *testing.T) {
+	cfg := &configs.Config{
+		Path: addrs.RootModule,
+		Module: &configs.Module{
+			Outputs: map[string]*configs.Output{
+				"both": {Name: "both"},
+			},
+			RemovedOutputs: map[string]*configs.Removed{
+				"both": {
+					DeclRange: hcl.Range{Filename: "main.tf", Start: hcl.Pos{Line: 1}},
+				},
+			},
+		},
+	}
+
+	transformer := &OutputTransformer{Config: cfg}
+
+	g := &Graph{}
+	if err := transformer.Transform(g); err == nil {
+		t.Fatal("expected an error for an output that is both declared and removed")
+	}
+}